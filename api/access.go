@@ -0,0 +1,86 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/repository"
+)
+
+// authorizeProject reports whether the current user, directly or through a
+// team, may perform action in projectId and writes the error response when
+// it may not. Users without any role in the project, and projects of other
+// organizations, get a 404 so that their existence is not revealed.
+func authorizeProject(w http.ResponseWriter, r *http.Request, projectId uint, action models.ProjectAction) bool {
+	exists, err := projectsFor(r).HasProject(projectId)
+	if err != nil && !errors.Is(err, repository.ErrNoOrganization) {
+		http.Error(w, "Failed to check project access", http.StatusInternalServerError)
+		return false
+	}
+	if !exists {
+		http.Error(w, "Project not found for this user", http.StatusNotFound)
+		return false
+	}
+
+	role, err := projectMemberRepository.GetEffectiveRole(projectId, CurrentUser(r).ID)
+	if err != nil {
+		http.Error(w, "Failed to check project access", http.StatusInternalServerError)
+		return false
+	}
+	if role == "" || !tokenAllowsProject(r, projectId) {
+		http.Error(w, "Project not found for this user", http.StatusNotFound)
+		return false
+	}
+	if !models.CanPerformProjectAction(role, action) {
+		http.Error(w, "Your project role does not allow this action", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// tokenAllowsProject reports whether a project scoped API token, if the
+// request uses one, covers projectId.
+func tokenAllowsProject(r *http.Request, projectId uint) bool {
+	token := CurrentAPIToken(r)
+	return token == nil || token.ProjectId == nil || *token.ProjectId == projectId
+}
+
+// projectsFor returns the project repository scoped to the organization the
+// request works in.
+func projectsFor(r *http.Request) *repository.ProjectRepository {
+	return projectRepository.ForOrganization(CurrentOrganizationId(r))
+}
+
+// tasksFor returns the task repository scoped to the organization the
+// request works in.
+func tasksFor(r *http.Request) *repository.TaskRepository {
+	return taskRepository.ForOrganization(CurrentOrganizationId(r))
+}
+
+// authorizeOrganization reports whether the current user has at least
+// minRole in organizationId and returns their role. Like authorizeProject
+// it answers 404 to non-members.
+func authorizeOrganization(w http.ResponseWriter, r *http.Request, organizationId uint, minRole string) (string, bool) {
+	member, err := organizationRepository.GetMembership(organizationId, CurrentUser(r).ID)
+	if err != nil {
+		http.Error(w, "Failed to check organization access", http.StatusInternalServerError)
+		return "", false
+	}
+	if member == nil || !tokenAllowsOrganization(r, organizationId) {
+		http.Error(w, "Organization not found for this user", http.StatusNotFound)
+		return "", false
+	}
+	if models.OrgRoleRank(member.Role) < models.OrgRoleRank(minRole) {
+		http.Error(w, "Your organization role does not allow this action", http.StatusForbidden)
+		return "", false
+	}
+	return member.Role, true
+}
+
+// tokenAllowsOrganization reports whether the API token of the request, if
+// it uses one, was issued for organizationId.
+func tokenAllowsOrganization(r *http.Request, organizationId uint) bool {
+	token := CurrentAPIToken(r)
+	return token == nil || token.OrganizationId == organizationId
+}
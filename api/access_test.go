@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/aminasadiam/DevTasks/internal/database/dbtest"
+	"github.com/aminasadiam/DevTasks/internal/models"
+)
+
+func TestProjectRolesOnTasks(t *testing.T) {
+	tests := []struct {
+		role                 string
+		get, update, destroy int
+	}{
+		{"", http.StatusNotFound, http.StatusNotFound, http.StatusNotFound},
+		{models.ProjectRoleViewer, http.StatusOK, http.StatusForbidden, http.StatusForbidden},
+		{models.ProjectRoleContributor, http.StatusOK, http.StatusOK, http.StatusForbidden},
+		{models.ProjectRoleMaintainer, http.StatusOK, http.StatusOK, http.StatusNoContent},
+		{models.ProjectRoleOwner, http.StatusOK, http.StatusOK, http.StatusNoContent},
+	}
+	for _, test := range tests {
+		t.Run("role "+test.role, func(t *testing.T) {
+			fake := testDB(t)
+			projectFixture(fake, test.role)
+			fake.Return(`FROM "tasks"`, dbtest.Records(testTask()))
+			fake.Return(`SELECT count(*) FROM "tasks"`, dbtest.Rows("count").Row(0))
+			fake.Return(`UPDATE "tasks"`, dbtest.Affected(1))
+
+			form := url.Values{"task_id": {"3"}, "title": {"Write tests"}, "description": {"For every handler"}}
+			statuses := []struct {
+				name    string
+				handler http.HandlerFunc
+				method  string
+				want    int
+			}{
+				{"GetTaskById", GetTaskById, http.MethodGet, test.get},
+				{"UpdateTask", UpdateTask, http.MethodPut, test.update},
+				{"DeleteTask", DeleteTask, http.MethodDelete, test.destroy},
+			}
+			for _, s := range statuses {
+				w := serve(s.handler, inOrganization(newRequest(s.method, "/api/task", form), models.OrgRoleMember))
+				if w.Code != s.want {
+					t.Errorf("%s: status = %d, want %d: %s", s.name, w.Code, s.want, w.Body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/utils"
+)
+
+func AdminGetUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	role := query.Get("role")
+	if role != "" && !models.IsValidRole(role) {
+		http.Error(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+
+	var active *bool
+	if status := query.Get("status"); status != "" {
+		isActive := status == "active"
+		if !isActive && status != "inactive" {
+			http.Error(w, "Invalid status", http.StatusBadRequest)
+			return
+		}
+		active = &isActive
+	}
+
+	users, err := userRepository.SearchUsers(strings.TrimSpace(query.Get("q")), role, active)
+	if err != nil {
+		http.Error(w, "Failed to get users", http.StatusInternalServerError)
+		return
+	}
+
+	adminUsers := make([]models.AdminUser, 0, len(users))
+	for _, user := range users {
+		adminUsers = append(adminUsers, user.Admin())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(adminUsers); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func AdminUpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	role := r.FormValue("role")
+	if !models.IsValidRole(role) {
+		http.Error(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+
+	if err := userRepository.SetRole(user.ID, role); err != nil {
+		http.Error(w, "Failed to update role", http.StatusInternalServerError)
+		return
+	}
+
+	user.Role = role
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user.Admin())
+}
+
+// AdminDeactivateUser blocks an account from signing in and ends all of its
+// sessions. API tokens stop working while the account is deactivated.
+func AdminDeactivateUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	if err := userRepository.SetDeactivatedAt(user.ID, &now); err != nil {
+		http.Error(w, "Failed to deactivate user", http.StatusInternalServerError)
+		return
+	}
+
+	if err := sessionRepository.DeleteUserSessions(user.ID, 0); err != nil {
+		log.Printf("failed to revoke sessions of user %d: %v\n", user.ID, err)
+	}
+
+	user.DeactivatedAt = &now
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user.Admin())
+}
+
+func AdminReactivateUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := userRepository.SetDeactivatedAt(user.ID, nil); err != nil {
+		http.Error(w, "Failed to reactivate user", http.StatusInternalServerError)
+		return
+	}
+
+	user.DeactivatedAt = nil
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user.Admin())
+}
+
+// AdminLogoutUser ends all sessions of the user and revokes its API tokens.
+func AdminLogoutUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := sessionRepository.DeleteUserSessions(user.ID, 0); err != nil {
+		http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+
+	if err := apiTokenRepository.RevokeUserTokens(user.ID); err != nil {
+		http.Error(w, "Failed to revoke API tokens", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "User logged out successfully"})
+}
+
+// AdminResetPassword locks the current password, signs the user out
+// everywhere, revokes its API tokens and mails a password reset link. The
+// admin never learns the new password.
+func AdminResetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := userRepository.UpdatePassword(user.ID, utils.UnusablePassword); err != nil {
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+
+	if err := sessionRepository.DeleteUserSessions(user.ID, 0); err != nil {
+		http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+
+	if err := apiTokenRepository.RevokeUserTokens(user.ID); err != nil {
+		http.Error(w, "Failed to revoke API tokens", http.StatusInternalServerError)
+		return
+	}
+
+	if err := sendPasswordReset(user); err != nil {
+		log.Printf("failed to send password reset to user %d: %v\n", user.ID, err)
+		http.Error(w, "Failed to send password reset", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password reset email sent"})
+}
+
+// adminTargetUser loads the user named by user_id. Admins cannot target
+// themselves so that they cannot lock themselves out.
+func adminTargetUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	userId, err := strconv.Atoi(r.FormValue("user_id"))
+	if err != nil || userId <= 0 {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	if uint(userId) == CurrentUser(r).ID {
+		http.Error(w, "Admins cannot change their own account here", http.StatusBadRequest)
+		return nil, false
+	}
+
+	user, err := userRepository.GetUserById(uint(userId))
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return nil, false
+	}
+	return user, true
+}
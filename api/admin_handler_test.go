@@ -0,0 +1,102 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aminasadiam/DevTasks/internal/database/dbtest"
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/utils"
+)
+
+// adminRequest is a request of the admin with id 9 about user_id.
+func adminRequest(target, userId string) *http.Request {
+	admin := testSession(5, 9)
+	admin.User.Role = models.RoleAdmin
+	return signedIn(newRequest(http.MethodPost, target, url.Values{"user_id": {userId}}), &admin)
+}
+
+func TestAdminTargetUser(t *testing.T) {
+	tests := []struct {
+		name   string
+		userId string
+		status int
+	}{
+		{"own account", "9", http.StatusBadRequest},
+		{"invalid id", "x", http.StatusBadRequest},
+		{"unknown user", "2", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := testDB(t)
+			for _, h := range []http.HandlerFunc{AdminLogoutUser, AdminResetPassword} {
+				if w := serve(h, adminRequest("/api/admin/users", test.userId)); w.Code != test.status {
+					t.Errorf("status = %d, want %d", w.Code, test.status)
+				}
+			}
+			for _, statement := range fake.Statements() {
+				if !strings.HasPrefix(statement.Query, "SELECT") {
+					t.Errorf("unexpected statement %s", statement.Query)
+				}
+			}
+		})
+	}
+}
+
+func TestAdminLogoutUser(t *testing.T) {
+	fake := testDB(t)
+	bob := models.User{Username: "bob", Email: "bob@example.org"}
+	bob.ID = 2
+	fake.Return(`FROM "users"`, dbtest.Records(bob))
+
+	if w := serve(http.HandlerFunc(AdminLogoutUser), adminRequest("/api/admin/users/logout", "2")); w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	for _, revoke := range []string{`DELETE FROM "sessions" WHERE user_id = $1`, `UPDATE "api_tokens" SET "revoked_at"`} {
+		statements := fake.Find(revoke)
+		if len(statements) != 1 || !hasArg(statements[0], int64(2)) {
+			t.Errorf("%s: %v, want one statement for user 2", revoke, statements)
+		}
+	}
+	if len(fake.Find(`UPDATE "users"`)) != 0 {
+		t.Error("logging a user out changed the account")
+	}
+}
+
+func TestAdminResetPassword(t *testing.T) {
+	fake := testDB(t)
+	sent := useTestMailer()
+	hash, err := utils.HashPass("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob := models.User{Username: "bob", Email: "bob@example.org", Password: hash}
+	bob.ID = 2
+	fake.Return(`FROM "users"`, dbtest.Records(bob))
+
+	if w := serve(http.HandlerFunc(AdminResetPassword), adminRequest("/api/admin/users/reset-password", "2")); w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	updates := fake.Find(`UPDATE "users" SET "password"`)
+	if len(updates) != 1 || !hasArg(updates[0], int64(2)) {
+		t.Fatalf("password updates = %v, want one for user 2", updates)
+	}
+	locked, _ := updates[0].Args[0].(string)
+	for _, password := range []string{"correct horse", "", locked} {
+		if utils.VerifyPassword(password, locked) {
+			t.Errorf("password %q still signs in", password)
+		}
+	}
+	for _, revoke := range []string{`DELETE FROM "sessions" WHERE user_id = $1`, `UPDATE "api_tokens" SET "revoked_at"`} {
+		statements := fake.Find(revoke)
+		if len(statements) != 1 || !hasArg(statements[0], int64(2)) {
+			t.Errorf("%s: %v, want one statement for user 2", revoke, statements)
+		}
+	}
+	if len(sent.sent) != 1 || sent.sent[0].to != bob.Email || !strings.Contains(sent.sent[0].body, "/reset-password?token=") {
+		t.Errorf("mails = %+v, want a reset link to %s", sent.sent, bob.Email)
+	}
+}
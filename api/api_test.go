@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aminasadiam/DevTasks/config"
+	"github.com/aminasadiam/DevTasks/internal/database/dbtest"
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMain(m *testing.M) {
+	// Hashing at the production cost would make every test that signs in
+	// take seconds.
+	utils.SetPasswordHasher(utils.BcryptHasher{Cost: bcrypt.MinCost})
+	os.Exit(m.Run())
+}
+
+// testDB points the repositories at a scripted database.
+func testDB(t *testing.T) *dbtest.DB {
+	t.Helper()
+	db, fake := dbtest.Open(t)
+	useDB(db)
+	return fake
+}
+
+// newRequest builds a request carrying form where r.FormValue reads it: in
+// the body of POST, PUT and PATCH requests and in the URL otherwise.
+func newRequest(method, target string, form url.Values) *http.Request {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		r := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return r
+	}
+	if len(form) > 0 {
+		target += "?" + form.Encode()
+	}
+	return httptest.NewRequest(method, target, nil)
+}
+
+// testMailer records the mails it is asked to send.
+type testMailer struct {
+	sent []testMail
+}
+
+type testMail struct {
+	to, subject, body string
+}
+
+func (m *testMailer) Send(to, subject, body string) error {
+	m.sent = append(m.sent, testMail{to, subject, body})
+	return nil
+}
+
+// useTestMailer replaces the mailer with one that records the mails.
+func useTestMailer() *testMailer {
+	m := &testMailer{}
+	mailer = m
+	mailConfig = &config.MailConfig{AppURL: "http://devtasks.test"}
+	return m
+}
+
+// signedIn returns r as sent from session by its user.
+func signedIn(r *http.Request, session *models.Session) *http.Request {
+	ctx := context.WithValue(r.Context(), currentSessionKey, session)
+	ctx = context.WithValue(ctx, currentUserKey, &session.User)
+	return r.WithContext(ctx)
+}
+
+// withToken returns r as sent with token by its user.
+func withToken(r *http.Request, token *models.APIToken) *http.Request {
+	ctx := context.WithValue(r.Context(), currentAPITokenKey, token)
+	ctx = context.WithValue(ctx, currentUserKey, &token.User)
+	return r.WithContext(ctx)
+}
+
+// serve runs h on r and returns the recorded response.
+func serve(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// cookie returns the cookie name set by w, or nil.
+func cookie(w *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// inOrganization returns r as made by user 1 within organization 1, where
+// the user is a member with orgRole.
+func inOrganization(r *http.Request, orgRole string) *http.Request {
+	session := testSession(5, 1)
+	member := &models.OrganizationMember{OrganizationId: 1, UserId: 1, Role: orgRole}
+	r = signedIn(r, &session)
+	return r.WithContext(context.WithValue(r.Context(), currentOrganizationKey, member))
+}
+
+// projectFixture makes project 7 part of the organization and grants user 1
+// role in it directly. An empty role grants nothing.
+func projectFixture(fake *dbtest.DB, role string) {
+	fake.Return(`SELECT count(*) FROM "projects"`, dbtest.Rows("count").Row(1))
+	fake.Return(`SELECT COUNT("id") FROM "projects"`, dbtest.Rows("count").Row(1))
+	if role != "" {
+		fake.Return(`FROM "project_members" JOIN projects`, dbtest.Records(models.ProjectMember{ProjectId: 7, UserId: 1, Role: role}))
+	}
+}
+
+// testTask is task 3 of project 7.
+func testTask() models.Task {
+	task := models.Task{ProjectId: 7, Title: "Write tests", Description: "For every handler"}
+	task.ID = 3
+	return task
+}
@@ -0,0 +1,320 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aminasadiam/DevTasks/config"
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/repository"
+	"github.com/aminasadiam/DevTasks/internal/storage"
+	"github.com/aminasadiam/DevTasks/internal/utils"
+)
+
+var attachmentRepository repository.AttachmentRepository
+var storageConfig *config.StorageConfig
+var blobStore storage.BlobStore
+
+// inlineContentTypes can be shown in the browser; everything else is
+// downloaded.
+var inlineContentTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"text/plain":      true,
+	"application/pdf": true,
+}
+
+// GetAttachments lists the attachments of a task.
+func GetAttachments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskId, err := strconv.Atoi(r.FormValue("task_id"))
+	if err != nil || taskId <= 0 {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	task, err := tasksFor(r).GetTaskById(uint(taskId))
+	if err != nil {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	if !authorizeProject(w, r, task.ProjectId, models.ActionViewTasks) {
+		return
+	}
+
+	attachments, err := attachmentRepository.GetTaskAttachments(task.ID)
+	if err != nil {
+		http.Error(w, "Failed to get attachments", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(attachments); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// AddAttachment uploads the multipart field file to a task. The content
+// type is sniffed from the content rather than taken from the client.
+func AddAttachment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Leave room for the other form fields and the multipart framing.
+	r.Body = http.MaxBytesReader(w, r.Body, storageConfig.MaxUploadSize+1<<20)
+	if err := r.ParseMultipartForm(8 << 20); err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			http.Error(w, fmt.Sprintf("Attachments can be at most %d MB", storageConfig.MaxUploadSize>>20), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	taskId, err := strconv.Atoi(r.FormValue("task_id"))
+	if err != nil || taskId <= 0 {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "File is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	if header.Size > storageConfig.MaxUploadSize {
+		http.Error(w, fmt.Sprintf("Attachments can be at most %d MB", storageConfig.MaxUploadSize>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if header.Size == 0 {
+		http.Error(w, "File is empty", http.StatusBadRequest)
+		return
+	}
+
+	task, err := tasksFor(r).GetTaskById(uint(taskId))
+	if err != nil {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	if !authorizeProject(w, r, task.ProjectId, models.ActionEditTask) {
+		return
+	}
+
+	contentType, checksum, err := inspectUpload(file)
+	if err != nil {
+		http.Error(w, "Failed to read file", http.StatusInternalServerError)
+		return
+	}
+
+	attachment := models.Attachment{
+		TaskId:      task.ID,
+		UploadedBy:  CurrentUser(r).ID,
+		FileName:    cleanFileName(header.Filename),
+		ContentType: contentType,
+		Size:        header.Size,
+		SHA256:      checksum,
+		StorageKey:  fmt.Sprintf("tasks/%d/%s", task.ID, utils.GenerateToken(18)),
+	}
+
+	info := storage.BlobInfo{Size: attachment.Size, ContentType: attachment.ContentType, SHA256: attachment.SHA256}
+	if err := blobStore.Put(r.Context(), attachment.StorageKey, file, info); err != nil {
+		log.Printf("failed to store attachment: %v\n", err)
+		http.Error(w, "Failed to store file", http.StatusInternalServerError)
+		return
+	}
+
+	if err := attachmentRepository.CreateAttachment(&attachment); err != nil {
+		if err := blobStore.Delete(r.Context(), attachment.StorageKey); err != nil {
+			log.Printf("failed to delete attachment blob %s: %v\n", attachment.StorageKey, err)
+		}
+		http.Error(w, "Failed to add attachment", http.StatusInternalServerError)
+		return
+	}
+	attachment.Uploader = *CurrentUser(r)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(attachment); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// DownloadAttachment streams the content of an attachment. Only a few safe
+// types are shown inline; the rest is served as a download.
+func DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	attachment, _, ok := getTaskAttachment(w, r, models.ActionViewTasks)
+	if !ok {
+		return
+	}
+
+	etag := `"` + attachment.SHA256 + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	body, err := blobStore.Get(r.Context(), attachment.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("failed to read attachment %d: %v\n", attachment.ID, err)
+		http.Error(w, "Failed to read file", http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	disposition := "attachment"
+	if mediaType, _, err := mime.ParseMediaType(attachment.ContentType); err == nil && inlineContentTypes[mediaType] {
+		disposition = "inline"
+	}
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("Cache-Control", "private, max-age=0, must-revalidate")
+	w.Header().Set("ETag", etag)
+	if _, err := io.Copy(w, body); err != nil {
+		log.Printf("failed to send attachment %d: %v\n", attachment.ID, err)
+	}
+}
+
+// DeleteAttachment lets the uploader or a project maintainer delete an
+// attachment.
+func DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	attachment, task, ok := getTaskAttachment(w, r, models.ActionViewTasks)
+	if !ok {
+		return
+	}
+
+	action := models.ActionEditTask
+	if attachment.UploadedBy != CurrentUser(r).ID {
+		action = models.ActionDeleteTask
+	}
+	if !authorizeProject(w, r, task.ProjectId, action) {
+		return
+	}
+
+	if err := attachmentRepository.DeleteAttachment(attachment.ID); err != nil {
+		http.Error(w, "Failed to delete attachment", http.StatusInternalServerError)
+		return
+	}
+	// The row is gone, so a failure here only leaves an unreferenced blob.
+	if err := blobStore.Delete(r.Context(), attachment.StorageKey); err != nil {
+		log.Printf("failed to delete attachment blob %s: %v\n", attachment.StorageKey, err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getTaskAttachment loads attachment_id and its task, checking that the
+// task belongs to the current organization and the caller may perform
+// action.
+func getTaskAttachment(w http.ResponseWriter, r *http.Request, action models.ProjectAction) (*models.Attachment, *models.Task, bool) {
+	attachmentId, err := strconv.Atoi(r.FormValue("attachment_id"))
+	if err != nil || attachmentId <= 0 {
+		http.Error(w, "Invalid attachment ID", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	attachment, err := attachmentRepository.GetAttachment(uint(attachmentId))
+	if err != nil {
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	task, err := tasksFor(r).GetTaskById(attachment.TaskId)
+	if err != nil {
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	if !authorizeProject(w, r, task.ProjectId, action) {
+		return nil, nil, false
+	}
+	return attachment, task, true
+}
+
+// inspectUpload sniffs the content type of file and computes its SHA-256
+// digest, leaving file rewound.
+func inspectUpload(file multipart.File) (string, string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", "", err
+	}
+	contentType := http.DetectContentType(head[:n])
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", "", err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", "", err
+	}
+	return contentType, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// cleanFileName drops any directories and control characters from the
+// name the client sent.
+func cleanFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	if len(name) > 255 {
+		name = strings.ToValidUTF8(name[:255], "")
+	}
+	return name
+}
@@ -0,0 +1,219 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/aminasadiam/DevTasks/internal/avatar"
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/storage"
+)
+
+const (
+	maxAvatarSize     = 5 << 20
+	defaultAvatarSize = 128
+)
+
+// UploadAvatar replaces the profile picture of the current user with the
+// multipart field avatar.
+func UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAvatarSize+64<<10)
+	if err := r.ParseMultipartForm(maxAvatarSize); err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			http.Error(w, fmt.Sprintf("Avatars can be at most %d MB", maxAvatarSize>>20), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, _, err := r.FormFile("avatar")
+	if err != nil {
+		http.Error(w, "Avatar is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxAvatarSize+1))
+	if err != nil {
+		http.Error(w, "Failed to read avatar", http.StatusInternalServerError)
+		return
+	}
+	if len(data) > maxAvatarSize {
+		http.Error(w, fmt.Sprintf("Avatars can be at most %d MB", maxAvatarSize>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	images, err := avatar.Process(data)
+	if err != nil {
+		if errors.Is(err, avatar.ErrUnsupportedImage) || errors.Is(err, avatar.ErrImageTooLarge) {
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+			return
+		}
+		http.Error(w, "Failed to process avatar", http.StatusInternalServerError)
+		return
+	}
+
+	user := CurrentUser(r)
+	sum := sha256.Sum256(data)
+	version := hex.EncodeToString(sum[:8])
+	for size, image := range images {
+		info := storage.BlobInfo{Size: int64(len(image)), ContentType: "image/png", SHA256: sha256Hex(image)}
+		if err := blobStore.Put(r.Context(), avatarKey(user.ID, version, size), bytes.NewReader(image), info); err != nil {
+			log.Printf("failed to store avatar of user %d: %v\n", user.ID, err)
+			http.Error(w, "Failed to store avatar", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	previous := user.AvatarVersion
+	if err := userRepository.SetAvatar(user.ID, version); err != nil {
+		http.Error(w, "Failed to update avatar", http.StatusInternalServerError)
+		return
+	}
+	if previous != "" && previous != version {
+		deleteAvatarBlobs(r, user.ID, previous)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"Profile": models.AvatarURL(user.ID, version)})
+}
+
+// DeleteAvatar removes the uploaded picture of the current user, who gets
+// the identicon again.
+func DeleteAvatar(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := CurrentUser(r)
+	if user.AvatarVersion == "" {
+		http.Error(w, "No avatar uploaded", http.StatusNotFound)
+		return
+	}
+
+	if err := userRepository.SetAvatar(user.ID, ""); err != nil {
+		http.Error(w, "Failed to update avatar", http.StatusInternalServerError)
+		return
+	}
+	deleteAvatarBlobs(r, user.ID, user.AvatarVersion)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAvatar serves the avatar of user_id in one of avatar.Sizes. Requests
+// for the current version, as linked from the user's Profile, may be cached
+// for good; everything else has to be revalidated.
+func GetAvatar(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userId, err := strconv.Atoi(r.FormValue("user_id"))
+	if err != nil || userId <= 0 {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	size := defaultAvatarSize
+	if sizeStr := r.FormValue("size"); sizeStr != "" {
+		size, err = strconv.Atoi(sizeStr)
+		if err != nil || !avatar.IsValidSize(size) {
+			http.Error(w, fmt.Sprintf("Size must be one of %v", avatar.Sizes), http.StatusBadRequest)
+			return
+		}
+	}
+
+	user, err := userRepository.GetUserById(uint(userId))
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if user.AvatarVersion == "" {
+		writeIdenticon(w, r, user.ID, size)
+		return
+	}
+
+	etag := fmt.Sprintf(`"%s-%d"`, user.AvatarVersion, size)
+	if r.FormValue("v") == user.AvatarVersion {
+		w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "private, no-cache")
+	}
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	body, err := blobStore.Get(r.Context(), avatarKey(user.ID, user.AvatarVersion, size))
+	if err != nil {
+		log.Printf("failed to read avatar of user %d: %v\n", user.ID, err)
+		w.Header().Del("ETag")
+		writeIdenticon(w, r, user.ID, size)
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if _, err := io.Copy(w, body); err != nil {
+		log.Printf("failed to send avatar of user %d: %v\n", user.ID, err)
+	}
+}
+
+func writeIdenticon(w http.ResponseWriter, r *http.Request, userId uint, size int) {
+	etag := fmt.Sprintf(`"identicon-%d"`, size)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	image, err := avatar.EncodePNG(avatar.Identicon(fmt.Sprintf("user:%d", userId), size))
+	if err != nil {
+		http.Error(w, "Failed to draw avatar", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Length", strconv.Itoa(len(image)))
+	w.Write(image)
+}
+
+func deleteAvatarBlobs(r *http.Request, userId uint, version string) {
+	for _, size := range avatar.Sizes {
+		if err := blobStore.Delete(r.Context(), avatarKey(userId, version, size)); err != nil {
+			log.Printf("failed to delete avatar of user %d: %v\n", userId, err)
+		}
+	}
+}
+
+func avatarKey(userId uint, version string, size int) string {
+	return fmt.Sprintf("avatars/%d/%s/%d.png", userId, version, size)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,341 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aminasadiam/DevTasks/internal/markdown"
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/repository"
+)
+
+var commentRepository repository.CommentRepository
+
+const maxCommentLength = 10000
+
+// GetComments returns the comments of a task as threads.
+func GetComments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskId, err := strconv.Atoi(r.FormValue("task_id"))
+	if err != nil || taskId <= 0 {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	task, err := tasksFor(r).GetTaskById(uint(taskId))
+	if err != nil {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	if !authorizeProject(w, r, task.ProjectId, models.ActionViewTasks) {
+		return
+	}
+
+	comments, err := commentRepository.GetTaskComments(task.ID)
+	if err != nil {
+		http.Error(w, "Failed to get comments", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(models.BuildCommentThreads(comments)); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// AddComment comments on a task, or replies to parent_id when set.
+func AddComment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskId, err := strconv.Atoi(r.FormValue("task_id"))
+	if err != nil || taskId <= 0 {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	body, ok := parseCommentBody(w, r)
+	if !ok {
+		return
+	}
+
+	task, err := tasksFor(r).GetTaskById(uint(taskId))
+	if err != nil {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	if !authorizeProject(w, r, task.ProjectId, models.ActionComment) {
+		return
+	}
+
+	comment := models.Comment{
+		TaskId: task.ID,
+		UserId: CurrentUser(r).ID,
+		Body:   body,
+	}
+
+	if parentIdStr := r.FormValue("parent_id"); parentIdStr != "" {
+		parentId, err := strconv.Atoi(parentIdStr)
+		if err != nil || parentId <= 0 {
+			http.Error(w, "Invalid parent ID", http.StatusBadRequest)
+			return
+		}
+		parent, err := commentRepository.GetComment(uint(parentId))
+		if err != nil || parent.TaskId != task.ID {
+			http.Error(w, "Parent comment not found", http.StatusNotFound)
+			return
+		}
+		comment.ParentId = &parent.ID
+	}
+
+	mentionedIds, ok := renderComment(w, r, task.ProjectId, &comment)
+	if !ok {
+		return
+	}
+
+	if err := commentRepository.CreateComment(&comment, mentionedIds); err != nil {
+		http.Error(w, "Failed to add comment", http.StatusInternalServerError)
+		return
+	}
+	comment.User = *CurrentUser(r)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(comment); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// UpdateComment lets the author edit a comment. The previous body is kept
+// as a revision.
+func UpdateComment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, ok := parseCommentBody(w, r)
+	if !ok {
+		return
+	}
+
+	comment, task, ok := getTaskComment(w, r, models.ActionComment)
+	if !ok {
+		return
+	}
+
+	if comment.UserId != CurrentUser(r).ID {
+		http.Error(w, "Only the author can edit a comment", http.StatusForbidden)
+		return
+	}
+	if comment.Removed {
+		http.Error(w, "Comment was deleted", http.StatusConflict)
+		return
+	}
+	if comment.Body == body {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(comment); err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	previousBody := comment.Body
+	comment.Body = body
+	mentionedIds, ok := renderComment(w, r, task.ProjectId, comment)
+	if !ok {
+		return
+	}
+
+	if err := commentRepository.UpdateComment(comment, previousBody, CurrentUser(r).ID, mentionedIds); err != nil {
+		http.Error(w, "Failed to update comment", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(comment); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// DeleteComment lets the author or a project maintainer delete a comment.
+func DeleteComment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	comment, task, ok := getTaskComment(w, r, models.ActionViewTasks)
+	if !ok {
+		return
+	}
+
+	action := models.ActionComment
+	if comment.UserId != CurrentUser(r).ID {
+		action = models.ActionModerateComments
+	}
+	if !authorizeProject(w, r, task.ProjectId, action) {
+		return
+	}
+
+	if err := commentRepository.DeleteComment(comment); err != nil {
+		http.Error(w, "Failed to delete comment", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetCommentRevisions returns the edit history of a comment.
+func GetCommentRevisions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	comment, _, ok := getTaskComment(w, r, models.ActionViewTasks)
+	if !ok {
+		return
+	}
+
+	revisions, err := commentRepository.GetRevisions(comment.ID)
+	if err != nil {
+		http.Error(w, "Failed to get revisions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(revisions); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetMentions lists the comments of the current organization that mention
+// the current user, only unread ones when unread is true.
+func GetMentions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	mentions, err := commentRepository.GetUserMentions(CurrentOrganizationId(r), CurrentUser(r).ID, r.FormValue("unread") == "true")
+	if err != nil {
+		http.Error(w, "Failed to get mentions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(mentions); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ReadMentions marks mention_ids, or all mentions of the current user when
+// none are given, as read.
+func ReadMentions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var ids []uint
+	for _, part := range strings.Split(r.FormValue("mention_ids"), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil || id <= 0 {
+			http.Error(w, "Invalid mention ID", http.StatusBadRequest)
+			return
+		}
+		ids = append(ids, uint(id))
+	}
+
+	if err := commentRepository.MarkMentionsRead(CurrentUser(r).ID, ids); err != nil {
+		http.Error(w, "Failed to update mentions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Mentions marked as read"})
+}
+
+func parseCommentBody(w http.ResponseWriter, r *http.Request) (string, bool) {
+	body := strings.TrimSpace(r.FormValue("body"))
+	if body == "" {
+		http.Error(w, "Body is required", http.StatusBadRequest)
+		return "", false
+	}
+	if utf8.RuneCountInString(body) > maxCommentLength {
+		http.Error(w, "Comment is too long", http.StatusBadRequest)
+		return "", false
+	}
+	return body, true
+}
+
+// getTaskComment loads comment_id and its task, checking that the task
+// belongs to the current organization and the caller may perform action.
+func getTaskComment(w http.ResponseWriter, r *http.Request, action models.ProjectAction) (*models.Comment, *models.Task, bool) {
+	commentId, err := strconv.Atoi(r.FormValue("comment_id"))
+	if err != nil || commentId <= 0 {
+		http.Error(w, "Invalid comment ID", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	comment, err := commentRepository.GetComment(uint(commentId))
+	if err != nil {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	task, err := tasksFor(r).GetTaskById(comment.TaskId)
+	if err != nil {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	if !authorizeProject(w, r, task.ProjectId, action) {
+		return nil, nil, false
+	}
+	return comment, task, true
+}
+
+// renderComment renders the body of comment and returns the project
+// members it mentions, leaving out the author. Mentions of users outside
+// the project stay plain text.
+func renderComment(w http.ResponseWriter, r *http.Request, projectId uint, comment *models.Comment) ([]uint, bool) {
+	users, err := commentRepository.ResolveMentions(projectId, markdown.Mentions(comment.Body))
+	if err != nil {
+		http.Error(w, "Failed to resolve mentions", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	mentioned := make(map[string]bool, len(users))
+	var ids []uint
+	for _, user := range users {
+		mentioned[user.Username] = true
+		if user.ID != CurrentUser(r).ID {
+			ids = append(ids, user.ID)
+		}
+	}
+	comment.BodyHTML = markdown.Render(comment.Body, mentioned)
+	return ids, true
+}
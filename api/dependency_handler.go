@@ -0,0 +1,222 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/repository"
+)
+
+var dependencyRepository repository.DependencyRepository
+
+// GetTaskBlockers lists the tasks that have to be done before a task.
+func GetTaskBlockers(w http.ResponseWriter, r *http.Request) {
+	writeTaskDependencies(w, r, dependencyRepository.GetBlockers)
+}
+
+// GetTaskDependents lists the tasks a task is blocking.
+func GetTaskDependents(w http.ResponseWriter, r *http.Request) {
+	writeTaskDependencies(w, r, dependencyRepository.GetDependents)
+}
+
+func writeTaskDependencies(w http.ResponseWriter, r *http.Request, get func(organizationId, taskId, userId uint) ([]models.Task, error)) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskId, err := strconv.Atoi(r.FormValue("task_id"))
+	if err != nil || taskId <= 0 {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	task, err := tasksFor(r).GetTaskById(uint(taskId))
+	if err != nil {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	if !authorizeProject(w, r, task.ProjectId, models.ActionViewTasks) {
+		return
+	}
+
+	tasks, err := get(CurrentOrganizationId(r), task.ID, CurrentUser(r).ID)
+	if err != nil {
+		http.Error(w, "Failed to get tasks", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tasks); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// AddTaskDependency makes blocker_id block blocked_id. The tasks can be in
+// different projects of the organization; the caller needs to be able to
+// edit the blocked task and see the blocker.
+func AddTaskDependency(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	blocker, blocked, ok := parseTaskDependency(w, r)
+	if !ok {
+		return
+	}
+
+	if !authorizeProject(w, r, blocker.ProjectId, models.ActionViewTasks) {
+		return
+	}
+
+	dependency := models.TaskDependency{
+		BlockerId: blocker.ID,
+		BlockedId: blocked.ID,
+		CreatedBy: CurrentUser(r).ID,
+	}
+	if err := dependencyRepository.AddDependency(CurrentOrganizationId(r), &dependency); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrDependencyCycle):
+			http.Error(w, "This dependency would create a cycle", http.StatusConflict)
+		case errors.Is(err, repository.ErrDependencyExists):
+			http.Error(w, "This dependency already exists", http.StatusConflict)
+		case errors.Is(err, repository.ErrDependencyTask):
+			http.Error(w, "Task not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to add dependency", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(dependency); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func RemoveTaskDependency(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	blocker, blocked, ok := parseTaskDependency(w, r)
+	if !ok {
+		return
+	}
+
+	removed, err := dependencyRepository.RemoveDependency(blocker.ID, blocked.ID)
+	if err != nil {
+		http.Error(w, "Failed to remove dependency", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "Dependency not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseTaskDependency loads the blocker and blocked task of a request and
+// checks that the caller can edit the blocked task.
+func parseTaskDependency(w http.ResponseWriter, r *http.Request) (*models.Task, *models.Task, bool) {
+	blockerId, err := strconv.Atoi(r.FormValue("blocker_id"))
+	if err != nil || blockerId <= 0 {
+		http.Error(w, "Invalid blocker ID", http.StatusBadRequest)
+		return nil, nil, false
+	}
+	blockedId, err := strconv.Atoi(r.FormValue("blocked_id"))
+	if err != nil || blockedId <= 0 {
+		http.Error(w, "Invalid blocked ID", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	blocker, err := tasksFor(r).GetTaskById(uint(blockerId))
+	if err != nil {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	blocked, err := tasksFor(r).GetTaskById(uint(blockedId))
+	if err != nil {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	if !authorizeProject(w, r, blocked.ProjectId, models.ActionEditTask) {
+		return nil, nil, false
+	}
+	return blocker, blocked, true
+}
+
+// GetCriticalPath returns the longest chain of open, dependent tasks of a
+// project, measured by their planned durations.
+func GetCriticalPath(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId, err := strconv.Atoi(r.FormValue("project_id"))
+	if err != nil || projectId <= 0 {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	if !authorizeProject(w, r, uint(projectId), models.ActionViewTasks) {
+		return
+	}
+
+	tasks, err := tasksFor(r).GetProjectTasks(uint(projectId), repository.TaskFilter{})
+	if err != nil {
+		http.Error(w, "Failed to get tasks", http.StatusInternalServerError)
+		return
+	}
+	open := tasks[:0]
+	for _, task := range tasks {
+		if task.CompletedAt == nil {
+			open = append(open, task)
+		}
+	}
+
+	dependencies, err := dependencyRepository.GetProjectDependencies(uint(projectId))
+	if err != nil {
+		http.Error(w, "Failed to get dependencies", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(models.FindCriticalPath(open, dependencies)); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// checkBlockers refuses to complete a task that still has open blockers
+// unless the request sets force, in which case the response carries a
+// warning instead.
+func checkBlockers(w http.ResponseWriter, r *http.Request, task *models.Task) bool {
+	open, err := dependencyRepository.CountOpenBlockers(task.ID)
+	if err != nil {
+		http.Error(w, "Failed to check blockers", http.StatusInternalServerError)
+		return false
+	}
+	if open == 0 {
+		return true
+	}
+	if r.FormValue("force") != "true" {
+		http.Error(w, fmt.Sprintf("Task is blocked by %d open tasks; set force to complete it anyway", open), http.StatusConflict)
+		return false
+	}
+	w.Header().Set("Warning", fmt.Sprintf(`299 - "Completed while blocked by %d open tasks"`, open))
+	return true
+}
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/repository"
+	"github.com/aminasadiam/DevTasks/internal/utils"
+)
+
+const (
+	defaultInvitationDuration = 7 * 24 * time.Hour
+	maxInvitationDuration     = 30 * 24 * time.Hour
+)
+
+var invitationRepository repository.InvitationRepository
+
+// InviteToProject creates an invitation. With an email address the link is
+// mailed to that address and can be used once; without one the link is
+// returned so that it can be shared, and max_uses decides how often.
+func InviteToProject(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId, err := strconv.Atoi(r.FormValue("project_id"))
+	if err != nil || projectId <= 0 {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	role := r.FormValue("role")
+	if !models.IsValidProjectRole(role) {
+		http.Error(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+
+	email := strings.TrimSpace(r.FormValue("email"))
+	if email != "" && !utils.IsValidEmail(email) {
+		http.Error(w, "Invalid Email", http.StatusBadRequest)
+		return
+	}
+
+	duration := defaultInvitationDuration
+	if hoursStr := r.FormValue("expires_in_hours"); hoursStr != "" {
+		hours, err := strconv.Atoi(hoursStr)
+		if err != nil || hours <= 0 || time.Duration(hours)*time.Hour > maxInvitationDuration {
+			http.Error(w, "Invalid expiry", http.StatusBadRequest)
+			return
+		}
+		duration = time.Duration(hours) * time.Hour
+	}
+
+	maxUses := 1
+	if maxUsesStr := r.FormValue("max_uses"); maxUsesStr != "" && email == "" {
+		maxUses, err = strconv.Atoi(maxUsesStr)
+		if err != nil || maxUses <= 0 {
+			http.Error(w, "Invalid max uses", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if !authorizeProject(w, r, uint(projectId), models.ActionManageMembers) {
+		return
+	}
+
+	actorRole, _ := projectMemberRepository.GetEffectiveRole(uint(projectId), CurrentUser(r).ID)
+	if !canAssignProjectRole(actorRole, "", role) {
+		http.Error(w, "Your project role does not allow granting this role", http.StatusForbidden)
+		return
+	}
+
+	project, err := projectsFor(r).GetProjectById(uint(projectId))
+	if err != nil {
+		http.Error(w, "Failed to get project", http.StatusInternalServerError)
+		return
+	}
+
+	token := utils.GenerateToken(32)
+	invitation := models.ProjectInvitation{
+		ProjectId: uint(projectId),
+		InvitedBy: CurrentUser(r).ID,
+		Email:     email,
+		Role:      role,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(duration),
+		MaxUses:   maxUses,
+	}
+	if err := invitationRepository.Create(&invitation); err != nil {
+		http.Error(w, "Failed to create invitation", http.StatusInternalServerError)
+		return
+	}
+
+	link := fmt.Sprintf("%s/invite?token=%s", mailConfig.AppURL, url.QueryEscape(token))
+	response := map[string]any{
+		"message":    "Invitation created successfully",
+		"invitation": invitation,
+	}
+
+	if email != "" {
+		body := fmt.Sprintf("Hi,\n\n%s invited you to join the project %q on DevTasks as %s. "+
+			"Sign in or create an account, then open the link below to accept:\n\n%s\n\n"+
+			"The invitation expires on %s.\n",
+			CurrentUser(r).Username, project.Name, role, link, invitation.ExpiresAt.Format(time.RFC1123))
+		if err := mailer.Send(email, "You have been invited to "+project.Name, body); err != nil {
+			log.Printf("failed to send invitation %d: %v\n", invitation.ID, err)
+			http.Error(w, "Failed to send invitation", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		response["link"] = link
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func GetInvitations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId, err := strconv.Atoi(r.FormValue("project_id"))
+	if err != nil || projectId <= 0 {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	if !authorizeProject(w, r, uint(projectId), models.ActionManageMembers) {
+		return
+	}
+
+	invitations, err := invitationRepository.GetPendingInvitations(uint(projectId))
+	if err != nil {
+		http.Error(w, "Failed to get invitations", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(invitations); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId, err := strconv.Atoi(r.FormValue("project_id"))
+	if err != nil || projectId <= 0 {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	invitationId, err := strconv.Atoi(r.FormValue("invitation_id"))
+	if err != nil || invitationId <= 0 {
+		http.Error(w, "Invalid invitation ID", http.StatusBadRequest)
+		return
+	}
+
+	if !authorizeProject(w, r, uint(projectId), models.ActionManageMembers) {
+		return
+	}
+
+	revoked, err := invitationRepository.RevokeInvitation(uint(invitationId), uint(projectId))
+	if err != nil {
+		http.Error(w, "Failed to revoke invitation", http.StatusInternalServerError)
+		return
+	}
+	if !revoked {
+		http.Error(w, "Invitation not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Invitation revoked successfully"})
+}
+
+// AcceptInvitation adds the signed in user to the invited project. Email
+// invitations can only be accepted by the account with that address.
+func AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := strings.TrimSpace(r.FormValue("token"))
+	if token == "" {
+		http.Error(w, "Token is required", http.StatusBadRequest)
+		return
+	}
+
+	invitation, err := invitationRepository.GetInvitationByToken(token)
+	if err != nil || !invitation.IsPending(time.Now()) {
+		http.Error(w, "Invitation is invalid or has expired", http.StatusNotFound)
+		return
+	}
+
+	user := CurrentUser(r)
+	if invitation.Email != "" && !strings.EqualFold(invitation.Email, user.Email) {
+		http.Error(w, "This invitation was sent to a different email address", http.StatusForbidden)
+		return
+	}
+
+	organization, err := organizationRepository.GetOrganizationById(invitation.Project.OrganizationId)
+	if err != nil {
+		http.Error(w, "Invitation is invalid or has expired", http.StatusNotFound)
+		return
+	}
+	if !organization.AllowsUser(user) {
+		http.Error(w, "Your verified email domain is not allowed in this organization", http.StatusForbidden)
+		return
+	}
+
+	existing, err := projectMemberRepository.GetMemberRole(invitation.ProjectId, user.ID)
+	if err != nil {
+		http.Error(w, "Failed to check membership", http.StatusInternalServerError)
+		return
+	}
+	if existing != "" {
+		http.Error(w, "You are already a member of this project", http.StatusConflict)
+		return
+	}
+
+	if err := invitationRepository.AcceptInvitation(invitation, user.ID); err != nil {
+		if errors.Is(err, repository.ErrInvitationUnavailable) {
+			http.Error(w, "Invitation is invalid or has expired", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to accept invitation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"message":      "Invitation accepted",
+		"organization": organization,
+		"project":      invitation.Project,
+		"role":         invitation.Role,
+	})
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/database/dbtest"
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/utils"
+)
+
+// testProject is project 7 of organization 1.
+func testProject() models.Project {
+	project := models.Project{Name: "Website", OrganizationId: 1}
+	project.ID = 7
+	return project
+}
+
+func TestInviteToProject(t *testing.T) {
+	tests := []struct {
+		name   string
+		role   string
+		form   url.Values
+		status int
+		uses   int64
+	}{
+		{"email", models.ProjectRoleOwner, url.Values{"role": {"maintainer"}, "email": {"bob@example.org"}, "max_uses": {"5"}}, http.StatusCreated, 1},
+		{"link", models.ProjectRoleOwner, url.Values{"role": {"viewer"}, "max_uses": {"5"}}, http.StatusCreated, 5},
+		{"maintainer grants a lower role", models.ProjectRoleMaintainer, url.Values{"role": {"contributor"}}, http.StatusCreated, 1},
+		{"maintainer grants an equal role", models.ProjectRoleMaintainer, url.Values{"role": {"maintainer"}}, http.StatusForbidden, 0},
+		{"contributor", models.ProjectRoleContributor, url.Values{"role": {"viewer"}}, http.StatusForbidden, 0},
+		{"unknown role", models.ProjectRoleOwner, url.Values{"role": {"admin"}}, http.StatusBadRequest, 0},
+		{"invalid email", models.ProjectRoleOwner, url.Values{"role": {"viewer"}, "email": {"bob"}}, http.StatusBadRequest, 0},
+		{"expiry too long", models.ProjectRoleOwner, url.Values{"role": {"viewer"}, "expires_in_hours": {"721"}}, http.StatusBadRequest, 0},
+		{"no uses", models.ProjectRoleOwner, url.Values{"role": {"viewer"}, "max_uses": {"0"}}, http.StatusBadRequest, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := testDB(t)
+			sent := useTestMailer()
+			projectFixture(fake, test.role)
+			fake.Return(`SELECT * FROM "projects"`, dbtest.Records(testProject()))
+			fake.Return(`INSERT INTO "project_invitations"`, dbtest.Rows("id").Row(4))
+
+			test.form.Set("project_id", "7")
+			w := serve(http.HandlerFunc(InviteToProject), inOrganization(newRequest(http.MethodPost, "/api/invite", test.form), models.OrgRoleMember))
+			if w.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+			inserts := fake.Find(`INSERT INTO "project_invitations"`)
+			if test.status != http.StatusCreated {
+				if len(inserts) != 0 || len(sent.sent) != 0 {
+					t.Error("a refused invitation was created")
+				}
+				return
+			}
+
+			var response struct{ Link string }
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			link := response.Link
+			if email := test.form.Get("email"); email != "" {
+				if len(sent.sent) != 1 || sent.sent[0].to != email || link != "" {
+					t.Fatalf("mails = %+v, link = %q, want the link mailed to %s only", sent.sent, link, email)
+				}
+				link = strings.Fields(sent.sent[0].body[strings.Index(sent.sent[0].body, "http://"):])[0]
+			} else if len(sent.sent) != 0 {
+				t.Errorf("link invitation was mailed: %+v", sent.sent)
+			}
+
+			rawToken, err := url.QueryUnescape(strings.TrimPrefix(link, "http://devtasks.test/invite?token="))
+			if err != nil || rawToken == link || rawToken == "" {
+				t.Fatalf("invalid invitation link %q", link)
+			}
+			if len(inserts) != 1 || !hasArg(inserts[0], utils.HashToken(rawToken)) || hasArg(inserts[0], rawToken) {
+				t.Fatalf("invitation is not stored by its token hash alone: %v", inserts)
+			}
+			if !hasArg(inserts[0], test.form.Get("role")) || !hasArg(inserts[0], test.uses) {
+				t.Errorf("invitation %v does not grant %s for %d uses", inserts[0].Args, test.form.Get("role"), test.uses)
+			}
+		})
+	}
+}
+
+func TestAcceptInvitation(t *testing.T) {
+	now := time.Now()
+	pending := models.ProjectInvitation{ProjectId: 7, Role: models.ProjectRoleContributor, ExpiresAt: now.Add(time.Hour), MaxUses: 2, Uses: 1}
+	pending.ID = 4
+	forAlice, forBob, expired, revoked, usedUp := pending, pending, pending, pending, pending
+	forAlice.Email = "Alice@example.org"
+	forBob.Email = "bob@example.org"
+	expired.ExpiresAt = now.Add(-time.Minute)
+	revoked.RevokedAt = &now
+	usedUp.Uses = 2
+
+	tests := []struct {
+		name       string
+		invitation models.ProjectInvitation
+		domain     string
+		unverified bool
+		member     bool
+		taken      bool
+		status     int
+	}{
+		{"link", pending, "", false, false, false, http.StatusOK},
+		{"email", forAlice, "", false, false, false, http.StatusOK},
+		{"email of someone else", forBob, "", false, false, false, http.StatusForbidden},
+		{"expired", expired, "", false, false, false, http.StatusNotFound},
+		{"revoked", revoked, "", false, false, false, http.StatusNotFound},
+		{"used up", usedUp, "", false, false, false, http.StatusNotFound},
+		{"used up meanwhile", pending, "", false, false, true, http.StatusNotFound},
+		{"allowed domain", pending, "example.org", false, false, false, http.StatusOK},
+		{"allowed domain unverified", pending, "example.org", true, false, false, http.StatusForbidden},
+		{"other domain", pending, "example.com", false, false, false, http.StatusForbidden},
+		{"member already", pending, "", false, true, false, http.StatusConflict},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := testDB(t)
+			invitation := test.invitation
+			invitation.TokenHash = utils.HashToken("invite")
+			fake.On(`FROM "project_invitations"`, func(args []any) (*dbtest.Result, error) {
+				if args[0] != invitation.TokenHash {
+					return nil, nil
+				}
+				return dbtest.Records(invitation), nil
+			})
+			fake.Return(`FROM "projects"`, dbtest.Records(testProject()))
+			organization := models.Organization{Name: "Acme", AllowedEmailDomain: test.domain}
+			organization.ID = 1
+			fake.Return(`FROM "organizations"`, dbtest.Records(organization))
+			if test.member {
+				fake.Return(`FROM "project_members" JOIN projects`, dbtest.Records(models.ProjectMember{ProjectId: 7, UserId: 1, Role: models.ProjectRoleViewer}))
+			}
+			uses := dbtest.Affected(1)
+			if test.taken {
+				uses = dbtest.Affected(0)
+			}
+			fake.Return(`UPDATE "project_invitations" SET "uses"`, uses)
+			fake.Return(`INSERT INTO "project_members"`, dbtest.Rows("id").Row(8))
+			fake.Return(`FROM "organization_members"`, dbtest.Rows("count").Row(1))
+
+			session := testSession(5, 1)
+			session.User.Email = "alice@example.org"
+			session.User.EmailVerified = !test.unverified
+			r := signedIn(newRequest(http.MethodPost, "/api/accept-invitation", url.Values{"token": {"invite"}}), &session)
+			w := serve(http.HandlerFunc(AcceptInvitation), r)
+			if w.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+
+			members := fake.Find(`INSERT INTO "project_members"`)
+			if test.status != http.StatusOK {
+				if len(members) != 0 {
+					t.Error("a refused invitation added a member")
+				}
+				return
+			}
+			if len(members) != 1 || !hasArg(members[0], models.ProjectRoleContributor) || !hasArg(members[0], int64(1)) {
+				t.Errorf("members = %v, want user 1 as contributor", members)
+			}
+		})
+	}
+}
+
+func TestRevokeInvitation(t *testing.T) {
+	tests := []struct {
+		affected int64
+		status   int
+	}{
+		{1, http.StatusOK},
+		{0, http.StatusNotFound},
+	}
+	for _, test := range tests {
+		fake := testDB(t)
+		projectFixture(fake, models.ProjectRoleOwner)
+		fake.Return(`UPDATE "project_invitations" SET "revoked_at"`, dbtest.Affected(test.affected))
+
+		r := newRequest(http.MethodDelete, "/api/revoke-invitation", url.Values{"project_id": {"7"}, "invitation_id": {"4"}})
+		w := serve(http.HandlerFunc(RevokeInvitation), inOrganization(r, models.OrgRoleMember))
+		if w.Code != test.status {
+			t.Errorf("%d rows revoked: status = %d, want %d", test.affected, w.Code, test.status)
+		}
+		revokes := fake.Find(`UPDATE "project_invitations" SET "revoked_at"`)
+		if len(revokes) != 1 || !hasArg(revokes[0], int64(4)) || !hasArg(revokes[0], int64(7)) {
+			t.Errorf("revokes = %v, want invitation 4 of project 7", revokes)
+		}
+	}
+}
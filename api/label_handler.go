@@ -0,0 +1,385 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/repository"
+)
+
+var labelRepository repository.LabelRepository
+
+const maxLabelName = 50
+
+// GetLabels lists the labels of the current organization, together with
+// those of project_id when it is given.
+func GetLabels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var projectId uint
+	if projectIdStr := r.FormValue("project_id"); projectIdStr != "" {
+		id, err := strconv.Atoi(projectIdStr)
+		if err != nil || id <= 0 {
+			http.Error(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+		if !authorizeProject(w, r, uint(id), models.ActionViewTasks) {
+			return
+		}
+		projectId = uint(id)
+	}
+
+	labels, err := labelRepository.GetLabels(CurrentOrganizationId(r), projectId)
+	if err != nil {
+		http.Error(w, "Failed to get labels", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(labels); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// AddLabel creates a label for project_id, or for the whole organization
+// when no project is given. Project labels need a maintainer, organization
+// labels an organization admin.
+func AddLabel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	label := models.Label{
+		OrganizationId: CurrentOrganizationId(r),
+		CreatedBy:      CurrentUser(r).ID,
+	}
+	if projectIdStr := r.FormValue("project_id"); projectIdStr != "" {
+		projectId, err := strconv.Atoi(projectIdStr)
+		if err != nil || projectId <= 0 {
+			http.Error(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+		id := uint(projectId)
+		label.ProjectId = &id
+	}
+
+	if !authorizeLabel(w, r, &label) {
+		return
+	}
+
+	if !parseLabel(w, r, &label) {
+		return
+	}
+
+	if err := labelRepository.CreateLabel(&label); err != nil {
+		http.Error(w, "Failed to add label", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(label); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// UpdateLabel renames or recolors a label.
+func UpdateLabel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	label, ok := currentLabel(w, r, "label_id")
+	if !ok {
+		return
+	}
+
+	if !authorizeLabel(w, r, label) {
+		return
+	}
+
+	if !parseLabel(w, r, label) {
+		return
+	}
+
+	if err := labelRepository.UpdateLabel(label); err != nil {
+		http.Error(w, "Failed to update label", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(label); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// DeleteLabel deletes a label and takes it off all tasks.
+func DeleteLabel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	label, ok := currentLabel(w, r, "label_id")
+	if !ok {
+		return
+	}
+
+	if !authorizeLabel(w, r, label) {
+		return
+	}
+
+	if err := labelRepository.DeleteLabel(label.ID); err != nil {
+		http.Error(w, "Failed to delete label", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Label deleted successfully"})
+}
+
+// MergeLabels replaces the label source_id with target_id on every task and
+// deletes source_id. A project label can only absorb labels of the same
+// project; an organization label can absorb any label.
+func MergeLabels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	source, ok := currentLabel(w, r, "source_id")
+	if !ok {
+		return
+	}
+	target, ok := currentLabel(w, r, "target_id")
+	if !ok {
+		return
+	}
+
+	if source.ID == target.ID {
+		http.Error(w, "A label cannot be merged into itself", http.StatusBadRequest)
+		return
+	}
+	if target.ProjectId != nil && (source.ProjectId == nil || *source.ProjectId != *target.ProjectId) {
+		http.Error(w, "Labels can only be merged into a label of the same project or of the organization", http.StatusBadRequest)
+		return
+	}
+
+	if !authorizeLabel(w, r, source) || !authorizeLabel(w, r, target) {
+		return
+	}
+
+	merged, err := labelRepository.MergeLabels(source, target)
+	if err != nil {
+		http.Error(w, "Failed to merge labels", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]any{
+		"Label": target,
+		"Tasks": merged,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// AddTaskLabel puts a label of the task's project or organization on a
+// task.
+func AddTaskLabel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	task, label, ok := parseTaskLabel(w, r)
+	if !ok {
+		return
+	}
+
+	added, err := labelRepository.AddTaskLabel(task.ID, label.ID, CurrentUser(r).ID)
+	if err != nil {
+		http.Error(w, "Failed to add label", http.StatusInternalServerError)
+		return
+	}
+	if !added {
+		http.Error(w, "Task already has this label", http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Label added successfully"})
+}
+
+// RemoveTaskLabel takes a label off a task.
+func RemoveTaskLabel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	task, label, ok := parseTaskLabel(w, r)
+	if !ok {
+		return
+	}
+
+	removed, err := labelRepository.RemoveTaskLabel(task.ID, label.ID)
+	if err != nil {
+		http.Error(w, "Failed to remove label", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "Task does not have this label", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Label removed successfully"})
+}
+
+// parseTaskLabel loads the task and label of a labeling request and checks
+// that the current user may edit the task and that the label applies to
+// it.
+func parseTaskLabel(w http.ResponseWriter, r *http.Request) (*models.Task, *models.Label, bool) {
+	taskId, err := strconv.Atoi(r.FormValue("task_id"))
+	if err != nil || taskId <= 0 {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	task, err := tasksFor(r).GetTaskById(uint(taskId))
+	if err != nil {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	if !authorizeProject(w, r, task.ProjectId, models.ActionEditTask) {
+		return nil, nil, false
+	}
+
+	label, ok := currentLabel(w, r, "label_id")
+	if !ok {
+		return nil, nil, false
+	}
+	if !label.AppliesTo(task.ProjectId) {
+		http.Error(w, "Label not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	return task, label, true
+}
+
+// parseLabel applies the name and color fields of the request to label.
+// A new label needs a name and gets DefaultLabelColor unless it has a
+// color.
+func parseLabel(w http.ResponseWriter, r *http.Request, label *models.Label) bool {
+	if r.Form.Has("name") || label.ID == 0 {
+		name := strings.TrimSpace(r.FormValue("name"))
+		if name == "" {
+			http.Error(w, "Name is required", http.StatusBadRequest)
+			return false
+		}
+		if len([]rune(name)) > maxLabelName {
+			http.Error(w, "Name is too long", http.StatusBadRequest)
+			return false
+		}
+		if labelRepository.ExistLabelName(label.OrganizationId, label.ProjectId, name, label.ID) {
+			http.Error(w, "A label with this name already exists", http.StatusConflict)
+			return false
+		}
+		label.Name = name
+	}
+
+	if color := r.FormValue("color"); color != "" {
+		parsed, ok := models.ParseLabelColor(color)
+		if !ok {
+			http.Error(w, "Color must be a hex color such as #1f883d", http.StatusBadRequest)
+			return false
+		}
+		label.Color = parsed
+	} else if label.Color == "" {
+		label.Color = models.DefaultLabelColor
+	}
+	return true
+}
+
+// authorizeLabel reports whether the current user may manage label: a
+// maintainer of its project, or an admin of the organization for labels of
+// the whole organization.
+func authorizeLabel(w http.ResponseWriter, r *http.Request, label *models.Label) bool {
+	if label.ProjectId != nil {
+		return authorizeProject(w, r, *label.ProjectId, models.ActionManageLabels)
+	}
+	_, ok := authorizeOrganization(w, r, label.OrganizationId, models.OrgRoleAdmin)
+	return ok
+}
+
+// currentLabel loads the label named by field from the current
+// organization. Labels of projects the user cannot see are not found.
+func currentLabel(w http.ResponseWriter, r *http.Request, field string) (*models.Label, bool) {
+	labelId, err := strconv.Atoi(r.FormValue(field))
+	if err != nil || labelId <= 0 {
+		http.Error(w, "Invalid label ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	label, err := labelRepository.GetLabel(CurrentOrganizationId(r), uint(labelId))
+	if err != nil {
+		http.Error(w, "Label not found", http.StatusNotFound)
+		return nil, false
+	}
+	if label.ProjectId != nil && !authorizeProject(w, r, *label.ProjectId, models.ActionViewTasks) {
+		return nil, false
+	}
+	return label, true
+}
+
+// parseLabelFilter reads label_ids, a comma separated list, and
+// label_match, "any" (the default) or "all", into filter.
+func parseLabelFilter(w http.ResponseWriter, r *http.Request, filter *repository.TaskFilter) bool {
+	seen := make(map[uint]bool)
+	for _, value := range r.Form["label_ids"] {
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			id, err := strconv.Atoi(part)
+			if err != nil || id <= 0 {
+				http.Error(w, "Invalid label ID", http.StatusBadRequest)
+				return false
+			}
+			if !seen[uint(id)] {
+				seen[uint(id)] = true
+				filter.LabelIds = append(filter.LabelIds, uint(id))
+			}
+		}
+	}
+
+	switch r.FormValue("label_match") {
+	case "", "any":
+		filter.AllLabels = false
+	case "all":
+		filter.AllLabels = true
+	default:
+		http.Error(w, `Label match must be "any" or "all"`, http.StatusBadRequest)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/aminasadiam/DevTasks/internal/database/dbtest"
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/repository"
+)
+
+// labelFixture scripts the labels of organization 1: labels 5 and 6 belong
+// to the whole organization, label 7 to project 7 and label 8 to project 8.
+// User 1 has orgRole in the organization and projectRole in every project.
+func labelFixture(fake *dbtest.DB, orgRole, projectRole string) {
+	projectFixture(fake, projectRole)
+	fake.Return(`FROM "organization_members" JOIN organizations`, dbtest.Records(models.OrganizationMember{OrganizationId: 1, UserId: 1, Role: orgRole}))
+	fake.On(`FROM "labels"`, func(args []any) (*dbtest.Result, error) {
+		if args[0] != int64(1) {
+			return nil, nil
+		}
+		id, ok := args[1].(int64)
+		if !ok || id < 5 || id > 8 {
+			return nil, nil
+		}
+		label := models.Label{OrganizationId: 1, Name: fmt.Sprint("label ", id), Color: models.DefaultLabelColor}
+		label.ID = uint(id)
+		if id >= 7 {
+			project := uint(id)
+			label.ProjectId = &project
+		}
+		return dbtest.Records(label), nil
+	})
+}
+
+func TestParseLabelFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		form   url.Values
+		status int
+		ids    []uint
+		all    bool
+	}{
+		{"no labels", url.Values{}, http.StatusOK, nil, false},
+		{"any", url.Values{"label_ids": {"1, 2"}, "label_match": {"any"}}, http.StatusOK, []uint{1, 2}, false},
+		{"all", url.Values{"label_ids": {"3,1"}, "label_match": {"all"}}, http.StatusOK, []uint{3, 1}, true},
+		{"duplicates", url.Values{"label_ids": {"2,2,", "1", "2"}}, http.StatusOK, []uint{2, 1}, false},
+		{"not a number", url.Values{"label_ids": {"1,bug"}}, http.StatusBadRequest, nil, false},
+		{"zero", url.Values{"label_ids": {"0"}}, http.StatusBadRequest, nil, false},
+		{"unknown match", url.Values{"label_ids": {"1"}, "label_match": {"some"}}, http.StatusBadRequest, nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newRequest(http.MethodPost, "/api/tasks", test.form)
+			r.ParseForm()
+			w := httptest.NewRecorder()
+			var filter repository.TaskFilter
+			ok := parseLabelFilter(w, r, &filter)
+			if ok != (test.status == http.StatusOK) || w.Code != test.status {
+				t.Fatalf("ok = %v, status = %d, want %d: %s", ok, w.Code, test.status, w.Body)
+			}
+			if !ok {
+				return
+			}
+			if fmt.Sprint(filter.LabelIds) != fmt.Sprint(test.ids) || filter.AllLabels != test.all {
+				t.Errorf("filter = %v all %v, want %v all %v", filter.LabelIds, filter.AllLabels, test.ids, test.all)
+			}
+		})
+	}
+}
+
+func TestMergeLabels(t *testing.T) {
+	tests := []struct {
+		name                 string
+		orgRole, projectRole string
+		source, target       uint
+		status               int
+	}{
+		{"project label into organization label", models.OrgRoleAdmin, models.ProjectRoleMaintainer, 7, 6, http.StatusOK},
+		{"organization labels", models.OrgRoleAdmin, models.ProjectRoleViewer, 5, 6, http.StatusOK},
+		{"into itself", models.OrgRoleAdmin, models.ProjectRoleMaintainer, 6, 6, http.StatusBadRequest},
+		{"organization label into project label", models.OrgRoleAdmin, models.ProjectRoleMaintainer, 5, 7, http.StatusBadRequest},
+		{"across projects", models.OrgRoleAdmin, models.ProjectRoleMaintainer, 8, 7, http.StatusBadRequest},
+		{"unknown label", models.OrgRoleAdmin, models.ProjectRoleMaintainer, 9, 6, http.StatusNotFound},
+		{"organization labels as member", models.OrgRoleMember, models.ProjectRoleMaintainer, 5, 6, http.StatusForbidden},
+		{"project label as viewer", models.OrgRoleAdmin, models.ProjectRoleViewer, 7, 6, http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := testDB(t)
+			labelFixture(fake, test.orgRole, test.projectRole)
+			fake.Return(`INSERT INTO task_labels`, dbtest.Affected(2))
+
+			form := url.Values{"source_id": {fmt.Sprint(test.source)}, "target_id": {fmt.Sprint(test.target)}}
+			w := serve(http.HandlerFunc(MergeLabels), inOrganization(newRequest(http.MethodPost, "/api/merge-labels", form), test.orgRole))
+			if w.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+
+			merges := fake.Find(`INSERT INTO task_labels`)
+			if test.status != http.StatusOK {
+				if len(merges) != 0 {
+					t.Errorf("labels merged: %v", merges)
+				}
+				return
+			}
+			if len(merges) != 1 || merges[0].Args[1] != int64(test.source) {
+				t.Errorf("merges = %v, want label %d merged", merges, test.source)
+			}
+			var response struct {
+				Label models.Label
+				Tasks int64
+			}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if response.Label.ID != test.target || response.Tasks != 2 {
+				t.Errorf("response = label %d with %d tasks, want label %d with 2", response.Label.ID, response.Tasks, test.target)
+			}
+		})
+	}
+}
+
+func TestAddTaskLabel(t *testing.T) {
+	tests := []struct {
+		name    string
+		role    string
+		label   string
+		already bool
+		status  int
+	}{
+		{"organization label", models.ProjectRoleContributor, "6", false, http.StatusOK},
+		{"label of the task's project", models.ProjectRoleContributor, "7", false, http.StatusOK},
+		{"label of another project", models.ProjectRoleContributor, "8", false, http.StatusNotFound},
+		{"already labeled", models.ProjectRoleContributor, "6", true, http.StatusConflict},
+		{"as viewer", models.ProjectRoleViewer, "6", false, http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := testDB(t)
+			labelFixture(fake, models.OrgRoleMember, test.role)
+			fake.Return(`FROM "tasks"`, dbtest.Records(testTask()))
+			if !test.already {
+				fake.Return(`INSERT INTO "task_labels"`, dbtest.Affected(1))
+			}
+
+			form := url.Values{"task_id": {"3"}, "label_id": {test.label}}
+			w := serve(http.HandlerFunc(AddTaskLabel), inOrganization(newRequest(http.MethodPost, "/api/add-task-label", form), models.OrgRoleMember))
+			if w.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+			inserts := fake.Find(`INSERT INTO "task_labels"`)
+			if tried := test.status == http.StatusOK || test.already; tried != (len(inserts) == 1) {
+				t.Errorf("inserts = %v", inserts)
+			}
+		})
+	}
+}
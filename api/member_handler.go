@@ -0,0 +1,265 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/repository"
+)
+
+var projectMemberRepository repository.ProjectMemberRepository
+
+func GetProjectMembers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId, err := strconv.Atoi(r.FormValue("project_id"))
+	if err != nil || projectId <= 0 {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	if !authorizeProject(w, r, uint(projectId), models.ActionViewProject) {
+		return
+	}
+
+	members, err := projectMemberRepository.GetProjectMembers(uint(projectId))
+	if err != nil {
+		http.Error(w, "Failed to get members", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(members); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func AddProjectMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId, err := strconv.Atoi(r.FormValue("project_id"))
+	if err != nil || projectId <= 0 {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	username := strings.TrimSpace(r.FormValue("username"))
+	role := r.FormValue("role")
+	if username == "" || !models.IsValidProjectRole(role) {
+		http.Error(w, "Username and a valid role are required", http.StatusBadRequest)
+		return
+	}
+
+	if !authorizeProject(w, r, uint(projectId), models.ActionManageMembers) {
+		return
+	}
+
+	actorRole, _ := projectMemberRepository.GetEffectiveRole(uint(projectId), CurrentUser(r).ID)
+	if !canAssignProjectRole(actorRole, "", role) {
+		http.Error(w, "Your project role does not allow granting this role", http.StatusForbidden)
+		return
+	}
+
+	user, ok := userRepository.GetUserByUsername(username)
+	if !ok || !user.IsActive() {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	orgMember, err := organizationRepository.GetMembership(CurrentOrganizationId(r), user.ID)
+	if err != nil {
+		http.Error(w, "Failed to check membership", http.StatusInternalServerError)
+		return
+	}
+	if orgMember == nil {
+		http.Error(w, "User is not a member of this organization", http.StatusBadRequest)
+		return
+	}
+
+	existing, err := projectMemberRepository.GetMemberRole(uint(projectId), user.ID)
+	if err != nil {
+		http.Error(w, "Failed to check membership", http.StatusInternalServerError)
+		return
+	}
+	if existing != "" {
+		http.Error(w, "User is already a member of this project", http.StatusConflict)
+		return
+	}
+
+	member := models.ProjectMember{
+		ProjectId: uint(projectId),
+		UserId:    user.ID,
+		Role:      role,
+		User:      *user,
+	}
+	if err := projectMemberRepository.AddMember(&member); err != nil {
+		http.Error(w, "Failed to add member", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(member); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func UpdateProjectMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId, userId, ok := parseMemberIds(w, r)
+	if !ok {
+		return
+	}
+
+	role := r.FormValue("role")
+	if !models.IsValidProjectRole(role) {
+		http.Error(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+
+	if !authorizeProject(w, r, projectId, models.ActionManageMembers) {
+		return
+	}
+
+	currentRole, ok := memberRole(w, projectId, userId)
+	if !ok {
+		return
+	}
+
+	actorRole, _ := projectMemberRepository.GetEffectiveRole(projectId, CurrentUser(r).ID)
+	if !canAssignProjectRole(actorRole, currentRole, role) {
+		http.Error(w, "Your project role does not allow this change", http.StatusForbidden)
+		return
+	}
+
+	if currentRole == models.ProjectRoleOwner && role != models.ProjectRoleOwner && !hasOtherOwner(w, projectId) {
+		return
+	}
+
+	if err := projectMemberRepository.UpdateMemberRole(projectId, userId, role); err != nil {
+		http.Error(w, "Failed to update member", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Member updated successfully"})
+}
+
+// RemoveProjectMember removes a member. Any member may remove themselves to
+// leave a project, as long as a project is not left without an owner.
+func RemoveProjectMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId, userId, ok := parseMemberIds(w, r)
+	if !ok {
+		return
+	}
+
+	leaving := userId == CurrentUser(r).ID
+	action := models.ActionManageMembers
+	if leaving {
+		action = models.ActionViewProject
+	}
+	if !authorizeProject(w, r, projectId, action) {
+		return
+	}
+
+	currentRole, ok := memberRole(w, projectId, userId)
+	if !ok {
+		return
+	}
+
+	if !leaving {
+		actorRole, _ := projectMemberRepository.GetEffectiveRole(projectId, CurrentUser(r).ID)
+		if !canAssignProjectRole(actorRole, currentRole, "") {
+			http.Error(w, "Your project role does not allow removing this member", http.StatusForbidden)
+			return
+		}
+	}
+
+	if currentRole == models.ProjectRoleOwner && !hasOtherOwner(w, projectId) {
+		return
+	}
+
+	if err := projectMemberRepository.RemoveMember(projectId, userId); err != nil {
+		http.Error(w, "Failed to remove member", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Member removed successfully"})
+}
+
+// canAssignProjectRole reports whether a member with actorRole may change a
+// member from currentRole to newRole. Owners may do anything; everyone else
+// may only manage and hand out roles below their own. Empty roles stand for
+// "not a member".
+func canAssignProjectRole(actorRole, currentRole, newRole string) bool {
+	if actorRole == models.ProjectRoleOwner {
+		return true
+	}
+	actorRank := models.ProjectRoleRank(actorRole)
+	return models.ProjectRoleRank(currentRole) < actorRank && models.ProjectRoleRank(newRole) < actorRank
+}
+
+func parseMemberIds(w http.ResponseWriter, r *http.Request) (uint, uint, bool) {
+	projectId, err := strconv.Atoi(r.FormValue("project_id"))
+	if err != nil || projectId <= 0 {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	userId, err := strconv.Atoi(r.FormValue("user_id"))
+	if err != nil || userId <= 0 {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	return uint(projectId), uint(userId), true
+}
+
+func memberRole(w http.ResponseWriter, projectId, userId uint) (string, bool) {
+	role, err := projectMemberRepository.GetMemberRole(projectId, userId)
+	if err != nil {
+		http.Error(w, "Failed to check membership", http.StatusInternalServerError)
+		return "", false
+	}
+	if role == "" {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return "", false
+	}
+	return role, true
+}
+
+func hasOtherOwner(w http.ResponseWriter, projectId uint) bool {
+	owners, err := projectMemberRepository.CountOwners(projectId)
+	if err != nil {
+		http.Error(w, "Failed to check owners", http.StatusInternalServerError)
+		return false
+	}
+	if owners <= 1 {
+		http.Error(w, "A project must keep at least one owner", http.StatusConflict)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+)
+
+type Middleware func(http.Handler) http.Handler
+
+type contextKey int
+
+const (
+	currentUserKey contextKey = iota
+	currentSessionKey
+	currentAPITokenKey
+	currentOrganizationKey
+)
+
+const apiTokenTouchInterval = time.Minute
+
+// Chain wraps h with middlewares so that the first middleware is the
+// outermost one.
+func Chain(h http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// protect builds the handler for a route that needs a signed in user.
+func protect(h http.HandlerFunc, middlewares ...Middleware) http.Handler {
+	return Chain(h, append([]Middleware{RequireAuth}, middlewares...)...)
+}
+
+// protectCookie is protect for GET routes that browsers load through
+// <img src> or plain links, which cannot send the CSRF header. The session
+// cookie alone is accepted there, so such routes must not change anything.
+func protectCookie(h http.HandlerFunc, middlewares ...Middleware) http.Handler {
+	return Chain(h, append([]Middleware{AllowCookieSession, RequireAuth}, middlewares...)...)
+}
+
+// Authenticate resolves the bearer token or, failing that, the session
+// cookie and stores the credential and its user on the request context. It
+// never rejects a request.
+func Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if value, ok := bearerToken(r); ok {
+			if token, err := apiTokenRepository.GetActiveToken(value); err == nil && token.User.IsActive() {
+				if token.LastUsedAt == nil || time.Since(*token.LastUsedAt) > apiTokenTouchInterval {
+					apiTokenRepository.TouchToken(token)
+				}
+				ctx := context.WithValue(r.Context(), currentAPITokenKey, token)
+				ctx = context.WithValue(ctx, currentUserKey, &token.User)
+				if member, err := organizationRepository.GetMembership(token.OrganizationId, token.UserId); err == nil && member != nil {
+					ctx = context.WithValue(ctx, currentOrganizationKey, member)
+				}
+				r = r.WithContext(ctx)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if session, err := authorizeSession(r, true); err == nil && session.User.IsActive() {
+			r = withSession(r, session)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AllowCookieSession authenticates GET requests that Authenticate left
+// anonymous by the session cookie alone, without the CSRF header.
+func AllowCookieSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, hasBearer := bearerToken(r)
+		safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+		if safe && !hasBearer && CurrentUser(r) == nil {
+			if session, err := authorizeSession(r, false); err == nil && session.User.IsActive() {
+				r = withSession(r, session)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func withSession(r *http.Request, session *models.Session) *http.Request {
+	ctx := context.WithValue(r.Context(), currentSessionKey, session)
+	ctx = context.WithValue(ctx, currentUserKey, &session.User)
+	if member := sessionOrganization(session); member != nil {
+		ctx = context.WithValue(ctx, currentOrganizationKey, member)
+	}
+	return r.WithContext(ctx)
+}
+
+// RequireAuth rejects requests that Authenticate could not attach a user to.
+func RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if CurrentUser(r) == nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireWrite rejects requests made with a read-only API token.
+func RequireWrite(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := CurrentAPIToken(r); token != nil && !token.CanWrite() {
+			http.Error(w, "Token does not allow write access", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireAdmin rejects users without the global admin role.
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !CurrentUser(r).IsAdmin() {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireMember rejects guests, who may only work in projects they were
+// given access to.
+func RequireMember(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if CurrentUser(r).Role == models.RoleGuest {
+			http.Error(w, "Guests cannot perform this action", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SessionOnly rejects requests made with an API token. It guards routes that
+// manage credentials so that a leaked token cannot be used to mint new ones.
+func SessionOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if CurrentSession(r) == nil {
+			http.Error(w, "This endpoint requires a browser session", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireOrganization rejects requests that are not working in an
+// organization, which every route touching projects or tasks needs.
+func RequireOrganization(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if currentMembership(r) == nil {
+			http.Error(w, "No organization selected", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sessionOrganization returns the membership of the organization session is
+// working in. Sessions that have none yet, or whose user has since left it,
+// fall back to the user's default organization.
+func sessionOrganization(session *models.Session) *models.OrganizationMember {
+	if session.OrganizationId != 0 {
+		member, err := organizationRepository.GetMembership(session.OrganizationId, session.UserId)
+		if err != nil {
+			return nil
+		}
+		if member != nil {
+			return member
+		}
+	}
+
+	member, err := organizationRepository.GetDefaultMembership(session.UserId)
+	if err != nil || member == nil {
+		return nil
+	}
+	sessionRepository.SetOrganization(session, member.OrganizationId)
+	return member
+}
+
+// CurrentUser returns the authenticated user of the request, or nil outside
+// of routes guarded by RequireAuth.
+func CurrentUser(r *http.Request) *models.User {
+	user, _ := r.Context().Value(currentUserKey).(*models.User)
+	return user
+}
+
+// CurrentSession returns the session the request was authenticated with.
+func CurrentSession(r *http.Request) *models.Session {
+	session, _ := r.Context().Value(currentSessionKey).(*models.Session)
+	return session
+}
+
+// CurrentAPIToken returns the API token the request was authenticated with,
+// or nil for session authenticated requests.
+func CurrentAPIToken(r *http.Request) *models.APIToken {
+	token, _ := r.Context().Value(currentAPITokenKey).(*models.APIToken)
+	return token
+}
+
+// CurrentOrganization returns the organization the request works in, or nil
+// outside of routes guarded by RequireOrganization.
+func CurrentOrganization(r *http.Request) *models.Organization {
+	if member := currentMembership(r); member != nil {
+		return &member.Organization
+	}
+	return nil
+}
+
+// CurrentOrganizationId returns the ID of CurrentOrganization, or zero.
+func CurrentOrganizationId(r *http.Request) uint {
+	if member := currentMembership(r); member != nil {
+		return member.OrganizationId
+	}
+	return 0
+}
+
+func currentMembership(r *http.Request) *models.OrganizationMember {
+	member, _ := r.Context().Value(currentOrganizationKey).(*models.OrganizationMember)
+	return member
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	value, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || strings.TrimSpace(value) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(value), true
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/database/dbtest"
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/utils"
+)
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	middleware := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	h := Chain(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		order = append(order, "handler")
+	}), middleware("first"), middleware("second"))
+
+	serve(h, newRequest(http.MethodGet, "/", nil))
+	if got := strings.Join(order, ","); got != "first,second,handler" {
+		t.Errorf("order = %s, want first,second,handler", got)
+	}
+}
+
+// sessionFixture answers the session lookup of Authenticate for the cookie
+// "token" with a session of user.
+func sessionFixture(fake *dbtest.DB, user models.User) {
+	session := testSession(5, user.ID)
+	session.TokenHash = utils.HashToken("token")
+	session.CSRFToken = "csrf"
+	fake.On(`FROM "sessions"`, func(args []any) (*dbtest.Result, error) {
+		if args[0] != session.TokenHash {
+			return nil, nil
+		}
+		return dbtest.Records(session), nil
+	})
+	fake.Return(`FROM "users"`, dbtest.Records(user))
+}
+
+func TestAuthenticate(t *testing.T) {
+	alice := models.User{Username: "alice"}
+	alice.ID = 1
+	deactivated := alice
+	deactivatedAt := time.Now()
+	deactivated.DeactivatedAt = &deactivatedAt
+
+	tests := []struct {
+		name   string
+		user   models.User
+		cookie bool
+		want   string
+	}{
+		{"session", alice, true, "alice"},
+		{"no session", alice, false, ""},
+		{"deactivated user", deactivated, true, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := testDB(t)
+			sessionFixture(fake, test.user)
+
+			// The username form field of the old API must not matter.
+			r := newRequest(http.MethodPost, "/api/validate", url.Values{"username": {"mallory"}})
+			if test.cookie {
+				r.AddCookie(&http.Cookie{Name: "session_token", Value: "token"})
+				r.Header.Set("X-CSRF-Token", "csrf")
+			}
+
+			var got string
+			h := Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if user := CurrentUser(r); user != nil {
+					got = user.Username
+				}
+				if (CurrentSession(r) != nil) != (got != "") {
+					t.Error("user and session are not set together")
+				}
+			}))
+			if w := serve(h, r); w.Code != http.StatusOK {
+				t.Errorf("Authenticate rejected the request with %d", w.Code)
+			}
+			if got != test.want {
+				t.Errorf("current user = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestProtect(t *testing.T) {
+	fake := testDB(t)
+	alice := models.User{Username: "alice"}
+	alice.ID = 1
+	sessionFixture(fake, alice)
+
+	h := Chain(protect(ValidateSession), Authenticate)
+
+	w := serve(h, newRequest(http.MethodPost, "/api/validate", url.Values{"username": {"alice"}}))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous request: status = %d, want 401", w.Code)
+	}
+
+	r := newRequest(http.MethodPost, "/api/validate", url.Values{"username": {"mallory"}})
+	r.AddCookie(&http.Cookie{Name: "session_token", Value: "token"})
+	r.Header.Set("X-CSRF-Token", "csrf")
+	w = serve(h, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("signed in request: status = %d: %s", w.Code, w.Body)
+	}
+	var response map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response["username"] != "alice" {
+		t.Errorf("username = %q, want the session's user alice", response["username"])
+	}
+}
+
+func TestProtectCookie(t *testing.T) {
+	alice := models.User{Username: "alice"}
+	alice.ID = 1
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(CurrentUser(r).Username))
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		csrf   bool
+		bearer bool
+		cookie bool
+		status int
+	}{
+		{"GET with the cookie alone", http.MethodGet, false, false, true, http.StatusOK},
+		{"HEAD with the cookie alone", http.MethodHead, false, false, true, http.StatusOK},
+		{"GET with cookie and CSRF header", http.MethodGet, true, false, true, http.StatusOK},
+		{"POST with the cookie alone", http.MethodPost, false, false, true, http.StatusUnauthorized},
+		{"GET with a rejected bearer token", http.MethodGet, false, true, true, http.StatusUnauthorized},
+		{"GET without credentials", http.MethodGet, false, false, false, http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := testDB(t)
+			sessionFixture(fake, alice)
+
+			r := newRequest(test.method, "/api/avatar", nil)
+			if test.cookie {
+				r.AddCookie(&http.Cookie{Name: "session_token", Value: "token"})
+			}
+			if test.csrf {
+				r.Header.Set("X-CSRF-Token", "csrf")
+			}
+			if test.bearer {
+				r.Header.Set("Authorization", "Bearer unknown")
+			}
+
+			if w := serve(Chain(protectCookie(ok), Authenticate), r); w.Code != test.status {
+				t.Errorf("protectCookie: status = %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+			// Routes behind protect keep requiring the CSRF header.
+			want := http.StatusUnauthorized
+			if test.csrf {
+				want = http.StatusOK
+			}
+			if w := serve(Chain(protect(ok), Authenticate), r); w.Code != want {
+				t.Errorf("protect: status = %d, want %d", w.Code, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/config"
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/oidc"
+)
+
+const (
+	oidcFlowCookie   = "oidc_flow"
+	oidcFlowDuration = 10 * time.Minute
+)
+
+var (
+	oidcConfig *config.OIDCConfig
+
+	oidcMu       sync.Mutex
+	oidcProvider *oidc.Provider
+
+	usernameCleaner = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+)
+
+// getOIDCProvider discovers the configured IdP on first use, so that the
+// server can start while the IdP is unreachable.
+func getOIDCProvider(ctx context.Context) (*oidc.Provider, error) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+
+	if oidcProvider != nil {
+		return oidcProvider, nil
+	}
+	if oidcConfig.Issuer == "" {
+		return nil, errors.New("single sign-on is not configured")
+	}
+
+	provider, err := oidc.NewProvider(ctx, oidc.Config{
+		Issuer:       oidcConfig.Issuer,
+		ClientID:     oidcConfig.ClientID,
+		ClientSecret: oidcConfig.ClientSecret,
+		RedirectURL:  oidcConfig.RedirectURL,
+		Scopes:       oidcConfig.Scopes,
+	}, &http.Client{Timeout: 10 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	oidcProvider = provider
+	return oidcProvider, nil
+}
+
+// OIDCLogin redirects the browser to the IdP. State, nonce and PKCE verifier
+// travel in a short-lived HttpOnly cookie until the callback.
+func OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	provider, err := getOIDCProvider(r.Context())
+	if err != nil {
+		log.Printf("oidc: %v\n", err)
+		http.Error(w, "Single sign-on is unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	state, err := oidc.RandomValue()
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+	nonce, err := oidc.RandomValue()
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+	verifier, err := oidc.GenerateVerifier()
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    strings.Join([]string{state, nonce, verifier}, "."),
+		Expires:  time.Now().Add(oidcFlowDuration),
+		HttpOnly: true,
+		Path:     "/api/oidc",
+		SameSite: http.SameSiteLaxMode,
+		Secure:   false, // Set to true in production with HTTPS
+	})
+
+	http.Redirect(w, r, provider.AuthCodeURL(state, nonce, oidc.CodeChallenge(verifier)), http.StatusFound)
+}
+
+// OIDCCallback finishes the authorization code flow, signs in the user that
+// belongs to the verified identity and redirects back to the frontend.
+func OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	if errCode := query.Get("error"); errCode != "" {
+		http.Error(w, "Single sign-on failed: "+errCode, http.StatusUnauthorized)
+		return
+	}
+
+	flow, err := r.Cookie(oidcFlowCookie)
+	if err != nil {
+		http.Error(w, "Login expired, please try again", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    "",
+		Expires:  time.Now().Add(-time.Hour),
+		HttpOnly: true,
+		Path:     "/api/oidc",
+	})
+
+	parts := strings.Split(flow.Value, ".")
+	if len(parts) != 3 || subtle.ConstantTimeCompare([]byte(parts[0]), []byte(query.Get("state"))) != 1 {
+		http.Error(w, "Invalid login state", http.StatusBadRequest)
+		return
+	}
+	nonce, verifier := parts[1], parts[2]
+
+	code := query.Get("code")
+	if code == "" {
+		http.Error(w, "Authorization code is required", http.StatusBadRequest)
+		return
+	}
+
+	provider, err := getOIDCProvider(r.Context())
+	if err != nil {
+		log.Printf("oidc: %v\n", err)
+		http.Error(w, "Single sign-on is unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	token, err := provider.Exchange(r.Context(), code, verifier)
+	if err != nil {
+		log.Printf("oidc: %v\n", err)
+		http.Error(w, "Failed to redeem authorization code", http.StatusUnauthorized)
+		return
+	}
+
+	claims, err := provider.VerifyIDToken(r.Context(), token.IDToken, nonce)
+	if err != nil {
+		log.Printf("oidc: %v\n", err)
+		http.Error(w, "Invalid identity token", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := userForOIDCClaims(claims)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !user.IsActive() {
+		http.Error(w, "Account is deactivated", http.StatusForbidden)
+		return
+	}
+
+	// The identity provider does not replace the account's own second
+	// factor; the frontend asks for it with the pending token.
+	if user.TOTPEnabled {
+		pendingToken, err := beginTwoFactorLogin(user)
+		if err != nil {
+			http.Error(w, "Failed to start login", http.StatusInternalServerError)
+			return
+		}
+
+		redirect, err := url.Parse(oidcConfig.PostLoginURL)
+		if err != nil {
+			http.Error(w, "Single sign-on is misconfigured", http.StatusInternalServerError)
+			return
+		}
+		fragment := url.Values{"twoFactorRequired": {"true"}, "pendingToken": {pendingToken}}
+		redirect.Fragment = fragment.Encode()
+		http.Redirect(w, r, redirect.String(), http.StatusFound)
+		return
+	}
+
+	if _, err := startSession(w, r, user); err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, oidcConfig.PostLoginURL, http.StatusFound)
+}
+
+// userForOIDCClaims returns the user linked to the identity in claims. An
+// unlinked identity is linked to the account with the same email when both
+// the IdP and the account have verified it, or a new account is created.
+func userForOIDCClaims(claims *oidc.Claims) (*models.User, error) {
+	if user, ok := userRepository.GetUserByOIDCSubject(claims.Issuer, claims.Subject); ok {
+		return user, nil
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, errors.New("Identity provider did not supply a verified email address")
+	}
+
+	if user, ok := userRepository.GetUserByEmail(claims.Email); ok {
+		if user.OIDCSubject != "" {
+			return nil, errors.New("Account is already linked to another identity")
+		}
+		// Anyone can register an address they do not own. Linking such an
+		// account would let its creator sign in next to the real owner.
+		if !user.EmailVerified {
+			return nil, errors.New("Account with this email is not verified, sign in and verify it first")
+		}
+		if err := userRepository.LinkOIDC(user.ID, claims.Issuer, claims.Subject); err != nil {
+			return nil, errors.New("Failed to link account")
+		}
+		return user, nil
+	}
+
+	now := time.Now()
+	user := models.User{
+		Username:        availableUsername(claims),
+		Email:           claims.Email,
+		EmailVerified:   true,
+		EmailVerifiedAt: &now,
+		OIDCIssuer:      claims.Issuer,
+		OIDCSubject:     claims.Subject,
+	}
+	if err := userRepository.Create(&user); err != nil {
+		return nil, errors.New("Failed to create new user")
+	}
+	if _, err := organizationRepository.CreatePersonalOrganization(&user); err != nil {
+		log.Printf("failed to create personal organization for user %d: %v\n", user.ID, err)
+	}
+	return &user, nil
+}
+
+// availableUsername derives a free username from the preferred username or
+// the local part of the email address.
+func availableUsername(claims *oidc.Claims) string {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base = usernameCleaner.ReplaceAllString(base, "")
+	if base == "" {
+		base = "user"
+	}
+
+	username := base
+	for i := 2; userRepository.ExistUsername(username); i++ {
+		username = fmt.Sprintf("%s%d", base, i)
+	}
+	return username
+}
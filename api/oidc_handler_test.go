@@ -0,0 +1,207 @@
+package api
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/config"
+	"github.com/aminasadiam/DevTasks/internal/database/dbtest"
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/oidc"
+)
+
+// mockIdP is an OpenID Provider that approves every login. Its token
+// endpoint hands out an RS256 id token carrying claims and the nonce of the
+// authorization request.
+type mockIdP struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]any
+	nonces map[string]string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdP{t: t, key: key, nonces: map[string]string{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidc.Discovery{
+			Issuer:                idp.server.URL,
+			AuthorizationEndpoint: idp.server.URL + "/authorize",
+			TokenEndpoint:         idp.server.URL + "/token",
+			JWKSURI:               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		encode := base64.RawURLEncoding.EncodeToString
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "idp",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   encode(key.N.Bytes()),
+			"e":   encode(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		nonce, ok := idp.nonces[r.FormValue("code")]
+		if !ok {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(oidc.TokenResponse{AccessToken: "access", TokenType: "Bearer", IDToken: idp.idToken(nonce), ExpiresIn: 3600})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	oidcProvider = nil
+	oidcConfig = &config.OIDCConfig{
+		Issuer:       idp.server.URL,
+		ClientID:     "devtasks",
+		RedirectURL:  "http://devtasks.test/api/oidc/callback",
+		PostLoginURL: "http://devtasks.test/",
+	}
+	t.Cleanup(func() { oidcProvider = nil })
+	return idp
+}
+
+func (idp *mockIdP) idToken(nonce string) string {
+	now := time.Now()
+	claims := map[string]any{
+		"iss":   idp.server.URL,
+		"aud":   "devtasks",
+		"exp":   now.Add(time.Hour).Unix(),
+		"iat":   now.Unix(),
+		"nonce": nonce,
+	}
+	for name, value := range idp.claims {
+		claims[name] = value
+	}
+
+	encode := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			idp.t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := encode(map[string]string{"alg": "RS256", "kid": "idp", "typ": "JWT"}) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, digest[:])
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// login walks the browser through OIDCLogin, the IdP and OIDCCallback and
+// returns the response of the callback.
+func (idp *mockIdP) login(claims map[string]any) *httptest.ResponseRecorder {
+	idp.t.Helper()
+	idp.claims = claims
+
+	w := serve(http.HandlerFunc(OIDCLogin), newRequest(http.MethodGet, "/api/oidc/login", nil))
+	if w.Code != http.StatusFound {
+		idp.t.Fatalf("login: status = %d: %s", w.Code, w.Body)
+	}
+	authorize, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	state := authorize.Query().Get("state")
+	code := "code-" + state
+	idp.nonces[code] = authorize.Query().Get("nonce")
+
+	r := newRequest(http.MethodGet, "/api/oidc/callback", url.Values{"state": {state}, "code": {code}})
+	r.AddCookie(cookie(w, oidcFlowCookie))
+	return serve(http.HandlerFunc(OIDCCallback), r)
+}
+
+func TestOIDCCallbackLinksAccounts(t *testing.T) {
+	verified := models.User{Username: "alice", Email: "alice@example.org", EmailVerified: true}
+	verified.ID = 1
+	unverified := verified
+	unverified.EmailVerified = false
+	linked := verified
+	linked.OIDCSubject = "other"
+
+	tests := []struct {
+		name     string
+		existing *models.User
+		status   int
+		link     bool
+	}{
+		{"verified account", &verified, http.StatusFound, true},
+		{"unverified account", &unverified, http.StatusForbidden, false},
+		{"account linked to another identity", &linked, http.StatusForbidden, false},
+		{"no account", nil, http.StatusFound, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := testDB(t)
+			idp := newMockIdP(t)
+			if test.existing != nil {
+				fake.Return(`LOWER(email) = LOWER($1)`, dbtest.Records(*test.existing))
+			}
+			fake.Return(`INSERT INTO "users"`, dbtest.Rows("id").Row(2))
+			fake.Return(`INSERT INTO "organizations"`, dbtest.Rows("id").Row(3))
+
+			w := idp.login(map[string]any{"sub": "alice-at-idp", "email": "alice@example.org", "email_verified": true})
+			if w.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+
+			links := fake.Find(`UPDATE "users" SET "oidc_issuer"`)
+			if (len(links) > 0) != test.link {
+				t.Errorf("linked: %v, want %v", len(links) > 0, test.link)
+			}
+			for _, link := range links {
+				if !hasArg(link, "alice-at-idp") || !hasArg(link, int64(1)) {
+					t.Errorf("link = %v, want the identity on user 1", link)
+				}
+				if strings.Contains(link.Query, "email_verified") {
+					t.Errorf("linking changed the verification of the email: %s", link.Query)
+				}
+			}
+			if signedIn := len(fake.Find(`INSERT INTO "sessions"`)) == 1; signedIn != (test.status == http.StatusFound) {
+				t.Errorf("signed in: %v, want %v", signedIn, test.status == http.StatusFound)
+			}
+			if test.existing == nil && len(fake.Find(`INSERT INTO "users"`)) != 1 {
+				t.Error("no account created for the new identity")
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackRequiresVerifiedClaim(t *testing.T) {
+	fake := testDB(t)
+	idp := newMockIdP(t)
+	alice := models.User{Username: "alice", Email: "alice@example.org", EmailVerified: true}
+	alice.ID = 1
+	fake.Return(`LOWER(email) = LOWER($1)`, dbtest.Records(alice))
+
+	w := idp.login(map[string]any{"sub": "alice-at-idp", "email": "alice@example.org"})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", w.Code)
+	}
+	if len(fake.Find(`UPDATE "users"`)) != 0 || len(fake.Find(`INSERT INTO`)) != 0 {
+		t.Errorf("an identity without a verified email changed the database: %v", fake.Statements())
+	}
+}
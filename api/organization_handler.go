@@ -0,0 +1,455 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/repository"
+)
+
+var organizationRepository repository.OrganizationRepository
+
+// GetOrganizations lists the organizations of the current user with their
+// role in each and marks the one the request works in.
+func GetOrganizations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	members, err := organizationRepository.GetUserMemberships(CurrentUser(r).ID)
+	if err != nil {
+		http.Error(w, "Failed to get organizations", http.StatusInternalServerError)
+		return
+	}
+
+	organizations := make([]map[string]any, 0, len(members))
+	for _, member := range members {
+		if !tokenAllowsOrganization(r, member.OrganizationId) {
+			continue
+		}
+		organizations = append(organizations, map[string]any{
+			"organization": member.Organization,
+			"role":         member.Role,
+			"current":      member.OrganizationId == CurrentOrganizationId(r),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(organizations); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func AddOrganization(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	slug := strings.TrimSpace(r.FormValue("slug"))
+	if slug == "" {
+		slug = name
+	}
+
+	organization := models.Organization{
+		Name: name,
+		Slug: slug,
+	}
+	if err := organizationRepository.CreateOrganization(&organization, CurrentUser(r).ID); err != nil {
+		http.Error(w, "Failed to add organization", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(organization); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// UpdateOrganization changes the name and settings of an organization.
+// Fields that are not sent keep their value.
+func UpdateOrganization(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	organizationId, ok := parseOrganizationId(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok := authorizeOrganization(w, r, organizationId, models.OrgRoleAdmin); !ok {
+		return
+	}
+
+	organization, err := organizationRepository.GetOrganizationById(organizationId)
+	if err != nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+
+	if name := strings.TrimSpace(r.FormValue("name")); name != "" {
+		organization.Name = name
+	}
+	if restrict := r.FormValue("restrict_project_creation"); restrict != "" {
+		value, err := strconv.ParseBool(restrict)
+		if err != nil {
+			http.Error(w, "Invalid value for restrict_project_creation", http.StatusBadRequest)
+			return
+		}
+		organization.RestrictProjectCreation = value
+	}
+	if r.Form.Has("allowed_email_domain") {
+		domain := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(r.FormValue("allowed_email_domain")), "@"))
+		if strings.ContainsAny(domain, "@ ") {
+			http.Error(w, "Invalid email domain", http.StatusBadRequest)
+			return
+		}
+		organization.AllowedEmailDomain = domain
+	}
+
+	if err := organizationRepository.UpdateOrganization(organization); err != nil {
+		http.Error(w, "Failed to update organization", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(organization); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// DeleteOrganization deletes an organization and all of its projects.
+// Personal organizations cannot be deleted.
+func DeleteOrganization(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	organizationId, ok := parseOrganizationId(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok := authorizeOrganization(w, r, organizationId, models.OrgRoleOwner); !ok {
+		return
+	}
+
+	organization, err := organizationRepository.GetOrganizationById(organizationId)
+	if err != nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+	if organization.Personal {
+		http.Error(w, "Personal organizations cannot be deleted", http.StatusConflict)
+		return
+	}
+
+	if err := organizationRepository.DeleteOrganization(organizationId); err != nil {
+		http.Error(w, "Failed to delete organization", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Organization deleted successfully"})
+}
+
+// SwitchOrganization changes the organization the current session works in.
+func SwitchOrganization(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	organizationId, ok := parseOrganizationId(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok := authorizeOrganization(w, r, organizationId, models.OrgRoleMember); !ok {
+		return
+	}
+
+	if err := sessionRepository.SetOrganization(CurrentSession(r), organizationId); err != nil {
+		http.Error(w, "Failed to switch organization", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Organization switched successfully"})
+}
+
+func GetOrganizationMembers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	organizationId, ok := parseOrganizationId(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok := authorizeOrganization(w, r, organizationId, models.OrgRoleMember); !ok {
+		return
+	}
+
+	members, err := organizationRepository.GetMembers(organizationId)
+	if err != nil {
+		http.Error(w, "Failed to get members", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(members); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func AddOrganizationMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	organizationId, ok := parseOrganizationId(w, r)
+	if !ok {
+		return
+	}
+
+	username := strings.TrimSpace(r.FormValue("username"))
+	role := r.FormValue("role")
+	if role == "" {
+		role = models.OrgRoleMember
+	}
+	if username == "" || !models.IsValidOrgRole(role) {
+		http.Error(w, "Username and a valid role are required", http.StatusBadRequest)
+		return
+	}
+
+	actorRole, ok := authorizeOrganization(w, r, organizationId, models.OrgRoleAdmin)
+	if !ok {
+		return
+	}
+	if !canAssignOrgRole(actorRole, "", role) {
+		http.Error(w, "Your organization role does not allow granting this role", http.StatusForbidden)
+		return
+	}
+
+	organization, err := organizationRepository.GetOrganizationById(organizationId)
+	if err != nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+
+	user, ok := userRepository.GetUserByUsername(username)
+	if !ok || !user.IsActive() {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if !organization.AllowsUser(user) {
+		http.Error(w, "The user's verified email domain is not allowed in this organization", http.StatusForbidden)
+		return
+	}
+
+	existing, err := organizationRepository.GetMembership(organizationId, user.ID)
+	if err != nil {
+		http.Error(w, "Failed to check membership", http.StatusInternalServerError)
+		return
+	}
+	if existing != nil {
+		http.Error(w, "User is already a member of this organization", http.StatusConflict)
+		return
+	}
+
+	member := models.OrganizationMember{
+		OrganizationId: organizationId,
+		UserId:         user.ID,
+		Role:           role,
+		User:           *user,
+	}
+	if err := organizationRepository.AddMember(&member); err != nil {
+		http.Error(w, "Failed to add member", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(member); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func UpdateOrganizationMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	organizationId, userId, ok := parseOrganizationMemberIds(w, r)
+	if !ok {
+		return
+	}
+
+	role := r.FormValue("role")
+	if !models.IsValidOrgRole(role) {
+		http.Error(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+
+	actorRole, ok := authorizeOrganization(w, r, organizationId, models.OrgRoleAdmin)
+	if !ok {
+		return
+	}
+
+	currentRole, ok := organizationMemberRole(w, organizationId, userId)
+	if !ok {
+		return
+	}
+
+	if !canAssignOrgRole(actorRole, currentRole, role) {
+		http.Error(w, "Your organization role does not allow this change", http.StatusForbidden)
+		return
+	}
+
+	if currentRole == models.OrgRoleOwner && role != models.OrgRoleOwner && !hasOtherOrganizationOwner(w, organizationId) {
+		return
+	}
+
+	if err := organizationRepository.UpdateMemberRole(organizationId, userId, role); err != nil {
+		http.Error(w, "Failed to update member", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Member updated successfully"})
+}
+
+// RemoveOrganizationMember removes a member from the organization and all
+// of its projects. Any member may remove themselves to leave.
+func RemoveOrganizationMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	organizationId, userId, ok := parseOrganizationMemberIds(w, r)
+	if !ok {
+		return
+	}
+
+	leaving := userId == CurrentUser(r).ID
+	minRole := models.OrgRoleAdmin
+	if leaving {
+		minRole = models.OrgRoleMember
+	}
+	actorRole, ok := authorizeOrganization(w, r, organizationId, minRole)
+	if !ok {
+		return
+	}
+
+	currentRole, ok := organizationMemberRole(w, organizationId, userId)
+	if !ok {
+		return
+	}
+
+	if !leaving && !canAssignOrgRole(actorRole, currentRole, "") {
+		http.Error(w, "Your organization role does not allow removing this member", http.StatusForbidden)
+		return
+	}
+
+	if currentRole == models.OrgRoleOwner && !hasOtherOrganizationOwner(w, organizationId) {
+		return
+	}
+
+	if err := organizationRepository.RemoveMember(organizationId, userId); err != nil {
+		http.Error(w, "Failed to remove member", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Member removed successfully"})
+}
+
+// canAssignOrgRole follows the rules of canAssignProjectRole for
+// organization roles.
+func canAssignOrgRole(actorRole, currentRole, newRole string) bool {
+	if actorRole == models.OrgRoleOwner {
+		return true
+	}
+	actorRank := models.OrgRoleRank(actorRole)
+	return models.OrgRoleRank(currentRole) < actorRank && models.OrgRoleRank(newRole) < actorRank
+}
+
+func parseOrganizationId(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	organizationId, err := strconv.Atoi(r.FormValue("organization_id"))
+	if err != nil || organizationId <= 0 {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return uint(organizationId), true
+}
+
+func parseOrganizationMemberIds(w http.ResponseWriter, r *http.Request) (uint, uint, bool) {
+	organizationId, ok := parseOrganizationId(w, r)
+	if !ok {
+		return 0, 0, false
+	}
+
+	userId, err := strconv.Atoi(r.FormValue("user_id"))
+	if err != nil || userId <= 0 {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	return organizationId, uint(userId), true
+}
+
+func organizationMemberRole(w http.ResponseWriter, organizationId, userId uint) (string, bool) {
+	member, err := organizationRepository.GetMembership(organizationId, userId)
+	if err != nil {
+		http.Error(w, "Failed to check membership", http.StatusInternalServerError)
+		return "", false
+	}
+	if member == nil {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return "", false
+	}
+	return member.Role, true
+}
+
+func hasOtherOrganizationOwner(w http.ResponseWriter, organizationId uint) bool {
+	owners, err := organizationRepository.CountOwners(organizationId)
+	if err != nil {
+		http.Error(w, "Failed to check owners", http.StatusInternalServerError)
+		return false
+	}
+	if owners <= 1 {
+		http.Error(w, "An organization must keep at least one owner", http.StatusConflict)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/aminasadiam/DevTasks/internal/database/dbtest"
+	"github.com/aminasadiam/DevTasks/internal/models"
+)
+
+func TestCanAssignOrgRole(t *testing.T) {
+	tests := []struct {
+		actor, current, role string
+		want                 bool
+	}{
+		{models.OrgRoleOwner, models.OrgRoleOwner, models.OrgRoleMember, true},
+		{models.OrgRoleOwner, "", models.OrgRoleOwner, true},
+		{models.OrgRoleAdmin, "", models.OrgRoleMember, true},
+		{models.OrgRoleAdmin, models.OrgRoleMember, models.OrgRoleAdmin, false},
+		{models.OrgRoleAdmin, models.OrgRoleAdmin, models.OrgRoleMember, false},
+		{models.OrgRoleMember, "", models.OrgRoleMember, false},
+	}
+	for _, test := range tests {
+		if got := canAssignOrgRole(test.actor, test.current, test.role); got != test.want {
+			t.Errorf("%s changes %q to %s = %v, want %v", test.actor, test.current, test.role, got, test.want)
+		}
+	}
+}
+
+func TestSwitchOrganization(t *testing.T) {
+	tests := []struct {
+		name   string
+		member bool
+		status int
+	}{
+		{"member", true, http.StatusOK},
+		{"other organization", false, http.StatusNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := testDB(t)
+			if test.member {
+				fake.Return(`FROM "organization_members" JOIN organizations`, dbtest.Records(models.OrganizationMember{OrganizationId: 2, UserId: 1, Role: models.OrgRoleMember}))
+			}
+
+			session := testSession(5, 1)
+			r := signedIn(newRequest(http.MethodPost, "/api/switch-organization", url.Values{"organization_id": {"2"}}), &session)
+			if w := serve(http.HandlerFunc(SwitchOrganization), r); w.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+
+			updates := fake.Find(`UPDATE "sessions" SET "organization_id"`)
+			if switched := len(updates) == 1 && hasArg(updates[0], int64(2)) && hasArg(updates[0], int64(5)); switched != test.member {
+				t.Errorf("session switched: %v, want %v (%v)", switched, test.member, updates)
+			}
+		})
+	}
+}
+
+func TestRequireOrganization(t *testing.T) {
+	h := RequireOrganization(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	session := testSession(5, 1)
+
+	if w := serve(h, signedIn(newRequest(http.MethodPost, "/api/projects", nil), &session)); w.Code != http.StatusForbidden {
+		t.Errorf("without organization: status = %d, want 403", w.Code)
+	}
+	if w := serve(h, inOrganization(newRequest(http.MethodPost, "/api/projects", nil), models.OrgRoleMember)); w.Code != http.StatusOK {
+		t.Errorf("in organization: status = %d, want 200", w.Code)
+	}
+}
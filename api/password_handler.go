@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/repository"
+	"github.com/aminasadiam/DevTasks/internal/utils"
+)
+
+const passwordResetDuration = time.Hour
+
+var passwordResetRepository repository.PasswordResetRepository
+
+// ForgotPassword mails a reset link to the given address. It answers the same
+// way whether or not the address belongs to an account, and requests are
+// throttled per address and client IP either way.
+func ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	email := strings.TrimSpace(r.FormValue("email"))
+	if email == "" {
+		http.Error(w, "Email is required", http.StatusBadRequest)
+		return
+	}
+
+	if resetThrottled(w, email, clientIP(r)) {
+		return
+	}
+
+	if user, ok := userRepository.GetUserByEmail(email); ok && user.IsActive() {
+		if err := sendPasswordReset(user); err != nil {
+			log.Printf("failed to send password reset to user %d: %v\n", user.ID, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"message": "If the address is registered, a reset link has been sent"})
+}
+
+func ResetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := strings.TrimSpace(r.FormValue("token"))
+	password := strings.TrimSpace(r.FormValue("password"))
+
+	if token == "" {
+		http.Error(w, "Token is required", http.StatusBadRequest)
+		return
+	}
+
+	if password == "" || !utils.IsValidPassword(password) {
+		http.Error(w, "Invalid Password", http.StatusNotAcceptable)
+		return
+	}
+
+	resetToken, err := passwordResetRepository.ConsumeToken(token)
+	if err != nil {
+		http.Error(w, "Invalid or expired token", http.StatusBadRequest)
+		return
+	}
+
+	hashedPassword, err := utils.HashPass(password)
+	if err != nil {
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+
+	if err := userRepository.UpdatePassword(resetToken.UserId, hashedPassword); err != nil {
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+
+	passwordResetRepository.DeleteUserTokens(resetToken.UserId)
+	if err := sessionRepository.DeleteUserSessions(resetToken.UserId, 0); err != nil {
+		log.Printf("failed to revoke sessions of user %d: %v\n", resetToken.UserId, err)
+	}
+	if err := apiTokenRepository.RevokeUserTokens(resetToken.UserId); err != nil {
+		log.Printf("failed to revoke API tokens of user %d: %v\n", resetToken.UserId, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password reset successfully"})
+}
+
+// sendPasswordReset replaces any outstanding reset tokens of user with a new
+// one and mails it.
+func sendPasswordReset(user *models.User) error {
+	if err := passwordResetRepository.DeleteUserTokens(user.ID); err != nil {
+		return err
+	}
+
+	token := utils.GenerateToken(32)
+	resetToken := models.PasswordResetToken{
+		UserId:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(passwordResetDuration),
+	}
+	if err := passwordResetRepository.Create(&resetToken); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", mailConfig.AppURL, url.QueryEscape(token))
+	body := fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your DevTasks account. "+
+		"Use the link below within the next hour to choose a new one:\n\n%s\n\n"+
+		"If this wasn't you, you can ignore this email.\n", user.Username, link)
+
+	return mailer.Send(user.Email, "Reset your DevTasks password", body)
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/database/dbtest"
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/throttle"
+	"github.com/aminasadiam/DevTasks/internal/utils"
+)
+
+func TestForgotPassword(t *testing.T) {
+	fake := testDB(t)
+	sent := useTestMailer()
+	resetGuard = throttle.NewResetGuard(throttle.NewMemoryStore(time.Hour))
+
+	alice := models.User{Username: "alice", Email: "alice@example.org"}
+	alice.ID = 1
+	fake.On(`FROM "users"`, func(args []any) (*dbtest.Result, error) {
+		if args[0] != alice.Email {
+			return nil, nil
+		}
+		return dbtest.Records(alice), nil
+	})
+
+	forgot := func(email string) int {
+		r := newRequest(http.MethodPost, "/api/password/forgot", url.Values{"email": {email}})
+		r.RemoteAddr = "192.0.2.1:1234"
+		return serve(http.HandlerFunc(ForgotPassword), r).Code
+	}
+
+	// Unknown addresses get the same answer but no mail.
+	if status := forgot("mallory@example.org"); status != http.StatusAccepted {
+		t.Errorf("unknown address: status = %d, want 202", status)
+	}
+	if len(sent.sent) != 0 {
+		t.Errorf("mail sent to an unknown address: %+v", sent.sent)
+	}
+
+	if status := forgot(alice.Email); status != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", status)
+	}
+	if len(sent.sent) != 1 || sent.sent[0].to != alice.Email {
+		t.Fatalf("mails = %+v, want one to %s", sent.sent, alice.Email)
+	}
+
+	// The mail carries the raw token, the database only its hash, and older
+	// tokens are dropped first.
+	_, link, _ := strings.Cut(sent.sent[0].body, "http://devtasks.test/reset-password?token=")
+	rawToken, err := url.QueryUnescape(strings.Fields(link)[0])
+	if err != nil || rawToken == "" {
+		t.Fatalf("no reset link in %q", sent.sent[0].body)
+	}
+	statements := fake.Find(`"password_reset_tokens"`)
+	if len(statements) != 2 || !strings.HasPrefix(statements[0].Query, "DELETE") || !strings.HasPrefix(statements[1].Query, "INSERT") {
+		t.Fatalf("token statements = %v, want a delete and an insert", statements)
+	}
+	if !hasArg(statements[1], utils.HashToken(rawToken)) || hasArg(statements[1], rawToken) {
+		t.Errorf("reset token is not stored by its hash alone: %v", statements[1].Args)
+	}
+
+	// A second request for the same address has to wait.
+	r := newRequest(http.MethodPost, "/api/password/forgot", url.Values{"email": {alice.Email}})
+	w := serve(http.HandlerFunc(ForgotPassword), r)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("repeated request: status = %d, Retry-After = %q, want 429 with Retry-After", w.Code, w.Header().Get("Retry-After"))
+	}
+	if len(sent.sent) != 1 {
+		t.Errorf("%d mails sent, want 1", len(sent.sent))
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	fake := testDB(t)
+	resetToken := models.PasswordResetToken{
+		UserId:    1,
+		TokenHash: utils.HashToken("reset"),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	resetToken.ID = 4
+	fake.On(`FROM "password_reset_tokens"`, func(args []any) (*dbtest.Result, error) {
+		if args[0] != resetToken.TokenHash {
+			return nil, nil
+		}
+		return dbtest.Records(resetToken), nil
+	})
+	// The conditional update succeeds once.
+	consumed := false
+	fake.On(`UPDATE "password_reset_tokens" SET "used_at"`, func([]any) (*dbtest.Result, error) {
+		if consumed {
+			return dbtest.Affected(0), nil
+		}
+		consumed = true
+		return dbtest.Affected(1), nil
+	})
+
+	reset := func(token, password string) int {
+		r := newRequest(http.MethodPost, "/api/password/reset", url.Values{"token": {token}, "password": {password}})
+		return serve(http.HandlerFunc(ResetPassword), r).Code
+	}
+
+	if status := reset("reset", "short"); status != http.StatusNotAcceptable {
+		t.Errorf("short password: status = %d, want 406", status)
+	}
+	if status := reset("unknown", "new password"); status != http.StatusBadRequest {
+		t.Errorf("unknown token: status = %d, want 400", status)
+	}
+	if status := reset("reset", "new password"); status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if status := reset("reset", "other password"); status != http.StatusBadRequest {
+		t.Errorf("reused token: status = %d, want 400", status)
+	}
+
+	updates := fake.Find(`UPDATE "users" SET "password"`)
+	if len(updates) != 1 {
+		t.Fatalf("password updated %d times, want 1", len(updates))
+	}
+	hash, _ := updates[0].Args[0].(string)
+	if !utils.VerifyPassword("new password", hash) {
+		t.Errorf("stored password %q does not verify", hash)
+	}
+
+	// Every way into the old account is closed.
+	for _, revoke := range []string{`DELETE FROM "sessions" WHERE user_id = $1`, `UPDATE "api_tokens" SET "revoked_at"`, `DELETE FROM "password_reset_tokens" WHERE user_id = $1`} {
+		statements := fake.Find(revoke)
+		if len(statements) != 1 || !hasArg(statements[0], int64(1)) {
+			t.Errorf("%s: %v, want one statement for user 1", revoke, statements)
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"github.com/aminasadiam/DevTasks/internal/models"
 	"github.com/aminasadiam/DevTasks/internal/repository"
@@ -18,19 +17,21 @@ func GetProjects(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := Authorize(r); err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	username := strings.TrimSpace(r.FormValue("username"))
-	userId := userRepository.GetUserIdByUsername(username)
-	projects, err := projectRepository.GetUserProjects(userId)
+	userId := CurrentUser(r).ID
+	projects, err := projectsFor(r).GetUserProjects(userId)
 	if err != nil {
 		http.Error(w, "failed to get projects", http.StatusInternalServerError)
 		return
 	}
 
+	visible := projects[:0]
+	for _, project := range projects {
+		if tokenAllowsProject(r, project.ID) {
+			visible = append(visible, project)
+		}
+	}
+	projects = visible
+
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(projects); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
@@ -44,12 +45,11 @@ func AddProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := Authorize(r); err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	if token := CurrentAPIToken(r); token != nil && token.ProjectId != nil {
+		http.Error(w, "Token is limited to a single project", http.StatusForbidden)
 		return
 	}
 
-	username := strings.TrimSpace(r.FormValue("username"))
 	name := r.FormValue("name")
 	description := r.FormValue("description")
 
@@ -58,7 +58,12 @@ func AddProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userId := userRepository.GetUserIdByUsername(username)
+	if CurrentOrganization(r).RestrictProjectCreation && models.OrgRoleRank(currentMembership(r).Role) < models.OrgRoleRank(models.OrgRoleAdmin) {
+		http.Error(w, "Only organization admins can create projects", http.StatusForbidden)
+		return
+	}
+
+	userId := CurrentUser(r).ID
 
 	project := models.Project{
 		Name:        name,
@@ -66,7 +71,7 @@ func AddProject(w http.ResponseWriter, r *http.Request) {
 		UserId:      userId,
 	}
 
-	if err := projectRepository.AddProject(&project); err != nil {
+	if err := projectsFor(r).AddProject(&project); err != nil {
 		http.Error(w, "Failed to add project", http.StatusInternalServerError)
 		return
 	}
@@ -89,12 +94,6 @@ func GetProjectById(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := Authorize(r); err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	username := strings.TrimSpace(r.FormValue("username"))
 	projectIdStr := r.FormValue("project_id")
 	if projectIdStr == "" {
 		http.Error(w, "Project ID is required", http.StatusBadRequest)
@@ -107,17 +106,10 @@ func GetProjectById(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userId := userRepository.GetUserIdByUsername(username)
-	exists, err := projectRepository.CheckProjectForUser(uint(projectId), userId)
-	if err != nil {
-		http.Error(w, "Failed to check project ownership", http.StatusInternalServerError)
-		return
-	}
-	if !exists {
-		http.Error(w, "Project not found for this user", http.StatusNotFound)
+	if !authorizeProject(w, r, uint(projectId), models.ActionViewProject) {
 		return
 	}
-	project, err := projectRepository.GetProjectById(uint(projectId))
+	project, err := projectsFor(r).GetProjectById(uint(projectId))
 	if err != nil {
 		http.Error(w, "Failed to get project", http.StatusInternalServerError)
 		return
@@ -135,12 +127,6 @@ func UpdateProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := Authorize(r); err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	username := strings.TrimSpace(r.FormValue("username"))
 	projectIdStr := r.FormValue("project_id")
 	name := r.FormValue("name")
 	description := r.FormValue("description")
@@ -156,18 +142,11 @@ func UpdateProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userId := userRepository.GetUserIdByUsername(username)
-	exists, err := projectRepository.CheckProjectForUser(uint(projectId), userId)
-	if err != nil {
-		http.Error(w, "Failed to check project ownership", http.StatusInternalServerError)
-		return
-	}
-	if !exists {
-		http.Error(w, "Project not found for this user", http.StatusNotFound)
+	if !authorizeProject(w, r, uint(projectId), models.ActionEditProject) {
 		return
 	}
 
-	project, _ := projectRepository.GetProjectById(uint(projectId))
+	project, _ := projectsFor(r).GetProjectById(uint(projectId))
 	if project == nil {
 		http.Error(w, "Project not found", http.StatusNotFound)
 		return
@@ -176,7 +155,7 @@ func UpdateProject(w http.ResponseWriter, r *http.Request) {
 	project.Name = name
 	project.Description = description
 
-	if err := projectRepository.UpdateProject(project); err != nil {
+	if err := projectsFor(r).UpdateProject(project); err != nil {
 		http.Error(w, "Failed to update project", http.StatusInternalServerError)
 		return
 	}
@@ -198,12 +177,6 @@ func DeleteProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := Authorize(r); err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	username := strings.TrimSpace(r.FormValue("username"))
 	projectIdStr := r.FormValue("project_id")
 	if projectIdStr == "" {
 		http.Error(w, "Project ID is required", http.StatusBadRequest)
@@ -216,18 +189,11 @@ func DeleteProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userId := userRepository.GetUserIdByUsername(username)
-	exists, err := projectRepository.CheckProjectForUser(uint(projectId), userId)
-	if err != nil {
-		http.Error(w, "Failed to check project ownership", http.StatusInternalServerError)
-		return
-	}
-	if !exists {
-		http.Error(w, "Project not found for this user", http.StatusNotFound)
+	if !authorizeProject(w, r, uint(projectId), models.ActionDeleteProject) {
 		return
 	}
 
-	if err := projectRepository.DeleteProject(uint(projectId)); err != nil {
+	if err := projectsFor(r).DeleteProject(uint(projectId)); err != nil {
 		http.Error(w, "Failed to delete project", http.StatusInternalServerError)
 		return
 	}
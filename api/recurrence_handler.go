@@ -0,0 +1,218 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/repository"
+	"github.com/aminasadiam/DevTasks/internal/rrule"
+)
+
+var recurrenceRepository repository.RecurrenceRepository
+
+// upcomingOccurrences is how many future schedules recurrence responses
+// preview.
+const upcomingOccurrences = 5
+
+// GetTaskRecurrence returns the recurrence of a task with its next
+// occurrences.
+func GetTaskRecurrence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	task, ok := getRecurringTask(w, r, models.ActionViewTasks)
+	if !ok {
+		return
+	}
+
+	recurrence, err := recurrenceRepository.GetRecurrence(*task.RecurrenceId)
+	if err != nil {
+		http.Error(w, "Recurrence not found", http.StatusNotFound)
+		return
+	}
+
+	writeRecurrence(w, recurrence, http.StatusOK)
+}
+
+// SetTaskRecurrence makes a task repeat on the schedule of rrule, an RFC
+// 5545 RRULE such as FREQ=WEEKLY;BYDAY=MO, in timezone. The task's due date
+// is the first occurrence. On a task that already recurs the rule is
+// replaced, starting from the newest occurrence.
+func SetTaskRecurrence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskId, err := strconv.Atoi(r.FormValue("task_id"))
+	if err != nil || taskId <= 0 {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	task, err := tasksFor(r).GetTaskById(uint(taskId))
+	if err != nil {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	if !authorizeProject(w, r, task.ProjectId, models.ActionEditTask) {
+		return
+	}
+
+	rule, err := rrule.Parse(r.FormValue("rrule"))
+	if err != nil {
+		http.Error(w, "Invalid recurrence rule: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	location, ok := requestLocation(w, r)
+	if !ok {
+		return
+	}
+
+	if task.RecurrenceId != nil {
+		recurrence, err := recurrenceRepository.UpdateRule(*task.RecurrenceId, rule.String(), location.String())
+		if err != nil {
+			http.Error(w, "Failed to update recurrence", http.StatusInternalServerError)
+			return
+		}
+		writeRecurrence(w, recurrence, http.StatusOK)
+		return
+	}
+
+	if task.DueDate == nil {
+		http.Error(w, "Recurring tasks need a due date", http.StatusBadRequest)
+		return
+	}
+
+	recurrence := models.TaskRecurrence{
+		ProjectId: task.ProjectId,
+		RRule:     rule.String(),
+		Timezone:  location.String(),
+		DTStart:   task.DueDate.Truncate(time.Second),
+		CreatedBy: CurrentUser(r).ID,
+	}
+	if err := recurrenceRepository.Create(&recurrence, task); err != nil {
+		if errors.Is(err, repository.ErrTaskRecurring) {
+			http.Error(w, "Task already recurs", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to add recurrence", http.StatusInternalServerError)
+		return
+	}
+
+	writeRecurrence(w, &recurrence, http.StatusCreated)
+}
+
+// RemoveTaskRecurrence stops a task from recurring. Occurrences created so
+// far stay.
+func RemoveTaskRecurrence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	task, ok := getRecurringTask(w, r, models.ActionEditTask)
+	if !ok {
+		return
+	}
+
+	if err := recurrenceRepository.End(*task.RecurrenceId); err != nil {
+		http.Error(w, "Failed to remove recurrence", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Recurrence removed"})
+}
+
+// getRecurringTask loads the task task_id and checks that the current user
+// may perform action on it and that it recurs.
+func getRecurringTask(w http.ResponseWriter, r *http.Request, action models.ProjectAction) (*models.Task, bool) {
+	taskId, err := strconv.Atoi(r.FormValue("task_id"))
+	if err != nil || taskId <= 0 {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	task, err := tasksFor(r).GetTaskById(uint(taskId))
+	if err != nil {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	if !authorizeProject(w, r, task.ProjectId, action) {
+		return nil, false
+	}
+
+	if task.RecurrenceId == nil {
+		http.Error(w, "Task does not recur", http.StatusNotFound)
+		return nil, false
+	}
+	return task, true
+}
+
+func writeRecurrence(w http.ResponseWriter, recurrence *models.TaskRecurrence, status int) {
+	upcoming, err := recurrenceRepository.UpcomingOccurrences(recurrence, upcomingOccurrences)
+	if err != nil {
+		http.Error(w, "Failed to compute occurrences", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	preview := models.RecurrencePreview{Recurrence: *recurrence, Upcoming: upcoming}
+	if err := json.NewEncoder(w).Encode(preview); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// materializeOccurrence creates the next occurrence of recurrenceId if it
+// is due or the newest one was completed. Failures are only logged; the
+// scheduler retries on its next run.
+func materializeOccurrence(recurrenceId uint) {
+	task, err := recurrenceRepository.Materialize(recurrenceId, time.Now())
+	if err != nil {
+		log.Printf("failed to create occurrence of recurrence %d: %v\n", recurrenceId, err)
+		return
+	}
+	if task != nil {
+		log.Printf("created task %d as occurrence of recurrence %d\n", task.ID, recurrenceId)
+	}
+}
+
+// runRecurrenceScheduler creates due occurrences of recurring tasks every
+// interval until ctx is done. Running it on several instances is safe: each
+// recurrence is handled by one instance at a time and occurrences are
+// unique.
+func runRecurrenceScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		ids, err := recurrenceRepository.GetPendingIds(time.Now())
+		if err != nil {
+			log.Printf("failed to get pending recurrences: %v\n", err)
+		}
+		for _, id := range ids {
+			if ctx.Err() != nil {
+				return
+			}
+			materializeOccurrence(id)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
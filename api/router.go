@@ -12,61 +12,270 @@ import (
 
 	"github.com/aminasadiam/DevTasks/config"
 	"github.com/aminasadiam/DevTasks/internal/database"
+	"github.com/aminasadiam/DevTasks/internal/mail"
 	"github.com/aminasadiam/DevTasks/internal/repository"
+	"github.com/aminasadiam/DevTasks/internal/storage"
+	"github.com/aminasadiam/DevTasks/internal/throttle"
+	"github.com/aminasadiam/DevTasks/internal/utils"
 	"github.com/rs/cors"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
 
-func init() {
-	cfg := config.LoadDbConfig()
-	DB = database.InitDB(cfg)
+var authConfig *config.AuthConfig
+var mailConfig *config.MailConfig
+var mailer mail.Mailer
+var taskConfig *config.TaskConfig
 
-	userRepository = *repository.NewUserRepository(DB)
-	projectRepository = *repository.NewProjectRepository(DB)
-	taskRepository = *repository.NewTaskRepository(DB)
+// setup connects to the database and loads the configuration of the
+// handlers. It runs when the server starts rather than on import, so that
+// the package can be tested without a database.
+func setup() {
+	DB = database.InitDB(config.LoadDbConfig())
+	useDB(DB)
+
+	taskConfig = config.LoadTaskConfig()
+	taskRepository.SetMaxDepth(taskConfig.MaxDepth)
+
+	storageConfig = config.LoadStorageConfig()
+	store, err := storage.NewBlobStore(storageConfig)
+	if err != nil {
+		log.Fatalf("failed to set up attachment storage: %v\n", err)
+	}
+	blobStore = store
+
+	authConfig = config.LoadAuthConfig()
+	oidcConfig = config.LoadOIDCConfig()
+	throttleStore := newThrottleStore(DB)
+	loginGuard = throttle.NewLoginGuard(throttleStore)
+	resetGuard = throttle.NewResetGuard(throttleStore)
+	utils.SetPasswordHasher(newPasswordHasher(config.LoadPasswordConfig()))
+
+	if err := userRepository.PromoteAdmins(authConfig.AdminUsernames); err != nil {
+		log.Printf("failed to promote admins: %v\n", err)
+	}
+	if err := userRepository.BackfillAvatars(); err != nil {
+		log.Printf("failed to backfill avatars: %v\n", err)
+	}
+	if err := projectMemberRepository.BackfillOwners(); err != nil {
+		log.Printf("failed to backfill project owners: %v\n", err)
+	}
+	if err := organizationRepository.Backfill(); err != nil {
+		log.Printf("failed to backfill organizations: %v\n", err)
+	}
+	if err := workflowRepository.BackfillWorkflows(); err != nil {
+		log.Printf("failed to backfill workflows: %v\n", err)
+	}
+	mailConfig = config.LoadMailConfig()
+	mailer = mail.NewMailer(mailConfig)
+}
+
+// useDB points the repositories at db.
+func useDB(db *gorm.DB) {
+	userRepository = *repository.NewUserRepository(db)
+	projectRepository = *repository.NewProjectRepository(db)
+	taskRepository = *repository.NewTaskRepository(db)
+	sessionRepository = *repository.NewSessionRepository(db)
+	apiTokenRepository = *repository.NewAPITokenRepository(db)
+	passwordResetRepository = *repository.NewPasswordResetRepository(db)
+	emailVerificationRepository = *repository.NewEmailVerificationRepository(db)
+	twoFactorRepository = *repository.NewTwoFactorRepository(db)
+	projectMemberRepository = *repository.NewProjectMemberRepository(db)
+	invitationRepository = *repository.NewInvitationRepository(db)
+	organizationRepository = *repository.NewOrganizationRepository(db)
+	teamRepository = *repository.NewTeamRepository(db)
+	workflowRepository = *repository.NewWorkflowRepository(db)
+	dependencyRepository = *repository.NewDependencyRepository(db)
+	commentRepository = *repository.NewCommentRepository(db)
+	attachmentRepository = *repository.NewAttachmentRepository(db)
+	worklogRepository = *repository.NewWorklogRepository(db)
+	recurrenceRepository = *repository.NewRecurrenceRepository(db)
+	labelRepository = *repository.NewLabelRepository(db)
 }
 
 func Serve(config *config.ServerConfig) error {
+	setup()
+
 	mux := http.NewServeMux()
 
 	// Routes
 	// User Routes
-	mux.HandleFunc("GET /api/users", GetUsers)
+	mux.Handle("GET /api/users", protect(GetUsers))
 	mux.HandleFunc("POST /api/register", RegisterHandler)
 	mux.HandleFunc("POST /api/login", LoginHandler)
-	mux.HandleFunc("/api/logout", LogoutHandler)
-	mux.HandleFunc("POST /api/validate", ValidateSession)
+	mux.HandleFunc("POST /api/login/2fa", LoginTwoFactor)
+	mux.Handle("/api/logout", protect(LogoutHandler, SessionOnly))
+	mux.Handle("POST /api/validate", protect(ValidateSession))
+	mux.Handle("GET /api/avatar", protectCookie(GetAvatar))
+	mux.Handle("POST /api/upload-avatar", protect(UploadAvatar, RequireWrite))
+	mux.Handle("DELETE /api/delete-avatar", protect(DeleteAvatar, RequireWrite))
+
+	// Password Routes
+	mux.HandleFunc("POST /api/password/forgot", ForgotPassword)
+	mux.HandleFunc("POST /api/password/reset", ResetPassword)
+
+	// Single Sign-On Routes
+	mux.HandleFunc("GET /api/oidc/login", OIDCLogin)
+	mux.HandleFunc("GET /api/oidc/callback", OIDCCallback)
+
+	// Two-Factor Routes
+	mux.Handle("POST /api/2fa/enroll", protect(EnrollTwoFactor, SessionOnly))
+	mux.Handle("POST /api/2fa/confirm", protect(ConfirmTwoFactor, SessionOnly))
+	mux.Handle("POST /api/2fa/disable", protect(DisableTwoFactor, SessionOnly))
+	mux.Handle("POST /api/2fa/recovery-codes", protect(RegenerateRecoveryCodes, SessionOnly))
+
+	// Email Verification Routes
+	mux.HandleFunc("POST /api/email/verify", VerifyEmail)
+	mux.Handle("POST /api/email/resend", protect(ResendVerification, SessionOnly))
+
+	// Admin Routes
+	mux.Handle("GET /api/admin/users", protect(AdminGetUsers, SessionOnly, RequireAdmin))
+	mux.Handle("PUT /api/admin/user-role", protect(AdminUpdateUserRole, SessionOnly, RequireAdmin))
+	mux.Handle("POST /api/admin/deactivate-user", protect(AdminDeactivateUser, SessionOnly, RequireAdmin))
+	mux.Handle("POST /api/admin/reactivate-user", protect(AdminReactivateUser, SessionOnly, RequireAdmin))
+	mux.Handle("POST /api/admin/logout-user", protect(AdminLogoutUser, SessionOnly, RequireAdmin))
+	mux.Handle("POST /api/admin/reset-password", protect(AdminResetPassword, SessionOnly, RequireAdmin))
+	mux.Handle("POST /api/admin/unlock", protect(UnlockLogin, SessionOnly, RequireAdmin))
+
+	// Session Routes
+	mux.Handle("POST /api/sessions", protect(GetSessions, SessionOnly))
+	mux.Handle("DELETE /api/revoke-session", protect(RevokeSession, SessionOnly))
+	mux.Handle("DELETE /api/revoke-sessions", protect(RevokeAllSessions, SessionOnly))
+
+	// API Token Routes
+	mux.Handle("POST /api/tokens", protect(GetTokens, SessionOnly))
+	mux.Handle("POST /api/add-token", protect(AddToken, SessionOnly, RequireOrganization))
+	mux.Handle("DELETE /api/revoke-token", protect(RevokeToken, SessionOnly))
+
+	// Organization Routes
+	mux.Handle("POST /api/organizations", protect(GetOrganizations))
+	mux.Handle("POST /api/add-organization", protect(AddOrganization, SessionOnly, RequireMember))
+	mux.Handle("PUT /api/update-organization", protect(UpdateOrganization, RequireWrite))
+	mux.Handle("DELETE /api/delete-organization", protect(DeleteOrganization, RequireWrite))
+	mux.Handle("POST /api/switch-organization", protect(SwitchOrganization, SessionOnly))
+	mux.Handle("POST /api/organization-members", protect(GetOrganizationMembers))
+	mux.Handle("POST /api/add-organization-member", protect(AddOrganizationMember, RequireWrite))
+	mux.Handle("PUT /api/update-organization-member", protect(UpdateOrganizationMember, RequireWrite))
+	mux.Handle("DELETE /api/remove-organization-member", protect(RemoveOrganizationMember, RequireWrite))
 
 	// Projects Routes
-	mux.HandleFunc("POST /api/projects", GetProjects)
-	mux.HandleFunc("POST /api/add-project", AddProject)
-	mux.HandleFunc("POST /api/project", GetProjectById)
-	mux.HandleFunc("PUT /api/update-project", UpdateProject)
-	mux.HandleFunc("DELETE /api/delete-project", DeleteProject)
+	mux.Handle("POST /api/projects", protect(GetProjects, RequireOrganization))
+	mux.Handle("POST /api/add-project", protect(AddProject, RequireOrganization, RequireWrite, RequireMember, RequireVerifiedEmail))
+	mux.Handle("POST /api/project", protect(GetProjectById, RequireOrganization))
+	mux.Handle("PUT /api/update-project", protect(UpdateProject, RequireOrganization, RequireWrite))
+	mux.Handle("DELETE /api/delete-project", protect(DeleteProject, RequireOrganization, RequireWrite))
+
+	// Project Member Routes
+	mux.Handle("POST /api/project-members", protect(GetProjectMembers, RequireOrganization))
+	mux.Handle("POST /api/add-project-member", protect(AddProjectMember, RequireOrganization, RequireWrite))
+	mux.Handle("PUT /api/update-project-member", protect(UpdateProjectMember, RequireOrganization, RequireWrite))
+	mux.Handle("DELETE /api/remove-project-member", protect(RemoveProjectMember, RequireOrganization, RequireWrite))
+
+	// Team Routes
+	mux.Handle("POST /api/teams", protect(GetTeams, RequireOrganization))
+	mux.Handle("POST /api/add-team", protect(AddTeam, RequireOrganization, RequireWrite))
+	mux.Handle("PUT /api/update-team", protect(UpdateTeam, RequireOrganization, RequireWrite))
+	mux.Handle("DELETE /api/delete-team", protect(DeleteTeam, RequireOrganization, RequireWrite))
+	mux.Handle("POST /api/team-members", protect(GetTeamMembers, RequireOrganization))
+	mux.Handle("POST /api/add-team-member", protect(AddTeamMember, RequireOrganization, RequireWrite))
+	mux.Handle("DELETE /api/remove-team-member", protect(RemoveTeamMember, RequireOrganization, RequireWrite))
+	mux.Handle("POST /api/project-teams", protect(GetProjectTeams, RequireOrganization))
+	mux.Handle("POST /api/add-project-team", protect(AddProjectTeam, RequireOrganization, RequireWrite))
+	mux.Handle("PUT /api/update-project-team", protect(UpdateProjectTeam, RequireOrganization, RequireWrite))
+	mux.Handle("DELETE /api/remove-project-team", protect(RemoveProjectTeam, RequireOrganization, RequireWrite))
+	mux.Handle("POST /api/project-access", protect(ExplainProjectAccess, RequireOrganization))
+
+	// Invitation Routes
+	mux.Handle("POST /api/invite", protect(InviteToProject, RequireOrganization, RequireWrite))
+	mux.Handle("POST /api/invitations", protect(GetInvitations, RequireOrganization))
+	mux.Handle("DELETE /api/revoke-invitation", protect(RevokeInvitation, RequireOrganization, RequireWrite))
+	mux.Handle("POST /api/accept-invitation", protect(AcceptInvitation, SessionOnly))
+
+	// Workflow Routes
+	mux.Handle("POST /api/workflow", protect(GetWorkflow, RequireOrganization))
+	mux.Handle("POST /api/add-workflow-state", protect(AddWorkflowState, RequireOrganization, RequireWrite))
+	mux.Handle("PUT /api/update-workflow-state", protect(UpdateWorkflowState, RequireOrganization, RequireWrite))
+	mux.Handle("DELETE /api/delete-workflow-state", protect(DeleteWorkflowState, RequireOrganization, RequireWrite))
+	mux.Handle("POST /api/add-workflow-transition", protect(AddWorkflowTransition, RequireOrganization, RequireWrite))
+	mux.Handle("DELETE /api/remove-workflow-transition", protect(RemoveWorkflowTransition, RequireOrganization, RequireWrite))
 
 	// Tasks Routes
-	mux.HandleFunc("POST /api/tasks", GetTasks)
-	mux.HandleFunc("POST /api/add-task", AddTask)
-	mux.HandleFunc("/api/task", GetTaskById) // Handle both GET and POST
-	mux.HandleFunc("PUT /api/update-task", UpdateTask)
-	mux.HandleFunc("DELETE /api/delete-task", DeleteTask)
+	mux.Handle("POST /api/tasks", protect(GetTasks, RequireOrganization))
+	mux.Handle("POST /api/add-task", protect(AddTask, RequireOrganization, RequireWrite))
+	mux.Handle("/api/task", protect(GetTaskById, RequireOrganization)) // Handle both GET and POST
+	mux.Handle("PUT /api/update-task", protect(UpdateTask, RequireOrganization, RequireWrite))
+	mux.Handle("DELETE /api/delete-task", protect(DeleteTask, RequireOrganization, RequireWrite))
+	mux.Handle("POST /api/task-transitions", protect(GetTaskTransitions, RequireOrganization))
+	mux.Handle("POST /api/task-tree", protect(GetTaskTree, RequireOrganization))
+	mux.Handle("POST /api/move-task", protect(MoveTask, RequireOrganization, RequireWrite))
+	mux.Handle("POST /api/task-blockers", protect(GetTaskBlockers, RequireOrganization))
+	mux.Handle("POST /api/task-dependents", protect(GetTaskDependents, RequireOrganization))
+	mux.Handle("POST /api/add-task-dependency", protect(AddTaskDependency, RequireOrganization, RequireWrite))
+	mux.Handle("DELETE /api/remove-task-dependency", protect(RemoveTaskDependency, RequireOrganization, RequireWrite))
+	mux.Handle("POST /api/critical-path", protect(GetCriticalPath, RequireOrganization))
+	mux.Handle("POST /api/my-tasks", protect(GetMyTasks, RequireOrganization))
+	mux.Handle("POST /api/assign-task", protect(AssignTask, RequireOrganization, RequireWrite))
+	mux.Handle("DELETE /api/unassign-task", protect(UnassignTask, RequireOrganization, RequireWrite))
+	mux.Handle("POST /api/tasks/overdue", protect(GetOverdueTasks, RequireOrganization))
+	mux.Handle("POST /api/tasks/due-this-week", protect(GetTasksDueThisWeek, RequireOrganization))
+	mux.Handle("POST /api/tasks/due-between", protect(GetTasksDueBetween, RequireOrganization))
+	mux.Handle("POST /api/task-recurrence", protect(GetTaskRecurrence, RequireOrganization))
+	mux.Handle("POST /api/set-task-recurrence", protect(SetTaskRecurrence, RequireOrganization, RequireWrite))
+	mux.Handle("DELETE /api/remove-task-recurrence", protect(RemoveTaskRecurrence, RequireOrganization, RequireWrite))
+
+	// Label Routes
+	mux.Handle("POST /api/labels", protect(GetLabels, RequireOrganization))
+	mux.Handle("POST /api/add-label", protect(AddLabel, RequireOrganization, RequireWrite))
+	mux.Handle("PUT /api/update-label", protect(UpdateLabel, RequireOrganization, RequireWrite))
+	mux.Handle("DELETE /api/delete-label", protect(DeleteLabel, RequireOrganization, RequireWrite))
+	mux.Handle("POST /api/merge-labels", protect(MergeLabels, RequireOrganization, RequireWrite))
+	mux.Handle("POST /api/add-task-label", protect(AddTaskLabel, RequireOrganization, RequireWrite))
+	mux.Handle("DELETE /api/remove-task-label", protect(RemoveTaskLabel, RequireOrganization, RequireWrite))
+
+	// Comment Routes
+	mux.Handle("POST /api/comments", protect(GetComments, RequireOrganization))
+	mux.Handle("POST /api/add-comment", protect(AddComment, RequireOrganization, RequireWrite))
+	mux.Handle("PUT /api/update-comment", protect(UpdateComment, RequireOrganization, RequireWrite))
+	mux.Handle("DELETE /api/delete-comment", protect(DeleteComment, RequireOrganization, RequireWrite))
+	mux.Handle("POST /api/comment-revisions", protect(GetCommentRevisions, RequireOrganization))
+	mux.Handle("POST /api/mentions", protect(GetMentions, RequireOrganization))
+	mux.Handle("POST /api/read-mentions", protect(ReadMentions, RequireOrganization, RequireWrite))
+
+	// Attachment Routes
+	mux.Handle("POST /api/attachments", protect(GetAttachments, RequireOrganization))
+	mux.Handle("POST /api/add-attachment", protect(AddAttachment, RequireOrganization, RequireWrite))
+	mux.Handle("GET /api/download-attachment", protectCookie(DownloadAttachment, RequireOrganization))
+	mux.Handle("DELETE /api/delete-attachment", protect(DeleteAttachment, RequireOrganization, RequireWrite))
+
+	// Time Tracking Routes
+	mux.Handle("POST /api/timer", protect(GetTimer))
+	mux.Handle("POST /api/start-timer", protect(StartTimer, RequireOrganization, RequireWrite))
+	mux.Handle("POST /api/stop-timer", protect(StopTimer, RequireWrite))
+	mux.Handle("POST /api/worklogs", protect(GetWorklogs, RequireOrganization))
+	mux.Handle("POST /api/add-worklog", protect(AddWorklog, RequireOrganization, RequireWrite))
+	mux.Handle("PUT /api/update-worklog", protect(UpdateWorklog, RequireOrganization, RequireWrite))
+	mux.Handle("DELETE /api/delete-worklog", protect(DeleteWorklog, RequireOrganization, RequireWrite))
+	mux.Handle("POST /api/time-report", protect(GetTimeReport, RequireOrganization))
 
 	c := cors.New(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:3030"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "X-CSRF-Token", "application/x-www-form-urlencoded"},
+		AllowedHeaders:   []string{"Content-Type", "X-CSRF-Token", "Authorization", "application/x-www-form-urlencoded"},
 		AllowCredentials: true,
 	})
 
-	handler := c.Handler(mux)
+	handler := Chain(mux, c.Handler, Authenticate)
 
 	server := &http.Server{
 		Addr:    fmt.Sprintf(":%s", config.Port),
 		Handler: handler,
 	}
 
+	scheduler, stopScheduler := context.WithCancel(context.Background())
+	defer stopScheduler()
+	go runRecurrenceScheduler(scheduler, time.Duration(taskConfig.RecurrenceInterval)*time.Second)
+
 	// Channel to listen for interrupt signals
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT) // Added SIGQUIT for Ctrl+Q
@@ -81,6 +290,7 @@ func Serve(config *config.ServerConfig) error {
 	// Wait for interrupt signal
 	<-quit
 	log.Println("shutting down server...")
+	stopScheduler()
 
 	// Create a context with timeout for graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -0,0 +1,214 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/repository"
+	"github.com/aminasadiam/DevTasks/internal/utils"
+)
+
+const (
+	sessionDuration      = 7 * 24 * time.Hour
+	sessionTouchInterval = time.Minute
+)
+
+var sessionRepository repository.SessionRepository
+
+// startSession creates a new session for user, sets the session and CSRF
+// cookies and returns the CSRF token that the client must echo back.
+func startSession(w http.ResponseWriter, r *http.Request, user *models.User) (string, error) {
+	sessionToken := utils.GenerateToken(32)
+	csrfToken := utils.GenerateToken(32)
+	now := time.Now()
+
+	deviceName := strings.TrimSpace(r.FormValue("device_name"))
+	if deviceName == "" {
+		deviceName = "Unknown device"
+	}
+
+	session := models.Session{
+		UserId:     user.ID,
+		TokenHash:  utils.HashToken(sessionToken),
+		CSRFToken:  csrfToken,
+		DeviceName: deviceName,
+		IP:         clientIP(r),
+		UserAgent:  r.UserAgent(),
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(sessionDuration),
+	}
+
+	if err := sessionRepository.Create(&session); err != nil {
+		return "", err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session_token",
+		Value:    sessionToken,
+		Expires:  session.ExpiresAt,
+		HttpOnly: false,
+		Path:     "/",
+		SameSite: http.SameSiteLaxMode,
+		Secure:   false, // Set to true in production with HTTPS
+	})
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "csrf_token",
+		Value:    csrfToken,
+		Expires:  session.ExpiresAt,
+		HttpOnly: false,
+		Path:     "/",
+		SameSite: http.SameSiteLaxMode,
+		Secure:   false, // Set to true in production with HTTPS
+	})
+
+	return csrfToken, nil
+}
+
+func clearSessionCookies(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session_token",
+		Value:    "",
+		Expires:  time.Now().Add(-time.Hour),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "csrf_token",
+		Value:    "",
+		Expires:  time.Now().Add(-time.Hour),
+		HttpOnly: false,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// authorizeSession resolves the session referenced by the session cookie and,
+// when checkCSRF is set, checks the CSRF header against it. It is used by
+// Authenticate and AllowCookieSession.
+func authorizeSession(r *http.Request, checkCSRF bool) (*models.Session, error) {
+	st, err := r.Cookie("session_token")
+	if err != nil || st.Value == "" {
+		return nil, AuthError
+	}
+
+	session, err := sessionRepository.GetSessionByToken(st.Value)
+	if err != nil {
+		return nil, AuthError
+	}
+
+	if checkCSRF {
+		csrf := r.Header.Get("X-CSRF-Token")
+		if csrf == "" || subtle.ConstantTimeCompare([]byte(csrf), []byte(session.CSRFToken)) != 1 {
+			return nil, AuthError
+		}
+	}
+
+	if time.Since(session.LastSeenAt) > sessionTouchInterval {
+		sessionRepository.TouchSession(session, clientIP(r))
+	}
+
+	return session, nil
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func GetSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	current := CurrentSession(r)
+
+	sessions, err := sessionRepository.GetUserSessions(current.UserId)
+	if err != nil {
+		http.Error(w, "Failed to get sessions", http.StatusInternalServerError)
+		return
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current.ID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(sessions); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func RevokeSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	current := CurrentSession(r)
+
+	sessionId, err := strconv.Atoi(r.FormValue("session_id"))
+	if err != nil || sessionId <= 0 {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := sessionRepository.DeleteSession(uint(sessionId), current.UserId)
+	if err != nil {
+		http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	if uint(sessionId) == current.ID {
+		clearSessionCookies(w)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Session revoked successfully"})
+}
+
+// RevokeAllSessions signs the user out everywhere. With keep_current=true the
+// session making the request survives.
+func RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	current := CurrentSession(r)
+
+	keepCurrent := r.FormValue("keep_current") == "true"
+	var exceptId uint
+	if keepCurrent {
+		exceptId = current.ID
+	}
+
+	if err := sessionRepository.DeleteUserSessions(current.UserId, exceptId); err != nil {
+		http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+
+	if !keepCurrent {
+		clearSessionCookies(w)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Sessions revoked successfully"})
+}
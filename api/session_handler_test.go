@@ -0,0 +1,229 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/database/dbtest"
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/utils"
+)
+
+func testSession(id, userId uint) models.Session {
+	now := time.Now()
+	session := models.Session{
+		UserId:     userId,
+		User:       models.User{Username: "alice"},
+		DeviceName: "Laptop",
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(time.Hour),
+	}
+	session.ID = id
+	session.User.ID = userId
+	return session
+}
+
+func TestStartSessionKeepsOtherSessions(t *testing.T) {
+	fake := testDB(t)
+	user := &models.User{Username: "alice"}
+	user.ID = 1
+
+	var tokens []string
+	for _, device := range []string{"Laptop", "Desktop"} {
+		r := newRequest(http.MethodPost, "/api/login", url.Values{"device_name": {device}})
+		w := httptest.NewRecorder()
+		csrfToken, err := startSession(w, r, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token := cookie(w, "session_token")
+		if token == nil || token.Value == "" || csrfToken == "" {
+			t.Fatalf("login on %s did not set the session cookie and CSRF token", device)
+		}
+		if csrf := cookie(w, "csrf_token"); csrf == nil || csrf.Value != csrfToken {
+			t.Errorf("CSRF cookie = %v, want %q", csrf, csrfToken)
+		}
+		tokens = append(tokens, token.Value)
+	}
+
+	if tokens[0] == tokens[1] {
+		t.Error("both logins got the same session token")
+	}
+	inserts := fake.Find(`INSERT INTO "sessions"`)
+	if len(inserts) != 2 {
+		t.Fatalf("%d sessions created, want 2", len(inserts))
+	}
+	if deletes := fake.Find(`DELETE FROM "sessions"`); len(deletes) > 0 {
+		t.Errorf("logging in deleted sessions: %v", deletes)
+	}
+	for i, insert := range inserts {
+		if !hasArg(insert, utils.HashToken(tokens[i])) {
+			t.Errorf("session %d is not stored by the hash of its token: %v", i, insert.Args)
+		}
+		if hasArg(insert, tokens[i]) {
+			t.Errorf("session %d stores the raw token", i)
+		}
+	}
+}
+
+func TestAuthorizeSession(t *testing.T) {
+	fake := testDB(t)
+	session := testSession(5, 1)
+	session.TokenHash = utils.HashToken("token")
+	session.CSRFToken = "csrf"
+	fake.On(`FROM "sessions"`, func(args []any) (*dbtest.Result, error) {
+		if args[0] != session.TokenHash {
+			return nil, nil
+		}
+		return dbtest.Records(session), nil
+	})
+	fake.Return(`FROM "users"`, dbtest.Records(session.User))
+
+	tests := []struct {
+		name  string
+		token string
+		csrf  string
+		// cookieOnly skips the CSRF check.
+		cookieOnly bool
+		ok         bool
+	}{
+		{"valid", "token", "csrf", false, true},
+		{"no cookie", "", "csrf", false, false},
+		{"unknown token", "other", "csrf", false, false},
+		{"no CSRF header", "token", "", false, false},
+		{"wrong CSRF header", "token", "other", false, false},
+		{"cookie only", "token", "", true, true},
+		{"cookie only, unknown token", "other", "", true, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/validate", nil)
+			if test.token != "" {
+				r.AddCookie(&http.Cookie{Name: "session_token", Value: test.token})
+			}
+			if test.csrf != "" {
+				r.Header.Set("X-CSRF-Token", test.csrf)
+			}
+
+			got, err := authorizeSession(r, !test.cookieOnly)
+			if !test.ok {
+				if !errors.Is(err, AuthError) {
+					t.Errorf("err = %v, want AuthError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ID != session.ID || got.User.Username != "alice" {
+				t.Errorf("session = %+v, want session %d of alice", got, session.ID)
+			}
+		})
+	}
+}
+
+func TestGetSessionsMarksCurrent(t *testing.T) {
+	fake := testDB(t)
+	current, other := testSession(5, 1), testSession(6, 1)
+	fake.Return(`FROM "sessions"`, dbtest.Records(other, current))
+
+	w := serve(http.HandlerFunc(GetSessions), signedIn(newRequest(http.MethodPost, "/api/sessions", nil), &current))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var sessions []struct {
+		ID      uint
+		Current bool
+	}
+	if err := json.NewDecoder(w.Body).Decode(&sessions); err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 || sessions[0].Current || !sessions[1].Current {
+		t.Errorf("sessions = %+v, want only session 5 current", sessions)
+	}
+	if query := fake.Find(`FROM "sessions"`); len(query) != 1 || query[0].Args[0] != int64(1) {
+		t.Errorf("sessions were not listed for user 1: %v", query)
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	tests := []struct {
+		name     string
+		id       string
+		deleted  int64
+		status   int
+		clearing bool
+	}{
+		{"other session", "6", 1, http.StatusOK, false},
+		{"current session", "5", 1, http.StatusOK, true},
+		{"someone else's session", "7", 0, http.StatusNotFound, false},
+		{"invalid ID", "x", 0, http.StatusBadRequest, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := testDB(t)
+			fake.Return(`DELETE FROM "sessions"`, dbtest.Affected(test.deleted))
+			current := testSession(5, 1)
+
+			r := signedIn(newRequest(http.MethodDelete, "/api/revoke-session", url.Values{"session_id": {test.id}}), &current)
+			w := serve(http.HandlerFunc(RevokeSession), r)
+			if w.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+			if cleared := cookie(w, "session_token") != nil; cleared != test.clearing {
+				t.Errorf("cookies cleared = %v, want %v", cleared, test.clearing)
+			}
+			for _, statement := range fake.Find(`DELETE FROM "sessions"`) {
+				if len(statement.Args) != 2 || statement.Args[1] != int64(1) {
+					t.Errorf("delete is not limited to the user's sessions: %v", statement)
+				}
+			}
+		})
+	}
+}
+
+func TestRevokeAllSessions(t *testing.T) {
+	for _, keepCurrent := range []bool{false, true} {
+		fake := testDB(t)
+		current := testSession(5, 1)
+
+		form := url.Values{}
+		if keepCurrent {
+			form.Set("keep_current", "true")
+		}
+		w := serve(http.HandlerFunc(RevokeAllSessions), signedIn(newRequest(http.MethodDelete, "/api/revoke-sessions", form), &current))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", w.Code, w.Body)
+		}
+
+		deletes := fake.Find(`DELETE FROM "sessions"`)
+		if len(deletes) != 1 {
+			t.Fatalf("keep_current=%v: %d deletes, want 1", keepCurrent, len(deletes))
+		}
+		wantExcept := int64(0)
+		if keepCurrent {
+			wantExcept = 5
+		}
+		if args := deletes[0].Args; args[0] != int64(1) || args[1] != wantExcept {
+			t.Errorf("keep_current=%v: deleted with %v, want user 1 except %d", keepCurrent, args, wantExcept)
+		}
+		if cleared := cookie(w, "session_token") != nil; cleared == keepCurrent {
+			t.Errorf("keep_current=%v: cookies cleared = %v", keepCurrent, cleared)
+		}
+	}
+}
+
+// hasArg reports whether value is one of the arguments of statement.
+func hasArg(statement dbtest.Statement, value any) bool {
+	for _, arg := range statement.Args {
+		if arg == value {
+			return true
+		}
+	}
+	return false
+}
@@ -2,26 +2,28 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aminasadiam/DevTasks/internal/models"
 	"github.com/aminasadiam/DevTasks/internal/repository"
+	"gorm.io/gorm"
 )
 
 var taskRepository repository.TaskRepository
 
+// GetTasks lists the tasks of a project, optionally only those with any or
+// all of the labels in label_ids.
 func GetTasks(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	if err := Authorize(r); err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
 	projectIdStr := r.FormValue("project_id")
 	projectId, err := strconv.Atoi(projectIdStr)
 	if err != nil || projectId <= 0 {
@@ -29,7 +31,16 @@ func GetTasks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tasks, err := taskRepository.GetProjectTasks(uint(projectId))
+	if !authorizeProject(w, r, uint(projectId), models.ActionViewTasks) {
+		return
+	}
+
+	var filter repository.TaskFilter
+	if !parseLabelFilter(w, r, &filter) {
+		return
+	}
+
+	tasks, err := tasksFor(r).GetProjectTasks(uint(projectId), filter)
 	if err != nil {
 		http.Error(w, "Failed to get tasks", http.StatusInternalServerError)
 		return
@@ -48,11 +59,6 @@ func AddTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := Authorize(r); err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
 	projectIdStr := r.FormValue("project_id")
 	projectId, err := strconv.Atoi(projectIdStr)
 	if err != nil || projectId <= 0 {
@@ -60,6 +66,10 @@ func AddTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !authorizeProject(w, r, uint(projectId), models.ActionCreateTask) {
+		return
+	}
+
 	title := r.FormValue("title")
 	description := r.FormValue("description")
 
@@ -68,14 +78,59 @@ func AddTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var state *models.WorkflowState
+	if stateIdStr := r.FormValue("state_id"); stateIdStr != "" {
+		stateId, err := strconv.Atoi(stateIdStr)
+		if err != nil || stateId <= 0 {
+			http.Error(w, "Invalid state ID", http.StatusBadRequest)
+			return
+		}
+		state, err = workflowRepository.GetState(uint(projectId), uint(stateId))
+		if err != nil {
+			http.Error(w, "State not found", http.StatusNotFound)
+			return
+		}
+	} else {
+		state, err = workflowRepository.GetInitialState(uint(projectId))
+		if err != nil {
+			http.Error(w, "Project has no initial workflow state", http.StatusConflict)
+			return
+		}
+	}
+
 	task := &models.Task{
 		Title:       title,
 		Description: description,
 		ProjectId:   uint(projectId),
-		AssignedTo:  userRepository.GetUserIdByUsername(r.FormValue("username")),
+		CreatedBy:   CurrentUser(r).ID,
+	}
+	if !parseTaskSchedule(w, r, task) {
+		return
+	}
+
+	if parentIdStr := r.FormValue("parent_id"); parentIdStr != "" {
+		parentId, err := strconv.Atoi(parentIdStr)
+		if err != nil || parentId <= 0 {
+			http.Error(w, "Invalid parent ID", http.StatusBadRequest)
+			return
+		}
+		parent := uint(parentId)
+		task.ParentId = &parent
 	}
 
-	if err := taskRepository.Create(task); err != nil {
+	assigneeIds, ok := parseAssignees(w, r, task.ProjectId)
+	if !ok {
+		return
+	}
+	for _, userId := range assigneeIds {
+		task.Assignees = append(task.Assignees, models.TaskAssignee{UserId: userId})
+	}
+
+	if err := tasksFor(r).Create(task, state, CurrentUser(r).ID); err != nil {
+		if errors.Is(err, repository.ErrTaskParent) || errors.Is(err, repository.ErrTaskTooDeep) {
+			writeHierarchyError(w, err)
+			return
+		}
 		http.Error(w, "Failed to add task", http.StatusInternalServerError)
 		return
 	}
@@ -94,85 +149,146 @@ func GetTaskById(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Handle authorization for both GET and POST
-	var username string
+	var taskIdStr string
 	if r.Method == http.MethodGet {
-		username = r.URL.Query().Get("username")
+		taskIdStr = r.URL.Query().Get("task_id")
 	} else {
-		username = r.FormValue("username")
+		taskIdStr = r.FormValue("task_id")
 	}
 
-	if username == "" {
-		http.Error(w, "Username is required", http.StatusBadRequest)
+	if taskIdStr == "" {
+		http.Error(w, "Task ID is required", http.StatusBadRequest)
 		return
 	}
 
-	// Check if user exists and validate session
-	user, ok := userRepository.GetUserByUsername(username)
-	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	taskId, err := strconv.Atoi(taskIdStr)
+	if err != nil || taskId <= 0 {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
 		return
 	}
 
-	st, err := r.Cookie("session_token")
-	if err != nil || st.Value == "" || st.Value != user.SessionToken {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	task, err := tasksFor(r).GetTaskById(uint(taskId))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get task", http.StatusInternalServerError)
 		return
 	}
 
-	csrf := r.Header.Get("X-CSRF-Token")
-	if csrf == "" || csrf != user.CSRFToken {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	if !authorizeProject(w, r, task.ProjectId, models.ActionViewTasks) {
 		return
 	}
 
-	var taskIdStr string
-	if r.Method == http.MethodGet {
-		taskIdStr = r.URL.Query().Get("task_id")
-	} else {
-		taskIdStr = r.FormValue("task_id")
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(task); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
 	}
+}
 
-	if taskIdStr == "" {
-		http.Error(w, "Task ID is required", http.StatusBadRequest)
+func UpdateTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	taskIdStr := r.FormValue("task_id")
 	taskId, err := strconv.Atoi(taskIdStr)
 	if err != nil || taskId <= 0 {
 		http.Error(w, "Invalid task ID", http.StatusBadRequest)
 		return
 	}
 
-	task, err := taskRepository.GetTaskById(uint(taskId))
+	// Only the fields that are sent change, so a state transition does not
+	// have to repeat the title and description.
+	title := r.FormValue("title")
+	description := r.FormValue("description")
+
+	if (r.Form.Has("title") && title == "") || (r.Form.Has("description") && description == "") {
+		http.Error(w, "Title and description cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	task, err := tasksFor(r).GetTaskById(uint(taskId))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to get task", http.StatusInternalServerError)
 		return
 	}
 
-	if task == nil {
-		http.Error(w, "Task not found", http.StatusNotFound)
+	if !authorizeProject(w, r, task.ProjectId, models.ActionEditTask) {
+		return
+	}
+
+	var from, to *models.WorkflowState
+	if stateIdStr := r.FormValue("state_id"); stateIdStr != "" {
+		stateId, err := strconv.Atoi(stateIdStr)
+		if err != nil || stateId <= 0 {
+			http.Error(w, "Invalid state ID", http.StatusBadRequest)
+			return
+		}
+		if task.StateId == nil || *task.StateId != uint(stateId) {
+			var ok bool
+			if from, to, ok = taskTransition(w, task, uint(stateId)); !ok {
+				return
+			}
+			if to.IsDone && task.CompletedAt == nil && !checkBlockers(w, r, task) {
+				return
+			}
+		}
+	}
+
+	if r.Form.Has("title") {
+		task.Title = title
+	}
+	if r.Form.Has("description") {
+		task.Description = description
+	}
+	if !parseTaskSchedule(w, r, task) {
 		return
 	}
 
+	update := repository.TaskUpdate{From: from, To: to, UserId: CurrentUser(r).ID}
+	if r.Form.Has("assignee_ids") {
+		var ok bool
+		if update.AssigneeIds, ok = parseAssignees(w, r, task.ProjectId); !ok {
+			return
+		}
+		update.SetAssignees = true
+	}
+
+	if err := tasksFor(r).SaveTaskChanges(task, update); err != nil {
+		if errors.Is(err, repository.ErrTaskStateChanged) {
+			http.Error(w, "Task state was changed by someone else", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to update task", http.StatusInternalServerError)
+		return
+	}
+
+	if to != nil && to.IsDone && task.RecurrenceId != nil {
+		materializeOccurrence(*task.RecurrenceId)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(task); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
 }
 
-func UpdateTask(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPut {
+func DeleteTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	if err := Authorize(r); err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
 	taskIdStr := r.FormValue("task_id")
 	taskId, err := strconv.Atoi(taskIdStr)
 	if err != nil || taskId <= 0 {
@@ -180,30 +296,147 @@ func UpdateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	title := r.FormValue("title")
-	description := r.FormValue("description")
+	task, err := tasksFor(r).GetTaskById(uint(taskId))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get task", http.StatusInternalServerError)
+		return
+	}
 
-	if title == "" || description == "" {
-		http.Error(w, "Title and description are required", http.StatusBadRequest)
+	if !authorizeProject(w, r, task.ProjectId, models.ActionDeleteTask) {
 		return
 	}
 
-	task, err := taskRepository.GetTaskById(uint(taskId))
+	subtasks, err := tasksFor(r).CountSubtasks(task.ID)
 	if err != nil {
-		http.Error(w, "Failed to get task", http.StatusInternalServerError)
+		http.Error(w, "Failed to check subtasks", http.StatusInternalServerError)
+		return
+	}
+
+	switch mode := r.FormValue("subtasks"); {
+	case subtasks == 0:
+		err = tasksFor(r).DeleteTask(task.ID)
+	case mode == "cascade":
+		err = tasksFor(r).DeleteTaskTree(task)
+	case mode == "reparent":
+		err = tasksFor(r).DeleteTaskReparent(task)
+	default:
+		http.Error(w, "Task has subtasks; set subtasks to cascade or reparent", http.StatusConflict)
 		return
 	}
+	if err != nil {
+		http.Error(w, "Failed to delete task", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	if task == nil {
+// GetTaskTree returns a task with all of its subtasks, or every task of a
+// project, as a tree with progress rolled up to each parent.
+func GetTaskTree(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var tasks []models.Task
+	if taskIdStr := r.FormValue("task_id"); taskIdStr != "" {
+		taskId, err := strconv.Atoi(taskIdStr)
+		if err != nil || taskId <= 0 {
+			http.Error(w, "Invalid task ID", http.StatusBadRequest)
+			return
+		}
+
+		task, err := tasksFor(r).GetTaskById(uint(taskId))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Task not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to get task", http.StatusInternalServerError)
+			return
+		}
+		if !authorizeProject(w, r, task.ProjectId, models.ActionViewTasks) {
+			return
+		}
+
+		if tasks, err = tasksFor(r).GetSubtree(task.ID); err != nil {
+			http.Error(w, "Failed to get tasks", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		projectId, err := strconv.Atoi(r.FormValue("project_id"))
+		if err != nil || projectId <= 0 {
+			http.Error(w, "Task ID or project ID is required", http.StatusBadRequest)
+			return
+		}
+		if !authorizeProject(w, r, uint(projectId), models.ActionViewTasks) {
+			return
+		}
+
+		if tasks, err = tasksFor(r).GetProjectTasks(uint(projectId), repository.TaskFilter{}); err != nil {
+			http.Error(w, "Failed to get tasks", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	tree := models.BuildTaskTree(tasks)
+	if tree == nil {
+		tree = []*models.TaskNode{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tree); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// MoveTask moves a task and its subtasks below another task of the same
+// project, or to the top level when parent_id is empty.
+func MoveTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskId, err := strconv.Atoi(r.FormValue("task_id"))
+	if err != nil || taskId <= 0 {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	var parentId *uint
+	if parentIdStr := r.FormValue("parent_id"); parentIdStr != "" {
+		id, err := strconv.Atoi(parentIdStr)
+		if err != nil || id <= 0 {
+			http.Error(w, "Invalid parent ID", http.StatusBadRequest)
+			return
+		}
+		parent := uint(id)
+		parentId = &parent
+	}
+
+	task, err := tasksFor(r).GetTaskById(uint(taskId))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
 		http.Error(w, "Task not found", http.StatusNotFound)
 		return
 	}
+	if err != nil {
+		http.Error(w, "Failed to get task", http.StatusInternalServerError)
+		return
+	}
 
-	task.Title = title
-	task.Description = description
+	if !authorizeProject(w, r, task.ProjectId, models.ActionEditTask) {
+		return
+	}
 
-	if err := taskRepository.UpdateTask(task); err != nil {
-		http.Error(w, "Failed to update task", http.StatusInternalServerError)
+	if err := tasksFor(r).MoveTask(task, parentId); err != nil {
+		writeHierarchyError(w, err)
 		return
 	}
 
@@ -215,28 +448,432 @@ func UpdateTask(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func DeleteTask(w http.ResponseWriter, r *http.Request) {
+func writeHierarchyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrTaskParent):
+		http.Error(w, "Parent task not found in this project", http.StatusBadRequest)
+	case errors.Is(err, repository.ErrTaskCycle):
+		http.Error(w, "A task cannot be moved below itself or its subtasks", http.StatusConflict)
+	case errors.Is(err, repository.ErrTaskTooDeep):
+		http.Error(w, fmt.Sprintf("Tasks cannot be nested more than %d levels deep", taskConfig.MaxDepth), http.StatusConflict)
+	default:
+		http.Error(w, "Failed to save task", http.StatusInternalServerError)
+	}
+}
+
+// taskTransition loads the current and the requested state of task and
+// checks that the project's workflow allows moving between them. Tasks
+// without a state may move to any state.
+func taskTransition(w http.ResponseWriter, task *models.Task, stateId uint) (*models.WorkflowState, *models.WorkflowState, bool) {
+	to, err := workflowRepository.GetState(task.ProjectId, stateId)
+	if err != nil {
+		http.Error(w, "State not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	if task.StateId == nil {
+		return nil, to, true
+	}
+
+	from, err := workflowRepository.GetState(task.ProjectId, *task.StateId)
+	if err != nil {
+		http.Error(w, "Failed to get task state", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	allowed, err := workflowRepository.IsTransitionAllowed(from.ID, to.ID)
+	if err != nil {
+		http.Error(w, "Failed to check transition", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	if !allowed {
+		http.Error(w, fmt.Sprintf("Moving a task from %q to %q is not allowed", from.Name, to.Name), http.StatusConflict)
+		return nil, nil, false
+	}
+	return from, to, true
+}
+
+// GetOverdueTasks lists the unfinished tasks whose due date has passed.
+func GetOverdueTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	now := time.Now()
+	completed := false
+	writeAccessibleTasks(w, r, repository.TaskFilter{DueBefore: &now, Completed: &completed})
+}
+
+// GetTasksDueThisWeek lists the unfinished tasks due from today until the
+// end of the week, which ends on Sunday. The optional timezone decides where
+// days begin and defaults to UTC.
+func GetTasksDueThisWeek(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	location, ok := requestLocation(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now().In(location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+	daysLeft := (7 - int(today.Weekday())) % 7
+	weekEnd := today.AddDate(0, 0, daysLeft+1)
+
+	completed := false
+	writeAccessibleTasks(w, r, repository.TaskFilter{DueFrom: &today, DueBefore: &weekEnd, Completed: &completed})
+}
+
+// GetTasksDueBetween lists the tasks due between from and to, both
+// inclusive. Dates without a time cover the whole day. Completed tasks are
+// only included when include_completed is set.
+func GetTasksDueBetween(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	location, ok := requestLocation(w, r)
+	if !ok {
+		return
+	}
+
+	from, _, err := parseTaskDate(r.FormValue("from"), location)
+	if err != nil {
+		http.Error(w, "Invalid from date", http.StatusBadRequest)
+		return
+	}
+	to, toIsDay, err := parseTaskDate(r.FormValue("to"), location)
+	if err != nil {
+		http.Error(w, "Invalid to date", http.StatusBadRequest)
+		return
+	}
+	if toIsDay {
+		to = to.AddDate(0, 0, 1)
+	} else {
+		to = to.Add(time.Nanosecond)
+	}
+	if !to.After(from) {
+		http.Error(w, "from must not be after to", http.StatusBadRequest)
+		return
+	}
+
+	completed := false
+	filter := repository.TaskFilter{DueFrom: &from, DueBefore: &to, Completed: &completed}
+	if includeStr := r.FormValue("include_completed"); includeStr != "" {
+		include, err := strconv.ParseBool(includeStr)
+		if err != nil {
+			http.Error(w, "Invalid value for include_completed", http.StatusBadRequest)
+			return
+		}
+		if include {
+			filter.Completed = nil
+		}
+	}
+
+	writeAccessibleTasks(w, r, filter)
+}
+
+// GetMyTasks lists the tasks assigned to the current user across all
+// projects of the organization. It can be narrowed to a project, a
+// workflow state, and a status of "open" (the default), "done" or "all".
+func GetMyTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var filter repository.TaskFilter
+	if projectIdStr := r.FormValue("project_id"); projectIdStr != "" {
+		projectId, err := strconv.Atoi(projectIdStr)
+		if err != nil || projectId <= 0 {
+			http.Error(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+		filter.ProjectId = uint(projectId)
+	}
+	if stateIdStr := r.FormValue("state_id"); stateIdStr != "" {
+		stateId, err := strconv.Atoi(stateIdStr)
+		if err != nil || stateId <= 0 {
+			http.Error(w, "Invalid state ID", http.StatusBadRequest)
+			return
+		}
+		filter.StateId = uint(stateId)
+	}
+
+	switch r.FormValue("status") {
+	case "", "open":
+		completed := false
+		filter.Completed = &completed
+	case "done":
+		completed := true
+		filter.Completed = &completed
+	case "all":
+	default:
+		http.Error(w, "Status must be open, done or all", http.StatusBadRequest)
+		return
+	}
+
+	filter.AssigneeId = CurrentUser(r).ID
+	writeAccessibleTasks(w, r, filter)
+}
+
+// AssignTask adds an assignee to a task.
+func AssignTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	task, userId, ok := parseTaskAssignee(w, r)
+	if !ok {
+		return
+	}
+
+	if !isAssignable(w, task.ProjectId, userId) {
+		return
+	}
+
+	added, err := tasksFor(r).AddAssignee(task, userId, CurrentUser(r).ID)
+	if err != nil {
+		http.Error(w, "Failed to assign task", http.StatusInternalServerError)
+		return
+	}
+	if !added {
+		http.Error(w, "User is already assigned to this task", http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Task assigned successfully"})
+}
+
+// UnassignTask removes an assignee from a task.
+func UnassignTask(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	if err := Authorize(r); err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	task, userId, ok := parseTaskAssignee(w, r)
+	if !ok {
 		return
 	}
 
-	taskIdStr := r.FormValue("task_id")
-	taskId, err := strconv.Atoi(taskIdStr)
+	removed, err := tasksFor(r).RemoveAssignee(task, userId)
+	if err != nil {
+		http.Error(w, "Failed to unassign task", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "User is not assigned to this task", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Task unassigned successfully"})
+}
+
+// parseTaskAssignee loads the task and user of an assignment request and
+// checks that the current user may edit the task.
+func parseTaskAssignee(w http.ResponseWriter, r *http.Request) (*models.Task, uint, bool) {
+	taskId, err := strconv.Atoi(r.FormValue("task_id"))
 	if err != nil || taskId <= 0 {
 		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return nil, 0, false
+	}
+
+	userId, err := strconv.Atoi(r.FormValue("user_id"))
+	if err != nil || userId <= 0 {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return nil, 0, false
+	}
+
+	task, err := tasksFor(r).GetTaskById(uint(taskId))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return nil, 0, false
+	}
+	if err != nil {
+		http.Error(w, "Failed to get task", http.StatusInternalServerError)
+		return nil, 0, false
+	}
+
+	if !authorizeProject(w, r, task.ProjectId, models.ActionEditTask) {
+		return nil, 0, false
+	}
+	return task, uint(userId), true
+}
+
+// parseAssignees reads the assignee_ids of the request, given either as
+// repeated fields or comma separated, and checks that every user can be
+// assigned to tasks of projectId.
+func parseAssignees(w http.ResponseWriter, r *http.Request, projectId uint) ([]uint, bool) {
+	var ids []uint
+	seen := make(map[uint]bool)
+	for _, value := range r.Form["assignee_ids"] {
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			id, err := strconv.Atoi(part)
+			if err != nil || id <= 0 {
+				http.Error(w, "Invalid assignee ID", http.StatusBadRequest)
+				return nil, false
+			}
+			if seen[uint(id)] {
+				continue
+			}
+			seen[uint(id)] = true
+			if !isAssignable(w, projectId, uint(id)) {
+				return nil, false
+			}
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids, true
+}
+
+// isAssignable reports whether userId is an active user with a role in
+// projectId, directly or through a team.
+func isAssignable(w http.ResponseWriter, projectId, userId uint) bool {
+	user, err := userRepository.GetUserById(userId)
+	if err != nil || !user.IsActive() {
+		http.Error(w, "Assignee not found", http.StatusNotFound)
+		return false
+	}
+
+	role, err := projectMemberRepository.GetEffectiveRole(projectId, userId)
+	if err != nil {
+		http.Error(w, "Failed to check membership", http.StatusInternalServerError)
+		return false
+	}
+	if role == "" {
+		http.Error(w, fmt.Sprintf("%s is not a member of this project", user.Username), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeAccessibleTasks(w http.ResponseWriter, r *http.Request, filter repository.TaskFilter) {
+	tasks, err := tasksFor(r).GetAccessibleTasks(CurrentUser(r).ID, filter)
+	if err != nil {
+		http.Error(w, "Failed to get tasks", http.StatusInternalServerError)
 		return
 	}
 
-	if err := taskRepository.DeleteTask(uint(taskId)); err != nil {
-		http.Error(w, "Failed to delete task", http.StatusInternalServerError)
+	visible := tasks[:0]
+	for _, task := range tasks {
+		if tokenAllowsProject(r, task.ProjectId) {
+			visible = append(visible, task)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(visible); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
+}
+
+// parseTaskSchedule applies the optional priority, start_date and due_date
+// fields of the request to task. Sending an empty date clears it.
+func parseTaskSchedule(w http.ResponseWriter, r *http.Request, task *models.Task) bool {
+	if r.Form.Has("priority") {
+		priority, ok := models.ParsePriority(r.FormValue("priority"))
+		if !ok {
+			http.Error(w, "Invalid priority", http.StatusBadRequest)
+			return false
+		}
+		task.Priority = priority
+	}
+
+	dates := []struct {
+		field  string
+		target **time.Time
+	}{
+		{"start_date", &task.StartDate},
+		{"due_date", &task.DueDate},
+	}
+	for _, date := range dates {
+		if !r.Form.Has(date.field) {
+			continue
+		}
+		value := r.FormValue(date.field)
+		if value == "" {
+			*date.target = nil
+			continue
+		}
+		parsed, _, err := parseTaskDate(value, time.UTC)
+		if err != nil {
+			http.Error(w, "Invalid "+strings.ReplaceAll(date.field, "_", " "), http.StatusBadRequest)
+			return false
+		}
+		*date.target = &parsed
+	}
+
+	if task.StartDate != nil && task.DueDate != nil && task.DueDate.Before(*task.StartDate) {
+		http.Error(w, "Due date cannot be before start date", http.StatusBadRequest)
+		return false
+	}
+
+	estimates := []struct {
+		field  string
+		target **int
+	}{
+		{"original_estimate", &task.OriginalEstimate},
+		{"remaining_estimate", &task.RemainingEstimate},
+	}
+	for _, estimate := range estimates {
+		if !r.Form.Has(estimate.field) {
+			continue
+		}
+		value := r.FormValue(estimate.field)
+		if value == "" {
+			*estimate.target = nil
+			continue
+		}
+		duration, err := parseWorkDuration(value)
+		if err != nil {
+			http.Error(w, "Invalid "+strings.ReplaceAll(estimate.field, "_", " "), http.StatusBadRequest)
+			return false
+		}
+		minutes := int(duration / time.Minute)
+		*estimate.target = &minutes
+	}
+	// A new estimate starts out fully remaining.
+	if r.Form.Has("original_estimate") && !r.Form.Has("remaining_estimate") && task.RemainingEstimate == nil && task.OriginalEstimate != nil {
+		remaining := *task.OriginalEstimate
+		task.RemainingEstimate = &remaining
+	}
+	return true
+}
+
+// parseTaskDate accepts an RFC 3339 timestamp or a plain date, which is
+// read as midnight in location. It reports whether value was a plain date.
+func parseTaskDate(value string, location *time.Location) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, location); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
 
-	w.WriteHeader(http.StatusNoContent)
+func requestLocation(w http.ResponseWriter, r *http.Request) (*time.Location, bool) {
+	name := r.FormValue("timezone")
+	if name == "" {
+		return time.UTC, true
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		http.Error(w, "Invalid timezone", http.StatusBadRequest)
+		return nil, false
+	}
+	return location, true
 }
@@ -0,0 +1,603 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/config"
+	"github.com/aminasadiam/DevTasks/internal/database/dbtest"
+	"github.com/aminasadiam/DevTasks/internal/models"
+)
+
+func TestTaskLookupErrors(t *testing.T) {
+	handlers := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+	}{
+		{"GetTaskById", GetTaskById, http.MethodPost},
+		{"UpdateTask", UpdateTask, http.MethodPut},
+		{"DeleteTask", DeleteTask, http.MethodDelete},
+		{"GetTaskTree", GetTaskTree, http.MethodPost},
+		{"MoveTask", MoveTask, http.MethodPost},
+		{"AssignTask", AssignTask, http.MethodPost},
+		{"UnassignTask", UnassignTask, http.MethodDelete},
+	}
+	for _, h := range handlers {
+		t.Run(h.name, func(t *testing.T) {
+			form := url.Values{"task_id": {"3"}, "user_id": {"2"}, "title": {"Write tests"}, "description": {"For every handler"}}
+
+			// Tasks of other organizations are simply not found.
+			fake := testDB(t)
+			projectFixture(fake, models.ProjectRoleOwner)
+			w := serve(h.handler, inOrganization(newRequest(h.method, "/api/task", form), models.OrgRoleMember))
+			if w.Code != http.StatusNotFound {
+				t.Errorf("missing task: status = %d, want 404: %s", w.Code, w.Body)
+			}
+
+			fake = testDB(t)
+			projectFixture(fake, models.ProjectRoleOwner)
+			fake.Fail(`FROM "tasks"`, errors.New("connection reset"))
+			w = serve(h.handler, inOrganization(newRequest(h.method, "/api/task", form), models.OrgRoleMember))
+			if w.Code != http.StatusInternalServerError {
+				t.Errorf("database failure: status = %d, want 500: %s", w.Code, w.Body)
+			}
+		})
+	}
+}
+
+func TestUpdateTaskWorkflow(t *testing.T) {
+	// Project 7 moves tasks from Todo to Done; Review cannot be reached.
+	states := map[int64]models.WorkflowState{
+		11: {ProjectId: 7, Name: "Todo", IsInitial: true},
+		12: {ProjectId: 7, Name: "Done", IsDone: true},
+		13: {ProjectId: 7, Name: "Review"},
+	}
+
+	tests := []struct {
+		name       string
+		form       url.Values
+		status     int
+		title      string
+		transition bool
+	}{
+		{"state alone", url.Values{"state_id": {"12"}}, http.StatusOK, "Write tests", true},
+		{"title alone", url.Values{"title": {"Write more tests"}}, http.StatusOK, "Write more tests", false},
+		{"same state", url.Values{"state_id": {"11"}, "title": {"Write more tests"}}, http.StatusOK, "Write more tests", false},
+		{"illegal transition", url.Values{"state_id": {"13"}}, http.StatusConflict, "", false},
+		{"unknown state", url.Values{"state_id": {"14"}}, http.StatusNotFound, "", false},
+		{"empty title", url.Values{"title": {""}, "state_id": {"12"}}, http.StatusBadRequest, "", false},
+		{"empty description", url.Values{"description": {""}}, http.StatusBadRequest, "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := testDB(t)
+			projectFixture(fake, models.ProjectRoleContributor)
+			task := testTask()
+			todo := uint(11)
+			task.StateId = &todo
+			fake.Return(`FROM "tasks"`, dbtest.Records(task))
+			fake.Return(`SELECT count(*) FROM "tasks"`, dbtest.Rows("count").Row(0))
+			fake.Return(`UPDATE "tasks"`, dbtest.Affected(1))
+			fake.On(`FROM "workflow_states"`, func(args []any) (*dbtest.Result, error) {
+				for _, arg := range args {
+					if state, ok := states[arg.(int64)]; ok {
+						state.ID = uint(arg.(int64))
+						return dbtest.Records(state), nil
+					}
+				}
+				return nil, nil
+			})
+			fake.On(`FROM "workflow_transitions"`, func(args []any) (*dbtest.Result, error) {
+				allowed := 0
+				if args[0] == int64(11) && args[1] == int64(12) {
+					allowed = 1
+				}
+				return dbtest.Rows("count").Row(allowed), nil
+			})
+
+			test.form.Set("task_id", "3")
+			w := serve(http.HandlerFunc(UpdateTask), inOrganization(newRequest(http.MethodPut, "/api/update-task", test.form), models.OrgRoleMember))
+			if w.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+
+			transitions := fake.Find(`INSERT INTO "task_transitions"`)
+			if test.status != http.StatusOK {
+				if saves := fake.Find(`UPDATE "tasks"`); len(saves) != 0 || len(transitions) != 0 {
+					t.Errorf("rejected update was saved: %v %v", saves, transitions)
+				}
+				return
+			}
+
+			var got models.Task
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Title != test.title || got.Description != "For every handler" {
+				t.Errorf("task = %q, %q, want %q, %q", got.Title, got.Description, test.title, "For every handler")
+			}
+			if (len(transitions) == 1) != test.transition {
+				t.Errorf("transitions recorded = %v, want one: %v", transitions, test.transition)
+			}
+			if test.transition && (got.StateId == nil || *got.StateId != 12 || got.CompletedAt == nil) {
+				t.Errorf("state = %v, completed at %v, want Done and completed", got.StateId, got.CompletedAt)
+			}
+		})
+	}
+}
+
+// dueWindow returns the bounds of the due date filter in the task query
+// recorded by fake.
+func dueWindow(t *testing.T, fake *dbtest.DB) (from, before time.Time) {
+	t.Helper()
+	queries := fake.Find(`tasks.due_date >= $`)
+	if len(queries) != 1 {
+		t.Fatalf("task queries = %v, want one filtered by due date", queries)
+	}
+	var bounds []time.Time
+	for _, arg := range queries[0].Args {
+		if bound, ok := arg.(time.Time); ok {
+			bounds = append(bounds, bound)
+		}
+	}
+	if len(bounds) != 2 {
+		t.Fatalf("due date bounds = %v, want two", bounds)
+	}
+	return bounds[0], bounds[1]
+}
+
+func TestGetTasksDueBetween(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		form         url.Values
+		status       int
+		from, before time.Time
+		completed    bool
+	}{
+		{"whole days", url.Values{"from": {"2025-03-01"}, "to": {"2025-03-31"}}, http.StatusOK,
+			time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), false},
+		{"days in a timezone", url.Values{"from": {"2025-03-01"}, "to": {"2025-03-01"}, "timezone": {"Asia/Tokyo"}}, http.StatusOK,
+			time.Date(2025, 3, 1, 0, 0, 0, 0, tokyo), time.Date(2025, 3, 2, 0, 0, 0, 0, tokyo), false},
+		{"timestamps", url.Values{"from": {"2025-03-01T08:00:00Z"}, "to": {"2025-03-01T17:00:00Z"}}, http.StatusOK,
+			time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC), time.Date(2025, 3, 1, 17, 0, 0, 1, time.UTC), false},
+		{"including completed", url.Values{"from": {"2025-03-01"}, "to": {"2025-03-01"}, "include_completed": {"true"}}, http.StatusOK,
+			time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC), true},
+		{"same instant", url.Values{"from": {"2025-03-01T08:00:00Z"}, "to": {"2025-03-01T08:00:00Z"}}, http.StatusOK,
+			time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC), time.Date(2025, 3, 1, 8, 0, 0, 1, time.UTC), false},
+		{"reversed", url.Values{"from": {"2025-03-02"}, "to": {"2025-03-01"}}, http.StatusBadRequest, time.Time{}, time.Time{}, false},
+		{"missing to", url.Values{"from": {"2025-03-01"}}, http.StatusBadRequest, time.Time{}, time.Time{}, false},
+		{"bad date", url.Values{"from": {"03/01/2025"}, "to": {"2025-03-01"}}, http.StatusBadRequest, time.Time{}, time.Time{}, false},
+		{"bad timezone", url.Values{"from": {"2025-03-01"}, "to": {"2025-03-01"}, "timezone": {"Mars/Olympus"}}, http.StatusBadRequest, time.Time{}, time.Time{}, false},
+		{"bad include_completed", url.Values{"from": {"2025-03-01"}, "to": {"2025-03-01"}, "include_completed": {"maybe"}}, http.StatusBadRequest, time.Time{}, time.Time{}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := testDB(t)
+			w := serve(http.HandlerFunc(GetTasksDueBetween), inOrganization(newRequest(http.MethodPost, "/api/tasks/due-between", test.form), models.OrgRoleMember))
+			if w.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+			if test.status != http.StatusOK {
+				return
+			}
+
+			from, before := dueWindow(t, fake)
+			if !from.Equal(test.from) || !before.Equal(test.before) {
+				t.Errorf("window = [%v, %v), want [%v, %v)", from, before, test.from, test.before)
+			}
+			query := fake.Find(`tasks.due_date >= $`)[0].Query
+			if completed := !strings.Contains(query, "completed_at IS NULL"); completed != test.completed {
+				t.Errorf("completed tasks included: %v, want %v: %s", completed, test.completed, query)
+			}
+			if !strings.Contains(query, "ORDER BY tasks.priority DESC") {
+				t.Errorf("tasks not sorted by priority: %s", query)
+			}
+		})
+	}
+}
+
+func TestGetTasksDueThisWeek(t *testing.T) {
+	for _, timezone := range []string{"", "Asia/Tokyo", "America/New_York"} {
+		t.Run("timezone "+timezone, func(t *testing.T) {
+			location := time.UTC
+			if timezone != "" {
+				var err error
+				if location, err = time.LoadLocation(timezone); err != nil {
+					t.Fatal(err)
+				}
+			}
+			fake := testDB(t)
+			form := url.Values{"timezone": {timezone}}
+			w := serve(http.HandlerFunc(GetTasksDueThisWeek), inOrganization(newRequest(http.MethodPost, "/api/tasks/due-this-week", form), models.OrgRoleMember))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+			}
+
+			// The week runs from the start of today until Monday begins.
+			from, before := dueWindow(t, fake)
+			now := time.Now().In(location)
+			today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+			if !from.Equal(today) {
+				t.Errorf("window starts %v, want %v", from, today)
+			}
+			before = before.In(location)
+			if before.Weekday() != time.Monday || before.Hour() != 0 || !before.After(now) || before.After(today.AddDate(0, 0, 7)) {
+				t.Errorf("window ends %v, want the next Monday midnight", before)
+			}
+		})
+	}
+}
+
+func TestParseTaskSchedule(t *testing.T) {
+	due := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	moved := due.AddDate(0, 0, 2)
+	tests := []struct {
+		name     string
+		form     url.Values
+		ok       bool
+		priority int
+		due      *time.Time
+	}{
+		{"nothing sent", url.Values{}, true, models.PriorityLow, &due},
+		{"priority by name", url.Values{"priority": {"urgent"}}, true, models.PriorityUrgent, &due},
+		{"priority by number", url.Values{"priority": {"3"}}, true, models.PriorityHigh, &due},
+		{"unknown priority", url.Values{"priority": {"9"}}, false, 0, nil},
+		{"new due date", url.Values{"due_date": {"2025-03-12"}}, true, models.PriorityLow, &moved},
+		{"cleared due date", url.Values{"due_date": {""}}, true, models.PriorityLow, nil},
+		{"due before start", url.Values{"start_date": {"2025-03-11"}}, false, 0, nil},
+		{"bad due date", url.Values{"due_date": {"tomorrow"}}, false, 0, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			task := testTask()
+			task.Priority = models.PriorityLow
+			task.DueDate = &due
+
+			r := newRequest(http.MethodPut, "/api/update-task", test.form)
+			r.ParseForm()
+			w := httptest.NewRecorder()
+			if ok := parseTaskSchedule(w, r, &task); ok != test.ok {
+				t.Fatalf("ok = %v, want %v: %s", ok, test.ok, w.Body)
+			}
+			if !test.ok {
+				if w.Code != http.StatusBadRequest {
+					t.Errorf("status = %d, want 400", w.Code)
+				}
+				return
+			}
+			if task.Priority != test.priority {
+				t.Errorf("priority = %d, want %d", task.Priority, test.priority)
+			}
+			if (task.DueDate == nil) != (test.due == nil) || (task.DueDate != nil && !task.DueDate.Equal(*test.due)) {
+				t.Errorf("due date = %v, want %v", task.DueDate, test.due)
+			}
+		})
+	}
+}
+
+// assigneeFixture makes user 1 the owner of project 7 and user 2 a viewer.
+// User 3 has a role through a team, user 4 none at all, and user 5 is
+// deactivated.
+func assigneeFixture(fake *dbtest.DB) {
+	projectFixture(fake, "")
+	fake.Return(`FROM "tasks"`, dbtest.Records(testTask()))
+	fake.Return(`SELECT count(*) FROM "tasks"`, dbtest.Rows("count").Row(0))
+	fake.On(`FROM "users"`, func(args []any) (*dbtest.Result, error) {
+		id, _ := args[0].(int64)
+		if id < 1 || id > 5 {
+			return nil, nil
+		}
+		user := models.User{Username: fmt.Sprintf("user%d", id)}
+		user.ID = uint(id)
+		if id == 5 {
+			deactivated := time.Now()
+			user.DeactivatedAt = &deactivated
+		}
+		return dbtest.Records(user), nil
+	})
+	roles := map[int64]string{1: models.ProjectRoleOwner, 2: models.ProjectRoleViewer, 5: models.ProjectRoleContributor}
+	fake.On(`FROM "project_members" JOIN projects`, func(args []any) (*dbtest.Result, error) {
+		role, ok := roles[args[1].(int64)]
+		if !ok {
+			return nil, nil
+		}
+		return dbtest.Records(models.ProjectMember{ProjectId: 7, UserId: uint(args[1].(int64)), Role: role}), nil
+	})
+	fake.On(`FROM "project_team_grants"`, func(args []any) (*dbtest.Result, error) {
+		if !slices.Contains(args, any(int64(3))) {
+			return nil, nil
+		}
+		return dbtest.Rows("source", "role", "team_id", "team_name").Row(models.AccessSourceTeam, models.ProjectRoleContributor, 1, "Backend"), nil
+	})
+}
+
+func TestAssignTask(t *testing.T) {
+	tests := []struct {
+		name     string
+		userId   string
+		assigned bool
+		status   int
+	}{
+		{"direct member", "2", false, http.StatusOK},
+		{"team member", "3", false, http.StatusOK},
+		{"already assigned", "2", true, http.StatusConflict},
+		{"not a member", "4", false, http.StatusBadRequest},
+		{"deactivated", "5", false, http.StatusNotFound},
+		{"unknown user", "6", false, http.StatusNotFound},
+		{"invalid user", "me", false, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := testDB(t)
+			assigneeFixture(fake)
+			affected := int64(1)
+			if test.assigned {
+				affected = 0
+			}
+			fake.Return(`INSERT INTO "task_assignees"`, dbtest.Affected(affected))
+
+			form := url.Values{"task_id": {"3"}, "user_id": {test.userId}}
+			w := serve(http.HandlerFunc(AssignTask), inOrganization(newRequest(http.MethodPost, "/api/assign-task", form), models.OrgRoleMember))
+			if w.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+
+			inserts := fake.Find(`INSERT INTO "task_assignees"`)
+			if wantInsert := test.status == http.StatusOK || test.assigned; (len(inserts) == 1) != wantInsert {
+				t.Fatalf("inserts = %v, want one: %v", inserts, wantInsert)
+			}
+			userId, _ := strconv.ParseInt(test.userId, 10, 64)
+			if len(inserts) == 1 && (inserts[0].Args[0] != int64(3) || inserts[0].Args[1] != userId || inserts[0].Args[2] != int64(1)) {
+				t.Errorf("insert args = %v, want task 3, user %s, assigned by 1", inserts[0].Args, test.userId)
+			}
+		})
+	}
+}
+
+func TestUnassignTask(t *testing.T) {
+	for _, assigned := range []bool{true, false} {
+		fake := testDB(t)
+		assigneeFixture(fake)
+		affected := int64(0)
+		if assigned {
+			affected = 1
+		}
+		fake.Return(`DELETE FROM "task_assignees"`, dbtest.Affected(affected))
+
+		want := http.StatusNotFound
+		if assigned {
+			want = http.StatusOK
+		}
+		form := url.Values{"task_id": {"3"}, "user_id": {"4"}}
+		w := serve(http.HandlerFunc(UnassignTask), inOrganization(newRequest(http.MethodDelete, "/api/unassign-task", form), models.OrgRoleMember))
+		if w.Code != want {
+			t.Errorf("assigned %v: status = %d, want %d: %s", assigned, w.Code, want, w.Body)
+		}
+		// Former members can still be taken off a task.
+		deletes := fake.Find(`DELETE FROM "task_assignees"`)
+		if len(deletes) != 1 || !hasArg(deletes[0], int64(3)) || !hasArg(deletes[0], int64(4)) {
+			t.Errorf("deletes = %v, want one for task 3 and user 4", deletes)
+		}
+	}
+}
+
+func TestUpdateTaskAssignees(t *testing.T) {
+	tests := []struct {
+		name   string
+		form   url.Values
+		status int
+		set    []int64
+	}{
+		{"comma separated", url.Values{"assignee_ids": {"2, 3"}}, http.StatusOK, []int64{2, 3}},
+		{"repeated with duplicates", url.Values{"assignee_ids": {"3", "2,3"}}, http.StatusOK, []int64{3, 2}},
+		{"cleared", url.Values{"assignee_ids": {""}}, http.StatusOK, nil},
+		{"not a member", url.Values{"assignee_ids": {"2,4"}}, http.StatusBadRequest, nil},
+		{"invalid", url.Values{"assignee_ids": {"2,x"}}, http.StatusBadRequest, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := testDB(t)
+			assigneeFixture(fake)
+			fake.Return(`UPDATE "tasks"`, dbtest.Affected(1))
+
+			test.form.Set("task_id", "3")
+			w := serve(http.HandlerFunc(UpdateTask), inOrganization(newRequest(http.MethodPut, "/api/update-task", test.form), models.OrgRoleMember))
+			if w.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+
+			removals := fake.Find(`DELETE FROM "task_assignees"`)
+			if test.status != http.StatusOK {
+				if len(removals) != 0 {
+					t.Errorf("assignees changed by a rejected update: %v", removals)
+				}
+				return
+			}
+			if len(removals) != 1 {
+				t.Fatalf("removals = %v, want one", removals)
+			}
+			var added []int64
+			for _, insert := range fake.Find(`INSERT INTO "task_assignees"`) {
+				added = append(added, insert.Args[1].(int64))
+			}
+			if !slices.Equal(added, test.set) {
+				t.Errorf("assigned %v, want %v", added, test.set)
+			}
+			for _, id := range test.set {
+				if !hasArg(removals[0], id) {
+					t.Errorf("removal %v drops user %d, who stays assigned", removals[0], id)
+				}
+			}
+		})
+	}
+}
+
+func TestGetMyTasks(t *testing.T) {
+	tests := []struct {
+		form      url.Values
+		status    int
+		fragments []string
+	}{
+		{url.Values{}, http.StatusOK, []string{"completed_at IS NULL"}},
+		{url.Values{"status": {"done"}}, http.StatusOK, []string{"completed_at IS NOT NULL"}},
+		{url.Values{"status": {"all"}, "project_id": {"7"}, "state_id": {"11"}}, http.StatusOK, []string{"tasks.project_id = $", "tasks.state_id = $"}},
+		{url.Values{"status": {"closed"}}, http.StatusBadRequest, nil},
+		{url.Values{"project_id": {"x"}}, http.StatusBadRequest, nil},
+	}
+	for _, test := range tests {
+		fake := testDB(t)
+		w := serve(http.HandlerFunc(GetMyTasks), inOrganization(newRequest(http.MethodPost, "/api/my-tasks", test.form), models.OrgRoleMember))
+		if w.Code != test.status {
+			t.Errorf("%v: status = %d, want %d: %s", test.form, w.Code, test.status, w.Body)
+			continue
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+
+		queries := fake.Find(`SELECT * FROM "tasks"`)
+		if len(queries) != 1 {
+			t.Fatalf("%v: task queries = %v, want one", test.form, queries)
+		}
+		fragments := append(test.fragments, "a.user_id = $", "project_members")
+		for _, fragment := range fragments {
+			if !strings.Contains(queries[0].Query, fragment) {
+				t.Errorf("%v: query lacks %q: %s", test.form, fragment, queries[0].Query)
+			}
+		}
+		if test.form.Get("status") == "all" && strings.Contains(queries[0].Query, "completed_at") {
+			t.Errorf("status all still filters on completion: %s", queries[0].Query)
+		}
+	}
+}
+
+func TestDeleteTaskWithSubtasks(t *testing.T) {
+	tests := []struct {
+		subtasks int
+		mode     string
+		status   int
+		deletes  string
+	}{
+		{0, "", http.StatusNoContent, `UPDATE "tasks" SET "deleted_at"`},
+		{2, "", http.StatusConflict, ""},
+		{2, "orphan", http.StatusConflict, ""},
+		{2, "cascade", http.StatusNoContent, `WITH RECURSIVE subtree`},
+		{2, "reparent", http.StatusNoContent, `UPDATE "tasks" SET "parent_id"`},
+	}
+	for _, test := range tests {
+		fake := testDB(t)
+		projectFixture(fake, models.ProjectRoleMaintainer)
+		fake.Return(`FROM "tasks"`, dbtest.Records(testTask()))
+		fake.Return(`SELECT count(*) FROM "tasks"`, dbtest.Rows("count").Row(test.subtasks))
+
+		form := url.Values{"task_id": {"3"}, "subtasks": {test.mode}}
+		w := serve(http.HandlerFunc(DeleteTask), inOrganization(newRequest(http.MethodDelete, "/api/delete-task", form), models.OrgRoleMember))
+		if w.Code != test.status {
+			t.Errorf("%d subtasks, mode %q: status = %d, want %d: %s", test.subtasks, test.mode, w.Code, test.status, w.Body)
+			continue
+		}
+		if test.deletes == "" {
+			if deletes := fake.Find(`UPDATE "tasks"`); len(deletes) != 0 {
+				t.Errorf("%d subtasks, mode %q: deleted without a choice: %v", test.subtasks, test.mode, deletes)
+			}
+		} else if len(fake.Find(test.deletes)) == 0 {
+			t.Errorf("%d subtasks, mode %q: no %s", test.subtasks, test.mode, test.deletes)
+		}
+	}
+}
+
+func TestMoveTaskErrors(t *testing.T) {
+	taskConfig = &config.TaskConfig{MaxDepth: 3}
+	tests := []struct {
+		name   string
+		parent string
+		status int
+	}{
+		{"to the top level", "", http.StatusOK},
+		{"below a sibling", "4", http.StatusOK},
+		{"below itself", "3", http.StatusConflict},
+		{"below its subtask", "5", http.StatusConflict},
+		{"deeper than allowed", "6", http.StatusConflict},
+		{"into another project", "8", http.StatusBadRequest},
+		{"invalid parent", "x", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Task 3 has the subtask 5, task 4 the subtask 6.
+			fake := testDB(t)
+			taskRepository.SetMaxDepth(taskConfig.MaxDepth)
+			projectFixture(fake, models.ProjectRoleContributor)
+			fake.Return(`FROM "tasks"`, dbtest.Records(testTask()))
+			fake.Return(`WITH RECURSIVE subtree`, dbtest.Rows("id", "level").Row(3, 1).Row(5, 2))
+			fake.On(`SELECT count(*) FROM "tasks"`, func(args []any) (*dbtest.Result, error) {
+				if args[0] == int64(4) || args[0] == int64(6) {
+					return dbtest.Rows("count").Row(1), nil
+				}
+				return dbtest.Rows("count").Row(0), nil
+			})
+			fake.On(`WITH RECURSIVE ancestors`, func(args []any) (*dbtest.Result, error) {
+				if args[0] == int64(6) {
+					return dbtest.Rows("depth").Row(2), nil
+				}
+				return dbtest.Rows("depth").Row(1), nil
+			})
+
+			form := url.Values{"task_id": {"3"}, "parent_id": {test.parent}}
+			w := serve(http.HandlerFunc(MoveTask), inOrganization(newRequest(http.MethodPost, "/api/move-task", form), models.OrgRoleMember))
+			if w.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+			if moved := len(fake.Find(`UPDATE "tasks" SET "parent_id"`)) == 1; moved != (test.status == http.StatusOK) {
+				t.Errorf("moved = %v, want %v", moved, test.status == http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestCheckBlockers(t *testing.T) {
+	tests := []struct {
+		open    int
+		force   string
+		ok      bool
+		warning bool
+	}{
+		{0, "", true, false},
+		{2, "", false, false},
+		{2, "true", true, true},
+	}
+	for _, test := range tests {
+		fake := testDB(t)
+		fake.Return(`SELECT count(*) FROM "tasks"`, dbtest.Rows("count").Row(test.open))
+
+		task := testTask()
+		r := newRequest(http.MethodPut, "/api/update-task", url.Values{"force": {test.force}})
+		w := httptest.NewRecorder()
+		if ok := checkBlockers(w, r, &task); ok != test.ok {
+			t.Errorf("%d open blockers, force %q: ok = %v, want %v", test.open, test.force, ok, test.ok)
+		}
+		if !test.ok && w.Code != http.StatusConflict {
+			t.Errorf("%d open blockers: status = %d, want 409", test.open, w.Code)
+		}
+		if warning := w.Header().Get("Warning"); (warning != "") != test.warning {
+			t.Errorf("%d open blockers, force %q: Warning = %q", test.open, test.force, warning)
+		}
+		queries := fake.Find(`SELECT count(*) FROM "tasks"`)
+		if len(queries) != 1 || !strings.Contains(queries[0].Query, "completed_at IS NULL") || !hasArg(queries[0], int64(3)) {
+			t.Errorf("blocker count = %v, want the open blockers of task 3", queries)
+		}
+	}
+}
@@ -0,0 +1,533 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/repository"
+)
+
+var teamRepository repository.TeamRepository
+
+// GetTeams lists the teams of the current organization.
+func GetTeams(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	teams, err := teamRepository.GetOrganizationTeams(CurrentOrganizationId(r))
+	if err != nil {
+		http.Error(w, "Failed to get teams", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(teams); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func AddTeam(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	organizationId := CurrentOrganizationId(r)
+	if _, ok := authorizeOrganization(w, r, organizationId, models.OrgRoleAdmin); !ok {
+		return
+	}
+
+	if teamRepository.ExistTeamName(organizationId, name, 0) {
+		http.Error(w, "A team with this name already exists", http.StatusConflict)
+		return
+	}
+
+	team := models.Team{
+		OrganizationId: organizationId,
+		Name:           name,
+		Description:    strings.TrimSpace(r.FormValue("description")),
+	}
+	if err := teamRepository.CreateTeam(&team); err != nil {
+		http.Error(w, "Failed to add team", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(team); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func UpdateTeam(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	team, ok := currentTeam(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok := authorizeOrganization(w, r, team.OrganizationId, models.OrgRoleAdmin); !ok {
+		return
+	}
+
+	if name := strings.TrimSpace(r.FormValue("name")); name != "" && name != team.Name {
+		if teamRepository.ExistTeamName(team.OrganizationId, name, team.ID) {
+			http.Error(w, "A team with this name already exists", http.StatusConflict)
+			return
+		}
+		team.Name = name
+	}
+	if r.Form.Has("description") {
+		team.Description = strings.TrimSpace(r.FormValue("description"))
+	}
+
+	if err := teamRepository.UpdateTeam(team); err != nil {
+		http.Error(w, "Failed to update team", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(team); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// DeleteTeam deletes a team. Its members keep their direct project roles
+// but lose everything the team gave them.
+func DeleteTeam(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	team, ok := currentTeam(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok := authorizeOrganization(w, r, team.OrganizationId, models.OrgRoleAdmin); !ok {
+		return
+	}
+
+	if err := teamRepository.DeleteTeam(team.ID); err != nil {
+		http.Error(w, "Failed to delete team", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Team deleted successfully"})
+}
+
+func GetTeamMembers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	team, ok := currentTeam(w, r)
+	if !ok {
+		return
+	}
+
+	members, err := teamRepository.GetTeamMembers(team.ID)
+	if err != nil {
+		http.Error(w, "Failed to get members", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(members); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// AddTeamMember adds a member of the organization to a team.
+func AddTeamMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	team, ok := currentTeam(w, r)
+	if !ok {
+		return
+	}
+
+	username := strings.TrimSpace(r.FormValue("username"))
+	if username == "" {
+		http.Error(w, "Username is required", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := authorizeOrganization(w, r, team.OrganizationId, models.OrgRoleAdmin); !ok {
+		return
+	}
+
+	user, ok := userRepository.GetUserByUsername(username)
+	if !ok || !user.IsActive() {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	orgMember, err := organizationRepository.GetMembership(team.OrganizationId, user.ID)
+	if err != nil {
+		http.Error(w, "Failed to check membership", http.StatusInternalServerError)
+		return
+	}
+	if orgMember == nil {
+		http.Error(w, "User is not a member of this organization", http.StatusBadRequest)
+		return
+	}
+
+	exists, err := teamRepository.IsTeamMember(team.ID, user.ID)
+	if err != nil {
+		http.Error(w, "Failed to check membership", http.StatusInternalServerError)
+		return
+	}
+	if exists {
+		http.Error(w, "User is already a member of this team", http.StatusConflict)
+		return
+	}
+
+	member := models.TeamMember{
+		TeamId: team.ID,
+		UserId: user.ID,
+		User:   *user,
+	}
+	if err := teamRepository.AddTeamMember(&member); err != nil {
+		http.Error(w, "Failed to add member", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(member); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func RemoveTeamMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	team, ok := currentTeam(w, r)
+	if !ok {
+		return
+	}
+
+	userId, err := strconv.Atoi(r.FormValue("user_id"))
+	if err != nil || userId <= 0 {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := authorizeOrganization(w, r, team.OrganizationId, models.OrgRoleAdmin); !ok {
+		return
+	}
+
+	removed, err := teamRepository.RemoveTeamMember(team.ID, uint(userId))
+	if err != nil {
+		http.Error(w, "Failed to remove member", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Member removed successfully"})
+}
+
+func GetProjectTeams(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId, err := strconv.Atoi(r.FormValue("project_id"))
+	if err != nil || projectId <= 0 {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	if !authorizeProject(w, r, uint(projectId), models.ActionViewProject) {
+		return
+	}
+
+	grants, err := teamRepository.GetProjectGrants(uint(projectId))
+	if err != nil {
+		http.Error(w, "Failed to get teams", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(grants); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// AddProjectTeam grants a team a role on a project. Ownership cannot be
+// granted to teams so that every project keeps individual owners.
+func AddProjectTeam(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId, team, ok := parseProjectTeam(w, r)
+	if !ok {
+		return
+	}
+
+	role := r.FormValue("role")
+	if !isValidTeamRole(role) {
+		http.Error(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+
+	if !authorizeProject(w, r, projectId, models.ActionManageMembers) {
+		return
+	}
+
+	actorRole, _ := projectMemberRepository.GetEffectiveRole(projectId, CurrentUser(r).ID)
+	if !canAssignProjectRole(actorRole, "", role) {
+		http.Error(w, "Your project role does not allow granting this role", http.StatusForbidden)
+		return
+	}
+
+	existing, err := teamRepository.GetGrantRole(projectId, team.ID)
+	if err != nil {
+		http.Error(w, "Failed to check team access", http.StatusInternalServerError)
+		return
+	}
+	if existing != "" {
+		http.Error(w, "Team already has access to this project", http.StatusConflict)
+		return
+	}
+
+	grant := models.ProjectTeamGrant{
+		ProjectId: projectId,
+		TeamId:    team.ID,
+		Team:      *team,
+		Role:      role,
+	}
+	if err := teamRepository.AddGrant(&grant); err != nil {
+		http.Error(w, "Failed to add team", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(grant); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func UpdateProjectTeam(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId, team, ok := parseProjectTeam(w, r)
+	if !ok {
+		return
+	}
+
+	role := r.FormValue("role")
+	if !isValidTeamRole(role) {
+		http.Error(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+
+	if !authorizeProject(w, r, projectId, models.ActionManageMembers) {
+		return
+	}
+
+	currentRole, ok := teamGrantRole(w, projectId, team.ID)
+	if !ok {
+		return
+	}
+
+	actorRole, _ := projectMemberRepository.GetEffectiveRole(projectId, CurrentUser(r).ID)
+	if !canAssignProjectRole(actorRole, currentRole, role) {
+		http.Error(w, "Your project role does not allow this change", http.StatusForbidden)
+		return
+	}
+
+	if err := teamRepository.UpdateGrantRole(projectId, team.ID, role); err != nil {
+		http.Error(w, "Failed to update team", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Team access updated successfully"})
+}
+
+func RemoveProjectTeam(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId, team, ok := parseProjectTeam(w, r)
+	if !ok {
+		return
+	}
+
+	if !authorizeProject(w, r, projectId, models.ActionManageMembers) {
+		return
+	}
+
+	currentRole, ok := teamGrantRole(w, projectId, team.ID)
+	if !ok {
+		return
+	}
+
+	actorRole, _ := projectMemberRepository.GetEffectiveRole(projectId, CurrentUser(r).ID)
+	if !canAssignProjectRole(actorRole, currentRole, "") {
+		http.Error(w, "Your project role does not allow removing this team", http.StatusForbidden)
+		return
+	}
+
+	if err := teamRepository.RemoveGrant(projectId, team.ID); err != nil {
+		http.Error(w, "Failed to remove team", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Team removed successfully"})
+}
+
+// ExplainProjectAccess lists where a user's role in a project comes from.
+// Members may always ask about themselves; asking about someone else needs
+// the right to manage members.
+func ExplainProjectAccess(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId, err := strconv.Atoi(r.FormValue("project_id"))
+	if err != nil || projectId <= 0 {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	userId := CurrentUser(r).ID
+	if userIdStr := r.FormValue("user_id"); userIdStr != "" {
+		id, err := strconv.Atoi(userIdStr)
+		if err != nil || id <= 0 {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		userId = uint(id)
+	}
+
+	action := models.ActionViewProject
+	if userId != CurrentUser(r).ID {
+		action = models.ActionManageMembers
+	}
+	if !authorizeProject(w, r, uint(projectId), action) {
+		return
+	}
+
+	grants, err := projectMemberRepository.GetAccessGrants(uint(projectId), userId)
+	if err != nil {
+		http.Error(w, "Failed to get access", http.StatusInternalServerError)
+		return
+	}
+	if grants == nil {
+		grants = []models.AccessGrant{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]any{
+		"ProjectId": projectId,
+		"UserId":    userId,
+		"Role":      models.EffectiveProjectRole(grants),
+		"Grants":    grants,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func isValidTeamRole(role string) bool {
+	return models.IsValidProjectRole(role) && role != models.ProjectRoleOwner
+}
+
+// currentTeam loads the team named by team_id from the current
+// organization.
+func currentTeam(w http.ResponseWriter, r *http.Request) (*models.Team, bool) {
+	teamId, err := strconv.Atoi(r.FormValue("team_id"))
+	if err != nil || teamId <= 0 {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	team, err := teamRepository.GetTeam(CurrentOrganizationId(r), uint(teamId))
+	if err != nil {
+		http.Error(w, "Team not found", http.StatusNotFound)
+		return nil, false
+	}
+	return team, true
+}
+
+func parseProjectTeam(w http.ResponseWriter, r *http.Request) (uint, *models.Team, bool) {
+	projectId, err := strconv.Atoi(r.FormValue("project_id"))
+	if err != nil || projectId <= 0 {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return 0, nil, false
+	}
+
+	team, ok := currentTeam(w, r)
+	if !ok {
+		return 0, nil, false
+	}
+	return uint(projectId), team, true
+}
+
+func teamGrantRole(w http.ResponseWriter, projectId, teamId uint) (string, bool) {
+	role, err := teamRepository.GetGrantRole(projectId, teamId)
+	if err != nil {
+		http.Error(w, "Failed to check team access", http.StatusInternalServerError)
+		return "", false
+	}
+	if role == "" {
+		http.Error(w, "Team has no access to this project", http.StatusNotFound)
+		return "", false
+	}
+	return role, true
+}
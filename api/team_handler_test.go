@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/aminasadiam/DevTasks/internal/database/dbtest"
+	"github.com/aminasadiam/DevTasks/internal/models"
+)
+
+func TestExplainProjectAccess(t *testing.T) {
+	backend := models.AccessGrant{Source: models.AccessSourceTeam, Role: models.ProjectRoleMaintainer, TeamId: 4, TeamName: "Backend"}
+	tests := []struct {
+		name   string
+		direct string
+		teams  []models.AccessGrant
+		role   string
+	}{
+		{"direct only", models.ProjectRoleContributor, nil, models.ProjectRoleContributor},
+		{"team only", "", []models.AccessGrant{backend}, models.ProjectRoleMaintainer},
+		{"team above direct", models.ProjectRoleViewer, []models.AccessGrant{backend}, models.ProjectRoleMaintainer},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := testDB(t)
+			projectFixture(fake, test.direct)
+			teams := dbtest.Rows("source", "role", "team_id", "team_name")
+			for _, grant := range test.teams {
+				teams.Row(grant.Source, grant.Role, grant.TeamId, grant.TeamName)
+			}
+			fake.Return(`FROM "project_team_grants"`, teams)
+
+			r := inOrganization(newRequest(http.MethodPost, "/api/project-access", url.Values{"project_id": {"7"}}), models.OrgRoleMember)
+			w := serve(http.HandlerFunc(ExplainProjectAccess), r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+
+			var response struct {
+				Role   string
+				Grants []models.AccessGrant
+			}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			var want []models.AccessGrant
+			if test.direct != "" {
+				want = append(want, models.AccessGrant{Source: models.AccessSourceDirect, Role: test.direct})
+			}
+			want = append(want, test.teams...)
+			if response.Role != test.role || !reflect.DeepEqual(response.Grants, want) {
+				t.Errorf("access = %s %+v, want %s %+v", response.Role, response.Grants, test.role, want)
+			}
+		})
+	}
+}
+
+func TestExplainProjectAccessOfOthers(t *testing.T) {
+	tests := []struct {
+		role   string
+		status int
+	}{
+		{models.ProjectRoleContributor, http.StatusForbidden},
+		{models.ProjectRoleMaintainer, http.StatusOK},
+	}
+	for _, test := range tests {
+		fake := testDB(t)
+		projectFixture(fake, test.role)
+
+		form := url.Values{"project_id": {"7"}, "user_id": {"2"}}
+		w := serve(http.HandlerFunc(ExplainProjectAccess), inOrganization(newRequest(http.MethodPost, "/api/project-access", form), models.OrgRoleMember))
+		if w.Code != test.status {
+			t.Errorf("%s asks about another user: status = %d, want %d", test.role, w.Code, test.status)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/repository"
+	"github.com/aminasadiam/DevTasks/internal/throttle"
+	"gorm.io/gorm"
+)
+
+var loginGuard *throttle.LoginGuard
+var resetGuard *throttle.ResetGuard
+
+func newThrottleStore(db *gorm.DB) throttle.Store {
+	if authConfig.LoginThrottleStore == "database" {
+		return repository.NewLoginAttemptRepository(db)
+	}
+	return throttle.NewMemoryStore(24 * time.Hour)
+}
+
+// loginLocked writes a 429 response and returns true when username or ip are
+// currently locked out. Store errors are logged and let the login through.
+func loginLocked(w http.ResponseWriter, username, ip string) bool {
+	wait, err := loginGuard.Check(username, ip)
+	if err != nil {
+		log.Printf("login throttle: %v\n", err)
+		return false
+	}
+	if wait > 0 {
+		writeTooManyRequests(w, wait, "Too many failed login attempts")
+		return true
+	}
+	return false
+}
+
+func loginFailed(username, ip string) {
+	if _, err := loginGuard.Fail(username, ip); err != nil {
+		log.Printf("login throttle: %v\n", err)
+	}
+}
+
+func loginSucceeded(username string) {
+	if err := loginGuard.Succeed(username); err != nil {
+		log.Printf("login throttle: %v\n", err)
+	}
+}
+
+// resetThrottled writes a 429 response and returns true when too many
+// password resets were requested for address or from ip. Store errors are
+// logged and let the request through.
+func resetThrottled(w http.ResponseWriter, address, ip string) bool {
+	wait, err := resetGuard.Request(address, ip)
+	if err != nil {
+		log.Printf("password reset throttle: %v\n", err)
+		return false
+	}
+	if wait > 0 {
+		writeTooManyRequests(w, wait, "Too many password reset requests")
+		return true
+	}
+	return false
+}
+
+func writeTooManyRequests(w http.ResponseWriter, wait time.Duration, message string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	http.Error(w, message, http.StatusTooManyRequests)
+}
+
+// UnlockLogin clears the failed login record of a username, an IP address
+// or both.
+func UnlockLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	username := strings.TrimSpace(r.FormValue("username"))
+	ip := strings.TrimSpace(r.FormValue("ip"))
+
+	if username == "" && ip == "" {
+		http.Error(w, "Username or IP is required", http.StatusBadRequest)
+		return
+	}
+
+	if username != "" {
+		if err := loginGuard.UnlockUser(username); err != nil {
+			http.Error(w, "Failed to unlock user", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if ip != "" {
+		if err := loginGuard.UnlockIP(ip); err != nil {
+			http.Error(w, "Failed to unlock IP", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Login unlocked successfully"})
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/database/dbtest"
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/throttle"
+	"github.com/aminasadiam/DevTasks/internal/utils"
+)
+
+func TestLoginThrottle(t *testing.T) {
+	fake := testDB(t)
+	loginGuard = throttle.NewLoginGuard(throttle.NewMemoryStore(time.Hour))
+	hash, err := utils.HashPass("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := models.User{Username: "alice", Password: hash}
+	alice.ID = 1
+	fake.Return(`FROM "users"`, dbtest.Records(alice))
+
+	login := func(password string) *http.Response {
+		r := newRequest(http.MethodPost, "/api/login", url.Values{"username": {"alice"}, "password": {password}})
+		r.RemoteAddr = "192.0.2.1:1234"
+		return serve(http.HandlerFunc(LoginHandler), r).Result()
+	}
+
+	for i := range 5 {
+		if resp := login("wrong"); resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("failure %d: status = %d, want 401", i+1, resp.StatusCode)
+		}
+	}
+	login("wrong")
+
+	// Even the right password has to wait now.
+	resp := login("correct horse")
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", resp.StatusCode)
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err != nil || seconds < 1 {
+		t.Errorf("Retry-After = %q, want whole seconds", resp.Header.Get("Retry-After"))
+	}
+	if len(fake.Find(`INSERT INTO "sessions"`)) != 0 {
+		t.Error("a locked out login started a session")
+	}
+
+	w := serve(http.HandlerFunc(UnlockLogin), newRequest(http.MethodPost, "/api/admin/unlock-login", url.Values{"username": {"alice"}}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unlock: status = %d", w.Code)
+	}
+	if resp := login("correct horse"); resp.StatusCode != http.StatusAccepted {
+		t.Errorf("after unlock: status = %d, want 202", resp.StatusCode)
+	}
+}
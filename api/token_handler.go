@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/repository"
+	"github.com/aminasadiam/DevTasks/internal/utils"
+)
+
+const apiTokenPrefix = "dtp_"
+
+var apiTokenRepository repository.APITokenRepository
+
+func GetTokens(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tokens, err := apiTokenRepository.GetUserTokens(CurrentUser(r).ID)
+	if err != nil {
+		http.Error(w, "Failed to get tokens", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tokens); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// AddToken creates a personal access token. The raw token is only part of
+// this response; afterwards just its hash is known to the server.
+func AddToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	scope := r.FormValue("scope")
+	if scope == "" {
+		scope = models.TokenScopeRead
+	}
+	if scope != models.TokenScopeRead && scope != models.TokenScopeWrite {
+		http.Error(w, "Invalid scope", http.StatusBadRequest)
+		return
+	}
+
+	apiToken := models.APIToken{
+		UserId:         CurrentUser(r).ID,
+		Name:           name,
+		Scope:          scope,
+		OrganizationId: CurrentOrganizationId(r),
+	}
+
+	if projectIdStr := r.FormValue("project_id"); projectIdStr != "" {
+		projectId, err := strconv.Atoi(projectIdStr)
+		if err != nil || projectId <= 0 {
+			http.Error(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+		if !authorizeProject(w, r, uint(projectId), models.ActionViewProject) {
+			return
+		}
+		id := uint(projectId)
+		apiToken.ProjectId = &id
+	}
+
+	if daysStr := r.FormValue("expires_in_days"); daysStr != "" {
+		days, err := strconv.Atoi(daysStr)
+		if err != nil || days <= 0 {
+			http.Error(w, "Invalid expiry", http.StatusBadRequest)
+			return
+		}
+		expiresAt := time.Now().AddDate(0, 0, days)
+		apiToken.ExpiresAt = &expiresAt
+	}
+
+	token := apiTokenPrefix + utils.GenerateToken(32)
+	apiToken.TokenHash = utils.HashToken(token)
+	apiToken.Prefix = token[:len(apiTokenPrefix)+8]
+
+	if err := apiTokenRepository.Create(&apiToken); err != nil {
+		http.Error(w, "Failed to create token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	response := map[string]any{
+		"message": "Token created successfully",
+		"token":   token,
+		"details": apiToken,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func RevokeToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tokenId, err := strconv.Atoi(r.FormValue("token_id"))
+	if err != nil || tokenId <= 0 {
+		http.Error(w, "Invalid token ID", http.StatusBadRequest)
+		return
+	}
+
+	revoked, err := apiTokenRepository.RevokeToken(uint(tokenId), CurrentUser(r).ID)
+	if err != nil {
+		http.Error(w, "Failed to revoke token", http.StatusInternalServerError)
+		return
+	}
+	if !revoked {
+		http.Error(w, "Token not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Token revoked successfully"})
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/database/dbtest"
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/utils"
+)
+
+func TestAddToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		form   url.Values
+		status int
+		scope  string
+	}{
+		{"default scope", url.Values{"name": {"CI"}}, http.StatusCreated, models.TokenScopeRead},
+		{"write scope", url.Values{"name": {"CI"}, "scope": {"write"}}, http.StatusCreated, models.TokenScopeWrite},
+		{"expiry", url.Values{"name": {"CI"}, "expires_in_days": {"30"}}, http.StatusCreated, models.TokenScopeRead},
+		{"no name", url.Values{"name": {" "}}, http.StatusBadRequest, ""},
+		{"unknown scope", url.Values{"name": {"CI"}, "scope": {"admin"}}, http.StatusBadRequest, ""},
+		{"invalid expiry", url.Values{"name": {"CI"}, "expires_in_days": {"0"}}, http.StatusBadRequest, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := testDB(t)
+			session := testSession(5, 1)
+
+			w := serve(http.HandlerFunc(AddToken), signedIn(newRequest(http.MethodPost, "/api/add-token", test.form), &session))
+			if w.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+			if test.status != http.StatusCreated {
+				if inserts := fake.Find(`INSERT INTO "api_tokens"`); len(inserts) > 0 {
+					t.Errorf("invalid request created a token")
+				}
+				return
+			}
+
+			var response struct {
+				Token   string
+				Details models.APIToken
+			}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(response.Token, apiTokenPrefix) || !strings.HasPrefix(response.Token, response.Details.Prefix) {
+				t.Errorf("token %q does not start with prefix %q", response.Token, response.Details.Prefix)
+			}
+			if response.Details.Scope != test.scope || response.Details.UserId != 1 {
+				t.Errorf("token details = %+v, want scope %s of user 1", response.Details, test.scope)
+			}
+			if expiry := test.form.Get("expires_in_days"); (expiry != "") != (response.Details.ExpiresAt != nil) {
+				t.Errorf("expires at %v for expires_in_days %q", response.Details.ExpiresAt, expiry)
+			}
+
+			inserts := fake.Find(`INSERT INTO "api_tokens"`)
+			if len(inserts) != 1 {
+				t.Fatalf("%d tokens created, want 1", len(inserts))
+			}
+			if !hasArg(inserts[0], utils.HashToken(response.Token)) || hasArg(inserts[0], response.Token) {
+				t.Errorf("token is not stored by its hash alone: %v", inserts[0].Args)
+			}
+		})
+	}
+}
+
+// tokenFixture answers the lookup of the bearer token "dtp_token" with token.
+func tokenFixture(fake *dbtest.DB, token models.APIToken) {
+	token.TokenHash = utils.HashToken("dtp_token")
+	fake.On(`FROM "api_tokens"`, func(args []any) (*dbtest.Result, error) {
+		if args[0] != token.TokenHash {
+			return nil, nil
+		}
+		return dbtest.Records(token), nil
+	})
+	fake.Return(`FROM "users"`, dbtest.Records(token.User))
+}
+
+func testToken(scope string, lastUsedAt *time.Time) models.APIToken {
+	token := models.APIToken{
+		UserId:     1,
+		User:       models.User{Username: "alice"},
+		Name:       "CI",
+		Scope:      scope,
+		LastUsedAt: lastUsedAt,
+	}
+	token.ID = 3
+	token.User.ID = 1
+	return token
+}
+
+func TestBearerToken(t *testing.T) {
+	recently := time.Now()
+	tests := []struct {
+		name    string
+		header  string
+		handler http.Handler
+		status  int
+	}{
+		{"read", "Bearer dtp_token", protect(ValidateSession), http.StatusOK},
+		{"unknown token", "Bearer dtp_other", protect(ValidateSession), http.StatusUnauthorized},
+		{"not a bearer token", "Basic dtp_token", protect(ValidateSession), http.StatusUnauthorized},
+		{"write with a read token", "Bearer dtp_token", protect(ValidateSession, RequireWrite), http.StatusForbidden},
+		{"session only route", "Bearer dtp_token", protect(ValidateSession, SessionOnly), http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := testDB(t)
+			tokenFixture(fake, testToken(models.TokenScopeRead, &recently))
+
+			r := newRequest(http.MethodPost, "/api/validate", nil)
+			r.Header.Set("Authorization", test.header)
+			if w := serve(Chain(test.handler, Authenticate), r); w.Code != test.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+			if touched := fake.Find(`UPDATE "api_tokens" SET "last_used_at"`); len(touched) > 0 {
+				t.Error("a token used a moment ago was touched again")
+			}
+		})
+	}
+}
+
+func TestBearerTokenWrite(t *testing.T) {
+	fake := testDB(t)
+	tokenFixture(fake, testToken(models.TokenScopeWrite, nil))
+
+	r := newRequest(http.MethodPost, "/api/validate", nil)
+	r.Header.Set("Authorization", "Bearer dtp_token")
+	if w := serve(Chain(protect(ValidateSession, RequireWrite), Authenticate), r); w.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	if touched := fake.Find(`UPDATE "api_tokens" SET "last_used_at"`); len(touched) != 1 {
+		t.Errorf("last use of the token recorded %d times, want 1", len(touched))
+	}
+}
+
+func TestTokenAllowsProject(t *testing.T) {
+	projectId := uint(7)
+	scoped := testToken(models.TokenScopeRead, nil)
+	scoped.ProjectId = &projectId
+	unscoped := testToken(models.TokenScopeRead, nil)
+
+	tests := []struct {
+		token   *models.APIToken
+		project uint
+		want    bool
+	}{
+		{nil, 8, true},
+		{&unscoped, 8, true},
+		{&scoped, 7, true},
+		{&scoped, 8, false},
+	}
+	for _, test := range tests {
+		r := newRequest(http.MethodPost, "/", nil)
+		if test.token != nil {
+			r = withToken(r, test.token)
+		}
+		if got := tokenAllowsProject(r, test.project); got != test.want {
+			t.Errorf("tokenAllowsProject(%v, %d) = %v, want %v", test.token, test.project, got, test.want)
+		}
+	}
+}
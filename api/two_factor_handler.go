@@ -0,0 +1,260 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/repository"
+	"github.com/aminasadiam/DevTasks/internal/utils"
+)
+
+const (
+	totpIssuer           = "DevTasks"
+	recoveryCodeCount    = 10
+	pendingLoginDuration = 5 * time.Minute
+	pendingLoginAttempts = 5
+)
+
+var twoFactorRepository repository.TwoFactorRepository
+
+// beginTwoFactorLogin stores a pending login for user and returns the token
+// the client has to present together with the second factor.
+func beginTwoFactorLogin(user *models.User) (string, error) {
+	twoFactorRepository.DeleteExpiredPendingLogins()
+
+	token := utils.GenerateToken(32)
+	pending := models.PendingLogin{
+		UserId:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(pendingLoginDuration),
+	}
+	if err := twoFactorRepository.CreatePendingLogin(&pending); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// verifySecondFactor accepts either a TOTP code or an unused recovery code.
+func verifySecondFactor(user *models.User, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		step, ok := utils.VerifyTOTP(user.TOTPSecret, code, time.Now(), user.TOTPLastStep)
+		if !ok {
+			return false, nil
+		}
+		return userRepository.AdvanceTOTPStep(user.ID, step)
+	}
+
+	if recoveryCode != "" {
+		return twoFactorRepository.ConsumeRecoveryCode(user.ID, recoveryCode)
+	}
+
+	return false, nil
+}
+
+func issueRecoveryCodes(userId uint) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		codes[i] = utils.GenerateRecoveryCode()
+	}
+	if err := twoFactorRepository.ReplaceRecoveryCodes(userId, codes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// LoginTwoFactor completes a login started by LoginHandler for a user with
+// two-factor authentication enabled.
+func LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pendingToken := strings.TrimSpace(r.FormValue("pending_token"))
+	code := strings.TrimSpace(r.FormValue("code"))
+	recoveryCode := strings.TrimSpace(r.FormValue("recovery_code"))
+
+	if pendingToken == "" || (code == "" && recoveryCode == "") {
+		http.Error(w, "Pending token and code are required", http.StatusBadRequest)
+		return
+	}
+
+	pending, err := twoFactorRepository.GetPendingLogin(pendingToken)
+	if err != nil {
+		http.Error(w, "Login expired, please sign in again", http.StatusUnauthorized)
+		return
+	}
+
+	ip := clientIP(r)
+	if loginLocked(w, pending.User.Username, ip) {
+		return
+	}
+
+	ok, err := verifySecondFactor(&pending.User, code, recoveryCode)
+	if err != nil {
+		http.Error(w, "Failed to verify code", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		loginFailed(pending.User.Username, ip)
+		twoFactorRepository.IncrementPendingAttempts(pending)
+		if pending.Attempts >= pendingLoginAttempts {
+			twoFactorRepository.DeletePendingLogin(pending.ID)
+		}
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+
+	twoFactorRepository.DeletePendingLogin(pending.ID)
+	loginSucceeded(pending.User.Username)
+
+	csrfToken, err := startSession(w, r, &pending.User)
+	if err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"csrfToken": csrfToken})
+}
+
+func EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := CurrentUser(r)
+	if user.TOTPEnabled {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	secret := utils.GenerateTOTPSecret()
+	if err := userRepository.SetTOTPSecret(user.ID, secret); err != nil {
+		http.Error(w, "Failed to start enrollment", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret": secret,
+		"uri":    utils.TOTPURI(totpIssuer, user.Username, secret),
+	})
+}
+
+// ConfirmTwoFactor enables two-factor authentication once the user proves
+// that their authenticator produces valid codes, and hands out the recovery
+// codes.
+func ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := CurrentUser(r)
+	if user.TOTPEnabled {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+	if user.TOTPSecret == "" {
+		http.Error(w, "Enrollment has not been started", http.StatusBadRequest)
+		return
+	}
+
+	step, ok := utils.VerifyTOTP(user.TOTPSecret, r.FormValue("code"), time.Now(), 0)
+	if !ok {
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+
+	codes, err := issueRecoveryCodes(user.ID)
+	if err != nil {
+		http.Error(w, "Failed to create recovery codes", http.StatusInternalServerError)
+		return
+	}
+
+	if err := userRepository.EnableTOTP(user.ID, step); err != nil {
+		http.Error(w, "Failed to enable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"message":       "Two-factor authentication enabled",
+		"recoveryCodes": codes,
+	})
+}
+
+func DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := CurrentUser(r)
+	if !user.TOTPEnabled {
+		http.Error(w, "Two-factor authentication is not enabled", http.StatusConflict)
+		return
+	}
+
+	if !utils.VerifyPassword(strings.TrimSpace(r.FormValue("password")), user.Password) {
+		http.Error(w, "Invalid Password", http.StatusUnauthorized)
+		return
+	}
+
+	ok, err := verifySecondFactor(user, strings.TrimSpace(r.FormValue("code")), strings.TrimSpace(r.FormValue("recovery_code")))
+	if err != nil {
+		http.Error(w, "Failed to verify code", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+
+	if err := userRepository.DisableTOTP(user.ID); err != nil {
+		http.Error(w, "Failed to disable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+	twoFactorRepository.DeleteRecoveryCodes(user.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Two-factor authentication disabled"})
+}
+
+func RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := CurrentUser(r)
+	if !user.TOTPEnabled {
+		http.Error(w, "Two-factor authentication is not enabled", http.StatusConflict)
+		return
+	}
+
+	ok, err := verifySecondFactor(user, strings.TrimSpace(r.FormValue("code")), "")
+	if err != nil {
+		http.Error(w, "Failed to verify code", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+
+	codes, err := issueRecoveryCodes(user.ID)
+	if err != nil {
+		http.Error(w, "Failed to create recovery codes", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"recoveryCodes": codes})
+}
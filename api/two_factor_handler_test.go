@@ -0,0 +1,91 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/database/dbtest"
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/throttle"
+	"github.com/aminasadiam/DevTasks/internal/utils"
+)
+
+// twoFactorFixture answers the pending login "pending" of alice, whose
+// stored last TOTP step is *stored. The user row handed to the handler
+// carries *loaded, which lags behind *stored when a concurrent login is
+// simulated. The conditional update of AdvanceTOTPStep succeeds only for a
+// step later than *stored.
+func twoFactorFixture(fake *dbtest.DB, secret string, stored, loaded *int64) {
+	pending := models.PendingLogin{UserId: 1, TokenHash: utils.HashToken("pending"), ExpiresAt: time.Now().Add(time.Minute)}
+	pending.ID = 6
+	fake.Return(`FROM "pending_logins"`, dbtest.Records(pending))
+	fake.On(`FROM "users"`, func([]any) (*dbtest.Result, error) {
+		alice := models.User{Username: "alice", TOTPSecret: secret, TOTPEnabled: true, TOTPLastStep: *loaded}
+		alice.ID = 1
+		return dbtest.Records(alice), nil
+	})
+	fake.On(`UPDATE "users" SET "totp_last_step"`, func(args []any) (*dbtest.Result, error) {
+		step, bound := args[0].(int64), args[len(args)-1].(int64)
+		if *stored >= bound {
+			return dbtest.Affected(0), nil
+		}
+		*stored = step
+		return dbtest.Affected(1), nil
+	})
+}
+
+func loginTwoFactor(code string) int {
+	r := newRequest(http.MethodPost, "/api/login/2fa", url.Values{"pending_token": {"pending"}, "code": {code}})
+	return serve(http.HandlerFunc(LoginTwoFactor), r).Code
+}
+
+func TestLoginTwoFactorRejectsReplay(t *testing.T) {
+	fake := testDB(t)
+	loginGuard = throttle.NewLoginGuard(throttle.NewMemoryStore(time.Hour))
+	secret := utils.GenerateTOTPSecret()
+	code, err := utils.TOTPCode(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stored int64
+	twoFactorFixture(fake, secret, &stored, &stored)
+
+	if status := loginTwoFactor(code); status != http.StatusAccepted {
+		t.Fatalf("first use: status = %d, want 202", status)
+	}
+	if stored == 0 {
+		t.Fatal("the used step was not recorded")
+	}
+	if status := loginTwoFactor(code); status != http.StatusUnauthorized {
+		t.Errorf("replayed code: status = %d, want 401", status)
+	}
+	if sessions := fake.Find(`INSERT INTO "sessions"`); len(sessions) != 1 {
+		t.Errorf("%d sessions started, want 1", len(sessions))
+	}
+}
+
+func TestLoginTwoFactorRejectsConcurrentReplay(t *testing.T) {
+	fake := testDB(t)
+	loginGuard = throttle.NewLoginGuard(throttle.NewMemoryStore(time.Hour))
+	secret := utils.GenerateTOTPSecret()
+	now := time.Now()
+	code, err := utils.TOTPCode(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another request used the code after this one loaded the user.
+	var loaded int64
+	stored := now.Unix() / 30
+	twoFactorFixture(fake, secret, &stored, &loaded)
+
+	if status := loginTwoFactor(code); status != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", status)
+	}
+	if sessions := fake.Find(`INSERT INTO "sessions"`); len(sessions) != 0 {
+		t.Errorf("%d sessions started, want none", len(sessions))
+	}
+}
@@ -3,10 +3,11 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"log"
 	"net/http"
 	"strings"
-	"time"
 
+	"github.com/aminasadiam/DevTasks/config"
 	"github.com/aminasadiam/DevTasks/internal/models"
 	"github.com/aminasadiam/DevTasks/internal/repository"
 	"github.com/aminasadiam/DevTasks/internal/utils"
@@ -21,13 +22,16 @@ func GetUsers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := Authorize(r); err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
+	users := userRepository.AllUsers()
+	publicUsers := make([]models.PublicUser, 0, len(users))
+	for _, user := range users {
+		if user.IsActive() {
+			publicUsers = append(publicUsers, user.Public())
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(userRepository.AllUsers()); err != nil {
+	if err := json.NewEncoder(w).Encode(publicUsers); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
@@ -64,7 +68,6 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		Username: username,
 		Email:    email,
 		Password: hashedPassword,
-		Profile:  "Default.png",
 	}
 
 	if err := userRepository.Create(&user); err != nil {
@@ -72,8 +75,31 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if _, err := organizationRepository.CreatePersonalOrganization(&user); err != nil {
+		log.Printf("failed to create personal organization for user %d: %v\n", user.ID, err)
+	}
+
+	if err := sendEmailVerification(&user); err != nil {
+		log.Printf("failed to send verification email to user %d: %v\n", user.ID, err)
+	}
+
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(user)
+	json.NewEncoder(w).Encode(user.Public())
+}
+
+// newPasswordHasher returns the hasher for new passwords. Argon2id is used
+// unless bcrypt is configured.
+func newPasswordHasher(cfg *config.PasswordConfig) utils.PasswordHasher {
+	if cfg.Algorithm == "bcrypt" {
+		return utils.BcryptHasher{Cost: cfg.BcryptCost}
+	}
+	return utils.Argon2idHasher{
+		Memory:      cfg.Argon2Memory,
+		Time:        cfg.Argon2Time,
+		Parallelism: cfg.Argon2Parallelism,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
 }
 
 func LoginHandler(w http.ResponseWriter, r *http.Request) {
@@ -85,94 +111,80 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	username := strings.TrimSpace(r.FormValue("username"))
 	password := strings.TrimSpace(r.FormValue("password"))
 
+	ip := clientIP(r)
+	if loginLocked(w, username, ip) {
+		return
+	}
+
 	user, ok := userRepository.LoginUser(username, password)
 	if !ok {
+		loginFailed(username, ip)
 		http.Error(w, "Invalid Username/Password", http.StatusUnauthorized)
 		return
 	}
 
-	sessionToken := utils.GenerateToken(32)
-	csrfToken := utils.GenerateToken(32)
-
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
-		Value:    sessionToken,
-		Expires:  time.Now().Add((24 * time.Hour) * 7),
-		HttpOnly: false,
-		Path:     "/",
-		SameSite: http.SameSiteLaxMode,
-		Secure:   false, // Set to true in production with HTTPS
-	})
-
-	http.SetCookie(w, &http.Cookie{
-		Name:     "csrf_token",
-		Value:    csrfToken,
-		Expires:  time.Now().Add((24 * time.Hour) * 7),
-		HttpOnly: false,
-		Path:     "/",
-		SameSite: http.SameSiteLaxMode,
-		Secure:   false, // Set to true in production with HTTPS
-	})
+	if !user.IsActive() {
+		http.Error(w, "Account is deactivated", http.StatusForbidden)
+		return
+	}
 
-	user.SessionToken = sessionToken
-	user.CSRFToken = csrfToken
-	userRepository.Update(user)
+	rehashPassword(user, password)
+	sessionRepository.DeleteExpired()
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusAccepted)
-	json.NewEncoder(w).Encode(map[string]string{"csrfToken": csrfToken})
-}
+	if user.TOTPEnabled {
+		pendingToken, err := beginTwoFactorLogin(user)
+		if err != nil {
+			http.Error(w, "Failed to start login", http.StatusInternalServerError)
+			return
+		}
 
-func LogoutHandler(w http.ResponseWriter, r *http.Request) {
-	if err := Authorize(r); err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]any{"twoFactorRequired": true, "pendingToken": pendingToken})
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
-		Value:    "",
-		Expires:  time.Now().Add(-time.Hour),
-		HttpOnly: true,
-		SameSite: http.SameSiteStrictMode,
-	})
+	loginSucceeded(username)
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "csrf_token",
-		Value:    "",
-		Expires:  time.Now().Add(-time.Hour),
-		HttpOnly: false,
-		SameSite: http.SameSiteStrictMode,
-	})
-
-	username := strings.TrimSpace(r.FormValue("username"))
-	user, _ := userRepository.GetUserByUsername(username)
-	user.SessionToken = ""
-	user.CSRFToken = ""
-	userRepository.Update(user)
+	csrfToken, err := startSession(w, r, user)
+	if err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
 
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
-	json.NewEncoder(w).Encode("Logged out Successfuly")
+	json.NewEncoder(w).Encode(map[string]string{"csrfToken": csrfToken})
 }
 
-func Authorize(r *http.Request) error {
-	username := strings.TrimSpace(r.FormValue("username"))
-	user, ok := userRepository.GetUserByUsername(username)
-	if !ok {
-		return AuthError
+// rehashPassword upgrades the stored hash of user after a successful login
+// when it was made with an outdated algorithm or parameters.
+func rehashPassword(user *models.User, password string) {
+	if !utils.PasswordNeedsRehash(user.Password) {
+		return
 	}
 
-	st, err := r.Cookie("session_token")
-	if err != nil || st.Value == "" || st.Value != user.SessionToken {
-		return AuthError
+	hashedPassword, err := utils.HashPass(password)
+	if err != nil {
+		log.Printf("failed to rehash password of user %d: %v\n", user.ID, err)
+		return
 	}
 
-	csrf := r.Header.Get("X-CSRF-Token")
-	if csrf == "" || csrf != user.CSRFToken {
-		return AuthError
+	if err := userRepository.UpdatePassword(user.ID, hashedPassword); err != nil {
+		log.Printf("failed to store rehashed password of user %d: %v\n", user.ID, err)
+		return
 	}
+	user.Password = hashedPassword
+}
+
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	session := CurrentSession(r)
+
+	clearSessionCookies(w)
+	sessionRepository.DeleteSession(session.ID, session.UserId)
 
-	return nil
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode("Logged out Successfuly")
 }
 
 func ValidateSession(w http.ResponseWriter, r *http.Request) {
@@ -181,11 +193,6 @@ func ValidateSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := Authorize(r); err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "valid"})
+	json.NewEncoder(w).Encode(map[string]string{"status": "valid", "username": CurrentUser(r).Username})
 }
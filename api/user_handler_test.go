@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/config"
+	"github.com/aminasadiam/DevTasks/internal/database/dbtest"
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/throttle"
+	"github.com/aminasadiam/DevTasks/internal/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestNewPasswordHasher(t *testing.T) {
+	bcryptHasher := newPasswordHasher(&config.PasswordConfig{Algorithm: "bcrypt", BcryptCost: 12})
+	if bcryptHasher != (utils.BcryptHasher{Cost: 12}) {
+		t.Errorf("bcrypt: hasher = %+v", bcryptHasher)
+	}
+
+	argon2Hasher := newPasswordHasher(&config.PasswordConfig{Argon2Memory: 19456, Argon2Time: 2, Argon2Parallelism: 1})
+	want := utils.Argon2idHasher{Memory: 19456, Time: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	if argon2Hasher != want {
+		t.Errorf("default: hasher = %+v, want %+v", argon2Hasher, want)
+	}
+}
+
+func TestLoginRehashesPassword(t *testing.T) {
+	bcryptHasher := utils.BcryptHasher{Cost: bcrypt.MinCost}
+	argon2Hasher := utils.Argon2idHasher{Memory: 64, Time: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	tests := []struct {
+		name     string
+		stored   utils.PasswordHasher
+		password string
+		rehash   bool
+	}{
+		{"bcrypt hash", bcryptHasher, "correct horse", true},
+		{"outdated argon2id parameters", utils.Argon2idHasher{Memory: 32, Time: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}, "correct horse", true},
+		{"current hash", argon2Hasher, "correct horse", false},
+		{"wrong password", bcryptHasher, "wrong horse", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := testDB(t)
+			loginGuard = throttle.NewLoginGuard(throttle.NewMemoryStore(time.Hour))
+			utils.SetPasswordHasher(argon2Hasher)
+			t.Cleanup(func() { utils.SetPasswordHasher(bcryptHasher) })
+
+			hash, err := test.stored.Hash("correct horse")
+			if err != nil {
+				t.Fatal(err)
+			}
+			alice := models.User{Username: "alice", Password: hash}
+			alice.ID = 1
+			fake.Return(`FROM "users"`, dbtest.Records(alice))
+
+			r := newRequest(http.MethodPost, "/api/login", url.Values{"username": {"alice"}, "password": {test.password}})
+			serve(http.HandlerFunc(LoginHandler), r)
+
+			updates := fake.Find(`UPDATE "users" SET "password"`)
+			if (len(updates) == 1) != test.rehash {
+				t.Fatalf("%d password updates, want rehash: %v", len(updates), test.rehash)
+			}
+			if !test.rehash {
+				return
+			}
+			rehashed, _ := updates[0].Args[0].(string)
+			if !strings.HasPrefix(rehashed, "$argon2id$v=19$m=64,t=1,p=1$") || !utils.VerifyPassword("correct horse", rehashed) {
+				t.Errorf("stored hash %q is not a current argon2id hash of the password", rehashed)
+			}
+			if !hasArg(updates[0], int64(1)) {
+				t.Errorf("update %v does not target user 1", updates[0])
+			}
+		})
+	}
+}
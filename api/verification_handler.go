@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/repository"
+	"github.com/aminasadiam/DevTasks/internal/utils"
+)
+
+const (
+	emailVerificationDuration = 24 * time.Hour
+	emailVerificationCooldown = time.Minute
+)
+
+var emailVerificationRepository repository.EmailVerificationRepository
+
+func VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := strings.TrimSpace(r.FormValue("token"))
+	if token == "" {
+		http.Error(w, "Token is required", http.StatusBadRequest)
+		return
+	}
+
+	verificationToken, err := emailVerificationRepository.ConsumeToken(token)
+	if err != nil {
+		http.Error(w, "Invalid or expired token", http.StatusBadRequest)
+		return
+	}
+
+	if err := userRepository.MarkEmailVerified(verificationToken.UserId); err != nil {
+		http.Error(w, "Failed to verify email", http.StatusInternalServerError)
+		return
+	}
+
+	emailVerificationRepository.DeleteUserTokens(verificationToken.UserId)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Email verified successfully"})
+}
+
+func ResendVerification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := CurrentUser(r)
+	if user.EmailVerified {
+		http.Error(w, "Email already verified", http.StatusConflict)
+		return
+	}
+
+	if lastSent, ok := emailVerificationRepository.LastSentAt(user.ID); ok {
+		if wait := emailVerificationCooldown - time.Since(lastSent); wait > 0 {
+			writeTooManyRequests(w, wait, "Verification email was sent recently")
+			return
+		}
+	}
+
+	if err := sendEmailVerification(user); err != nil {
+		log.Printf("failed to send verification email to user %d: %v\n", user.ID, err)
+		http.Error(w, "Failed to send verification email", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Verification email sent"})
+}
+
+// RequireVerifiedEmail rejects users whose address is not verified yet when
+// REQUIRE_VERIFIED_EMAIL is enabled.
+func RequireVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authConfig.RequireVerifiedEmail && !CurrentUser(r).EmailVerified {
+			http.Error(w, "Email address is not verified", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sendEmailVerification replaces the outstanding verification tokens of user
+// with a new one and mails it.
+func sendEmailVerification(user *models.User) error {
+	if err := emailVerificationRepository.DeleteUserTokens(user.ID); err != nil {
+		return err
+	}
+
+	token := utils.GenerateToken(32)
+	verificationToken := models.EmailVerificationToken{
+		UserId:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(emailVerificationDuration),
+	}
+	if err := emailVerificationRepository.Create(&verificationToken); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", mailConfig.AppURL, url.QueryEscape(token))
+	body := fmt.Sprintf("Hi %s,\n\nPlease confirm the email address of your DevTasks account "+
+		"by opening the link below within the next 24 hours:\n\n%s\n", user.Username, link)
+
+	return mailer.Send(user.Email, "Verify your DevTasks email address", body)
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/config"
+	"github.com/aminasadiam/DevTasks/internal/database/dbtest"
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/utils"
+)
+
+func TestRegisterSendsVerification(t *testing.T) {
+	tests := []struct {
+		email  string
+		status int
+	}{
+		{"alice@example.org", http.StatusCreated},
+		{"alice@example.io", http.StatusCreated},
+		{"alice@example", http.StatusCreated},
+		{"alice", http.StatusNotAcceptable},
+		{"Alice <alice@example.org>", http.StatusNotAcceptable},
+	}
+	for _, test := range tests {
+		t.Run(test.email, func(t *testing.T) {
+			fake := testDB(t)
+			sent := useTestMailer()
+			fake.Return(`INSERT INTO "users"`, dbtest.Rows("id").Row(1))
+
+			form := url.Values{"username": {"alice"}, "email": {test.email}, "password": {"correct horse"}}
+			w := serve(http.HandlerFunc(RegisterHandler), newRequest(http.MethodPost, "/api/register", form))
+			if w.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+			if test.status != http.StatusCreated {
+				return
+			}
+
+			if len(sent.sent) != 1 || sent.sent[0].to != test.email || !strings.Contains(sent.sent[0].body, "/verify-email?token=") {
+				t.Errorf("mails = %+v, want a verification link to %s", sent.sent, test.email)
+			}
+			inserts := fake.Find(`INSERT INTO "users"`)
+			if len(inserts) != 1 || !hasArg(inserts[0], false) {
+				t.Errorf("user not created unverified: %v", inserts)
+			}
+		})
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	fake := testDB(t)
+	token := models.EmailVerificationToken{UserId: 1, TokenHash: utils.HashToken("verify"), ExpiresAt: time.Now().Add(time.Hour)}
+	token.ID = 2
+	fake.On(`FROM "email_verification_tokens"`, func(args []any) (*dbtest.Result, error) {
+		if args[0] != token.TokenHash {
+			return nil, nil
+		}
+		return dbtest.Records(token), nil
+	})
+	fake.Return(`UPDATE "email_verification_tokens" SET "used_at"`, dbtest.Affected(1))
+
+	verify := func(value string) int {
+		return serve(http.HandlerFunc(VerifyEmail), newRequest(http.MethodPost, "/api/email/verify", url.Values{"token": {value}})).Code
+	}
+	if status := verify("other"); status != http.StatusBadRequest {
+		t.Errorf("unknown token: status = %d, want 400", status)
+	}
+	if len(fake.Find(`UPDATE "users"`)) != 0 {
+		t.Fatal("an unknown token verified the email")
+	}
+	if status := verify("verify"); status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	updates := fake.Find(`UPDATE "users" SET "email_verified"=$1`)
+	if len(updates) != 1 || updates[0].Args[0] != true || !hasArg(updates[0], int64(1)) {
+		t.Errorf("email of user 1 not marked verified: %v", updates)
+	}
+}
+
+func TestResendVerification(t *testing.T) {
+	tests := []struct {
+		name     string
+		verified bool
+		lastSent time.Duration
+		status   int
+	}{
+		{"never sent", false, 0, http.StatusAccepted},
+		{"sent long ago", false, time.Hour, http.StatusAccepted},
+		{"sent recently", false, 10 * time.Second, http.StatusTooManyRequests},
+		{"already verified", true, 0, http.StatusConflict},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := testDB(t)
+			sent := useTestMailer()
+			if test.lastSent > 0 {
+				previous := models.EmailVerificationToken{UserId: 1}
+				previous.CreatedAt = time.Now().Add(-test.lastSent)
+				fake.Return(`FROM "email_verification_tokens"`, dbtest.Records(previous))
+			}
+			session := testSession(5, 1)
+			session.User.Email = "alice@example.org"
+			session.User.EmailVerified = test.verified
+
+			w := serve(http.HandlerFunc(ResendVerification), signedIn(newRequest(http.MethodPost, "/api/email/resend", nil), &session))
+			if w.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+			if wantMail := test.status == http.StatusAccepted; (len(sent.sent) == 1) != wantMail {
+				t.Errorf("mails = %+v, want a mail: %v", sent.sent, wantMail)
+			}
+			if test.status == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "50" {
+				t.Errorf("Retry-After = %q, want 50", w.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
+
+func TestRequireVerifiedEmail(t *testing.T) {
+	tests := []struct {
+		require  bool
+		verified bool
+		status   int
+	}{
+		{false, false, http.StatusOK},
+		{true, false, http.StatusForbidden},
+		{true, true, http.StatusOK},
+	}
+	for _, test := range tests {
+		authConfig = &config.AuthConfig{RequireVerifiedEmail: test.require}
+		session := testSession(5, 1)
+		session.User.EmailVerified = test.verified
+
+		h := RequireVerifiedEmail(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+		if w := serve(h, signedIn(newRequest(http.MethodPost, "/api/add-project", nil), &session)); w.Code != test.status {
+			t.Errorf("required %v, verified %v: status = %d, want %d", test.require, test.verified, w.Code, test.status)
+		}
+	}
+}
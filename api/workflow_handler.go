@@ -0,0 +1,390 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/repository"
+)
+
+var workflowRepository repository.WorkflowRepository
+
+// GetWorkflow returns the states of a project in order together with the
+// allowed transitions between them.
+func GetWorkflow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId, err := strconv.Atoi(r.FormValue("project_id"))
+	if err != nil || projectId <= 0 {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	if !authorizeProject(w, r, uint(projectId), models.ActionViewProject) {
+		return
+	}
+
+	states, err := workflowRepository.GetStates(uint(projectId))
+	if err != nil {
+		http.Error(w, "Failed to get workflow", http.StatusInternalServerError)
+		return
+	}
+	transitions, err := workflowRepository.GetTransitions(uint(projectId))
+	if err != nil {
+		http.Error(w, "Failed to get workflow", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]any{
+		"States":      states,
+		"Transitions": transitions,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func AddWorkflowState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId, err := strconv.Atoi(r.FormValue("project_id"))
+	if err != nil || projectId <= 0 {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	state := models.WorkflowState{
+		ProjectId: uint(projectId),
+		Name:      name,
+	}
+	if !parseStateSettings(w, r, &state) {
+		return
+	}
+
+	if !authorizeProject(w, r, uint(projectId), models.ActionEditProject) {
+		return
+	}
+
+	if workflowRepository.ExistStateName(uint(projectId), name, 0) {
+		http.Error(w, "A state with this name already exists", http.StatusConflict)
+		return
+	}
+
+	if err := workflowRepository.CreateState(&state); err != nil {
+		http.Error(w, "Failed to add state", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// UpdateWorkflowState renames, moves or reconfigures a state. Fields that
+// are not sent keep their value. There is always exactly one initial state:
+// marking a state as initial takes the flag from the previous one, and it
+// cannot be removed from the initial state directly.
+func UpdateWorkflowState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId, stateId, ok := parseStateIds(w, r)
+	if !ok {
+		return
+	}
+
+	if !authorizeProject(w, r, projectId, models.ActionEditProject) {
+		return
+	}
+
+	state, err := workflowRepository.GetState(projectId, stateId)
+	if err != nil {
+		http.Error(w, "State not found", http.StatusNotFound)
+		return
+	}
+
+	wasInitial := state.IsInitial
+	if name := strings.TrimSpace(r.FormValue("name")); name != "" && name != state.Name {
+		if workflowRepository.ExistStateName(projectId, name, state.ID) {
+			http.Error(w, "A state with this name already exists", http.StatusConflict)
+			return
+		}
+		state.Name = name
+	}
+	if !parseStateSettings(w, r, state) {
+		return
+	}
+	if wasInitial && !state.IsInitial {
+		http.Error(w, "Mark another state as initial instead", http.StatusConflict)
+		return
+	}
+
+	if err := workflowRepository.UpdateState(state); err != nil {
+		http.Error(w, "Failed to update state", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// DeleteWorkflowState deletes a state and its transitions. States that
+// still hold tasks and the initial state cannot be deleted.
+func DeleteWorkflowState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId, stateId, ok := parseStateIds(w, r)
+	if !ok {
+		return
+	}
+
+	if !authorizeProject(w, r, projectId, models.ActionEditProject) {
+		return
+	}
+
+	state, err := workflowRepository.GetState(projectId, stateId)
+	if err != nil {
+		http.Error(w, "State not found", http.StatusNotFound)
+		return
+	}
+	if state.IsInitial {
+		http.Error(w, "The initial state cannot be deleted", http.StatusConflict)
+		return
+	}
+
+	count, err := workflowRepository.CountTasksInState(state.ID)
+	if err != nil {
+		http.Error(w, "Failed to check state", http.StatusInternalServerError)
+		return
+	}
+	if count > 0 {
+		http.Error(w, "Move the tasks in this state elsewhere first", http.StatusConflict)
+		return
+	}
+
+	if err := workflowRepository.DeleteState(projectId, state.ID); err != nil {
+		http.Error(w, "Failed to delete state", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "State deleted successfully"})
+}
+
+func AddWorkflowTransition(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId, err := strconv.Atoi(r.FormValue("project_id"))
+	if err != nil || projectId <= 0 {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	fromStateId, err := strconv.Atoi(r.FormValue("from_state_id"))
+	if err != nil || fromStateId <= 0 {
+		http.Error(w, "Invalid from state ID", http.StatusBadRequest)
+		return
+	}
+
+	toStateId, err := strconv.Atoi(r.FormValue("to_state_id"))
+	if err != nil || toStateId <= 0 {
+		http.Error(w, "Invalid to state ID", http.StatusBadRequest)
+		return
+	}
+
+	if fromStateId == toStateId {
+		http.Error(w, "A transition needs two different states", http.StatusBadRequest)
+		return
+	}
+
+	if !authorizeProject(w, r, uint(projectId), models.ActionEditProject) {
+		return
+	}
+
+	for _, id := range []int{fromStateId, toStateId} {
+		if _, err := workflowRepository.GetState(uint(projectId), uint(id)); err != nil {
+			http.Error(w, "State not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	allowed, err := workflowRepository.IsTransitionAllowed(uint(fromStateId), uint(toStateId))
+	if err != nil {
+		http.Error(w, "Failed to check transition", http.StatusInternalServerError)
+		return
+	}
+	if allowed {
+		http.Error(w, "Transition already exists", http.StatusConflict)
+		return
+	}
+
+	transition := models.WorkflowTransition{
+		ProjectId:   uint(projectId),
+		FromStateId: uint(fromStateId),
+		ToStateId:   uint(toStateId),
+	}
+	if err := workflowRepository.AddTransition(&transition); err != nil {
+		http.Error(w, "Failed to add transition", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(transition); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func RemoveWorkflowTransition(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId, err := strconv.Atoi(r.FormValue("project_id"))
+	if err != nil || projectId <= 0 {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	transitionId, err := strconv.Atoi(r.FormValue("transition_id"))
+	if err != nil || transitionId <= 0 {
+		http.Error(w, "Invalid transition ID", http.StatusBadRequest)
+		return
+	}
+
+	if !authorizeProject(w, r, uint(projectId), models.ActionEditProject) {
+		return
+	}
+
+	removed, err := workflowRepository.RemoveTransition(uint(projectId), uint(transitionId))
+	if err != nil {
+		http.Error(w, "Failed to remove transition", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "Transition not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Transition removed successfully"})
+}
+
+// GetTaskTransitions returns the state history of a task.
+func GetTaskTransitions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskId, err := strconv.Atoi(r.FormValue("task_id"))
+	if err != nil || taskId <= 0 {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	task, err := tasksFor(r).GetTaskById(uint(taskId))
+	if err != nil {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	if !authorizeProject(w, r, task.ProjectId, models.ActionViewTasks) {
+		return
+	}
+
+	transitions, err := workflowRepository.GetTaskTransitions(task.ID)
+	if err != nil {
+		http.Error(w, "Failed to get transitions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(transitions); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func parseStateIds(w http.ResponseWriter, r *http.Request) (uint, uint, bool) {
+	projectId, err := strconv.Atoi(r.FormValue("project_id"))
+	if err != nil || projectId <= 0 {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	stateId, err := strconv.Atoi(r.FormValue("state_id"))
+	if err != nil || stateId <= 0 {
+		http.Error(w, "Invalid state ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	return uint(projectId), uint(stateId), true
+}
+
+// parseStateSettings applies the optional position, is_initial and is_done
+// fields of the request to state.
+func parseStateSettings(w http.ResponseWriter, r *http.Request, state *models.WorkflowState) bool {
+	if positionStr := r.FormValue("position"); positionStr != "" {
+		position, err := strconv.Atoi(positionStr)
+		if err != nil || position <= 0 {
+			http.Error(w, "Invalid position", http.StatusBadRequest)
+			return false
+		}
+		state.Position = position
+	}
+	flags := []struct {
+		field  string
+		target *bool
+	}{
+		{"is_initial", &state.IsInitial},
+		{"is_done", &state.IsDone},
+	}
+	for _, flag := range flags {
+		if value := r.FormValue(flag.field); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				http.Error(w, "Invalid value for "+flag.field, http.StatusBadRequest)
+				return false
+			}
+			*flag.target = parsed
+		}
+	}
+	return true
+}
@@ -0,0 +1,468 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/repository"
+	"gorm.io/gorm"
+)
+
+var worklogRepository repository.WorklogRepository
+
+// maxWorklogDuration bounds a single manual entry.
+const maxWorklogDuration = 24 * time.Hour
+
+// StartTimer starts tracking time on a task for the current user, who can
+// only have one timer running.
+func StartTimer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskId, err := strconv.Atoi(r.FormValue("task_id"))
+	if err != nil || taskId <= 0 {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	task, err := tasksFor(r).GetTaskById(uint(taskId))
+	if err != nil {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	if !authorizeProject(w, r, task.ProjectId, models.ActionEditTask) {
+		return
+	}
+
+	worklog := models.Worklog{
+		TaskId: task.ID,
+		UserId: CurrentUser(r).ID,
+		Note:   strings.TrimSpace(r.FormValue("note")),
+	}
+	if err := worklogRepository.StartTimer(&worklog); err != nil {
+		if errors.Is(err, repository.ErrTimerRunning) {
+			http.Error(w, "Another timer is already running; stop it first", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to start timer", http.StatusInternalServerError)
+		return
+	}
+	worklog.User = *CurrentUser(r)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(worklog); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// StopTimer stops the running timer of the current user and logs its time.
+// Users can always stop their own timer, even after its task was deleted or
+// they lost access to it.
+func StopTimer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	worklog, err := worklogRepository.StopTimer(CurrentUser(r).ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNoTimer) {
+			http.Error(w, "No timer is running", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to stop timer", http.StatusInternalServerError)
+		return
+	}
+	worklog.User = *CurrentUser(r)
+	if !hideTimerTask(w, r, worklog) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(worklog); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetTimer returns the running timer of the current user, or null.
+func GetTimer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	worklog, err := worklogRepository.GetRunningTimer(CurrentUser(r).ID)
+	if err != nil {
+		http.Error(w, "Failed to get timer", http.StatusInternalServerError)
+		return
+	}
+	if worklog != nil && !hideTimerTask(w, r, worklog) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(worklog); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// hideTimerTask leaves the task and the note out of a timer of the current
+// user unless the request may view the task. That is the case only within
+// the task's organization, with a role in its project, and with a token
+// covering that project.
+func hideTimerTask(w http.ResponseWriter, r *http.Request, worklog *models.Worklog) bool {
+	visible, err := canViewTask(r, worklog.TaskId)
+	if err != nil {
+		http.Error(w, "Failed to check project access", http.StatusInternalServerError)
+		return false
+	}
+	if !visible {
+		worklog.TaskId = 0
+		worklog.Note = ""
+	}
+	return true
+}
+
+func canViewTask(r *http.Request, taskId uint) (bool, error) {
+	task, err := tasksFor(r).GetTaskById(taskId)
+	if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, repository.ErrNoOrganization) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !tokenAllowsProject(r, task.ProjectId) {
+		return false, nil
+	}
+	role, err := projectMemberRepository.GetEffectiveRole(task.ProjectId, CurrentUser(r).ID)
+	if err != nil {
+		return false, err
+	}
+	return models.CanPerformProjectAction(role, models.ActionViewTasks), nil
+}
+
+// GetWorklogs lists the time logged on a task.
+func GetWorklogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskId, err := strconv.Atoi(r.FormValue("task_id"))
+	if err != nil || taskId <= 0 {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	task, err := tasksFor(r).GetTaskById(uint(taskId))
+	if err != nil {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	if !authorizeProject(w, r, task.ProjectId, models.ActionViewTasks) {
+		return
+	}
+
+	worklogs, err := worklogRepository.GetTaskWorklogs(task.ID)
+	if err != nil {
+		http.Error(w, "Failed to get worklogs", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(worklogs); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// AddWorklog logs time spent on a task by hand. duration is in minutes or
+// a Go duration such as 1h30m; started_at defaults to duration ago.
+func AddWorklog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskId, err := strconv.Atoi(r.FormValue("task_id"))
+	if err != nil || taskId <= 0 {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	task, err := tasksFor(r).GetTaskById(uint(taskId))
+	if err != nil {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	if !authorizeProject(w, r, task.ProjectId, models.ActionEditTask) {
+		return
+	}
+
+	worklog := models.Worklog{
+		TaskId: task.ID,
+		UserId: CurrentUser(r).ID,
+	}
+	if !parseWorklog(w, r, &worklog) {
+		return
+	}
+
+	if err := worklogRepository.AddWorklog(&worklog); err != nil {
+		http.Error(w, "Failed to add worklog", http.StatusInternalServerError)
+		return
+	}
+	worklog.User = *CurrentUser(r)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(worklog); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// UpdateWorklog lets users correct their own finished worklogs.
+func UpdateWorklog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	worklog, _, ok := getTaskWorklog(w, r, models.ActionEditTask)
+	if !ok {
+		return
+	}
+
+	if worklog.UserId != CurrentUser(r).ID {
+		http.Error(w, "Only your own worklogs can be changed", http.StatusForbidden)
+		return
+	}
+	if worklog.EndedAt == nil {
+		http.Error(w, "Stop the timer before changing it", http.StatusConflict)
+		return
+	}
+
+	if !r.Form.Has("duration") {
+		r.Form.Set("duration", strconv.FormatInt(worklog.Seconds/60, 10))
+	}
+	if !r.Form.Has("started_at") {
+		r.Form.Set("started_at", worklog.StartedAt.Format(time.RFC3339))
+	}
+	if !r.Form.Has("note") {
+		r.Form.Set("note", worklog.Note)
+	}
+	if !parseWorklog(w, r, worklog) {
+		return
+	}
+
+	if err := worklogRepository.UpdateWorklog(worklog); err != nil {
+		http.Error(w, "Failed to update worklog", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(worklog); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// DeleteWorklog lets users delete their own worklogs and project
+// maintainers delete anyone's.
+func DeleteWorklog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	worklog, task, ok := getTaskWorklog(w, r, models.ActionViewTasks)
+	if !ok {
+		return
+	}
+
+	action := models.ActionEditTask
+	if worklog.UserId != CurrentUser(r).ID {
+		action = models.ActionEditProject
+	}
+	if !authorizeProject(w, r, task.ProjectId, action) {
+		return
+	}
+
+	if err := worklogRepository.DeleteWorklog(worklog.ID); err != nil {
+		http.Error(w, "Failed to delete worklog", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetTimeReport sums logged time by group_by (task, project, user or day)
+// over the projects of the organization the caller can access, optionally
+// narrowed to a project, task, user and date range. Dates are days in
+// timezone; to is inclusive.
+func GetTimeReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter := repository.TimeReportFilter{GroupBy: r.FormValue("group_by")}
+	if filter.GroupBy == "" {
+		filter.GroupBy = models.ReportByTask
+	}
+	if !models.IsValidReportGroup(filter.GroupBy) {
+		http.Error(w, "Group by must be task, project, user or day", http.StatusBadRequest)
+		return
+	}
+
+	ids := []struct {
+		field  string
+		target *uint
+	}{
+		{"project_id", &filter.ProjectId},
+		{"task_id", &filter.TaskId},
+		{"user_id", &filter.UserId},
+	}
+	for _, id := range ids {
+		value := r.FormValue(id.field)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "Invalid "+strings.ReplaceAll(id.field, "_", " "), http.StatusBadRequest)
+			return
+		}
+		*id.target = uint(parsed)
+	}
+	if filter.ProjectId != 0 && !authorizeProject(w, r, filter.ProjectId, models.ActionViewTasks) {
+		return
+	}
+
+	location, ok := requestLocation(w, r)
+	if !ok {
+		return
+	}
+	filter.Location = location
+
+	if value := r.FormValue("from"); value != "" {
+		from, _, err := parseTaskDate(value, location)
+		if err != nil {
+			http.Error(w, "Invalid from date", http.StatusBadRequest)
+			return
+		}
+		filter.From = &from
+	}
+	if value := r.FormValue("to"); value != "" {
+		to, isDay, err := parseTaskDate(value, location)
+		if err != nil {
+			http.Error(w, "Invalid to date", http.StatusBadRequest)
+			return
+		}
+		if isDay {
+			to = to.AddDate(0, 0, 1)
+		}
+		filter.Before = &to
+	}
+
+	report, err := worklogRepository.TimeReport(CurrentOrganizationId(r), CurrentUser(r).ID, filter)
+	if err != nil {
+		http.Error(w, "Failed to build report", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// getTaskWorklog loads worklog_id and its task, checking that the task
+// belongs to the current organization and the caller may perform action.
+func getTaskWorklog(w http.ResponseWriter, r *http.Request, action models.ProjectAction) (*models.Worklog, *models.Task, bool) {
+	worklogId, err := strconv.Atoi(r.FormValue("worklog_id"))
+	if err != nil || worklogId <= 0 {
+		http.Error(w, "Invalid worklog ID", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	worklog, err := worklogRepository.GetWorklog(uint(worklogId))
+	if err != nil {
+		http.Error(w, "Worklog not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	task, err := tasksFor(r).GetTaskById(worklog.TaskId)
+	if err != nil {
+		http.Error(w, "Worklog not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	if !authorizeProject(w, r, task.ProjectId, action) {
+		return nil, nil, false
+	}
+	return worklog, task, true
+}
+
+// parseWorklog reads duration, started_at and note into worklog.
+func parseWorklog(w http.ResponseWriter, r *http.Request, worklog *models.Worklog) bool {
+	duration, err := parseWorkDuration(r.FormValue("duration"))
+	if err != nil || duration < time.Minute || duration > maxWorklogDuration {
+		http.Error(w, "Duration must be between 1 minute and 24 hours", http.StatusBadRequest)
+		return false
+	}
+	worklog.Seconds = int64(duration / time.Second)
+
+	worklog.StartedAt = time.Now().Add(-duration)
+	if value := r.FormValue("started_at"); value != "" {
+		startedAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			http.Error(w, "Invalid start time", http.StatusBadRequest)
+			return false
+		}
+		worklog.StartedAt = startedAt
+	}
+	if worklog.StartedAt.Add(duration).After(time.Now().Add(time.Minute)) {
+		http.Error(w, "Worklogs cannot end in the future", http.StatusBadRequest)
+		return false
+	}
+
+	worklog.Note = strings.TrimSpace(r.FormValue("note"))
+	return true
+}
+
+// parseWorkDuration accepts a number of minutes or a Go duration such as
+// 1h30m.
+func parseWorkDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if minutes, err := strconv.Atoi(value); err == nil {
+		if minutes < 0 {
+			return 0, errors.New("negative duration")
+		}
+		return time.Duration(minutes) * time.Minute, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if duration < 0 {
+		return 0, errors.New("negative duration")
+	}
+	return duration, nil
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/database/dbtest"
+	"github.com/aminasadiam/DevTasks/internal/models"
+)
+
+// TestTimerTaskAccess checks that users can always see and stop their own
+// running timer, and that its task is only shown to those who may view it.
+func TestTimerTaskAccess(t *testing.T) {
+	otherProject := uint(8)
+	tests := []struct {
+		name string
+		role string
+		// deleted leaves task 3 out of the organization.
+		deleted bool
+		// token limits the request to a project.
+		token *uint
+		// outside sends the request without an organization.
+		outside  bool
+		showTask bool
+	}{
+		{"viewer", models.ProjectRoleViewer, false, nil, false, true},
+		{"task deleted", models.ProjectRoleViewer, true, nil, false, false},
+		{"no project role", "", false, nil, false, false},
+		{"token for another project", models.ProjectRoleViewer, false, &otherProject, false, false},
+		{"outside the organization", models.ProjectRoleViewer, false, nil, true, false},
+	}
+	handlers := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"GetTimer", GetTimer},
+		{"StopTimer", StopTimer},
+	}
+	for _, test := range tests {
+		for _, h := range handlers {
+			t.Run(test.name+"/"+h.name, func(t *testing.T) {
+				fake := testDB(t)
+				projectFixture(fake, test.role)
+				if !test.deleted {
+					fake.Return(`FROM "tasks"`, dbtest.Records(testTask()))
+				}
+				running := models.Worklog{TaskId: 3, UserId: 1, StartedAt: time.Now().Add(-10 * time.Minute), Note: "Flaky test"}
+				running.ID = 9
+				fake.Return(`FROM "worklogs"`, dbtest.Records(running))
+				fake.Return(`UPDATE "worklogs"`, dbtest.Affected(1))
+				fake.Return(`UPDATE "tasks"`, dbtest.Affected(1))
+
+				r := newRequest(http.MethodPost, "/api/timer", nil)
+				if test.outside {
+					session := testSession(5, 1)
+					r = signedIn(r, &session)
+				} else {
+					r = inOrganization(r, models.OrgRoleMember)
+				}
+				if test.token != nil {
+					token := testToken(models.TokenScopeWrite, nil)
+					token.ProjectId = test.token
+					r = withToken(r, &token)
+				}
+				w := serve(h.handler, r)
+				if w.Code != http.StatusOK {
+					t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+				}
+
+				var timer struct {
+					ID     uint
+					TaskId uint
+					Note   string
+				}
+				if err := json.NewDecoder(w.Body).Decode(&timer); err != nil {
+					t.Fatal(err)
+				}
+				if timer.ID != 9 {
+					t.Errorf("timer = %d, want 9", timer.ID)
+				}
+				if shown := timer.TaskId == 3 && timer.Note == "Flaky test"; shown != test.showTask {
+					t.Errorf("task %d with note %q shown, want shown = %v", timer.TaskId, timer.Note, test.showTask)
+				}
+				if h.name == "StopTimer" && len(fake.Find(`UPDATE "worklogs"`)) != 1 {
+					t.Errorf("timer was not stopped: %v", fake.Statements())
+				}
+			})
+		}
+	}
+}
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"errors"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -19,9 +22,63 @@ type ServerConfig struct {
 	Port string
 }
 
+type MailConfig struct {
+	Driver       string
+	From         string
+	AppURL       string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUser     string
+	SMTPPassword string
+	LogFile      string
+}
+
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	PostLoginURL string
+}
+
+type PasswordConfig struct {
+	Algorithm         string
+	BcryptCost        int
+	Argon2Memory      uint32
+	Argon2Time        uint32
+	Argon2Parallelism uint8
+}
+
+type AuthConfig struct {
+	RequireVerifiedEmail bool
+	LoginThrottleStore   string
+	AdminUsernames       []string
+}
+
+type TaskConfig struct {
+	MaxDepth int
+	// RecurrenceInterval is how often, in seconds, recurring tasks are
+	// checked for due occurrences.
+	RecurrenceInterval int
+}
+
+type StorageConfig struct {
+	Driver        string
+	LocalPath     string
+	S3Endpoint    string
+	S3Region      string
+	S3Bucket      string
+	S3AccessKey   string
+	S3SecretKey   string
+	S3PathStyle   bool
+	MaxUploadSize int64
+}
+
 func init() {
+	// Without a .env file the settings come from the environment alone.
 	err := godotenv.Load()
-	if err != nil {
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
 		log.Fatalf("Unable to load env file: %v", err)
 	}
 }
@@ -41,3 +98,93 @@ func LoadServerConfig() *ServerConfig {
 		Port: os.Getenv("SERVERPORT"),
 	}
 }
+
+func LoadMailConfig() *MailConfig {
+	return &MailConfig{
+		Driver:       os.Getenv("MAIL_DRIVER"),
+		From:         os.Getenv("MAIL_FROM"),
+		AppURL:       os.Getenv("APP_URL"),
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     os.Getenv("SMTP_PORT"),
+		SMTPUser:     os.Getenv("SMTP_USER"),
+		SMTPPassword: os.Getenv("SMTP_PASS"),
+		LogFile:      os.Getenv("MAIL_LOG_FILE"),
+	}
+}
+
+func LoadAuthConfig() *AuthConfig {
+	requireVerifiedEmail, _ := strconv.ParseBool(os.Getenv("REQUIRE_VERIFIED_EMAIL"))
+	return &AuthConfig{
+		RequireVerifiedEmail: requireVerifiedEmail,
+		LoginThrottleStore:   os.Getenv("LOGIN_THROTTLE_STORE"),
+		AdminUsernames:       strings.Fields(strings.ReplaceAll(os.Getenv("ADMIN_USERNAMES"), ",", " ")),
+	}
+}
+
+func LoadOIDCConfig() *OIDCConfig {
+	postLoginURL := os.Getenv("OIDC_POST_LOGIN_URL")
+	if postLoginURL == "" {
+		postLoginURL = os.Getenv("APP_URL")
+	}
+
+	return &OIDCConfig{
+		Issuer:       os.Getenv("OIDC_ISSUER"),
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:       strings.Fields(os.Getenv("OIDC_SCOPES")),
+		PostLoginURL: postLoginURL,
+	}
+}
+
+func LoadPasswordConfig() *PasswordConfig {
+	return &PasswordConfig{
+		Algorithm:         os.Getenv("PASSWORD_HASH"),
+		BcryptCost:        envInt("BCRYPT_COST", 14),
+		Argon2Memory:      uint32(envInt("ARGON2_MEMORY", 19456)),
+		Argon2Time:        uint32(envInt("ARGON2_TIME", 2)),
+		Argon2Parallelism: uint8(envInt("ARGON2_PARALLELISM", 1)),
+	}
+}
+
+func LoadTaskConfig() *TaskConfig {
+	return &TaskConfig{
+		MaxDepth:           envInt("TASK_MAX_DEPTH", 3),
+		RecurrenceInterval: envInt("RECURRENCE_INTERVAL", 60),
+	}
+}
+
+func LoadStorageConfig() *StorageConfig {
+	localPath := os.Getenv("STORAGE_PATH")
+	if localPath == "" {
+		localPath = "data/attachments"
+	}
+	region := os.Getenv("S3_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	pathStyle, err := strconv.ParseBool(os.Getenv("S3_PATH_STYLE"))
+	if err != nil {
+		pathStyle = true
+	}
+
+	return &StorageConfig{
+		Driver:        os.Getenv("STORAGE_DRIVER"),
+		LocalPath:     localPath,
+		S3Endpoint:    os.Getenv("S3_ENDPOINT"),
+		S3Region:      region,
+		S3Bucket:      os.Getenv("S3_BUCKET"),
+		S3AccessKey:   os.Getenv("S3_ACCESS_KEY"),
+		S3SecretKey:   os.Getenv("S3_SECRET_KEY"),
+		S3PathStyle:   pathStyle,
+		MaxUploadSize: int64(envInt("MAX_UPLOAD_SIZE_MB", 25)) << 20,
+	}
+}
+
+func envInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
@@ -13,8 +13,7 @@ const ProtectedRoute: Component<ParentProps> = (props: ParentProps) => {
 
   const check = async () => {
     setLoading(true);
-    const currentUsername = localStorage.getItem("username") || "";
-    const result = await checkAuth(currentUsername);
+    const result = await checkAuth();
     setLoading(false);
   };
 
@@ -87,7 +87,7 @@ function getCookie(name: string): string {
   return match ? match[2] : '';
 }
 
-export const checkAuth = async (username: string): Promise<boolean> => {
+export const checkAuth = async (): Promise<boolean> => {
   try {
     const sessionToken = getCookie('session_token');
     const csrfToken = getCookie('csrf_token');
@@ -97,15 +97,11 @@ export const checkAuth = async (username: string): Promise<boolean> => {
       return false;
     }
 
-    const formData = new URLSearchParams();
-    formData.append('username', username);
-
     const response = await fetch('http://localhost:3000/api/validate', {
       method: 'POST',
       headers: {
         'X-CSRF-Token': csrfToken,
       },
-      body: formData,
       credentials: 'include',
     });
 
@@ -124,19 +120,15 @@ export const checkAuth = async (username: string): Promise<boolean> => {
   }
 };
 
-export const logout = async (username: string, navigate: (path: string) => void): Promise<void> => {
+export const logout = async (navigate: (path: string) => void): Promise<void> => {
   try {
     const csrfToken = getCookie('csrf_token');
 
-    const formData = new URLSearchParams();
-    formData.append('username', username);
-
     const response = await fetch('http://localhost:3000/api/logout', {
       method: 'POST',
       headers: {
         'X-CSRF-Token': csrfToken,
       },
-      body: formData,
       credentials: 'include',
     });
 
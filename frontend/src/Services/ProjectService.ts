@@ -20,15 +20,12 @@ interface ProjectError {
   error: string;
 }
 
-export const getProjects = async (username: string): Promise<Project[]> => {
+export const getProjects = async (): Promise<Project[]> => {
   if (!isAuthenticated()) {
     throw new Error('Not authenticated');
   }
 
   try {
-    const formData = new URLSearchParams();
-    formData.append('username', username);
-
     const csrf_token = getCookie('csrf_token');
 
     const response = await fetch('http://localhost:3000/api/projects', {
@@ -36,7 +33,6 @@ export const getProjects = async (username: string): Promise<Project[]> => {
       headers: {
         'X-CSRF-Token': csrf_token,
       },
-      body: formData,
       credentials: 'include',
     });
 
@@ -51,14 +47,13 @@ export const getProjects = async (username: string): Promise<Project[]> => {
   }
 };
 
-export const addProject = async (username: string, name: string, description?: string) => {
+export const addProject = async (name: string, description?: string) => {
   if (!isAuthenticated()) {
     throw new Error('Not authenticated');
   }
 
   try {
     const formData = new URLSearchParams();
-    formData.append('username', username);
     formData.append('name', name);
     if (description) formData.append('description', description);
 
@@ -84,7 +79,7 @@ export const addProject = async (username: string, name: string, description?: s
   }
 };
 
-export const getProjectViaID = async (projectID: string, username: string): Promise<Project> => {
+export const getProjectViaID = async (projectID: string): Promise<Project> => {
   if (!isAuthenticated()) {
     throw new Error('Not authenticated');
   }
@@ -97,7 +92,6 @@ export const getProjectViaID = async (projectID: string, username: string): Prom
     }
 
     const formData = new URLSearchParams();
-    formData.append('username', username);
     formData.append('project_id', projectID);
 
     const response = await fetch(`http://localhost:3000/api/project`, {
@@ -120,14 +114,13 @@ export const getProjectViaID = async (projectID: string, username: string): Prom
   }
 }
 
-export const getProjectTasks = async (projectID: string, username: string): Promise<Task[]> => {
+export const getProjectTasks = async (projectID: string): Promise<Task[]> => {
   if (!isAuthenticated()) {
     throw new Error('Not authenticated');
   }
 
   try {
     const formData = new URLSearchParams();
-    formData.append('username', username);
     formData.append('project_id', projectID);
 
     const csrf_token = getCookie('csrf_token');
@@ -152,14 +145,13 @@ export const getProjectTasks = async (projectID: string, username: string): Prom
   }
 }
 
-export const addTask = async (username: string, projectId: string, title: string, description?: string) => {
+export const addTask = async (projectId: string, title: string, description?: string) => {
   if (!isAuthenticated()) {
     throw new Error('Not authenticated');
   }
 
   try {
     const formData = new URLSearchParams();
-    formData.append('username', username);
     formData.append('project_id', projectId);
     formData.append('title', title);
     if (description) formData.append('description', description);
@@ -186,7 +178,7 @@ export const addTask = async (username: string, projectId: string, title: string
   }
 }
 
-export const getTaskById = async (taskId: string, username: string): Promise<Task> => {
+export const getTaskById = async (taskId: string): Promise<Task> => {
   if (!isAuthenticated()) {
     throw new Error('Not authenticated');
   }
@@ -194,7 +186,7 @@ export const getTaskById = async (taskId: string, username: string): Promise<Tas
   try {
     const csrf_token = getCookie('csrf_token');
 
-    const response = await fetch(`http://localhost:3000/api/task?task_id=${taskId}&username=${username}`, {
+    const response = await fetch(`http://localhost:3000/api/task?task_id=${taskId}`, {
       method: 'GET',
       headers: {
         'X-CSRF-Token': csrf_token,
@@ -213,14 +205,13 @@ export const getTaskById = async (taskId: string, username: string): Promise<Tas
   }
 }
 
-export const updateTask = async (taskId: string, username: string, title: string, description?: string) => {
+export const updateTask = async (taskId: string, title: string, description?: string) => {
   if (!isAuthenticated()) {
     throw new Error('Not authenticated');
   }
 
   try {
     const formData = new URLSearchParams();
-    formData.append('username', username);
     formData.append('task_id', taskId);
     formData.append('title', title);
     if (description) formData.append('description', description);
@@ -247,14 +238,13 @@ export const updateTask = async (taskId: string, username: string, title: string
   }
 }
 
-export const deleteTask = async (taskId: string, username: string) => {
+export const deleteTask = async (taskId: string) => {
   if (!isAuthenticated()) {
     throw new Error('Not authenticated');
   }
 
   try {
     const formData = new URLSearchParams();
-    formData.append('username', username);
     formData.append('task_id', taskId);
 
     const csrf_token = getCookie('csrf_token');
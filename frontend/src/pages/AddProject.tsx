@@ -5,7 +5,6 @@ import GlassCard from "../components/GlassCard.jsx";
 
 const AddProject: Component = () => {
   const navigate = useNavigate();
-  const username = localStorage.getItem("username") || "";
   const [name, setName] = createSignal("");
   const [description, setDescription] = createSignal("");
   const [error, setError] = createSignal("");
@@ -21,7 +20,7 @@ const AddProject: Component = () => {
     setError("");
 
     try {
-      await addProject(username, name(), description());
+      await addProject(name(), description());
       navigate("/");
     } catch (err) {
       setError(err instanceof Error ? err.message : "Failed to create project");
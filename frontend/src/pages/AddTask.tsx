@@ -6,7 +6,6 @@ import GlassCard from "../components/GlassCard.jsx";
 const AddTask: Component = () => {
   const params = useParams();
   const navigate = useNavigate();
-  const username = localStorage.getItem("username") || "";
   const [title, setTitle] = createSignal("");
   const [description, setDescription] = createSignal("");
   const [error, setError] = createSignal("");
@@ -22,7 +21,7 @@ const AddTask: Component = () => {
     setError("");
 
     try {
-      await addTask(username, params.project_id, title(), description());
+      await addTask(params.project_id, title(), description());
       console.log(
         "Task created successfully, navigating to project:",
         params.project_id
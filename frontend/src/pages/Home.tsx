@@ -14,7 +14,7 @@ const Home: Component = () => {
     const fetchProjects = async () => {
       try {
         setIsLoading(true);
-        const data = await getProjects(username);
+        const data = await getProjects();
         setProjects(data);
       } catch (err) {
         setError(
@@ -12,7 +12,6 @@ const ProjectDetails: Component = () => {
   const params = useParams();
   const navigate = useNavigate();
   const id = Number(params.id);
-  const username = localStorage.getItem("username") || "";
   const [project, setProject] = createSignal<Project | null>(null);
   const [tasks, setTasks] = createSignal<Task[]>([]);
   const [error, setError] = createSignal("");
@@ -22,9 +21,9 @@ const ProjectDetails: Component = () => {
     const fetchProject = async () => {
       try {
         setIsLoading(true);
-        const data = await getProjectViaID(id.toString(), username);
+        const data = await getProjectViaID(id.toString());
         setProject(data);
-        const taskData = await getProjectTasks(id.toString(), username);
+        const taskData = await getProjectTasks(id.toString());
         setTasks(taskData);
       } catch (err) {
         setError(
@@ -10,7 +10,6 @@ import {
 
 const Task: Component = () => {
   const params = useParams();
-  const username: string = localStorage.getItem("username") || "";
   const navigate = useNavigate();
 
   const [task, setTask] = createSignal<Task | null>(null);
@@ -28,8 +27,8 @@ const Task: Component = () => {
     try {
       setIsLoading(true);
       setError("");
-      console.log("Loading task with ID:", params.id, "for user:", username);
-      const taskData = await getTaskById(params.id, username);
+      console.log("Loading task with ID:", params.id);
+      const taskData = await getTaskById(params.id);
       console.log("Task data received:", taskData);
       setTask(taskData);
       setEditTitle(taskData.Title);
@@ -53,7 +52,6 @@ const Task: Component = () => {
       setError("");
       const updatedTask = await updateTask(
         params.id,
-        username,
         editTitle(),
         editDescription()
       );
@@ -78,7 +76,7 @@ const Task: Component = () => {
     try {
       setIsDeleting(true);
       setError("");
-      await deleteTask(params.id, username);
+      await deleteTask(params.id);
       navigate(`/project/${params.project_id}`);
     } catch (err) {
       setError(err instanceof Error ? err.message : "Failed to delete task");
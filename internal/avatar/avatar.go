@@ -0,0 +1,138 @@
+// Package avatar turns uploaded pictures into square profile images and
+// draws identicons for users without one.
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+// Sizes are the edge lengths, in pixels, of the images generated for every
+// avatar, largest first.
+var Sizes = []int{256, 128, 64, 32}
+
+// maxPixels bounds the size of images that are decoded at all, so that a
+// small file cannot expand into an enormous bitmap.
+const maxPixels = 25_000_000
+
+var (
+	ErrUnsupportedImage = errors.New("image must be a PNG, JPEG or GIF")
+	ErrImageTooLarge    = errors.New("image dimensions are too large")
+)
+
+// IsValidSize reports whether size is one of Sizes.
+func IsValidSize(size int) bool {
+	for _, s := range Sizes {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
+
+// Process decodes an uploaded picture, crops its center to a square, turns
+// it upright according to its EXIF orientation and returns it as PNG in
+// each of Sizes. Re-encoding drops EXIF and any other metadata.
+func Process(data []byte) (map[int][]byte, error) {
+	switch http.DetectContentType(data) {
+	case "image/png", "image/jpeg", "image/gif":
+	default:
+		return nil, ErrUnsupportedImage
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxPixels {
+		return nil, ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+
+	// Cropping the center commutes with the EXIF rotations and flips, so
+	// only the square has to be turned.
+	square := orient(cropSquare(img), jpegOrientation(data))
+
+	images := make(map[int][]byte, len(Sizes))
+	current := square
+	for _, size := range Sizes {
+		current = resize(current, size)
+		encoded, err := EncodePNG(current)
+		if err != nil {
+			return nil, err
+		}
+		images[size] = encoded
+	}
+	return images, nil
+}
+
+func EncodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func cropSquare(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	origin := image.Pt(
+		bounds.Min.X+(bounds.Dx()-side)/2,
+		bounds.Min.Y+(bounds.Dy()-side)/2,
+	)
+	square := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(square, square.Bounds(), img, origin, draw.Src)
+	return square
+}
+
+// resize scales a square image to size×size. Each target pixel averages
+// the source pixels it covers, weighted by coverage, which keeps
+// downscaled pictures smooth; upscaling degrades to nearest neighbour.
+func resize(src *image.RGBA, size int) *image.RGBA {
+	side := src.Bounds().Dx()
+	if side == size {
+		return src
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	scale := float64(side) / float64(size)
+
+	for dy := 0; dy < size; dy++ {
+		y0, y1 := float64(dy)*scale, float64(dy+1)*scale
+		for dx := 0; dx < size; dx++ {
+			x0, x1 := float64(dx)*scale, float64(dx+1)*scale
+
+			var r, g, b, a, total float64
+			for sy := int(y0); sy < side && float64(sy) < y1; sy++ {
+				wy := min(y1, float64(sy+1)) - max(y0, float64(sy))
+				for sx := int(x0); sx < side && float64(sx) < x1; sx++ {
+					w := wy * (min(x1, float64(sx+1)) - max(x0, float64(sx)))
+					i := src.PixOffset(sx, sy)
+					r += w * float64(src.Pix[i])
+					g += w * float64(src.Pix[i+1])
+					b += w * float64(src.Pix[i+2])
+					a += w * float64(src.Pix[i+3])
+					total += w
+				}
+			}
+
+			i := dst.PixOffset(dx, dy)
+			dst.Pix[i] = uint8(r/total + 0.5)
+			dst.Pix[i+1] = uint8(g/total + 0.5)
+			dst.Pix[i+2] = uint8(b/total + 0.5)
+			dst.Pix[i+3] = uint8(a/total + 0.5)
+		}
+	}
+	return dst
+}
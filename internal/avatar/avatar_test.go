@@ -0,0 +1,168 @@
+package avatar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+var (
+	red   = color.RGBA{255, 0, 0, 255}
+	green = color.RGBA{0, 255, 0, 255}
+	blue  = color.RGBA{0, 0, 255, 255}
+	white = color.RGBA{255, 255, 255, 255}
+)
+
+// quadrants returns a side×side image whose quadrants are red, green (top)
+// and blue, white (bottom).
+func quadrants(side int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, side, side))
+	half := side / 2
+	draw.Draw(img, image.Rect(0, 0, half, half), image.NewUniform(red), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(half, 0, side, half), image.NewUniform(green), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, half, half, side), image.NewUniform(blue), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(half, half, side, side), image.NewUniform(white), image.Point{}, draw.Src)
+	return img
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decodePNG(t *testing.T, data []byte) image.Image {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// near reports whether c is within a JPEG's rounding of want.
+func near(c color.Color, want color.RGBA) bool {
+	r, g, b, _ := c.RGBA()
+	diff := func(got uint32, want uint8) bool {
+		d := int(got>>8) - int(want)
+		return d > -40 && d < 40
+	}
+	return diff(r, want.R) && diff(g, want.G) && diff(b, want.B)
+}
+
+func TestProcess(t *testing.T) {
+	images, err := Process(encodeJPEG(t, quadrants(64)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != len(Sizes) {
+		t.Fatalf("got %d sizes, want %d", len(images), len(Sizes))
+	}
+	for _, size := range Sizes {
+		img := decodePNG(t, images[size])
+		if img.Bounds() != image.Rect(0, 0, size, size) {
+			t.Errorf("size %d: bounds %v", size, img.Bounds())
+		}
+		quarter := size / 4
+		if !near(img.At(quarter, quarter), red) || !near(img.At(size-quarter, size-quarter), white) {
+			t.Errorf("size %d: quadrants moved", size)
+		}
+	}
+}
+
+func TestProcessAppliesOrientation(t *testing.T) {
+	// Each orientation moves the red top-left quadrant of the stored image
+	// to where a viewer would see it.
+	corners := map[int]image.Point{
+		1: {0, 0}, 2: {1, 0}, 3: {1, 1}, 4: {0, 1},
+		5: {0, 0}, 6: {1, 0}, 7: {1, 1}, 8: {0, 1},
+	}
+	stored := encodeJPEG(t, quadrants(64))
+	for orientation, corner := range corners {
+		data := withSegments(stored, exifSegment(binary.BigEndian, uint16(orientation)))
+		images, err := Process(data)
+		if err != nil {
+			t.Fatalf("orientation %d: %v", orientation, err)
+		}
+		img := decodePNG(t, images[32])
+		if at := img.At(8+16*corner.X, 8+16*corner.Y); !near(at, red) {
+			t.Errorf("orientation %d: %v at the corner %v, want red", orientation, at, corner)
+		}
+		// Re-encoding leaves no trace of the EXIF block.
+		for _, size := range Sizes {
+			if bytes.Contains(images[size], []byte("Exif")) {
+				t.Errorf("orientation %d: EXIF kept in size %d", orientation, size)
+			}
+		}
+	}
+}
+
+func TestProcessCropsCenter(t *testing.T) {
+	// A wide image with a green center square between red margins.
+	img := image.NewRGBA(image.Rect(0, 0, 96, 32))
+	draw.Draw(img, img.Bounds(), image.NewUniform(red), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(32, 0, 64, 32), image.NewUniform(green), image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	images, err := Process(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := decodePNG(t, images[32])
+	for _, p := range []image.Point{{0, 0}, {31, 0}, {0, 31}, {31, 31}, {16, 16}} {
+		if at := out.At(p.X, p.Y); !near(at, green) {
+			t.Errorf("%v is %v, want the green center", p, at)
+		}
+	}
+}
+
+// pngWithSize returns a valid 1×1 PNG whose header claims width×height.
+func pngWithSize(t *testing.T, width, height uint32) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// IHDR follows the 8-byte signature, its length and its type.
+	ihdr := data[12 : 12+4+13]
+	binary.BigEndian.PutUint32(ihdr[4:], width)
+	binary.BigEndian.PutUint32(ihdr[8:], height)
+	binary.BigEndian.PutUint32(data[12+4+13:], crc32.ChecksumIEEE(ihdr))
+	return data
+}
+
+func TestProcessRejects(t *testing.T) {
+	valid := pngWithSize(t, 1, 1)
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"text", []byte("hello, world"), ErrUnsupportedImage},
+		{"SVG", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), ErrUnsupportedImage},
+		{"too many pixels", pngWithSize(t, 6000, 5000), ErrImageTooLarge},
+		{"too wide", pngWithSize(t, 1<<30, 1), ErrImageTooLarge},
+		{"no pixels", pngWithSize(t, 0, 10), ErrUnsupportedImage},
+		{"truncated header", valid[:20], ErrUnsupportedImage},
+		{"truncated image data", valid[:len(valid)-16], ErrUnsupportedImage},
+	}
+	for _, test := range tests {
+		if _, err := Process(test.data); !errors.Is(err, test.err) {
+			t.Errorf("%s: err = %v, want %v", test.name, err, test.err)
+		}
+	}
+}
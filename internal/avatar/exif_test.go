@@ -0,0 +1,157 @@
+package avatar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// exifSegment returns an APP1 segment, marker included, whose first IFD
+// holds orientation.
+func exifSegment(order binary.ByteOrder, orientation uint16) []byte {
+	tiff := make([]byte, 8+2+12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], exifOrientationTag)
+	order.PutUint16(tiff[12:], 3) // SHORT
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], orientation)
+	return app1(append([]byte("Exif\x00\x00"), tiff...))
+}
+
+func app1(payload []byte) []byte {
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// withSegments inserts segments right after the SOI marker of a JPEG.
+func withSegments(jpeg []byte, segments ...[]byte) []byte {
+	data := append([]byte{}, jpeg[:2]...)
+	for _, segment := range segments {
+		data = append(data, segment...)
+	}
+	return append(data, jpeg[2:]...)
+}
+
+// minimalJPEG is the start of a JPEG up to its image data.
+var minimalJPEG = []byte{0xFF, 0xD8, 0xFF, 0xDA, 0x00, 0x02, 0xFF, 0xD9}
+
+func TestJPEGOrientation(t *testing.T) {
+	for orientation := uint16(1); orientation <= 8; orientation++ {
+		for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+			data := withSegments(minimalJPEG, exifSegment(order, orientation))
+			if got := jpegOrientation(data); got != int(orientation) {
+				t.Errorf("%v orientation %d: got %d", order, orientation, got)
+			}
+		}
+	}
+
+	jfif := []byte{0xFF, 0xE0, 0x00, 0x07, 'J', 'F', 'I', 'F', 0}
+	if got := jpegOrientation(withSegments(minimalJPEG, jfif, exifSegment(binary.BigEndian, 6))); got != 6 {
+		t.Errorf("EXIF after JFIF: got %d, want 6", got)
+	}
+}
+
+func TestJPEGOrientationMalformed(t *testing.T) {
+	valid := exifSegment(binary.BigEndian, 6)
+	tiff := func(edit func(tiff []byte) []byte) []byte {
+		payload := append([]byte{}, valid[4:]...)
+		return app1(append(payload[:6], edit(payload[6:])...))
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"not a JPEG", []byte("\x89PNG\r\n\x1a\n")},
+		{"segment length past the end", withSegments(minimalJPEG[:2], valid[:len(valid)-4])},
+		{"segment length below two", withSegments(minimalJPEG, []byte{0xFF, 0xE1, 0x00, 0x01})},
+		{"garbage instead of a marker", withSegments(minimalJPEG, []byte{0x00, 0xE1, 0x00, 0x02})},
+		{"EXIF after the image data", append(append([]byte{}, minimalJPEG[:6]...), valid...)},
+		{"EXIF header alone", withSegments(minimalJPEG, app1([]byte("Exif\x00\x00")))},
+		{"short TIFF header", withSegments(minimalJPEG, tiff(func(b []byte) []byte { return b[:6] }))},
+		{"unknown byte order", withSegments(minimalJPEG, tiff(func(b []byte) []byte { copy(b, "XX"); return b }))},
+		{"wrong TIFF magic", withSegments(minimalJPEG, tiff(func(b []byte) []byte { b[3] = 43; return b }))},
+		{"IFD offset past the end", withSegments(minimalJPEG, tiff(func(b []byte) []byte { binary.BigEndian.PutUint32(b[4:], 1000); return b }))},
+		{"IFD offset inside the header", withSegments(minimalJPEG, tiff(func(b []byte) []byte { binary.BigEndian.PutUint32(b[4:], 2); return b }))},
+		{"truncated IFD entry", withSegments(minimalJPEG, tiff(func(b []byte) []byte { return b[:16] }))},
+		{"more entries than data", withSegments(minimalJPEG, tiff(func(b []byte) []byte {
+			binary.BigEndian.PutUint16(b[8:], 5000)
+			binary.BigEndian.PutUint16(b[10:], 0x0100)
+			return b
+		}))},
+		{"orientation out of range", withSegments(minimalJPEG, exifSegment(binary.BigEndian, 9))},
+		{"orientation zero", withSegments(minimalJPEG, exifSegment(binary.LittleEndian, 0))},
+	}
+	for _, test := range tests {
+		if got := jpegOrientation(test.data); got != 1 {
+			t.Errorf("%s: orientation = %d, want 1", test.name, got)
+		}
+	}
+
+	// No prefix of a valid file may make the parser read out of bounds.
+	data := withSegments(minimalJPEG, exifSegment(binary.LittleEndian, 6))
+	for n := range data {
+		jpegOrientation(data[:n])
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// The 2×2 image AB/CD with a distinct color per pixel.
+	colors := map[byte]color.RGBA{
+		'A': {255, 0, 0, 255},
+		'B': {0, 255, 0, 255},
+		'C': {0, 0, 255, 255},
+		'D': {255, 255, 255, 255},
+	}
+	layout := func(img *image.RGBA) string {
+		var s []byte
+		for _, p := range []image.Point{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+			for name, c := range colors {
+				if img.RGBAAt(p.X, p.Y) == c {
+					s = append(s, name)
+				}
+			}
+		}
+		return string(s)
+	}
+	src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	src.SetRGBA(0, 0, colors['A'])
+	src.SetRGBA(1, 0, colors['B'])
+	src.SetRGBA(0, 1, colors['C'])
+	src.SetRGBA(1, 1, colors['D'])
+
+	want := map[int]string{
+		0: "ABCD",
+		1: "ABCD",
+		2: "BADC",
+		3: "DCBA",
+		4: "CDAB",
+		5: "ACBD",
+		6: "CADB",
+		7: "DBCA",
+		8: "BDAC",
+		9: "ABCD",
+	}
+	for orientation := 0; orientation <= 9; orientation++ {
+		if got := layout(orient(src, orientation)); got != want[orientation] {
+			t.Errorf("orientation %d: layout %s, want %s", orientation, got, want[orientation])
+		}
+	}
+	if got := layout(src); got != "ABCD" {
+		t.Errorf("source changed to %s", got)
+	}
+	if !bytes.Equal(orient(src, 1).Pix, src.Pix) {
+		t.Error("orientation 1 changed the image")
+	}
+}
@@ -0,0 +1,78 @@
+package avatar
+
+import (
+	"crypto/sha256"
+	"image"
+	"image/color"
+	"math"
+)
+
+// identiconGrid is the number of cells per row and column. The pattern is
+// mirrored around the middle column.
+const identiconGrid = 5
+
+var identiconBackground = color.RGBA{R: 240, G: 240, B: 240, A: 255}
+
+// Identicon draws the size×size identicon for seed. The same seed always
+// gives the same picture.
+func Identicon(seed string, size int) image.Image {
+	sum := sha256.Sum256([]byte(seed))
+	foreground := hslColor(float64(int(sum[0])<<8|int(sum[1]))/65536*360, 0.55, 0.55)
+
+	// The first 15 bits after the color pick the cells of the left three
+	// columns.
+	var cells [identiconGrid][identiconGrid]bool
+	bit := 0
+	for col := 0; col < (identiconGrid+1)/2; col++ {
+		for row := 0; row < identiconGrid; row++ {
+			on := sum[2+bit/8]&(1<<(bit%8)) != 0
+			cells[row][col] = on
+			cells[row][identiconGrid-1-col] = on
+			bit++
+		}
+	}
+
+	// Half a cell of margin on every side.
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	units := float64(identiconGrid + 1)
+	for y := 0; y < size; y++ {
+		row := int(math.Floor((float64(y)+0.5)/float64(size)*units - 0.5))
+		for x := 0; x < size; x++ {
+			col := int(math.Floor((float64(x)+0.5)/float64(size)*units - 0.5))
+			c := identiconBackground
+			if row >= 0 && row < identiconGrid && col >= 0 && col < identiconGrid && cells[row][col] {
+				c = foreground
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func hslColor(h, s, l float64) color.RGBA {
+	c := (1 - math.Abs(2*l-1)) * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - c/2
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return color.RGBA{
+		R: uint8(math.Round((r + m) * 255)),
+		G: uint8(math.Round((g + m) * 255)),
+		B: uint8(math.Round((b + m) * 255)),
+		A: 255,
+	}
+}
@@ -0,0 +1,47 @@
+package avatar
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+func TestIdenticon(t *testing.T) {
+	pixels := func(img image.Image) []byte {
+		return img.(*image.RGBA).Pix
+	}
+
+	for _, size := range Sizes {
+		first, again := Identicon("alice@example.org", size), Identicon("alice@example.org", size)
+		if first.Bounds() != image.Rect(0, 0, size, size) {
+			t.Errorf("size %d: bounds %v", size, first.Bounds())
+		}
+		if !bytes.Equal(pixels(first), pixels(again)) {
+			t.Errorf("size %d: the same seed drew different pictures", size)
+		}
+	}
+
+	img := Identicon("alice@example.org", 60).(*image.RGBA)
+	if bytes.Equal(img.Pix, pixels(Identicon("bob@example.org", 60))) {
+		t.Error("different seeds drew the same picture")
+	}
+
+	// Two colors, the pattern mirrored around the middle column and half a
+	// cell of background around it.
+	colors := map[[4]uint8]bool{}
+	for y := 0; y < 60; y++ {
+		for x := 0; x < 60; x++ {
+			c := img.RGBAAt(x, y)
+			colors[[4]uint8{c.R, c.G, c.B, c.A}] = true
+			if mirrored := img.RGBAAt(59-x, y); c != mirrored {
+				t.Fatalf("(%d, %d) is %v but its mirror is %v", x, y, c, mirrored)
+			}
+			if (x < 5 || y < 5 || x >= 55 || y >= 55) && c != identiconBackground {
+				t.Fatalf("margin at (%d, %d) is %v", x, y, c)
+			}
+		}
+	}
+	if len(colors) != 2 {
+		t.Errorf("identicon uses %d colors, want 2", len(colors))
+	}
+}
@@ -21,9 +21,35 @@ func InitDB(cfg *config.DbConfig) *gorm.DB {
 
 	log.Println("Connected to Database.")
 
-	db.AutoMigrate(&models.User{}, &models.Project{}, &models.Task{})
+	db.AutoMigrate(&models.User{}, &models.Project{}, &models.Task{}, &models.Session{}, &models.APIToken{}, &models.PasswordResetToken{}, &models.EmailVerificationToken{}, &models.RecoveryCode{}, &models.PendingLogin{}, &models.LoginAttempt{}, &models.ProjectMember{}, &models.ProjectInvitation{}, &models.Organization{}, &models.OrganizationMember{}, &models.Team{}, &models.TeamMember{}, &models.ProjectTeamGrant{}, &models.WorkflowState{}, &models.WorkflowTransition{}, &models.TaskTransition{}, &models.TaskAssignee{}, &models.TaskDependency{}, &models.Comment{}, &models.CommentRevision{}, &models.Mention{}, &models.Attachment{}, &models.Worklog{}, &models.TaskRecurrence{}, &models.Label{}, &models.TaskLabel{})
+
+	if err := migrateTaskAssignees(db); err != nil {
+		log.Printf("failed to migrate task assignees: %v\n", err)
+	}
 
 	log.Println("Successful Migration.")
 
 	return db
 }
+
+// migrateTaskAssignees moves the old single assigned_to column of tasks,
+// which always held the creator, to created_by and task_assignees.
+func migrateTaskAssignees(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&models.Task{}, "assigned_to") {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("UPDATE tasks SET created_by = assigned_to WHERE created_by IS NULL OR created_by = 0").Error; err != nil {
+			return err
+		}
+		err := tx.Exec(`
+			INSERT INTO task_assignees (task_id, user_id, assigned_by, assigned_at)
+			SELECT id, assigned_to, assigned_to, created_at FROM tasks
+			WHERE assigned_to IS NOT NULL AND assigned_to <> 0
+			ON CONFLICT DO NOTHING`).Error
+		if err != nil {
+			return err
+		}
+		return tx.Migrator().DropColumn(&models.Task{}, "assigned_to")
+	})
+}
@@ -0,0 +1,287 @@
+// Package dbtest runs gorm with the Postgres dialect on a scripted driver so
+// that repositories and handlers can be tested without a database server.
+//
+// Every statement is recorded. Statements are answered by the most recently
+// registered answer whose fragment they contain; the rest return no rows and
+// affect nothing. Transactions are recorded as BEGIN, COMMIT and ROLLBACK
+// but have no effect.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// Statement is a statement sent to the database, with its whitespace
+// collapsed.
+type Statement struct {
+	Query string
+	Args  []any
+}
+
+// Result is the answer to a statement. Queries return Rows of Columns,
+// other statements report RowsAffected.
+type Result struct {
+	Columns      []string
+	Rows         [][]any
+	RowsAffected int64
+}
+
+// Rows starts a query result with columns.
+func Rows(columns ...string) *Result {
+	return &Result{Columns: columns}
+}
+
+// Row adds a row to r. Values are converted like query arguments, so
+// integers, pointers and times can be passed as they are.
+func (r *Result) Row(values ...any) *Result {
+	r.Rows = append(r.Rows, values)
+	return r
+}
+
+// Records returns rows holding values, which must all be models of the same
+// type, under the columns gorm maps them to.
+func Records(values ...any) *Result {
+	if len(values) == 0 {
+		panic("dbtest: Records needs at least one value")
+	}
+	s, err := schema.Parse(values[0], &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		panic(err)
+	}
+
+	var fields []*schema.Field
+	result := &Result{}
+	for _, field := range s.Fields {
+		if field.DBName != "" {
+			fields = append(fields, field)
+			result.Columns = append(result.Columns, field.DBName)
+		}
+	}
+	for _, value := range values {
+		model := reflect.Indirect(reflect.ValueOf(value))
+		row := make([]any, len(fields))
+		for i, field := range fields {
+			row[i], _ = field.ValueOf(context.Background(), model)
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return result
+}
+
+// Affected returns the result of a statement that changed n rows.
+func Affected(n int64) *Result {
+	return &Result{RowsAffected: n}
+}
+
+// Answer computes the result of a statement from its arguments. A nil
+// result means no rows and nothing affected.
+type Answer func(args []any) (*Result, error)
+
+type answer struct {
+	fragment string
+	answer   Answer
+}
+
+// DB is the scripted database behind a gorm.DB returned by Open.
+type DB struct {
+	mu         sync.Mutex
+	answers    []answer
+	statements []Statement
+}
+
+// Open returns a gorm.DB on a new scripted database.
+func Open(t testing.TB) (*gorm.DB, *DB) {
+	t.Helper()
+	fake := &DB{}
+	sqlDB := sql.OpenDB(connector{fake})
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, fake
+}
+
+// On answers statements containing fragment with fn.
+func (d *DB) On(fragment string, fn Answer) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.answers = append(d.answers, answer{fragment, fn})
+}
+
+// Return answers statements containing fragment with result.
+func (d *DB) Return(fragment string, result *Result) {
+	d.On(fragment, func([]any) (*Result, error) { return result, nil })
+}
+
+// Fail answers statements containing fragment with err.
+func (d *DB) Fail(fragment string, err error) {
+	d.On(fragment, func([]any) (*Result, error) { return nil, err })
+}
+
+// Statements returns the statements sent so far.
+func (d *DB) Statements() []Statement {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Statement(nil), d.statements...)
+}
+
+// Find returns the statements sent so far that contain fragment.
+func (d *DB) Find(fragment string) []Statement {
+	var found []Statement
+	for _, statement := range d.Statements() {
+		if strings.Contains(statement.Query, fragment) {
+			found = append(found, statement)
+		}
+	}
+	return found
+}
+
+func (d *DB) run(query string, named []driver.NamedValue) (*Result, error) {
+	args := make([]any, len(named))
+	for i, arg := range named {
+		args[i] = arg.Value
+	}
+	statement := Statement{Query: strings.Join(strings.Fields(query), " "), Args: args}
+
+	d.mu.Lock()
+	d.statements = append(d.statements, statement)
+	var found Answer
+	for i := len(d.answers) - 1; i >= 0; i-- {
+		if strings.Contains(statement.Query, d.answers[i].fragment) {
+			found = d.answers[i].answer
+			break
+		}
+	}
+	d.mu.Unlock()
+
+	if found == nil {
+		return &Result{}, nil
+	}
+	result, err := found(args)
+	if result == nil && err == nil {
+		result = &Result{}
+	}
+	return result, err
+}
+
+func (d *DB) record(query string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.statements = append(d.statements, Statement{Query: query})
+}
+
+type connector struct{ db *DB }
+
+func (c connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{db: c.db}, nil
+}
+
+func (c connector) Driver() driver.Driver { return openDriver{} }
+
+type openDriver struct{}
+
+func (openDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("dbtest: use a connector")
+}
+
+type conn struct{ db *DB }
+
+func (c *conn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("dbtest: prepared statements are not supported")
+}
+
+func (c *conn) Close() error { return nil }
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	c.db.record("BEGIN")
+	return tx{c.db}, nil
+}
+
+func (c *conn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	result, err := c.db.run(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(result.RowsAffected), nil
+}
+
+func (c *conn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	result, err := c.db.run(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{result: result}, nil
+}
+
+// CheckNamedValue converts arguments the way database/sql would, but lets
+// through those it cannot convert, such as slices, as the Postgres driver
+// does.
+func (c *conn) CheckNamedValue(arg *driver.NamedValue) error {
+	value, err := driver.DefaultParameterConverter.ConvertValue(arg.Value)
+	if err == nil {
+		arg.Value = value
+	}
+	return nil
+}
+
+type tx struct{ db *DB }
+
+func (t tx) Commit() error {
+	t.db.record("COMMIT")
+	return nil
+}
+
+func (t tx) Rollback() error {
+	t.db.record("ROLLBACK")
+	return nil
+}
+
+type rows struct {
+	result *Result
+	next   int
+}
+
+func (r *rows) Columns() []string { return r.result.Columns }
+
+func (r *rows) Close() error { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.Rows) {
+		return io.EOF
+	}
+	row := r.result.Rows[r.next]
+	r.next++
+	for i := range dest {
+		if i >= len(row) {
+			dest[i] = nil
+			continue
+		}
+		value, err := driver.DefaultParameterConverter.ConvertValue(row[i])
+		if err != nil {
+			return err
+		}
+		dest[i] = value
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package mail
+
+import (
+	"log"
+	"os"
+	"sync"
+)
+
+// LogMailer writes messages to a file, or to the standard logger when no
+// path is configured, instead of delivering them.
+type LogMailer struct {
+	mu   sync.Mutex
+	path string
+	from string
+}
+
+func NewLogMailer(path, from string) *LogMailer {
+	return &LogMailer{
+		path: path,
+		from: from,
+	}
+}
+
+func (m *LogMailer) Send(to, subject, body string) error {
+	message := buildMessage(m.from, to, subject, body)
+
+	if m.path == "" {
+		log.Printf("mail to %s:\n%s\n", to, message)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(message, "\r\n\r\n"...)); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package mail
+
+import (
+	"github.com/aminasadiam/DevTasks/config"
+)
+
+// Mailer delivers plain text messages to a single recipient.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// NewMailer returns the Mailer selected by cfg.Driver. Anything other than
+// "smtp" falls back to the log mailer, which is meant for local development.
+func NewMailer(cfg *config.MailConfig) Mailer {
+	if cfg.Driver == "smtp" {
+		return NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPassword, cfg.From)
+	}
+	return NewLogMailer(cfg.LogFile, cfg.From)
+}
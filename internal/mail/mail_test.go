@@ -0,0 +1,155 @@
+package mail
+
+import (
+	"bufio"
+	"encoding/base64"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogMailer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail.log")
+	m := NewLogMailer(path, "DevTasks <noreply@devtasks.test>")
+
+	if err := m.Send("alice@example.org", "Reset your password", "Hi,\nclick the link.\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Send("bob@example.org", "Verify your email", "Hi Bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := string(content)
+	for _, want := range []string{
+		"From: DevTasks <noreply@devtasks.test>\r\n",
+		"To: alice@example.org\r\nSubject: Reset your password\r\n",
+		"Content-Type: text/plain; charset=UTF-8\r\n\r\nHi,\r\nclick the link.\r\n",
+		"To: bob@example.org\r\nSubject: Verify your email\r\n",
+	} {
+		if !strings.Contains(log, want) {
+			t.Errorf("log does not contain %q:\n%s", want, log)
+		}
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm() != 0o600 {
+		t.Errorf("log file mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+// smtpMessage is what fakeSMTP received in one session.
+type smtpMessage struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTP accepts a single SMTP session on a local port. It offers AUTH
+// PLAIN but no STARTTLS.
+func fakeSMTP(t *testing.T) (host, port string, received <-chan smtpMessage) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	messages := make(chan smtpMessage, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var message smtpMessage
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 fake ESMTP")
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			verb := strings.ToUpper(strings.Fields(line + " x")[0])
+			switch verb {
+			case "EHLO":
+				reply("250-fake")
+				reply("250 AUTH PLAIN")
+			case "AUTH":
+				credentials, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(line, "AUTH PLAIN "))
+				message.auth = string(credentials)
+				reply("235 accepted")
+			case "MAIL":
+				message.from = line
+				reply("250 ok")
+			case "RCPT":
+				message.to = append(message.to, line)
+				reply("250 ok")
+			case "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				message.data = data.String()
+				reply("250 queued")
+			case "QUIT":
+				reply("221 bye")
+				messages <- message
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+
+	host, port, _ = net.SplitHostPort(listener.Addr().String())
+	return host, port, messages
+}
+
+func TestSMTPMailer(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		auth     string
+	}{
+		{"without auth", "", ""},
+		{"with auth", "devtasks", "\x00devtasks\x00secret"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			host, port, received := fakeSMTP(t)
+			m := NewSMTPMailer(host, port, test.username, "secret", "DevTasks <noreply@devtasks.test>")
+
+			if err := m.Send("alice@example.org", "Reset your password", "Hi,\nclick the link.\n"); err != nil {
+				t.Fatal(err)
+			}
+			message := <-received
+			if message.auth != test.auth {
+				t.Errorf("auth = %q, want %q", message.auth, test.auth)
+			}
+			if message.from != "MAIL FROM:<noreply@devtasks.test>" {
+				t.Errorf("envelope sender = %q, want the bare address", message.from)
+			}
+			if len(message.to) != 1 || message.to[0] != "RCPT TO:<alice@example.org>" {
+				t.Errorf("recipients = %q", message.to)
+			}
+			for _, want := range []string{"From: DevTasks <noreply@devtasks.test>\r\n", "Subject: Reset your password\r\n", "\r\n\r\nHi,\r\nclick the link.\r\n"} {
+				if !strings.Contains(message.data, want) {
+					t.Errorf("message does not contain %q:\n%s", want, message.data)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,403 @@
+// Package markdown renders the Markdown subset used in comments: headings,
+// paragraphs, emphasis, strikethrough, inline and fenced code, links,
+// block quotes, lists, rules and @mentions.
+//
+// The renderer never passes source HTML through. All text is escaped and
+// only the tags generated here are emitted, with links restricted to http,
+// https and mailto, so the output is safe to embed without a separate
+// sanitizer.
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"strings"
+)
+
+// Render converts source to HTML. Mentions of usernames in mentions are
+// highlighted; other @words are left as text.
+func Render(source string, mentions map[string]bool) string {
+	r := renderer{mentions: mentions}
+	r.blocks(splitLines(source))
+	return r.out.String()
+}
+
+// Mentions returns the usernames mentioned in source in order of first
+// appearance. Mentions inside code are ignored.
+func Mentions(source string) []string {
+	r := renderer{}
+	r.blocks(splitLines(source))
+	return r.found
+}
+
+type renderer struct {
+	out      strings.Builder
+	mentions map[string]bool
+	found    []string
+	inLink   bool
+}
+
+func splitLines(source string) []string {
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	return strings.Split(strings.ReplaceAll(source, "\r", "\n"), "\n")
+}
+
+func (r *renderer) blocks(lines []string) {
+	for i := 0; i < len(lines); {
+		trimmed := strings.TrimSpace(lines[i])
+		switch {
+		case trimmed == "":
+			i++
+
+		case strings.HasPrefix(trimmed, "```"):
+			language := codeLanguage(trimmed[3:])
+			i++
+			start := i
+			for i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```") {
+				i++
+			}
+			code := strings.Join(lines[start:i], "\n")
+			if i < len(lines) {
+				i++
+			}
+			if language != "" {
+				r.out.WriteString(`<pre><code class="language-` + language + `">`)
+			} else {
+				r.out.WriteString("<pre><code>")
+			}
+			r.out.WriteString(html.EscapeString(code))
+			r.out.WriteString("</code></pre>\n")
+
+		case headingLevel(trimmed) > 0:
+			level := headingLevel(trimmed)
+			tag := string(rune('0' + level))
+			r.out.WriteString("<h" + tag + ">")
+			r.inline(strings.TrimSpace(strings.TrimRight(trimmed[level:], "#")))
+			r.out.WriteString("</h" + tag + ">\n")
+			i++
+
+		case isRule(trimmed):
+			r.out.WriteString("<hr>\n")
+			i++
+
+		case strings.HasPrefix(trimmed, ">"):
+			var quoted []string
+			for i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">") {
+				line := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quoted = append(quoted, strings.TrimPrefix(line, " "))
+				i++
+			}
+			r.out.WriteString("<blockquote>\n")
+			r.blocks(quoted)
+			r.out.WriteString("</blockquote>\n")
+
+		default:
+			if ordered, _, ok := listItem(lines[i]); ok {
+				i = r.list(lines, i, ordered)
+				continue
+			}
+			start := i
+			for i < len(lines) && (i == start || !startsBlock(lines[i])) {
+				i++
+			}
+			r.out.WriteString("<p>")
+			r.inline(strings.TrimSpace(strings.Join(lines[start:i], "\n")))
+			r.out.WriteString("</p>\n")
+		}
+	}
+}
+
+// list renders the items of a list starting at lines[i] and returns the
+// index of the first line after it. Indented lines continue an item.
+func (r *renderer) list(lines []string, i int, ordered bool) int {
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+	r.out.WriteString("<" + tag + ">\n")
+	for i < len(lines) {
+		itemOrdered, text, ok := listItem(lines[i])
+		if !ok || itemOrdered != ordered {
+			break
+		}
+		i++
+		for i < len(lines) && strings.TrimSpace(lines[i]) != "" && startsWithSpace(lines[i]) {
+			if _, _, nested := listItem(lines[i]); nested {
+				break
+			}
+			text += "\n" + strings.TrimSpace(lines[i])
+			i++
+		}
+		r.out.WriteString("<li>")
+		r.inline(text)
+		r.out.WriteString("</li>\n")
+	}
+	r.out.WriteString("</" + tag + ">\n")
+	return i
+}
+
+func (r *renderer) inline(s string) {
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_~[]()#>@!-+.", s[i+1]) >= 0:
+			r.text(s[i+1 : i+2])
+			i += 2
+			continue
+
+		case c == '\n':
+			r.out.WriteString("<br>\n")
+			i++
+			continue
+
+		case c == '`':
+			if end := strings.IndexByte(s[i+1:], '`'); end > 0 {
+				r.out.WriteString("<code>")
+				r.text(s[i+1 : i+1+end])
+				r.out.WriteString("</code>")
+				i += end + 2
+				continue
+			}
+
+		case c == '[' && !r.inLink:
+			if n := r.link(s[i:]); n > 0 {
+				i += n
+				continue
+			}
+
+		case c == '*' || c == '_' || c == '~':
+			if n := r.emphasis(s, i); n > 0 {
+				i += n
+				continue
+			}
+
+		case c == '@' && (i == 0 || !isWordByte(s[i-1])):
+			if name := mentionAt(s[i+1:]); name != "" {
+				r.mention(name)
+				i += 1 + len(name)
+				continue
+			}
+		}
+		r.text(s[i : i+1])
+		i++
+	}
+}
+
+// link renders a [text](url) link at the start of s and returns its length,
+// or 0 if s does not start with one. Links with other schemes keep their
+// text only.
+func (r *renderer) link(s string) int {
+	closeText := strings.Index(s, "](")
+	if closeText < 0 {
+		return 0
+	}
+	closeURL := strings.IndexByte(s[closeText+2:], ')')
+	if closeURL < 0 {
+		return 0
+	}
+	text := s[1:closeText]
+	target := strings.TrimSpace(s[closeText+2 : closeText+2+closeURL])
+	if text == "" || strings.ContainsAny(text, "\n") || strings.ContainsAny(target, "\n") {
+		return 0
+	}
+
+	if isSafeURL(target) {
+		r.out.WriteString(`<a href="` + html.EscapeString(target) + `" rel="nofollow noopener noreferrer">`)
+		r.inLink = true
+		r.inline(text)
+		r.inLink = false
+		r.out.WriteString("</a>")
+	} else {
+		r.inline(text)
+	}
+	return closeText + 2 + closeURL + 1
+}
+
+// emphasis renders the emphasis starting at s[i] and returns its length,
+// or 0 if the delimiter is not closed. Underscores inside words do not
+// count so that snake_case survives.
+func (r *renderer) emphasis(s string, i int) int {
+	c := s[i]
+	if c == '_' && i > 0 && isWordByte(s[i-1]) {
+		return 0
+	}
+
+	delimiter, tag := string(c), "em"
+	if strings.HasPrefix(s[i:], strings.Repeat(string(c), 2)) {
+		delimiter = strings.Repeat(string(c), 2)
+		tag = "strong"
+		if c == '~' {
+			tag = "del"
+		}
+	} else if c == '~' {
+		return 0
+	}
+
+	rest := s[i+len(delimiter):]
+	end := closingDelimiter(rest, delimiter)
+	if end <= 0 || rest[0] == ' ' {
+		return 0
+	}
+	after := i + len(delimiter) + end + len(delimiter)
+	if c == '_' && after < len(s) && isWordByte(s[after]) {
+		return 0
+	}
+
+	r.out.WriteString("<" + tag + ">")
+	r.inline(rest[:end])
+	r.out.WriteString("</" + tag + ">")
+	return after - i
+}
+
+// closingDelimiter returns the index in s of the delimiter that closes
+// emphasis opened right before s, or -1. Delimiters after a space open
+// rather than close. When emphasis opened inside s is still open, a longer
+// run closes it first and the outer emphasis takes the end of the run, so
+// that "**a *b***" nests.
+func closingDelimiter(s, delimiter string) int {
+	open := 0
+	for j := 0; j < len(s); {
+		if s[j] != delimiter[0] {
+			j++
+			continue
+		}
+		run := j + 1
+		for run < len(s) && s[run] == delimiter[0] {
+			run++
+		}
+		length := run - j
+		closes := j > 0 && s[j-1] != ' '
+		switch {
+		case closes && open > 0 && length > len(delimiter):
+			return run - len(delimiter)
+		case closes && open == 0 && length >= len(delimiter):
+			return j
+		case closes:
+			open -= min(open, length)
+		case run < len(s) && s[run] != ' ':
+			open += length
+		}
+		j = run
+	}
+	return -1
+}
+
+func (r *renderer) mention(name string) {
+	seen := false
+	for _, found := range r.found {
+		if found == name {
+			seen = true
+			break
+		}
+	}
+	if !seen {
+		r.found = append(r.found, name)
+	}
+
+	if r.mentions[name] {
+		r.out.WriteString(`<span class="mention">@`)
+		r.text(name)
+		r.out.WriteString("</span>")
+	} else {
+		r.text("@" + name)
+	}
+}
+
+func (r *renderer) text(s string) {
+	r.out.WriteString(html.EscapeString(s))
+}
+
+// mentionAt returns the username at the start of s. Usernames consist of
+// letters, digits, '_', '.' and '-' and do not end in punctuation.
+func mentionAt(s string) string {
+	end := 0
+	for end < len(s) && (isWordByte(s[end]) || s[end] == '.' || s[end] == '-') {
+		end++
+	}
+	return strings.TrimRight(s[:end], ".-")
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isSafeURL(target string) bool {
+	if target == "" || strings.ContainsAny(target, " \t\n<>\"'") {
+		return false
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return u.Host != ""
+	case "mailto":
+		return u.Opaque != ""
+	}
+	return false
+}
+
+func codeLanguage(info string) string {
+	info = strings.TrimSpace(info)
+	for i := 0; i < len(info); i++ {
+		if !isWordByte(info[i]) && info[i] != '-' && info[i] != '+' {
+			return ""
+		}
+	}
+	return info
+}
+
+func headingLevel(line string) int {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || (level < len(line) && line[level] != ' ') {
+		return 0
+	}
+	return level
+}
+
+func isRule(line string) bool {
+	line = strings.ReplaceAll(line, " ", "")
+	if len(line) < 3 || strings.IndexByte("-*_", line[0]) < 0 {
+		return false
+	}
+	return strings.Count(line, line[:1]) == len(line)
+}
+
+// listItem reports whether line starts a list item and returns the text
+// after its marker.
+func listItem(line string) (bool, string, bool) {
+	trimmed := strings.TrimLeft(line, " \t")
+	if len(trimmed) >= 2 && strings.IndexByte("-*+", trimmed[0]) >= 0 && trimmed[1] == ' ' {
+		return false, strings.TrimSpace(trimmed[2:]), true
+	}
+	digits := 0
+	for digits < len(trimmed) && digits < 9 && trimmed[digits] >= '0' && trimmed[digits] <= '9' {
+		digits++
+	}
+	if digits > 0 && digits+1 < len(trimmed) && (trimmed[digits] == '.' || trimmed[digits] == ')') && trimmed[digits+1] == ' ' {
+		return true, strings.TrimSpace(trimmed[digits+2:]), true
+	}
+	return false, "", false
+}
+
+// startsBlock reports whether line ends a paragraph by starting another
+// block.
+func startsBlock(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, ">") {
+		return true
+	}
+	if headingLevel(trimmed) > 0 || isRule(trimmed) {
+		return true
+	}
+	_, _, ok := listItem(line)
+	return ok
+}
+
+func startsWithSpace(line string) bool {
+	return line[0] == ' ' || line[0] == '\t'
+}
@@ -0,0 +1,198 @@
+package markdown
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"paragraph", "Hello world", "<p>Hello world</p>\n"},
+		{"line break", "one\ntwo", "<p>one<br>\ntwo</p>\n"},
+		{"heading", "## Title ##", "<h2>Title</h2>\n"},
+		{"rule", "---", "<hr>\n"},
+		{"emphasis", "*a* _b_ **c** __d__ ~~e~~", "<p><em>a</em> <em>b</em> <strong>c</strong> <strong>d</strong> <del>e</del></p>\n"},
+		{"nested emphasis", "**bold *and italic***", "<p><strong>bold <em>and italic</em></strong></p>\n"},
+		{"nested strong", "*italic **bold***", "<p><em>italic <strong>bold</strong></em></p>\n"},
+		{"closed inner emphasis", "**a *b* c**", "<p><strong>a <em>b</em> c</strong></p>\n"},
+		{"emphasis before run", "*a**", "<p><em>a</em>*</p>\n"},
+		{"strikethrough with emphasis", "~~*gone*~~", "<p><del><em>gone</em></del></p>\n"},
+		{"emphasis inside link", "[**bold** link](https://example.com)", `<p><a href="https://example.com" rel="nofollow noopener noreferrer"><strong>bold</strong> link</a></p>` + "\n"},
+		{"snake_case", "some_snake_case_name", "<p>some_snake_case_name</p>\n"},
+		{"unclosed emphasis", "*open and **half", "<p>*open and **half</p>\n"},
+		{"spaced emphasis", "a * b * c", "<p>a * b * c</p>\n"},
+		{"escaped delimiter", `\*not emphasis\*`, "<p>*not emphasis*</p>\n"},
+		{"inline code", "use `x := <y>`", "<p>use <code>x := &lt;y&gt;</code></p>\n"},
+		{"emphasis in code", "`*a*`", "<p><code>*a*</code></p>\n"},
+		{"fenced code", "```go\nif a < b {}\n```", `<pre><code class="language-go">if a &lt; b {}</code></pre>` + "\n"},
+		{"unclosed fence", "```\ncode", "<pre><code>code</code></pre>\n"},
+		{"blockquote", "> quoted\n> text", "<blockquote>\n<p>quoted<br>\ntext</p>\n</blockquote>\n"},
+		{"unordered list", "- one\n- two", "<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n"},
+		{"ordered list", "1. one\n2) two", "<ol>\n<li>one</li>\n<li>two</li>\n</ol>\n"},
+		{"list continuation", "- one\n  more", "<ul>\n<li>one<br>\nmore</li>\n</ul>\n"},
+		{"http link", "[site](https://example.com/a?b=1&c=2)", `<p><a href="https://example.com/a?b=1&amp;c=2" rel="nofollow noopener noreferrer">site</a></p>` + "\n"},
+		{"mailto link", "[mail](mailto:a@example.com)", `<p><a href="mailto:a@example.com" rel="nofollow noopener noreferrer">mail</a></p>` + "\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Render(test.source, nil); got != test.want {
+				t.Errorf("Render(%q)\n got %q\nwant %q", test.source, got, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderUnsafeLinks(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		// The target ends at the first ')', the rest stays text.
+		{"javascript", "[click](javascript:alert(1))", "<p>click)</p>\n"},
+		{"javascript mixed case", "[click](JaVaScRiPt:alert`1`)", "<p>click</p>\n"},
+		{"javascript with whitespace", "[click]( javascript:alert`1` )", "<p>click</p>\n"},
+		{"javascript entity", "[click](&#106;avascript:alert`1`)", "<p>click</p>\n"},
+		{"vbscript", "[click](vbscript:msgbox)", "<p>click</p>\n"},
+		{"data", "[click](data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==)", "<p>click</p>\n"},
+		{"data mixed case", "[click](DATA:text/html,<script>alert`1`</script>)", "<p>click</p>\n"},
+		{"relative", "[click](/admin)", "<p>click</p>\n"},
+		{"scheme relative", "[click](//evil.example)", "<p>click</p>\n"},
+		{"http without host", "[click](http:evil)", "<p>click</p>\n"},
+		{"empty mailto", "[click](mailto:)", "<p>click</p>\n"},
+		{"quote breaks out of href", `[click](https://example.com/"onmouseover="alert` + "`1`" + `)`, "<p>click</p>\n"},
+		{"single quote", "[click](https://example.com/'onmouseover='alert`1`)", "<p>click</p>\n"},
+		{"angle bracket", "[click](https://example.com/<script>)", "<p>click</p>\n"},
+		{"space splits attribute", "[click](https://example.com/ onmouseover=alert`1`)", "<p>click</p>\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Render(test.source, nil); got != test.want {
+				t.Errorf("Render(%q)\n got %q\nwant %q", test.source, got, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderEscapesHTML(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"script tag", "<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n"},
+		{"event handler", `<img src=x onerror="alert(1)">`, "<p>&lt;img src=x onerror=&#34;alert(1)&#34;&gt;</p>\n"},
+		{"entity", "&lt;b&gt; & &amp;", "<p>&amp;lt;b&amp;gt; &amp; &amp;amp;</p>\n"},
+		{"html in heading", "# <b>x</b>", "<h1>&lt;b&gt;x&lt;/b&gt;</h1>\n"},
+		{"html in emphasis", "**<i>x</i>**", "<p><strong>&lt;i&gt;x&lt;/i&gt;</strong></p>\n"},
+		{"html in link text", "[<b>x</b>](https://example.com)", `<p><a href="https://example.com" rel="nofollow noopener noreferrer">&lt;b&gt;x&lt;/b&gt;</a></p>` + "\n"},
+		{"html in inline code", "`</code><script>`", "<p><code>&lt;/code&gt;&lt;script&gt;</code></p>\n"},
+		{"html in fenced code", "```\n</code></pre><script>alert(1)</script>\n```", "<pre><code>&lt;/code&gt;&lt;/pre&gt;&lt;script&gt;alert(1)&lt;/script&gt;</code></pre>\n"},
+		{"language injection", "```go\" onclick=\"alert(1)\nx\n```", "<pre><code>x</code></pre>\n"},
+		{"html in list", "- <script>", "<ul>\n<li>&lt;script&gt;</li>\n</ul>\n"},
+		{"html in quote", "> <script>", "<blockquote>\n<p>&lt;script&gt;</p>\n</blockquote>\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Render(test.source, nil); got != test.want {
+				t.Errorf("Render(%q)\n got %q\nwant %q", test.source, got, test.want)
+			}
+		})
+	}
+}
+
+// generatedTag matches every tag in the output together with its
+// attributes.
+var generatedTag = regexp.MustCompile(`<(/?)([a-zA-Z0-9]+)([^>]*)>`)
+
+var allowedAttributes = regexp.MustCompile(`^(| href="[^"<>]*" rel="nofollow noopener noreferrer"| class="language-[A-Za-z0-9_+-]+"| class="mention")$`)
+
+// TestRenderOnlyGeneratesKnownTags feeds hostile input through the renderer
+// and checks that every tag in the output is one the renderer emits itself,
+// with no attributes besides the expected ones.
+func TestRenderOnlyGeneratesKnownTags(t *testing.T) {
+	allowed := []string{"p", "br", "h1", "h2", "h3", "h4", "h5", "h6", "hr", "em", "strong", "del", "code", "pre", "blockquote", "ul", "ol", "li", "a", "span"}
+	sources := []string{
+		`<svg onload=alert(1)>`,
+		`[x](https://example.com"><script>alert(1)</script>)`,
+		`[x](https://example.com/?q=" onclick="alert(1))`,
+		"[a](https://a.example)[b](javascript:alert(1))",
+		"**[x](javascript:alert(1))**",
+		"*<img src=x onerror=alert(1)>*",
+		"```\"><script>\n<script>\n```",
+		"> # <h1 onclick=x>\n> - [x](data:text/html,<script>)",
+		"@<script> @admin\"onclick=\"x",
+		"`unclosed <b>code",
+		"[unclosed <a href=x>(https://example.com",
+		"\\<script>",
+		"~~<del onclick=x>~~",
+	}
+	for _, source := range sources {
+		out := Render(source, map[string]bool{"admin": true})
+		for _, match := range generatedTag.FindAllStringSubmatch(out, -1) {
+			if !slices.Contains(allowed, match[2]) {
+				t.Errorf("Render(%q) emitted <%s>: %q", source, match[2], out)
+			}
+			if !allowedAttributes.MatchString(match[3]) {
+				t.Errorf("Render(%q) emitted attributes %q: %q", source, match[3], out)
+			}
+		}
+		if lower := strings.ToLower(out); strings.Contains(lower, `href="javascript`) || strings.Contains(lower, `href="data`) {
+			t.Errorf("Render(%q) emitted an unsafe link: %q", source, out)
+		}
+	}
+}
+
+func TestRenderMentions(t *testing.T) {
+	mentions := map[string]bool{"alice": true, "bob.smith": true}
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"known user", "hi @alice", `<p>hi <span class="mention">@alice</span></p>` + "\n"},
+		{"trailing punctuation", "thanks @bob.smith.", `<p>thanks <span class="mention">@bob.smith</span>.</p>` + "\n"},
+		{"unknown user", "hi @mallory", "<p>hi @mallory</p>\n"},
+		{"email address", "mail alice@example.com", "<p>mail alice@example.com</p>\n"},
+		{"in code", "`@alice`", "<p><code>@alice</code></p>\n"},
+		{"escaped", `\@alice`, "<p>@alice</p>\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Render(test.source, mentions); got != test.want {
+				t.Errorf("Render(%q)\n got %q\nwant %q", test.source, got, test.want)
+			}
+		})
+	}
+}
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{"none", "no mentions here", nil},
+		{"order and duplicates", "@bob and @alice, then @bob again", []string{"bob", "alice"}},
+		{"punctuation", "(@alice) @bob.smith. @carol-", []string{"alice", "bob.smith", "carol"}},
+		{"email address", "write to alice@example.com", nil},
+		{"inline code", "`@alice` but @bob", []string{"bob"}},
+		{"fenced code", "```\n@alice\n```\n@bob", []string{"bob"}},
+		{"escaped", `\@alice`, nil},
+		{"blocks", "# @alice\n> @bob\n- @carol\n\n**@dave**", []string{"alice", "bob", "carol", "dave"}},
+		{"link text", "[@alice](https://example.com)", []string{"alice"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Mentions(test.source); !slices.Equal(got, test.want) {
+				t.Errorf("Mentions(%q) = %q, want %q", test.source, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	TokenScopeRead  = "read"
+	TokenScopeWrite = "write"
+)
+
+type APIToken struct {
+	gorm.Model
+	UserId         uint       `gorm:"index" json:"UserId"`
+	User           User       `gorm:"foreignKey:UserId" json:"-"`
+	Name           string     `json:"Name"`
+	TokenHash      string     `gorm:"uniqueIndex" json:"-"`
+	Prefix         string     `json:"Prefix"`
+	Scope          string     `json:"Scope"`
+	ProjectId      *uint      `json:"ProjectId"`
+	OrganizationId uint       `json:"OrganizationId"`
+	ExpiresAt      *time.Time `json:"ExpiresAt"`
+	LastUsedAt     *time.Time `json:"LastUsedAt"`
+	RevokedAt      *time.Time `json:"RevokedAt"`
+}
+
+// CanWrite reports whether the token may be used for requests that change data.
+func (t *APIToken) CanWrite() bool {
+	return t.Scope == TokenScopeWrite
+}
+
+// MarshalJSON overrides the default JSON marshaling to use the expected field names
+func (t APIToken) MarshalJSON() ([]byte, error) {
+	type Alias APIToken
+	return json.Marshal(&struct {
+		ID        uint   `json:"ID"`
+		CreatedAt string `json:"created_at"`
+		UpdatedAt string `json:"updated_at"`
+		*Alias
+	}{
+		ID:        t.ID,
+		CreatedAt: t.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt: t.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		Alias:     (*Alias)(&t),
+	})
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+// Attachment is a file uploaded to a task. The content lives in the blob
+// store under StorageKey.
+type Attachment struct {
+	gorm.Model
+	TaskId      uint   `gorm:"index" json:"TaskId"`
+	Task        Task   `gorm:"foreignKey:TaskId" json:"-"`
+	UploadedBy  uint   `json:"UploadedBy"`
+	Uploader    User   `gorm:"foreignKey:UploadedBy" json:"-"`
+	FileName    string `json:"FileName"`
+	ContentType string `json:"ContentType"`
+	Size        int64  `json:"Size"`
+	SHA256      string `gorm:"size:64;index" json:"SHA256"`
+	StorageKey  string `gorm:"uniqueIndex" json:"-"`
+}
+
+// MarshalJSON overrides the default JSON marshaling to use the expected field names
+func (a Attachment) MarshalJSON() ([]byte, error) {
+	type Alias Attachment
+	return json.Marshal(&struct {
+		ID        uint   `json:"ID"`
+		CreatedAt string `json:"created_at"`
+		UpdatedAt string `json:"updated_at"`
+		Username  string `json:"Username"`
+		*Alias
+	}{
+		ID:        a.ID,
+		CreatedAt: a.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt: a.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		Username:  a.Uploader.Username,
+		Alias:     (*Alias)(&a),
+	})
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Comment is a message in the discussion of a task. Replies point to the
+// comment they answer. BodyHTML is the Markdown of Body rendered when the
+// comment is saved. A deleted comment that has replies is kept as Removed
+// with its body cleared so that the thread stays intact.
+type Comment struct {
+	gorm.Model
+	TaskId   uint       `gorm:"index" json:"TaskId"`
+	Task     Task       `gorm:"foreignKey:TaskId" json:"-"`
+	UserId   uint       `json:"UserId"`
+	User     User       `gorm:"foreignKey:UserId" json:"-"`
+	ParentId *uint      `gorm:"index" json:"ParentId"`
+	Body     string     `json:"Body"`
+	BodyHTML string     `json:"BodyHTML"`
+	EditedAt *time.Time `json:"EditedAt"`
+	Removed  bool       `json:"Removed"`
+}
+
+// CommentRevision keeps the body a comment had before an edit.
+type CommentRevision struct {
+	ID        uint      `gorm:"primarykey" json:"ID"`
+	CommentId uint      `gorm:"index" json:"CommentId"`
+	Body      string    `json:"Body"`
+	EditedBy  uint      `json:"EditedBy"`
+	EditedAt  time.Time `json:"EditedAt"`
+}
+
+// Mention records that a comment mentioned a project member. Each member
+// is mentioned at most once per comment, even across edits.
+type Mention struct {
+	ID          uint       `gorm:"primarykey" json:"ID"`
+	CommentId   uint       `gorm:"uniqueIndex:idx_mention" json:"CommentId"`
+	Comment     Comment    `gorm:"foreignKey:CommentId" json:"Comment"`
+	UserId      uint       `gorm:"uniqueIndex:idx_mention;index" json:"UserId"`
+	TaskId      uint       `json:"TaskId"`
+	MentionedBy uint       `json:"MentionedBy"`
+	CreatedAt   time.Time  `json:"CreatedAt"`
+	ReadAt      *time.Time `json:"ReadAt"`
+}
+
+// CommentThread is a comment with its replies.
+type CommentThread struct {
+	Comment Comment          `json:"Comment"`
+	Replies []*CommentThread `json:"Replies"`
+}
+
+// BuildCommentThreads arranges comments into threads. Comments whose parent
+// is not part of comments start a thread of their own.
+func BuildCommentThreads(comments []Comment) []*CommentThread {
+	threads := make(map[uint]*CommentThread, len(comments))
+	for _, comment := range comments {
+		threads[comment.ID] = &CommentThread{Comment: comment, Replies: []*CommentThread{}}
+	}
+
+	roots := []*CommentThread{}
+	for _, comment := range comments {
+		thread := threads[comment.ID]
+		if comment.ParentId != nil {
+			if parent, ok := threads[*comment.ParentId]; ok {
+				parent.Replies = append(parent.Replies, thread)
+				continue
+			}
+		}
+		roots = append(roots, thread)
+	}
+	return roots
+}
+
+// MarshalJSON overrides the default JSON marshaling to use the expected field names
+func (c Comment) MarshalJSON() ([]byte, error) {
+	type Alias Comment
+	return json.Marshal(&struct {
+		ID        uint   `json:"ID"`
+		CreatedAt string `json:"created_at"`
+		UpdatedAt string `json:"updated_at"`
+		Username  string `json:"Username"`
+		Profile   string `json:"Profile"`
+		*Alias
+	}{
+		ID:        c.ID,
+		CreatedAt: c.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt: c.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		Username:  c.User.Username,
+		Profile:   c.User.Profile,
+		Alias:     (*Alias)(&c),
+	})
+}
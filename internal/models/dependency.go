@@ -0,0 +1,105 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// TaskDependency records that the blocker task has to be done before the
+// blocked task. Both tasks belong to the same organization but can be in
+// different projects.
+type TaskDependency struct {
+	ID        uint      `gorm:"primarykey" json:"ID"`
+	BlockerId uint      `gorm:"uniqueIndex:idx_task_dependency" json:"BlockerId"`
+	Blocker   Task      `gorm:"foreignKey:BlockerId" json:"-"`
+	BlockedId uint      `gorm:"uniqueIndex:idx_task_dependency;index" json:"BlockedId"`
+	Blocked   Task      `gorm:"foreignKey:BlockedId" json:"-"`
+	CreatedBy uint      `json:"CreatedBy"`
+	CreatedAt time.Time `json:"CreatedAt"`
+}
+
+// CriticalPath is the longest chain of dependent tasks.
+type CriticalPath struct {
+	Tasks []Task `json:"Tasks"`
+	// Days is the sum of the planned durations of Tasks.
+	Days int `json:"Days"`
+}
+
+// PlannedDays is the number of days between the start and due date of
+// task, rounded up. Tasks without both dates count as one day.
+func PlannedDays(task Task) int {
+	if task.StartDate == nil || task.DueDate == nil || !task.DueDate.After(*task.StartDate) {
+		return 1
+	}
+	return int(math.Ceil(task.DueDate.Sub(*task.StartDate).Hours() / 24))
+}
+
+// FindCriticalPath returns the chain of tasks connected by dependencies
+// with the largest total planned duration. Dependencies on tasks that are
+// not part of tasks are ignored. Ties go to the chain ending in the lower
+// task ID.
+func FindCriticalPath(tasks []Task, dependencies []TaskDependency) CriticalPath {
+	sorted := make([]Task, len(tasks))
+	copy(sorted, tasks)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	byId := make(map[uint]Task, len(sorted))
+	for _, task := range sorted {
+		byId[task.ID] = task
+	}
+	next := make(map[uint][]uint)
+	blockers := make(map[uint]int)
+	for _, dependency := range dependencies {
+		_, blocker := byId[dependency.BlockerId]
+		_, blocked := byId[dependency.BlockedId]
+		if !blocker || !blocked {
+			continue
+		}
+		next[dependency.BlockerId] = append(next[dependency.BlockerId], dependency.BlockedId)
+		blockers[dependency.BlockedId]++
+	}
+
+	// Visit the tasks in topological order, tracking the longest chain that
+	// ends in each of them.
+	var queue []uint
+	for _, task := range sorted {
+		if blockers[task.ID] == 0 {
+			queue = append(queue, task.ID)
+		}
+	}
+	days := make(map[uint]int, len(sorted))
+	previous := make(map[uint]uint)
+	var last uint
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		days[id] += PlannedDays(byId[id])
+		if last == 0 || days[id] > days[last] || (days[id] == days[last] && id < last) {
+			last = id
+		}
+		for _, blocked := range next[id] {
+			if days[id] > days[blocked] || (days[id] == days[blocked] && previous[blocked] > id) {
+				days[blocked] = days[id]
+				previous[blocked] = id
+			}
+			blockers[blocked]--
+			if blockers[blocked] == 0 {
+				queue = append(queue, blocked)
+			}
+		}
+	}
+
+	path := CriticalPath{Tasks: []Task{}}
+	if last == 0 {
+		return path
+	}
+	path.Days = days[last]
+	for id := last; id != 0; id = previous[id] {
+		path.Tasks = append(path.Tasks, byId[id])
+	}
+	for i, j := 0, len(path.Tasks)-1; i < j; i, j = i+1, j-1 {
+		path.Tasks[i], path.Tasks[j] = path.Tasks[j], path.Tasks[i]
+	}
+	return path
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPlannedDays(t *testing.T) {
+	start := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+	after := func(d time.Duration) *time.Time {
+		due := start.Add(d)
+		return &due
+	}
+	tests := []struct {
+		start, due *time.Time
+		days       int
+	}{
+		{nil, nil, 1},
+		{&start, nil, 1},
+		{&start, &start, 1},
+		{&start, after(-time.Hour), 1},
+		{&start, after(48 * time.Hour), 2},
+		{&start, after(49 * time.Hour), 3},
+	}
+	for _, test := range tests {
+		if days := PlannedDays(Task{StartDate: test.start, DueDate: test.due}); days != test.days {
+			t.Errorf("PlannedDays(%v, %v) = %d, want %d", test.start, test.due, days, test.days)
+		}
+	}
+}
+
+func TestFindCriticalPath(t *testing.T) {
+	start := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	task := func(id uint, days int) Task {
+		due := start.AddDate(0, 0, days)
+		task := Task{StartDate: &start, DueDate: &due}
+		task.ID = id
+		return task
+	}
+	edge := func(blocker, blocked uint) TaskDependency {
+		return TaskDependency{BlockerId: blocker, BlockedId: blocked}
+	}
+	// The diamond 1 → {2, 3} → 4, where the tasks in the middle take
+	// different times.
+	diamond := []TaskDependency{edge(1, 2), edge(1, 3), edge(2, 4), edge(3, 4)}
+
+	tests := []struct {
+		name         string
+		tasks        []Task
+		dependencies []TaskDependency
+		path         []uint
+		days         int
+	}{
+		{"no tasks", nil, nil, nil, 0},
+		{"no dependencies", []Task{task(1, 1), task(2, 3)}, nil, []uint{2}, 3},
+		{"diamond through the longer side", []Task{task(4, 1), task(3, 5), task(2, 2), task(1, 1)}, diamond, []uint{1, 3, 4}, 7},
+		{"diamond tie goes to the lower ID", []Task{task(1, 1), task(2, 2), task(3, 2), task(4, 1)}, diamond, []uint{1, 2, 4}, 4},
+		{"diamond beside a longer chain", []Task{task(1, 1), task(2, 1), task(3, 1), task(4, 1), task(5, 2), task(6, 2)},
+			append(diamond, edge(5, 6)), []uint{5, 6}, 4},
+		{"dependency on an unknown task", []Task{task(1, 1), task(2, 1)}, []TaskDependency{edge(1, 2), edge(9, 1)}, []uint{1, 2}, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := FindCriticalPath(test.tasks, test.dependencies)
+			var ids []uint
+			for _, task := range path.Tasks {
+				ids = append(ids, task.ID)
+			}
+			if !slices.Equal(ids, test.path) || path.Days != test.days {
+				t.Errorf("path = %v over %d days, want %v over %d days", ids, path.Days, test.path, test.days)
+			}
+		})
+	}
+}
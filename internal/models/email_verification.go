@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type EmailVerificationToken struct {
+	gorm.Model
+	UserId    uint   `gorm:"index"`
+	User      User   `gorm:"foreignKey:UserId"`
+	TokenHash string `gorm:"uniqueIndex"`
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
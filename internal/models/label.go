@@ -0,0 +1,80 @@
+package models
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultLabelColor is used for labels created without a color.
+const DefaultLabelColor = "#6b7280"
+
+var labelColorPattern = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+
+// Label categorizes tasks. Labels without a ProjectId belong to the whole
+// organization and can be put on tasks of any of its projects.
+type Label struct {
+	gorm.Model
+	OrganizationId uint   `gorm:"index" json:"OrganizationId"`
+	ProjectId      *uint  `gorm:"index" json:"ProjectId"`
+	Name           string `json:"Name"`
+	Color          string `json:"Color"`
+	CreatedBy      uint   `json:"CreatedBy"`
+}
+
+// TaskLabel puts a label on a task. A task can have any number of labels.
+type TaskLabel struct {
+	TaskId  uint      `gorm:"primaryKey" json:"TaskId"`
+	LabelId uint      `gorm:"primaryKey;index" json:"LabelId"`
+	Label   Label     `gorm:"foreignKey:LabelId" json:"-"`
+	AddedBy uint      `json:"AddedBy"`
+	AddedAt time.Time `json:"AddedAt"`
+}
+
+// AppliesTo reports whether the label can be used on tasks of projectId.
+func (l *Label) AppliesTo(projectId uint) bool {
+	return l.ProjectId == nil || *l.ProjectId == projectId
+}
+
+// ParseLabelColor accepts a hex color such as #1f883d and returns it in
+// lower case.
+func ParseLabelColor(value string) (string, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if !strings.HasPrefix(value, "#") {
+		value = "#" + value
+	}
+	return value, labelColorPattern.MatchString(value)
+}
+
+// MarshalJSON overrides the default JSON marshaling to use the expected field names
+func (l Label) MarshalJSON() ([]byte, error) {
+	type Alias Label
+	return json.Marshal(&struct {
+		ID        uint   `json:"ID"`
+		CreatedAt string `json:"created_at"`
+		UpdatedAt string `json:"updated_at"`
+		*Alias
+	}{
+		ID:        l.ID,
+		CreatedAt: l.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt: l.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		Alias:     (*Alias)(&l),
+	})
+}
+
+// MarshalJSON adds the label's name and color
+func (l TaskLabel) MarshalJSON() ([]byte, error) {
+	type Alias TaskLabel
+	return json.Marshal(&struct {
+		Name  string `json:"Name"`
+		Color string `json:"Color"`
+		*Alias
+	}{
+		Name:  l.Label.Name,
+		Color: l.Label.Color,
+		Alias: (*Alias)(&l),
+	})
+}
@@ -0,0 +1,36 @@
+package models
+
+import "testing"
+
+func TestParseLabelColor(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		ok    bool
+	}{
+		{"#1f883d", "#1f883d", true},
+		{"#1F883D", "#1f883d", true},
+		{" 1f883d ", "#1f883d", true},
+		{"#fff", "", false},
+		{"#1f883g", "", false},
+		{"##1f883d", "", false},
+		{"", "", false},
+	}
+	for _, test := range tests {
+		color, ok := ParseLabelColor(test.value)
+		if ok != test.ok || ok && color != test.want {
+			t.Errorf("ParseLabelColor(%q) = %q, %v, want %q, %v", test.value, color, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestLabelAppliesTo(t *testing.T) {
+	project := uint(7)
+	organization, projectLabel := Label{}, Label{ProjectId: &project}
+	if !organization.AppliesTo(7) || !organization.AppliesTo(8) {
+		t.Error("organization label does not apply to every project")
+	}
+	if !projectLabel.AppliesTo(7) || projectLabel.AppliesTo(8) {
+		t.Error("project label does not apply to its project only")
+	}
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+var orgRoleRanks = map[string]int{
+	OrgRoleMember: 1,
+	OrgRoleAdmin:  2,
+	OrgRoleOwner:  3,
+}
+
+// Organization is the tenant boundary. Every project belongs to exactly one
+// organization and users only see projects of the organization they are
+// currently working in.
+type Organization struct {
+	gorm.Model
+	Name     string `json:"Name"`
+	Slug     string `gorm:"uniqueIndex" json:"Slug"`
+	Personal bool   `json:"Personal"`
+
+	// Settings
+	RestrictProjectCreation bool   `json:"RestrictProjectCreation"`
+	AllowedEmailDomain      string `json:"AllowedEmailDomain"`
+}
+
+type OrganizationMember struct {
+	gorm.Model
+	OrganizationId uint         `gorm:"uniqueIndex:idx_organization_member" json:"OrganizationId"`
+	Organization   Organization `gorm:"foreignKey:OrganizationId" json:"-"`
+	UserId         uint         `gorm:"uniqueIndex:idx_organization_member;index" json:"UserId"`
+	User           User         `gorm:"foreignKey:UserId" json:"-"`
+	Role           string       `json:"Role"`
+}
+
+func IsValidOrgRole(role string) bool {
+	_, ok := orgRoleRanks[role]
+	return ok
+}
+
+// OrgRoleRank orders organization roles from member (1) to owner (3).
+// Unknown roles rank 0.
+func OrgRoleRank(role string) int {
+	return orgRoleRanks[role]
+}
+
+// AllowsUser reports whether user may join the organization. When a domain
+// is enforced the address must be verified, otherwise anyone could claim it.
+func (o *Organization) AllowsUser(user *User) bool {
+	if o.AllowedEmailDomain == "" {
+		return true
+	}
+	if !user.EmailVerified {
+		return false
+	}
+	_, domain, ok := strings.Cut(user.Email, "@")
+	return ok && strings.EqualFold(domain, o.AllowedEmailDomain)
+}
+
+// MarshalJSON overrides the default JSON marshaling to use the expected field names
+func (o Organization) MarshalJSON() ([]byte, error) {
+	type Alias Organization
+	return json.Marshal(&struct {
+		ID        uint   `json:"ID"`
+		CreatedAt string `json:"created_at"`
+		UpdatedAt string `json:"updated_at"`
+		*Alias
+	}{
+		ID:        o.ID,
+		CreatedAt: o.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt: o.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		Alias:     (*Alias)(&o),
+	})
+}
+
+// MarshalJSON overrides the default JSON marshaling to use the expected field names
+func (m OrganizationMember) MarshalJSON() ([]byte, error) {
+	type Alias OrganizationMember
+	return json.Marshal(&struct {
+		ID        uint   `json:"ID"`
+		CreatedAt string `json:"created_at"`
+		UpdatedAt string `json:"updated_at"`
+		Username  string `json:"Username"`
+		Profile   string `json:"Profile"`
+		*Alias
+	}{
+		ID:        m.ID,
+		CreatedAt: m.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt: m.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		Username:  m.User.Username,
+		Profile:   m.User.Profile,
+		Alias:     (*Alias)(&m),
+	})
+}
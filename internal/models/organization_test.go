@@ -0,0 +1,27 @@
+package models
+
+import "testing"
+
+func TestOrganizationAllowsUser(t *testing.T) {
+	tests := []struct {
+		domain   string
+		email    string
+		verified bool
+		want     bool
+	}{
+		{"", "alice@example.org", false, true},
+		{"example.org", "alice@example.org", true, true},
+		{"example.org", "alice@EXAMPLE.org", true, true},
+		{"example.org", "alice@example.org", false, false},
+		{"example.org", "alice@example.com", true, false},
+		{"example.org", "alice@sub.example.org", true, false},
+		{"example.org", "example.org", true, false},
+	}
+	for _, test := range tests {
+		organization := Organization{AllowedEmailDomain: test.domain}
+		user := User{Email: test.email, EmailVerified: test.verified}
+		if got := organization.AllowsUser(&user); got != test.want {
+			t.Errorf("domain %q allows %q (verified %v) = %v, want %v", test.domain, test.email, test.verified, got, test.want)
+		}
+	}
+}
@@ -8,10 +8,12 @@ import (
 
 type Project struct {
 	gorm.Model
-	Name        string `json:"Name"`
-	Description string `json:"Description"`
-	UserId      uint   `json:"UserId"`
-	User        User   `gorm:"foreignKey:UserId" json:"-"`
+	Name           string       `json:"Name"`
+	Description    string       `json:"Description"`
+	UserId         uint         `json:"UserId"`
+	User           User         `gorm:"foreignKey:UserId" json:"-"`
+	OrganizationId uint         `gorm:"index" json:"OrganizationId"`
+	Organization   Organization `gorm:"foreignKey:OrganizationId" json:"-"`
 }
 
 // MarshalJSON overrides the default JSON marshaling to use the expected field names
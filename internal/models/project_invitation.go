@@ -0,0 +1,45 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProjectInvitation invites either a single email address or, when Email is
+// empty, anyone holding the link, to join a project with Role.
+type ProjectInvitation struct {
+	gorm.Model
+	ProjectId uint       `gorm:"index" json:"ProjectId"`
+	Project   Project    `gorm:"foreignKey:ProjectId" json:"-"`
+	InvitedBy uint       `json:"InvitedBy"`
+	Inviter   User       `gorm:"foreignKey:InvitedBy" json:"-"`
+	Email     string     `json:"Email"`
+	Role      string     `json:"Role"`
+	TokenHash string     `gorm:"uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `json:"ExpiresAt"`
+	MaxUses   int        `json:"MaxUses"`
+	Uses      int        `json:"Uses"`
+	RevokedAt *time.Time `json:"RevokedAt"`
+}
+
+func (i *ProjectInvitation) IsPending(now time.Time) bool {
+	return i.RevokedAt == nil && now.Before(i.ExpiresAt) && i.Uses < i.MaxUses
+}
+
+// MarshalJSON overrides the default JSON marshaling to use the expected field names
+func (i ProjectInvitation) MarshalJSON() ([]byte, error) {
+	type Alias ProjectInvitation
+	return json.Marshal(&struct {
+		ID        uint   `json:"ID"`
+		CreatedAt string `json:"created_at"`
+		UpdatedAt string `json:"updated_at"`
+		*Alias
+	}{
+		ID:        i.ID,
+		CreatedAt: i.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt: i.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		Alias:     (*Alias)(&i),
+	})
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ProjectRoleOwner       = "owner"
+	ProjectRoleMaintainer  = "maintainer"
+	ProjectRoleContributor = "contributor"
+	ProjectRoleViewer      = "viewer"
+)
+
+type ProjectAction int
+
+const (
+	ActionViewProject ProjectAction = iota
+	ActionEditProject
+	ActionDeleteProject
+	ActionManageMembers
+	ActionViewTasks
+	ActionCreateTask
+	ActionEditTask
+	ActionDeleteTask
+	ActionComment
+	ActionModerateComments
+	ActionManageLabels
+)
+
+var projectRoleRanks = map[string]int{
+	ProjectRoleViewer:      1,
+	ProjectRoleContributor: 2,
+	ProjectRoleMaintainer:  3,
+	ProjectRoleOwner:       4,
+}
+
+// projectActionRoles is the role matrix: the lowest role that may perform
+// each action. Higher roles inherit everything below them.
+var projectActionRoles = map[ProjectAction]string{
+	ActionViewProject:      ProjectRoleViewer,
+	ActionViewTasks:        ProjectRoleViewer,
+	ActionCreateTask:       ProjectRoleContributor,
+	ActionEditTask:         ProjectRoleContributor,
+	ActionDeleteTask:       ProjectRoleMaintainer,
+	ActionComment:          ProjectRoleContributor,
+	ActionModerateComments: ProjectRoleMaintainer,
+	ActionManageLabels:     ProjectRoleMaintainer,
+	ActionEditProject:      ProjectRoleMaintainer,
+	ActionManageMembers:    ProjectRoleMaintainer,
+	ActionDeleteProject:    ProjectRoleOwner,
+}
+
+type ProjectMember struct {
+	gorm.Model
+	ProjectId uint    `gorm:"uniqueIndex:idx_project_member" json:"ProjectId"`
+	Project   Project `gorm:"foreignKey:ProjectId" json:"-"`
+	UserId    uint    `gorm:"uniqueIndex:idx_project_member;index" json:"UserId"`
+	User      User    `gorm:"foreignKey:UserId" json:"-"`
+	Role      string  `json:"Role"`
+}
+
+func IsValidProjectRole(role string) bool {
+	_, ok := projectRoleRanks[role]
+	return ok
+}
+
+// ProjectRoleRank orders project roles from viewer (1) to owner (4). Unknown
+// roles rank 0.
+func ProjectRoleRank(role string) int {
+	return projectRoleRanks[role]
+}
+
+func CanPerformProjectAction(role string, action ProjectAction) bool {
+	required, ok := projectActionRoles[action]
+	return ok && ProjectRoleRank(role) >= ProjectRoleRank(required)
+}
+
+// MarshalJSON overrides the default JSON marshaling to use the expected field names
+func (m ProjectMember) MarshalJSON() ([]byte, error) {
+	type Alias ProjectMember
+	return json.Marshal(&struct {
+		ID        uint   `json:"ID"`
+		CreatedAt string `json:"created_at"`
+		UpdatedAt string `json:"updated_at"`
+		Username  string `json:"Username"`
+		Profile   string `json:"Profile"`
+		*Alias
+	}{
+		ID:        m.ID,
+		CreatedAt: m.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt: m.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		Username:  m.User.Username,
+		Profile:   m.User.Profile,
+		Alias:     (*Alias)(&m),
+	})
+}
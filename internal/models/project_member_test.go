@@ -0,0 +1,44 @@
+package models
+
+import "testing"
+
+func TestCanPerformProjectAction(t *testing.T) {
+	actions := []struct {
+		name   string
+		action ProjectAction
+		// allowed lists for viewer, contributor, maintainer and owner.
+		allowed [4]bool
+	}{
+		{"view project", ActionViewProject, [4]bool{true, true, true, true}},
+		{"view tasks", ActionViewTasks, [4]bool{true, true, true, true}},
+		{"create task", ActionCreateTask, [4]bool{false, true, true, true}},
+		{"edit task", ActionEditTask, [4]bool{false, true, true, true}},
+		{"comment", ActionComment, [4]bool{false, true, true, true}},
+		{"delete task", ActionDeleteTask, [4]bool{false, false, true, true}},
+		{"moderate comments", ActionModerateComments, [4]bool{false, false, true, true}},
+		{"manage labels", ActionManageLabels, [4]bool{false, false, true, true}},
+		{"edit project", ActionEditProject, [4]bool{false, false, true, true}},
+		{"manage members", ActionManageMembers, [4]bool{false, false, true, true}},
+		{"delete project", ActionDeleteProject, [4]bool{false, false, false, true}},
+	}
+	roles := []string{ProjectRoleViewer, ProjectRoleContributor, ProjectRoleMaintainer, ProjectRoleOwner}
+
+	if len(actions) != len(projectActionRoles) {
+		t.Fatalf("table covers %d actions, the matrix has %d", len(actions), len(projectActionRoles))
+	}
+	for _, test := range actions {
+		for i, role := range roles {
+			if got := CanPerformProjectAction(role, test.action); got != test.allowed[i] {
+				t.Errorf("%s may %s = %v, want %v", role, test.name, got, test.allowed[i])
+			}
+		}
+		for _, role := range []string{"", "admin", "Owner"} {
+			if CanPerformProjectAction(role, test.action) {
+				t.Errorf("unknown role %q may %s", role, test.name)
+			}
+		}
+	}
+	if CanPerformProjectAction(ProjectRoleOwner, ProjectAction(-1)) {
+		t.Error("owner may perform an unknown action")
+	}
+}
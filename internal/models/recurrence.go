@@ -0,0 +1,51 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TaskRecurrence repeats a task on the schedule of an RFC 5545 RRULE. Every
+// occurrence is a task of its own; the first one is the task the rule was
+// set on and DTStart is its due date.
+type TaskRecurrence struct {
+	gorm.Model
+	ProjectId uint      `gorm:"index" json:"ProjectId"`
+	RRule     string    `gorm:"column:rrule" json:"RRule"`
+	Timezone  string    `json:"Timezone"`
+	DTStart   time.Time `gorm:"column:dtstart" json:"DTStart"`
+	CreatedBy uint      `json:"CreatedBy"`
+	// LastOccurrenceAt is the schedule of the newest occurrence, NextAt that
+	// of the one after it, which is created once it is due unless the newest
+	// is completed first. NextAt is nil when the rule has no more
+	// occurrences.
+	LastOccurrenceAt time.Time  `json:"LastOccurrenceAt"`
+	NextAt           *time.Time `gorm:"index" json:"NextAt"`
+	// EndedAt is set when the rule ran out of occurrences or was removed.
+	EndedAt *time.Time `gorm:"index" json:"EndedAt"`
+}
+
+// RecurrencePreview is a recurrence with the schedules of its next
+// occurrences.
+type RecurrencePreview struct {
+	Recurrence TaskRecurrence `json:"Recurrence"`
+	Upcoming   []time.Time    `json:"Upcoming"`
+}
+
+// MarshalJSON overrides the default JSON marshaling to use the expected field names
+func (r TaskRecurrence) MarshalJSON() ([]byte, error) {
+	type Alias TaskRecurrence
+	return json.Marshal(&struct {
+		ID        uint   `json:"ID"`
+		CreatedAt string `json:"created_at"`
+		UpdatedAt string `json:"updated_at"`
+		*Alias
+	}{
+		ID:        r.ID,
+		CreatedAt: r.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt: r.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		Alias:     (*Alias)(&r),
+	})
+}
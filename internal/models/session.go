@@ -0,0 +1,39 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Session struct {
+	gorm.Model
+	UserId         uint      `gorm:"index" json:"UserId"`
+	User           User      `gorm:"foreignKey:UserId" json:"-"`
+	TokenHash      string    `gorm:"uniqueIndex" json:"-"`
+	CSRFToken      string    `json:"-"`
+	DeviceName     string    `json:"DeviceName"`
+	IP             string    `json:"IP"`
+	UserAgent      string    `json:"UserAgent"`
+	LastSeenAt     time.Time `json:"LastSeenAt"`
+	ExpiresAt      time.Time `json:"ExpiresAt"`
+	OrganizationId uint      `json:"OrganizationId"`
+	Current        bool      `gorm:"-" json:"Current"`
+}
+
+// MarshalJSON overrides the default JSON marshaling to use the expected field names
+func (s Session) MarshalJSON() ([]byte, error) {
+	type Alias Session
+	return json.Marshal(&struct {
+		ID        uint   `json:"ID"`
+		CreatedAt string `json:"created_at"`
+		UpdatedAt string `json:"updated_at"`
+		*Alias
+	}{
+		ID:        s.ID,
+		CreatedAt: s.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt: s.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		Alias:     (*Alias)(&s),
+	})
+}
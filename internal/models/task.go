@@ -2,32 +2,164 @@ package models
 
 import (
 	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+const (
+	PriorityNone = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+	PriorityUrgent
+)
+
+var priorityNames = []string{"none", "low", "medium", "high", "urgent"}
+
 type Task struct {
 	gorm.Model
-	Title       string  `json:"Title"`
-	Description string  `json:"Description"`
-	ProjectId   uint    `json:"ProjectId"`
-	Project     Project `gorm:"foreignKey:ProjectId" json:"-"`
-	AssignedTo  uint    `json:"AssignedTo"`
-	User        User    `gorm:"foreignKey:AssignedTo" json:"-"`
+	Title       string         `json:"Title"`
+	Description string         `json:"Description"`
+	ProjectId   uint           `json:"ProjectId"`
+	Project     Project        `gorm:"foreignKey:ProjectId" json:"-"`
+	CreatedBy   uint           `json:"CreatedBy"`
+	Creator     User           `gorm:"foreignKey:CreatedBy" json:"-"`
+	StateId     *uint          `gorm:"index" json:"StateId"`
+	Priority    int            `gorm:"default:0;index" json:"Priority"`
+	StartDate   *time.Time     `json:"StartDate"`
+	DueDate     *time.Time     `gorm:"index" json:"DueDate"`
+	CompletedAt *time.Time     `json:"CompletedAt"`
+	Assignees   []TaskAssignee `gorm:"foreignKey:TaskId" json:"Assignees"`
+	Labels      []TaskLabel    `gorm:"foreignKey:TaskId" json:"Labels"`
+	ParentId    *uint          `gorm:"index" json:"ParentId"`
+	// Estimates are in minutes. Logging work lowers the remaining estimate.
+	OriginalEstimate  *int `json:"OriginalEstimate"`
+	RemainingEstimate *int `json:"RemainingEstimate"`
+	// Occurrences of a recurring task share a RecurrenceId. OccurrenceAt is
+	// the scheduled due date, which stays put when DueDate is changed.
+	RecurrenceId *uint      `gorm:"uniqueIndex:idx_task_occurrence" json:"RecurrenceId"`
+	OccurrenceAt *time.Time `gorm:"uniqueIndex:idx_task_occurrence" json:"OccurrenceAt"`
+}
+
+// TaskAssignee assigns a task to a member of its project. A task can have
+// any number of assignees.
+type TaskAssignee struct {
+	TaskId     uint      `gorm:"primaryKey" json:"TaskId"`
+	UserId     uint      `gorm:"primaryKey;index" json:"UserId"`
+	User       User      `gorm:"foreignKey:UserId" json:"-"`
+	AssignedBy uint      `json:"AssignedBy"`
+	AssignedAt time.Time `json:"AssignedAt"`
+}
+
+// TaskNode is a task with its subtasks and the progress rolled up from
+// them.
+type TaskNode struct {
+	Task     Task         `json:"Task"`
+	Subtasks []*TaskNode  `json:"Subtasks"`
+	Progress TaskProgress `json:"Progress"`
+}
+
+// TaskProgress counts completed subtasks, both direct children and all
+// descendants.
+type TaskProgress struct {
+	SubtasksDone     int `json:"SubtasksDone"`
+	SubtasksTotal    int `json:"SubtasksTotal"`
+	DescendantsDone  int `json:"DescendantsDone"`
+	DescendantsTotal int `json:"DescendantsTotal"`
+}
+
+// BuildTaskTree arranges tasks into trees. Tasks whose parent is not part of
+// tasks become roots.
+func BuildTaskTree(tasks []Task) []*TaskNode {
+	nodes := make(map[uint]*TaskNode, len(tasks))
+	for _, task := range tasks {
+		nodes[task.ID] = &TaskNode{Task: task, Subtasks: []*TaskNode{}}
+	}
+
+	var roots []*TaskNode
+	for _, task := range tasks {
+		node := nodes[task.ID]
+		if task.ParentId != nil {
+			if parent, ok := nodes[*task.ParentId]; ok {
+				parent.Subtasks = append(parent.Subtasks, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	for _, root := range roots {
+		root.rollUp()
+	}
+	return roots
+}
+
+func (n *TaskNode) rollUp() {
+	for _, child := range n.Subtasks {
+		child.rollUp()
+		n.Progress.SubtasksTotal++
+		n.Progress.DescendantsTotal += 1 + child.Progress.DescendantsTotal
+		n.Progress.DescendantsDone += child.Progress.DescendantsDone
+		if child.Task.CompletedAt != nil {
+			n.Progress.SubtasksDone++
+			n.Progress.DescendantsDone++
+		}
+	}
+}
+
+// ParsePriority accepts a priority by name ("low", "high", ...) or number.
+func ParsePriority(value string) (int, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	for priority, name := range priorityNames {
+		if value == name {
+			return priority, true
+		}
+	}
+	priority, err := strconv.Atoi(value)
+	if err != nil || priority < PriorityNone || priority > PriorityUrgent {
+		return 0, false
+	}
+	return priority, true
+}
+
+func PriorityName(priority int) string {
+	if priority < PriorityNone || priority > PriorityUrgent {
+		return ""
+	}
+	return priorityNames[priority]
 }
 
 // MarshalJSON overrides the default JSON marshaling to use the expected field names
 func (t Task) MarshalJSON() ([]byte, error) {
 	type Alias Task
 	return json.Marshal(&struct {
-		ID        uint   `json:"ID"`
-		CreatedAt string `json:"created_at"`
-		UpdatedAt string `json:"updated_at"`
+		ID           uint   `json:"ID"`
+		CreatedAt    string `json:"created_at"`
+		UpdatedAt    string `json:"updated_at"`
+		PriorityName string `json:"PriorityName"`
+		*Alias
+	}{
+		ID:           t.ID,
+		CreatedAt:    t.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt:    t.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		PriorityName: PriorityName(t.Priority),
+		Alias:        (*Alias)(&t),
+	})
+}
+
+// MarshalJSON adds the assignee's username and profile picture
+func (a TaskAssignee) MarshalJSON() ([]byte, error) {
+	type Alias TaskAssignee
+	return json.Marshal(&struct {
+		Username string `json:"Username"`
+		Profile  string `json:"Profile"`
 		*Alias
 	}{
-		ID:        t.ID,
-		CreatedAt: t.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-		UpdatedAt: t.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
-		Alias:     (*Alias)(&t),
+		Username: a.User.Username,
+		Profile:  a.User.Profile,
+		Alias:    (*Alias)(&a),
 	})
 }
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParsePriority(t *testing.T) {
+	tests := []struct {
+		value    string
+		priority int
+		ok       bool
+	}{
+		{"none", PriorityNone, true},
+		{"low", PriorityLow, true},
+		{" High ", PriorityHigh, true},
+		{"URGENT", PriorityUrgent, true},
+		{"2", PriorityMedium, true},
+		{"0", PriorityNone, true},
+		{"5", 0, false},
+		{"-1", 0, false},
+		{"critical", 0, false},
+		{"", 0, false},
+	}
+	for _, test := range tests {
+		priority, ok := ParsePriority(test.value)
+		if priority != test.priority || ok != test.ok {
+			t.Errorf("ParsePriority(%q) = %d, %v, want %d, %v", test.value, priority, ok, test.priority, test.ok)
+		}
+	}
+
+	for priority := PriorityNone; priority <= PriorityUrgent; priority++ {
+		if parsed, ok := ParsePriority(PriorityName(priority)); !ok || parsed != priority {
+			t.Errorf("priority %d does not survive its name %q", priority, PriorityName(priority))
+		}
+	}
+	if name := PriorityName(PriorityUrgent + 1); name != "" {
+		t.Errorf("PriorityName(%d) = %q, want none", PriorityUrgent+1, name)
+	}
+}
+
+func TestBuildTaskTree(t *testing.T) {
+	done := time.Now()
+	task := func(id, parentId uint, completed bool) Task {
+		task := Task{Title: fmt.Sprintf("task %d", id)}
+		task.ID = id
+		if parentId != 0 {
+			task.ParentId = &parentId
+		}
+		if completed {
+			task.CompletedAt = &done
+		}
+		return task
+	}
+	// Epic 1 has the stories 2 (done) and 3, which has the subtasks 4, 5
+	// (done) and 6 (done). The parent of 7 is not part of the list.
+	roots := BuildTaskTree([]Task{
+		task(1, 0, false),
+		task(2, 1, true),
+		task(3, 1, false),
+		task(4, 3, false),
+		task(5, 3, true),
+		task(6, 3, true),
+		task(7, 8, false),
+	})
+
+	if len(roots) != 2 || roots[0].Task.ID != 1 || roots[1].Task.ID != 7 {
+		t.Fatalf("roots = %v, want tasks 1 and 7", roots)
+	}
+	epic := roots[0]
+	if len(epic.Subtasks) != 2 || len(epic.Subtasks[1].Subtasks) != 3 {
+		t.Fatalf("epic = %+v, want two stories, the second with three subtasks", epic)
+	}
+
+	tests := []struct {
+		node *TaskNode
+		want TaskProgress
+	}{
+		{epic, TaskProgress{SubtasksDone: 1, SubtasksTotal: 2, DescendantsDone: 3, DescendantsTotal: 5}},
+		{epic.Subtasks[0], TaskProgress{}},
+		{epic.Subtasks[1], TaskProgress{SubtasksDone: 2, SubtasksTotal: 3, DescendantsDone: 2, DescendantsTotal: 3}},
+		{roots[1], TaskProgress{}},
+	}
+	for _, test := range tests {
+		if test.node.Progress != test.want {
+			t.Errorf("progress of task %d = %+v, want %+v", test.node.Task.ID, test.node.Progress, test.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+// Team groups members of an organization so that they can be given access
+// to projects as a unit.
+type Team struct {
+	gorm.Model
+	OrganizationId uint         `gorm:"uniqueIndex:idx_team_name" json:"OrganizationId"`
+	Organization   Organization `gorm:"foreignKey:OrganizationId" json:"-"`
+	Name           string       `gorm:"uniqueIndex:idx_team_name" json:"Name"`
+	Description    string       `json:"Description"`
+}
+
+type TeamMember struct {
+	gorm.Model
+	TeamId uint `gorm:"uniqueIndex:idx_team_member" json:"TeamId"`
+	Team   Team `gorm:"foreignKey:TeamId" json:"-"`
+	UserId uint `gorm:"uniqueIndex:idx_team_member;index" json:"UserId"`
+	User   User `gorm:"foreignKey:UserId" json:"-"`
+}
+
+// ProjectTeamGrant gives every member of a team a role on a project.
+type ProjectTeamGrant struct {
+	gorm.Model
+	ProjectId uint    `gorm:"uniqueIndex:idx_project_team" json:"ProjectId"`
+	Project   Project `gorm:"foreignKey:ProjectId" json:"-"`
+	TeamId    uint    `gorm:"uniqueIndex:idx_project_team;index" json:"TeamId"`
+	Team      Team    `gorm:"foreignKey:TeamId" json:"-"`
+	Role      string  `json:"Role"`
+}
+
+const (
+	AccessSourceDirect = "direct"
+	AccessSourceTeam   = "team"
+)
+
+// AccessGrant is one of the reasons a user has a role on a project.
+type AccessGrant struct {
+	Source   string `json:"Source"`
+	Role     string `json:"Role"`
+	TeamId   uint   `json:"TeamId,omitempty"`
+	TeamName string `json:"TeamName,omitempty"`
+}
+
+// EffectiveProjectRole returns the highest role among grants, or an empty
+// string when there are none.
+func EffectiveProjectRole(grants []AccessGrant) string {
+	role := ""
+	for _, grant := range grants {
+		if ProjectRoleRank(grant.Role) > ProjectRoleRank(role) {
+			role = grant.Role
+		}
+	}
+	return role
+}
+
+// MarshalJSON overrides the default JSON marshaling to use the expected field names
+func (t Team) MarshalJSON() ([]byte, error) {
+	type Alias Team
+	return json.Marshal(&struct {
+		ID        uint   `json:"ID"`
+		CreatedAt string `json:"created_at"`
+		UpdatedAt string `json:"updated_at"`
+		*Alias
+	}{
+		ID:        t.ID,
+		CreatedAt: t.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt: t.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		Alias:     (*Alias)(&t),
+	})
+}
+
+// MarshalJSON overrides the default JSON marshaling to use the expected field names
+func (m TeamMember) MarshalJSON() ([]byte, error) {
+	type Alias TeamMember
+	return json.Marshal(&struct {
+		ID        uint   `json:"ID"`
+		CreatedAt string `json:"created_at"`
+		UpdatedAt string `json:"updated_at"`
+		Username  string `json:"Username"`
+		Profile   string `json:"Profile"`
+		*Alias
+	}{
+		ID:        m.ID,
+		CreatedAt: m.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt: m.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		Username:  m.User.Username,
+		Profile:   m.User.Profile,
+		Alias:     (*Alias)(&m),
+	})
+}
+
+// MarshalJSON overrides the default JSON marshaling to use the expected field names
+func (g ProjectTeamGrant) MarshalJSON() ([]byte, error) {
+	type Alias ProjectTeamGrant
+	return json.Marshal(&struct {
+		ID        uint   `json:"ID"`
+		CreatedAt string `json:"created_at"`
+		UpdatedAt string `json:"updated_at"`
+		TeamName  string `json:"TeamName"`
+		*Alias
+	}{
+		ID:        g.ID,
+		CreatedAt: g.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt: g.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		TeamName:  g.Team.Name,
+		Alias:     (*Alias)(&g),
+	})
+}
@@ -0,0 +1,28 @@
+package models
+
+import "testing"
+
+func TestEffectiveProjectRole(t *testing.T) {
+	direct := func(role string) AccessGrant { return AccessGrant{Source: AccessSourceDirect, Role: role} }
+	team := func(role string, id uint) AccessGrant {
+		return AccessGrant{Source: AccessSourceTeam, Role: role, TeamId: id}
+	}
+	tests := []struct {
+		name   string
+		grants []AccessGrant
+		want   string
+	}{
+		{"no grants", nil, ""},
+		{"direct only", []AccessGrant{direct(ProjectRoleContributor)}, ProjectRoleContributor},
+		{"team only", []AccessGrant{team(ProjectRoleViewer, 1)}, ProjectRoleViewer},
+		{"team above direct", []AccessGrant{direct(ProjectRoleViewer), team(ProjectRoleMaintainer, 1)}, ProjectRoleMaintainer},
+		{"direct above team", []AccessGrant{direct(ProjectRoleOwner), team(ProjectRoleMaintainer, 1)}, ProjectRoleOwner},
+		{"several teams", []AccessGrant{team(ProjectRoleViewer, 1), team(ProjectRoleContributor, 2), team(ProjectRoleViewer, 3)}, ProjectRoleContributor},
+		{"unknown role", []AccessGrant{team("admin", 1)}, ""},
+	}
+	for _, test := range tests {
+		if got := EffectiveProjectRole(test.grants); got != test.want {
+			t.Errorf("%s: role = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
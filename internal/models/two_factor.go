@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type RecoveryCode struct {
+	gorm.Model
+	UserId   uint   `gorm:"index"`
+	User     User   `gorm:"foreignKey:UserId"`
+	CodeHash string `gorm:"index"`
+	UsedAt   *time.Time
+}
+
+// PendingLogin is created when a user with two-factor authentication passes
+// the password check and is waiting to supply the second factor.
+type PendingLogin struct {
+	gorm.Model
+	UserId    uint   `gorm:"index"`
+	User      User   `gorm:"foreignKey:UserId"`
+	TokenHash string `gorm:"uniqueIndex"`
+	Attempts  int
+	ExpiresAt time.Time
+}
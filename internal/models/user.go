@@ -1,13 +1,100 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+	RoleGuest  = "guest"
+)
 
 type User struct {
 	gorm.Model
-	Username     string
-	Email        string `gorm:"unique"`
-	Password     string
-	Profile      string
-	SessionToken string
-	CSRFToken    string
+	Username        string
+	Email           string `gorm:"unique"`
+	Password        string `json:"-"`
+	Profile         string
+	Role            string `gorm:"default:member"`
+	DeactivatedAt   *time.Time
+	EmailVerified   bool
+	EmailVerifiedAt *time.Time
+	TOTPSecret      string `json:"-"`
+	TOTPEnabled     bool
+	TOTPLastStep    int64  `json:"-"`
+	OIDCIssuer      string `gorm:"index:idx_users_oidc" json:"-"`
+	OIDCSubject     string `gorm:"index:idx_users_oidc" json:"-"`
+	AvatarVersion   string `json:"-"`
+}
+
+// PublicUser is the view of a user that any signed in user may see.
+type PublicUser struct {
+	ID       uint   `json:"ID"`
+	Username string `json:"Username"`
+	Profile  string `json:"Profile"`
+}
+
+// AdminUser is the view of a user returned by the admin API.
+type AdminUser struct {
+	ID            uint       `json:"ID"`
+	Username      string     `json:"Username"`
+	Email         string     `json:"Email"`
+	Profile       string     `json:"Profile"`
+	Role          string     `json:"Role"`
+	Active        bool       `json:"Active"`
+	DeactivatedAt *time.Time `json:"DeactivatedAt"`
+	EmailVerified bool       `json:"EmailVerified"`
+	TOTPEnabled   bool       `json:"TOTPEnabled"`
+	SSO           bool       `json:"SSO"`
+	CreatedAt     string     `json:"created_at"`
+}
+
+// AvatarURL is where the avatar of a user is served. The version changes
+// with every upload so that clients can cache each URL for good; users
+// without an upload have no version and get an identicon.
+func AvatarURL(userId uint, version string) string {
+	if version == "" {
+		return fmt.Sprintf("/api/avatar?user_id=%d", userId)
+	}
+	return fmt.Sprintf("/api/avatar?user_id=%d&v=%s", userId, version)
+}
+
+func IsValidRole(role string) bool {
+	return role == RoleAdmin || role == RoleMember || role == RoleGuest
+}
+
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+func (u *User) IsActive() bool {
+	return u.DeactivatedAt == nil
+}
+
+func (u *User) Public() PublicUser {
+	return PublicUser{
+		ID:       u.ID,
+		Username: u.Username,
+		Profile:  u.Profile,
+	}
+}
+
+func (u *User) Admin() AdminUser {
+	return AdminUser{
+		ID:            u.ID,
+		Username:      u.Username,
+		Email:         u.Email,
+		Profile:       u.Profile,
+		Role:          u.Role,
+		Active:        u.IsActive(),
+		DeactivatedAt: u.DeactivatedAt,
+		EmailVerified: u.EmailVerified,
+		TOTPEnabled:   u.TOTPEnabled,
+		SSO:           u.OIDCSubject != "",
+		CreatedAt:     u.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
 }
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WorkflowState is one of the statuses tasks of a project move through.
+// New tasks start in the initial state; tasks in a done state count as
+// finished.
+type WorkflowState struct {
+	gorm.Model
+	ProjectId uint    `gorm:"uniqueIndex:idx_workflow_state_name" json:"ProjectId"`
+	Project   Project `gorm:"foreignKey:ProjectId" json:"-"`
+	Name      string  `gorm:"uniqueIndex:idx_workflow_state_name" json:"Name"`
+	Position  int     `json:"Position"`
+	IsInitial bool    `json:"IsInitial"`
+	IsDone    bool    `json:"IsDone"`
+}
+
+// WorkflowTransition allows tasks to move from one state to another.
+type WorkflowTransition struct {
+	gorm.Model
+	ProjectId   uint          `gorm:"index" json:"ProjectId"`
+	FromStateId uint          `gorm:"uniqueIndex:idx_workflow_transition" json:"FromStateId"`
+	FromState   WorkflowState `gorm:"foreignKey:FromStateId" json:"-"`
+	ToStateId   uint          `gorm:"uniqueIndex:idx_workflow_transition" json:"ToStateId"`
+	ToState     WorkflowState `gorm:"foreignKey:ToStateId" json:"-"`
+}
+
+// TaskTransition records a task entering a state. The first transition of
+// a task has no FromStateId. State names are copied so that the history
+// stays readable after a state is renamed or deleted.
+type TaskTransition struct {
+	ID             uint      `gorm:"primarykey" json:"ID"`
+	TaskId         uint      `gorm:"index" json:"TaskId"`
+	FromStateId    *uint     `json:"FromStateId"`
+	FromStateName  string    `json:"FromStateName"`
+	ToStateId      uint      `json:"ToStateId"`
+	ToStateName    string    `json:"ToStateName"`
+	UserId         uint      `json:"UserId"`
+	TransitionedAt time.Time `gorm:"index" json:"TransitionedAt"`
+}
+
+// DefaultWorkflowState describes a state of the workflow new projects get.
+type DefaultWorkflowState struct {
+	Name      string
+	IsInitial bool
+	IsDone    bool
+}
+
+var DefaultWorkflowStates = []DefaultWorkflowState{
+	{Name: "Backlog", IsInitial: true},
+	{Name: "Todo"},
+	{Name: "In Progress"},
+	{Name: "Review"},
+	{Name: "Done", IsDone: true},
+}
+
+// DefaultWorkflowTransitions lists the allowed moves between
+// DefaultWorkflowStates by name.
+var DefaultWorkflowTransitions = [][2]string{
+	{"Backlog", "Todo"},
+	{"Todo", "Backlog"},
+	{"Todo", "In Progress"},
+	{"In Progress", "Todo"},
+	{"In Progress", "Review"},
+	{"Review", "In Progress"},
+	{"Review", "Done"},
+	{"Done", "In Progress"},
+}
+
+// MarshalJSON overrides the default JSON marshaling to use the expected field names
+func (s WorkflowState) MarshalJSON() ([]byte, error) {
+	type Alias WorkflowState
+	return json.Marshal(&struct {
+		ID        uint   `json:"ID"`
+		CreatedAt string `json:"created_at"`
+		UpdatedAt string `json:"updated_at"`
+		*Alias
+	}{
+		ID:        s.ID,
+		CreatedAt: s.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt: s.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		Alias:     (*Alias)(&s),
+	})
+}
+
+// MarshalJSON overrides the default JSON marshaling to use the expected field names
+func (t WorkflowTransition) MarshalJSON() ([]byte, error) {
+	type Alias WorkflowTransition
+	return json.Marshal(&struct {
+		ID        uint   `json:"ID"`
+		CreatedAt string `json:"created_at"`
+		UpdatedAt string `json:"updated_at"`
+		*Alias
+	}{
+		ID:        t.ID,
+		CreatedAt: t.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt: t.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		Alias:     (*Alias)(&t),
+	})
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ReportByTask    = "task"
+	ReportByProject = "project"
+	ReportByUser    = "user"
+	ReportByDay     = "day"
+)
+
+// Worklog is time a user spent on a task, either logged by hand or
+// recorded by a timer. A running timer has no EndedAt yet; each user can
+// have only one.
+type Worklog struct {
+	gorm.Model
+	TaskId    uint       `gorm:"index" json:"TaskId"`
+	Task      Task       `gorm:"foreignKey:TaskId" json:"-"`
+	UserId    uint       `gorm:"index;uniqueIndex:idx_worklog_running_timer,where:ended_at IS NULL AND deleted_at IS NULL" json:"UserId"`
+	User      User       `gorm:"foreignKey:UserId" json:"-"`
+	StartedAt time.Time  `gorm:"index" json:"StartedAt"`
+	EndedAt   *time.Time `json:"EndedAt"`
+	Seconds   int64      `json:"Seconds"`
+	Note      string     `json:"Note"`
+}
+
+// TimeReport sums logged time by task, project, user or day.
+type TimeReport struct {
+	GroupBy      string          `json:"GroupBy"`
+	TotalSeconds int64           `json:"TotalSeconds"`
+	TotalHours   float64         `json:"TotalHours"`
+	Rows         []TimeReportRow `json:"Rows"`
+}
+
+// TimeReportRow is one group of a TimeReport. Days have no Id and use the
+// date as Name.
+type TimeReportRow struct {
+	Id      uint    `json:"Id"`
+	Name    string  `json:"Name"`
+	Seconds int64   `json:"Seconds"`
+	Hours   float64 `json:"Hours"`
+}
+
+func IsValidReportGroup(groupBy string) bool {
+	return groupBy == ReportByTask || groupBy == ReportByProject || groupBy == ReportByUser || groupBy == ReportByDay
+}
+
+// Hours converts seconds to hours rounded to two decimals.
+func Hours(seconds int64) float64 {
+	return float64(seconds*100/3600) / 100
+}
+
+// MarshalJSON overrides the default JSON marshaling to use the expected field names
+func (w Worklog) MarshalJSON() ([]byte, error) {
+	type Alias Worklog
+	return json.Marshal(&struct {
+		ID        uint   `json:"ID"`
+		CreatedAt string `json:"created_at"`
+		UpdatedAt string `json:"updated_at"`
+		Username  string `json:"Username"`
+		Running   bool   `json:"Running"`
+		*Alias
+	}{
+		ID:        w.ID,
+		CreatedAt: w.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt: w.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		Username:  w.User.Username,
+		Running:   w.EndedAt == nil,
+		Alias:     (*Alias)(&w),
+	})
+}
@@ -0,0 +1,548 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"hash"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testClientID = "devtasks"
+
+// mockIdP is an OpenID Provider served from an httptest server. It issues
+// id tokens for the codes handed out by authorize and checks PKCE when they
+// are redeemed.
+type mockIdP struct {
+	t      *testing.T
+	server *httptest.Server
+	issuer string
+
+	mu         sync.Mutex
+	keys       []signingKey
+	jwksCalls  int
+	challenges map[string]string
+	claims     map[string]map[string]any
+}
+
+type signingKey struct {
+	kid string
+	alg string
+	key crypto.Signer
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	idp := &mockIdP{
+		t:          t,
+		challenges: map[string]string{},
+		claims:     map[string]map[string]any{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Discovery{
+			Issuer:                idp.issuer,
+			AuthorizationEndpoint: idp.issuer + "/authorize",
+			TokenEndpoint:         idp.issuer + "/token",
+			JWKSURI:               idp.issuer + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		idp.jwksCalls++
+		set := jsonWebKeySet{}
+		for _, k := range idp.keys {
+			set.Keys = append(set.Keys, publicJWK(k))
+		}
+		json.NewEncoder(w).Encode(set)
+	})
+	mux.HandleFunc("POST /token", idp.token)
+
+	idp.server = httptest.NewServer(mux)
+	idp.issuer = idp.server.URL
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *mockIdP) setKeys(keys ...signingKey) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.keys = keys
+}
+
+func (idp *mockIdP) jwksFetches() int {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	return idp.jwksCalls
+}
+
+// authorize plays the user approving the login at authCodeURL and returns
+// the code the IdP sends back to the redirect URI.
+func (idp *mockIdP) authorize(authCodeURL string, claims map[string]any) string {
+	idp.t.Helper()
+	u, err := url.Parse(authCodeURL)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	query := u.Query()
+	if method := query.Get("code_challenge_method"); method != "S256" {
+		idp.t.Fatalf("code_challenge_method = %q, want S256", method)
+	}
+
+	claims["nonce"] = query.Get("nonce")
+	code := "code-" + query.Get("state")
+
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.challenges[code] = query.Get("code_challenge")
+	idp.claims[code] = claims
+	return code
+}
+
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	code := r.FormValue("code")
+	challenge, ok := idp.challenges[code]
+	claims := idp.claims[code]
+	delete(idp.challenges, code)
+	key := idp.keys[0]
+	idp.mu.Unlock()
+
+	if !ok {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+	if r.FormValue("grant_type") != "authorization_code" || r.FormValue("client_id") != testClientID {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+	if CodeChallenge(r.FormValue("code_verifier")) != challenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	json.NewEncoder(w).Encode(TokenResponse{
+		AccessToken: "access",
+		TokenType:   "Bearer",
+		IDToken:     signToken(idp.t, key, claims),
+		ExpiresIn:   3600,
+	})
+}
+
+func (idp *mockIdP) provider(t *testing.T) *Provider {
+	t.Helper()
+	p, err := NewProvider(context.Background(), Config{
+		Issuer:      idp.issuer,
+		ClientID:    testClientID,
+		RedirectURL: "http://localhost/api/oidc/callback",
+	}, idp.server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func (idp *mockIdP) claimsFor(nonce string) map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"iss":   idp.issuer,
+		"sub":   "user-1",
+		"aud":   testClientID,
+		"exp":   now.Add(time.Hour).Unix(),
+		"iat":   now.Unix(),
+		"nonce": nonce,
+		"email": "user@example.com",
+	}
+}
+
+func newRSAKey(t *testing.T, kid, alg string) signingKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingKey{kid: kid, alg: alg, key: key}
+}
+
+func newECKey(t *testing.T, kid, alg string, curve elliptic.Curve) signingKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingKey{kid: kid, alg: alg, key: key}
+}
+
+func publicJWK(k signingKey) jsonWebKey {
+	encode := base64.RawURLEncoding.EncodeToString
+	switch key := k.key.(type) {
+	case *rsa.PrivateKey:
+		return jsonWebKey{
+			Kty: "RSA",
+			Kid: k.kid,
+			Use: "sig",
+			N:   encode(key.N.Bytes()),
+			E:   encode(big.NewInt(int64(key.E)).Bytes()),
+		}
+	case *ecdsa.PrivateKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		return jsonWebKey{
+			Kty: "EC",
+			Kid: k.kid,
+			Use: "sig",
+			Crv: key.Curve.Params().Name,
+			X:   encode(key.X.FillBytes(make([]byte, size))),
+			Y:   encode(key.Y.FillBytes(make([]byte, size))),
+		}
+	}
+	panic("unsupported key")
+}
+
+// signToken returns a compact JWS of claims signed with k.
+func signToken(t *testing.T, k signingKey, claims map[string]any) string {
+	t.Helper()
+	header := map[string]string{"alg": k.alg, "typ": "JWT"}
+	if k.kid != "" {
+		header["kid"] = k.kid
+	}
+	headerJSON, _ := json.Marshal(header)
+	claimsJSON, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	var h hash.Hash
+	var hashFunc crypto.Hash
+	switch k.alg[2:] {
+	case "256":
+		h, hashFunc = sha256.New(), crypto.SHA256
+	case "384":
+		h, hashFunc = sha512.New384(), crypto.SHA384
+	case "512":
+		h, hashFunc = sha512.New(), crypto.SHA512
+	}
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	var signature []byte
+	var err error
+	switch k.alg[:2] {
+	case "RS":
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k.key.(*rsa.PrivateKey), hashFunc, digest)
+	case "PS":
+		signature, err = rsa.SignPSS(rand.Reader, k.key.(*rsa.PrivateKey), hashFunc, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	case "ES":
+		key := k.key.(*ecdsa.PrivateKey)
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, key, digest)
+		if err == nil {
+			size := (key.Curve.Params().BitSize + 7) / 8
+			signature = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestDiscovery(t *testing.T) {
+	idp := newMockIdP(t)
+	p := idp.provider(t)
+
+	discovery := p.Discovery()
+	if discovery.Issuer != idp.issuer || discovery.TokenEndpoint != idp.issuer+"/token" || discovery.JWKSURI != idp.issuer+"/jwks" {
+		t.Errorf("unexpected discovery document %+v", discovery)
+	}
+
+	authURL, err := url.Parse(p.AuthCodeURL("state", "nonce", "challenge"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := authURL.Query()
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          "http://localhost/api/oidc/callback",
+		"scope":                 "openid email profile",
+		"state":                 "state",
+		"nonce":                 "nonce",
+		"code_challenge":        "challenge",
+		"code_challenge_method": "S256",
+	}
+	for name, value := range want {
+		if got := query.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	idp := newMockIdP(t)
+	_, err := NewProvider(context.Background(), Config{Issuer: idp.issuer + "/", ClientID: testClientID}, idp.server.Client())
+	if !errors.Is(err, ErrIssuerMismatch) {
+		t.Errorf("err = %v, want ErrIssuerMismatch", err)
+	}
+}
+
+func TestDiscoveryMissingEndpoints(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Discovery{
+			Issuer:                server.URL,
+			AuthorizationEndpoint: server.URL + "/authorize",
+		})
+	}))
+	defer server.Close()
+
+	if _, err := NewProvider(context.Background(), Config{Issuer: server.URL}, server.Client()); err == nil {
+		t.Error("provider accepted a discovery document without token and jwks endpoints")
+	}
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	idp := newMockIdP(t)
+	idp.setKeys(newRSAKey(t, "rsa", "RS256"))
+	p := idp.provider(t)
+
+	verifier, err := GenerateVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce, err := RandomValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := idp.authorize(p.AuthCodeURL("state", nonce, CodeChallenge(verifier)), idp.claimsFor(""))
+
+	token, err := p.Exchange(context.Background(), code, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := p.VerifyIDToken(context.Background(), token.IDToken, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "user-1" || claims.Email != "user@example.com" {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	idp := newMockIdP(t)
+	idp.setKeys(newRSAKey(t, "rsa", "RS256"))
+	p := idp.provider(t)
+
+	verifier, _ := GenerateVerifier()
+	other, _ := GenerateVerifier()
+	code := idp.authorize(p.AuthCodeURL("state", "nonce", CodeChallenge(verifier)), idp.claimsFor(""))
+
+	if _, err := p.Exchange(context.Background(), code, other); err == nil {
+		t.Error("code redeemed with the wrong verifier")
+	}
+}
+
+func TestCodeChallengeRFC7636(t *testing.T) {
+	// RFC 7636 Appendix B.
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	if got, want := CodeChallenge(verifier), "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"; got != want {
+		t.Errorf("CodeChallenge = %s, want %s", got, want)
+	}
+
+	generated, err := GenerateVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A verifier is 43 to 128 unreserved characters.
+	if len(generated) < 43 || len(generated) > 128 || strings.Trim(generated, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-._~") != "" {
+		t.Errorf("GenerateVerifier returned invalid verifier %q", generated)
+	}
+}
+
+func TestVerifyIDTokenAlgorithms(t *testing.T) {
+	keys := []signingKey{
+		newRSAKey(t, "rs256", "RS256"),
+		newRSAKey(t, "rs512", "RS512"),
+		newRSAKey(t, "ps256", "PS256"),
+		newRSAKey(t, "ps384", "PS384"),
+		newECKey(t, "es256", "ES256", elliptic.P256()),
+		newECKey(t, "es384", "ES384", elliptic.P384()),
+		newECKey(t, "es512", "ES512", elliptic.P521()),
+	}
+	idp := newMockIdP(t)
+	idp.setKeys(keys...)
+	p := idp.provider(t)
+
+	for _, key := range keys {
+		t.Run(key.alg, func(t *testing.T) {
+			token := signToken(t, key, idp.claimsFor("nonce"))
+			if _, err := p.VerifyIDToken(context.Background(), token, "nonce"); err != nil {
+				t.Errorf("valid token rejected: %v", err)
+			}
+
+			// Flip a bit of the signature.
+			parts := strings.Split(token, ".")
+			signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			signature[len(signature)/2] ^= 1
+			tampered := parts[0] + "." + parts[1] + "." + base64.RawURLEncoding.EncodeToString(signature)
+			if _, err := p.VerifyIDToken(context.Background(), tampered, "nonce"); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("tampered signature: err = %v, want ErrInvalidToken", err)
+			}
+
+			// Swap the claims under the original signature.
+			claims := idp.claimsFor("nonce")
+			claims["sub"] = "admin"
+			claimsJSON, _ := json.Marshal(claims)
+			forged := parts[0] + "." + base64.RawURLEncoding.EncodeToString(claimsJSON) + "." + parts[2]
+			if _, err := p.VerifyIDToken(context.Background(), forged, "nonce"); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("tampered claims: err = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenRejectsAlgorithmConfusion(t *testing.T) {
+	rsaKey := newRSAKey(t, "rsa", "RS256")
+	idp := newMockIdP(t)
+	idp.setKeys(rsaKey)
+	p := idp.provider(t)
+
+	// An RSA key must not verify an ES token, and "none" is never accepted.
+	ecKey := newECKey(t, "rsa", "ES256", elliptic.P256())
+	if _, err := p.VerifyIDToken(context.Background(), signToken(t, ecKey, idp.claimsFor("nonce")), "nonce"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ES token with RSA key: err = %v, want ErrInvalidToken", err)
+	}
+
+	headerJSON, _ := json.Marshal(map[string]string{"alg": "none", "kid": "rsa"})
+	claimsJSON, _ := json.Marshal(idp.claimsFor("nonce"))
+	unsigned := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON) + "."
+	if _, err := p.VerifyIDToken(context.Background(), unsigned, "nonce"); err == nil {
+		t.Error("unsigned token accepted")
+	}
+}
+
+func TestVerifyIDTokenClaims(t *testing.T) {
+	key := newRSAKey(t, "rsa", "RS256")
+	idp := newMockIdP(t)
+	idp.setKeys(key)
+	p := idp.provider(t)
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		modify func(claims map[string]any)
+		nonce  string
+		ok     bool
+	}{
+		{"valid", func(map[string]any) {}, "nonce", true},
+		{"wrong nonce", func(map[string]any) {}, "other", false},
+		{"missing nonce", func(c map[string]any) { delete(c, "nonce") }, "nonce", false},
+		{"wrong issuer", func(c map[string]any) { c["iss"] = "https://evil.example.com" }, "nonce", false},
+		{"missing subject", func(c map[string]any) { delete(c, "sub") }, "nonce", false},
+		{"wrong audience", func(c map[string]any) { c["aud"] = "other-client" }, "nonce", false},
+		{"audience list", func(c map[string]any) { c["aud"] = []string{testClientID} }, "nonce", true},
+		{"audience list without client", func(c map[string]any) { c["aud"] = []string{"a", "b"} }, "nonce", false},
+		{"multiple audiences without azp", func(c map[string]any) { c["aud"] = []string{testClientID, "other"} }, "nonce", false},
+		{"multiple audiences with other azp", func(c map[string]any) {
+			c["aud"] = []string{testClientID, "other"}
+			c["azp"] = "other"
+		}, "nonce", false},
+		{"multiple audiences with azp", func(c map[string]any) {
+			c["aud"] = []string{testClientID, "other"}
+			c["azp"] = testClientID
+		}, "nonce", true},
+		{"expired", func(c map[string]any) { c["exp"] = now.Add(-2 * clockSkew).Unix() }, "nonce", false},
+		{"expired within skew", func(c map[string]any) { c["exp"] = now.Add(-clockSkew / 2).Unix() }, "nonce", true},
+		{"missing expiry", func(c map[string]any) { delete(c, "exp") }, "nonce", false},
+		{"issued in the future", func(c map[string]any) { c["iat"] = now.Add(2 * clockSkew).Unix() }, "nonce", false},
+		{"issued within skew", func(c map[string]any) { c["iat"] = now.Add(clockSkew / 2).Unix() }, "nonce", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims := idp.claimsFor("nonce")
+			test.modify(claims)
+			_, err := p.VerifyIDToken(context.Background(), signToken(t, key, claims), test.nonce)
+			if test.ok && err != nil {
+				t.Errorf("rejected: %v", err)
+			}
+			if !test.ok && !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("err = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestJWKSRefresh(t *testing.T) {
+	first := newRSAKey(t, "first", "RS256")
+	second := newECKey(t, "second", "ES256", elliptic.P256())
+	idp := newMockIdP(t)
+	idp.setKeys(first)
+	p := idp.provider(t)
+	ctx := context.Background()
+
+	if _, err := p.VerifyIDToken(ctx, signToken(t, first, idp.claimsFor("nonce")), "nonce"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.VerifyIDToken(ctx, signToken(t, first, idp.claimsFor("nonce")), "nonce"); err != nil {
+		t.Fatal(err)
+	}
+	if fetches := idp.jwksFetches(); fetches != 1 {
+		t.Fatalf("key set fetched %d times, want 1", fetches)
+	}
+
+	// The IdP rotates its keys. Within the refresh interval an unknown kid
+	// does not trigger another fetch.
+	idp.setKeys(first, second)
+	if _, err := p.VerifyIDToken(ctx, signToken(t, second, idp.claimsFor("nonce")), "nonce"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("err = %v, want ErrUnknownKey", err)
+	}
+	if fetches := idp.jwksFetches(); fetches != 1 {
+		t.Fatalf("key set fetched %d times within the refresh interval, want 1", fetches)
+	}
+
+	// Once the interval has passed the unknown kid refreshes the set.
+	p.mu.Lock()
+	p.keysFetched = time.Now().Add(-jwksRefreshInterval)
+	p.mu.Unlock()
+	if _, err := p.VerifyIDToken(ctx, signToken(t, second, idp.claimsFor("nonce")), "nonce"); err != nil {
+		t.Fatalf("rotated key rejected: %v", err)
+	}
+	if fetches := idp.jwksFetches(); fetches != 2 {
+		t.Fatalf("key set fetched %d times, want 2", fetches)
+	}
+
+	// A kid the IdP does not know stays unknown after the refresh.
+	p.mu.Lock()
+	p.keysFetched = time.Now().Add(-jwksRefreshInterval)
+	p.mu.Unlock()
+	unknown := newRSAKey(t, "unknown", "RS256")
+	if _, err := p.VerifyIDToken(ctx, signToken(t, unknown, idp.claimsFor("nonce")), "nonce"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("err = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestJWKSWithoutKid(t *testing.T) {
+	key := newRSAKey(t, "", "RS256")
+	idp := newMockIdP(t)
+	idp.setKeys(key)
+	p := idp.provider(t)
+
+	if _, err := p.VerifyIDToken(context.Background(), signToken(t, key, idp.claimsFor("nonce")), "nonce"); err != nil {
+		t.Errorf("token without kid rejected for a single key set: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/utils"
+	"gorm.io/gorm"
+)
+
+type APITokenRepository struct {
+	db *gorm.DB
+}
+
+func NewAPITokenRepository(db *gorm.DB) *APITokenRepository {
+	return &APITokenRepository{
+		db: db,
+	}
+}
+
+func (r *APITokenRepository) Create(token *models.APIToken) error {
+	return r.db.Create(token).Error
+}
+
+// GetActiveToken looks up a token that is neither revoked nor expired by its
+// raw value and preloads the owning user.
+func (r *APITokenRepository) GetActiveToken(token string) (*models.APIToken, error) {
+	var apiToken models.APIToken
+	err := r.db.Preload("User").
+		Where("token_hash = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", utils.HashToken(token), time.Now()).
+		First(&apiToken).Error
+	if err != nil {
+		return nil, err
+	}
+	return &apiToken, nil
+}
+
+func (r *APITokenRepository) GetUserTokens(userId uint) ([]models.APIToken, error) {
+	var tokens []models.APIToken
+	err := r.db.Model(&models.APIToken{}).Where("user_id = ?", userId).Order("created_at DESC").Find(&tokens).Error
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (r *APITokenRepository) TouchToken(token *models.APIToken) error {
+	now := time.Now()
+	token.LastUsedAt = &now
+	return r.db.Model(&models.APIToken{}).Where("id = ?", token.ID).Update("last_used_at", now).Error
+}
+
+// RevokeToken marks a token of userId as revoked and reports whether an
+// active token was found.
+func (r *APITokenRepository) RevokeToken(id, userId uint) (bool, error) {
+	result := r.db.Model(&models.APIToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userId).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// RevokeUserTokens revokes all active tokens of userId, e.g. after the
+// account was recovered through a password reset.
+func (r *APITokenRepository) RevokeUserTokens(userId uint) error {
+	return r.db.Model(&models.APIToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userId).
+		Update("revoked_at", time.Now()).Error
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"gorm.io/gorm"
+)
+
+type AttachmentRepository struct {
+	db *gorm.DB
+}
+
+func NewAttachmentRepository(db *gorm.DB) *AttachmentRepository {
+	return &AttachmentRepository{
+		db: db,
+	}
+}
+
+func (r *AttachmentRepository) CreateAttachment(attachment *models.Attachment) error {
+	return r.db.Create(attachment).Error
+}
+
+func (r *AttachmentRepository) GetAttachment(id uint) (*models.Attachment, error) {
+	var attachment models.Attachment
+	err := r.db.Preload("Uploader").First(&attachment, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// GetTaskAttachments returns the attachments of taskId, newest first.
+func (r *AttachmentRepository) GetTaskAttachments(taskId uint) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	err := r.db.Preload("Uploader").Where("task_id = ?", taskId).Order("created_at DESC, id DESC").Find(&attachments).Error
+	if err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// DeleteAttachment removes the row for good; the caller deletes the blob.
+func (r *AttachmentRepository) DeleteAttachment(id uint) error {
+	return r.db.Unscoped().Delete(&models.Attachment{}, id).Error
+}
@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CommentRepository struct {
+	db *gorm.DB
+}
+
+func NewCommentRepository(db *gorm.DB) *CommentRepository {
+	return &CommentRepository{
+		db: db,
+	}
+}
+
+// GetTaskComments returns the comments of taskId, oldest first.
+func (r *CommentRepository) GetTaskComments(taskId uint) ([]models.Comment, error) {
+	var comments []models.Comment
+	err := r.db.Preload("User").Where("task_id = ?", taskId).Order("created_at, id").Find(&comments).Error
+	if err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+func (r *CommentRepository) GetComment(id uint) (*models.Comment, error) {
+	var comment models.Comment
+	err := r.db.Preload("User").First(&comment, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// CreateComment saves comment and records a mention for each of
+// mentionedIds.
+func (r *CommentRepository) CreateComment(comment *models.Comment, mentionedIds []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(comment).Error; err != nil {
+			return err
+		}
+		return addMentions(tx, comment, comment.UserId, mentionedIds)
+	})
+}
+
+// UpdateComment saves the new body of comment, keeping previousBody as a
+// revision. Users mentioned for the first time are recorded; earlier
+// mentions stay.
+func (r *CommentRepository) UpdateComment(comment *models.Comment, previousBody string, editorId uint, mentionedIds []uint) error {
+	now := time.Now()
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		revision := models.CommentRevision{
+			CommentId: comment.ID,
+			Body:      previousBody,
+			EditedBy:  editorId,
+			EditedAt:  now,
+		}
+		if err := tx.Create(&revision).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&models.Comment{}).Where("id = ?", comment.ID).Updates(map[string]any{
+			"body":      comment.Body,
+			"body_html": comment.BodyHTML,
+			"edited_at": now,
+		}).Error
+		if err != nil {
+			return err
+		}
+		comment.EditedAt = &now
+		return addMentions(tx, comment, editorId, mentionedIds)
+	})
+}
+
+func addMentions(tx *gorm.DB, comment *models.Comment, mentionedBy uint, userIds []uint) error {
+	for _, userId := range userIds {
+		mention := models.Mention{
+			CommentId:   comment.ID,
+			UserId:      userId,
+			TaskId:      comment.TaskId,
+			MentionedBy: mentionedBy,
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&mention).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetRevisions returns the earlier bodies of commentId, newest first.
+func (r *CommentRepository) GetRevisions(commentId uint) ([]models.CommentRevision, error) {
+	var revisions []models.CommentRevision
+	err := r.db.Where("comment_id = ?", commentId).Order("edited_at DESC, id DESC").Find(&revisions).Error
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// DeleteComment deletes comment with its history and mentions. A comment
+// with replies is marked removed and cleared instead.
+func (r *CommentRepository) DeleteComment(comment *models.Comment) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("comment_id = ?", comment.ID).Delete(&models.Mention{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("comment_id = ?", comment.ID).Delete(&models.CommentRevision{}).Error; err != nil {
+			return err
+		}
+
+		var replies int64
+		if err := tx.Model(&models.Comment{}).Where("parent_id = ?", comment.ID).Count(&replies).Error; err != nil {
+			return err
+		}
+		if replies == 0 {
+			return tx.Delete(&models.Comment{}, comment.ID).Error
+		}
+		return tx.Model(&models.Comment{}).Where("id = ?", comment.ID).Updates(map[string]any{
+			"body":      "",
+			"body_html": "",
+			"removed":   true,
+		}).Error
+	})
+}
+
+// ResolveMentions returns the users among usernames that have a role in
+// projectId.
+func (r *CommentRepository) ResolveMentions(projectId uint, usernames []string) ([]models.User, error) {
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+	var users []models.User
+	err := r.db.Where("username IN ?", usernames).
+		Where("id IN (?)", projectUserIds(r.db, projectId)).
+		Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetUserMentions returns the mentions of userId in tasks of projects the
+// user can still access, newest first.
+func (r *CommentRepository) GetUserMentions(organizationId, userId uint, unreadOnly bool) ([]models.Mention, error) {
+	query := r.db.Preload("Comment.User").
+		Where("mentions.user_id = ?", userId).
+		Where("mentions.task_id IN (?)", organizationTasks(r.db, organizationId).Select("tasks.id").
+			Where("tasks.project_id IN (?)", accessibleProjectIds(r.db, userId)))
+	if unreadOnly {
+		query = query.Where("mentions.read_at IS NULL")
+	}
+
+	var mentions []models.Mention
+	err := query.Order("mentions.created_at DESC, mentions.id DESC").Find(&mentions).Error
+	if err != nil {
+		return nil, err
+	}
+	return mentions, nil
+}
+
+// MarkMentionsRead marks the unread mentions of userId among ids as read,
+// or all of them when ids is empty.
+func (r *CommentRepository) MarkMentionsRead(userId uint, ids []uint) error {
+	query := r.db.Model(&models.Mention{}).Where("user_id = ? AND read_at IS NULL", userId)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+	return query.Update("read_at", time.Now()).Error
+}
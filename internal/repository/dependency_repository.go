@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDependencyCycle  = errors.New("dependency would create a cycle")
+	ErrDependencyExists = errors.New("dependency already exists")
+	ErrDependencyTask   = errors.New("task not found in this organization")
+)
+
+// taskDependencyLock namespaces the advisory locks that serialize changes to
+// the dependency graph of an organization.
+const taskDependencyLock = 1901
+
+type DependencyRepository struct {
+	db *gorm.DB
+}
+
+func NewDependencyRepository(db *gorm.DB) *DependencyRepository {
+	return &DependencyRepository{
+		db: db,
+	}
+}
+
+func organizationTasks(db *gorm.DB, organizationId uint) *gorm.DB {
+	projects := db.Model(&models.Project{}).Select("id").Where("organization_id = ?", organizationId)
+	return db.Model(&models.Task{}).Where("tasks.project_id IN (?)", projects)
+}
+
+// AddDependency adds dependency if both of its tasks belong to
+// organizationId and the blocked task does not already block the blocker,
+// directly or through other tasks.
+func (r *DependencyRepository) AddDependency(organizationId uint, dependency *models.TaskDependency) error {
+	if dependency.BlockerId == dependency.BlockedId {
+		return ErrDependencyCycle
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", taskDependencyLock, int32(organizationId)).Error
+		if err != nil {
+			return err
+		}
+
+		var count int64
+		err = organizationTasks(tx, organizationId).
+			Where("tasks.id IN ?", []uint{dependency.BlockerId, dependency.BlockedId}).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count != 2 {
+			return ErrDependencyTask
+		}
+
+		err = tx.Model(&models.TaskDependency{}).
+			Where("blocker_id = ? AND blocked_id = ?", dependency.BlockerId, dependency.BlockedId).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrDependencyExists
+		}
+
+		// Follow the edges from the blocked task; reaching the blocker
+		// means the new edge would close a cycle.
+		var reachable bool
+		err = tx.Raw(`
+			WITH RECURSIVE reachable(id) AS (
+				SELECT CAST(? AS bigint)
+				UNION
+				SELECT d.blocked_id FROM task_dependencies d JOIN reachable ON d.blocker_id = reachable.id
+			)
+			SELECT EXISTS (SELECT 1 FROM reachable WHERE id = ?)`,
+			dependency.BlockedId, dependency.BlockerId).Scan(&reachable).Error
+		if err != nil {
+			return err
+		}
+		if reachable {
+			return ErrDependencyCycle
+		}
+
+		return tx.Create(dependency).Error
+	})
+}
+
+func (r *DependencyRepository) RemoveDependency(blockerId, blockedId uint) (bool, error) {
+	result := r.db.Where("blocker_id = ? AND blocked_id = ?", blockerId, blockedId).Delete(&models.TaskDependency{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// GetBlockers returns the tasks blocking taskId that userId can see.
+func (r *DependencyRepository) GetBlockers(organizationId, taskId, userId uint) ([]models.Task, error) {
+	ids := r.db.Model(&models.TaskDependency{}).Select("blocker_id").Where("blocked_id = ?", taskId)
+	return r.visibleTasks(organizationId, userId, ids)
+}
+
+// GetDependents returns the tasks blocked by taskId that userId can see.
+func (r *DependencyRepository) GetDependents(organizationId, taskId, userId uint) ([]models.Task, error) {
+	ids := r.db.Model(&models.TaskDependency{}).Select("blocked_id").Where("blocker_id = ?", taskId)
+	return r.visibleTasks(organizationId, userId, ids)
+}
+
+func (r *DependencyRepository) visibleTasks(organizationId, userId uint, ids *gorm.DB) ([]models.Task, error) {
+	var tasks []models.Task
+	err := organizationTasks(r.db, organizationId).
+		Where("tasks.id IN (?)", ids).
+		Where("tasks.project_id IN (?)", accessibleProjectIds(r.db, userId)).
+		Preload("Assignees.User").
+		Preload("Labels.Label").
+		Order("tasks.id").
+		Find(&tasks).Error
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// CountOpenBlockers counts the tasks blocking taskId that are not done,
+// including those in projects the caller cannot see.
+func (r *DependencyRepository) CountOpenBlockers(taskId uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Task{}).
+		Where("id IN (?)", r.db.Model(&models.TaskDependency{}).Select("blocker_id").Where("blocked_id = ?", taskId)).
+		Where("completed_at IS NULL").
+		Count(&count).Error
+	return count, err
+}
+
+// GetProjectDependencies returns the dependencies between tasks of
+// projectId.
+func (r *DependencyRepository) GetProjectDependencies(projectId uint) ([]models.TaskDependency, error) {
+	tasks := r.db.Model(&models.Task{}).Select("id").Where("project_id = ?", projectId)
+	var dependencies []models.TaskDependency
+	err := r.db.Where("blocker_id IN (?) AND blocked_id IN (?)", tasks, tasks).Find(&dependencies).Error
+	if err != nil {
+		return nil, err
+	}
+	return dependencies, nil
+}
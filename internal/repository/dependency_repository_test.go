@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aminasadiam/DevTasks/internal/database/dbtest"
+	"github.com/aminasadiam/DevTasks/internal/models"
+)
+
+// dependencyFixture scripts organization 4 with tasks 1 to 5 and the
+// dependencies 1 → 2 → 3 → 4. The reachability query follows these edges
+// the way the recursive query walks the table.
+func dependencyFixture(fake *dbtest.DB) {
+	edges := map[int64][]int64{1: {2}, 2: {3}, 3: {4}}
+	fake.On(`SELECT count(*) FROM "tasks"`, func(args []any) (*dbtest.Result, error) {
+		count := 0
+		for _, arg := range args[1:] {
+			if id, ok := arg.(int64); ok && id >= 1 && id <= 5 {
+				count++
+			}
+		}
+		return dbtest.Rows("count").Row(count), nil
+	})
+	fake.On(`SELECT count(*) FROM "task_dependencies"`, func(args []any) (*dbtest.Result, error) {
+		for _, blocked := range edges[args[0].(int64)] {
+			if blocked == args[1] {
+				return dbtest.Rows("count").Row(1), nil
+			}
+		}
+		return dbtest.Rows("count").Row(0), nil
+	})
+	fake.On(`WITH RECURSIVE reachable`, func(args []any) (*dbtest.Result, error) {
+		from, target := args[0].(int64), args[1].(int64)
+		seen := map[int64]bool{from: true}
+		for queue := []int64{from}; len(queue) > 0; queue = queue[1:] {
+			for _, next := range edges[queue[0]] {
+				if !seen[next] {
+					seen[next] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+		return dbtest.Rows("exists").Row(seen[target]), nil
+	})
+	fake.Return(`INSERT INTO "task_dependencies"`, dbtest.Rows("id").Row(9))
+}
+
+func TestAddDependency(t *testing.T) {
+	tests := []struct {
+		name             string
+		blocker, blocked uint
+		err              error
+		checked          bool
+	}{
+		{"new edge", 4, 5, nil, true},
+		{"shortcut along the chain", 1, 3, nil, true},
+		{"self edge", 2, 2, ErrDependencyCycle, false},
+		{"two-cycle", 2, 1, ErrDependencyCycle, true},
+		{"longer cycle", 4, 1, ErrDependencyCycle, true},
+		{"existing edge", 1, 2, ErrDependencyExists, false},
+		{"task of another organization", 1, 8, ErrDependencyTask, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, fake := dbtest.Open(t)
+			dependencyFixture(fake)
+
+			dependency := models.TaskDependency{BlockerId: test.blocker, BlockedId: test.blocked}
+			err := NewDependencyRepository(db).AddDependency(4, &dependency)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("AddDependency(%d → %d) = %v, want %v", test.blocker, test.blocked, err, test.err)
+			}
+
+			if checked := len(fake.Find(`WITH RECURSIVE reachable`)) == 1; checked != test.checked {
+				t.Errorf("reachability checked: %v, want %v", checked, test.checked)
+			}
+			inserts := fake.Find(`INSERT INTO "task_dependencies"`)
+			if test.err != nil {
+				if len(inserts) != 0 {
+					t.Errorf("rejected dependency was saved: %v", inserts)
+				}
+				return
+			}
+			if len(inserts) != 1 || dependency.ID != 9 {
+				t.Fatalf("inserts = %v, want one", inserts)
+			}
+			if locks := fake.Find(`pg_advisory_xact_lock`); len(locks) != 1 || !hasArg(locks[0], int64(4)) {
+				t.Errorf("dependency graph of organization 4 not locked: %v", locks)
+			}
+		})
+	}
+}
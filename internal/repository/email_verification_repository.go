@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/utils"
+	"gorm.io/gorm"
+)
+
+type EmailVerificationRepository struct {
+	db *gorm.DB
+}
+
+func NewEmailVerificationRepository(db *gorm.DB) *EmailVerificationRepository {
+	return &EmailVerificationRepository{
+		db: db,
+	}
+}
+
+func (r *EmailVerificationRepository) Create(token *models.EmailVerificationToken) error {
+	return r.db.Create(token).Error
+}
+
+// ConsumeToken marks an unused, unexpired token as used and returns it.
+func (r *EmailVerificationRepository) ConsumeToken(token string) (*models.EmailVerificationToken, error) {
+	var verificationToken models.EmailVerificationToken
+	err := r.db.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", utils.HashToken(token), time.Now()).
+		First(&verificationToken).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := r.db.Model(&models.EmailVerificationToken{}).
+		Where("id = ? AND used_at IS NULL", verificationToken.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &verificationToken, nil
+}
+
+// LastSentAt returns when the most recent token of userId was issued.
+func (r *EmailVerificationRepository) LastSentAt(userId uint) (time.Time, bool) {
+	var token models.EmailVerificationToken
+	err := r.db.Unscoped().Where("user_id = ?", userId).Order("created_at DESC").First(&token).Error
+	if err != nil {
+		return time.Time{}, false
+	}
+	return token.CreatedAt, true
+}
+
+// DeleteUserTokens removes the unused tokens of userId. Used tokens are kept
+// so that LastSentAt keeps working for the resend throttle.
+func (r *EmailVerificationRepository) DeleteUserTokens(userId uint) error {
+	return r.db.Where("user_id = ? AND used_at IS NULL", userId).Delete(&models.EmailVerificationToken{}).Error
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/utils"
+	"gorm.io/gorm"
+)
+
+var ErrInvitationUnavailable = errors.New("invitation is expired, revoked or used up")
+
+type InvitationRepository struct {
+	db *gorm.DB
+}
+
+func NewInvitationRepository(db *gorm.DB) *InvitationRepository {
+	return &InvitationRepository{
+		db: db,
+	}
+}
+
+func (r *InvitationRepository) Create(invitation *models.ProjectInvitation) error {
+	return r.db.Create(invitation).Error
+}
+
+func (r *InvitationRepository) GetPendingInvitations(projectId uint) ([]models.ProjectInvitation, error) {
+	var invitations []models.ProjectInvitation
+	err := r.db.Where("project_id = ? AND revoked_at IS NULL AND expires_at > ? AND uses < max_uses", projectId, time.Now()).
+		Order("created_at DESC").
+		Find(&invitations).Error
+	if err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+// GetInvitationByToken looks up an invitation by its raw token and preloads
+// the project.
+func (r *InvitationRepository) GetInvitationByToken(token string) (*models.ProjectInvitation, error) {
+	var invitation models.ProjectInvitation
+	err := r.db.Preload("Project").Where("token_hash = ?", utils.HashToken(token)).First(&invitation).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *InvitationRepository) RevokeInvitation(id, projectId uint) (bool, error) {
+	result := r.db.Model(&models.ProjectInvitation{}).
+		Where("id = ? AND project_id = ? AND revoked_at IS NULL", id, projectId).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// AcceptInvitation uses up one use of invitation and adds userId to the
+// project, and to its organization if needed, in the same transaction. The use counter is incremented
+// conditionally so that a link cannot be accepted more than MaxUses times.
+func (r *InvitationRepository) AcceptInvitation(invitation *models.ProjectInvitation, userId uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.ProjectInvitation{}).
+			Where("id = ? AND revoked_at IS NULL AND expires_at > ? AND uses < max_uses", invitation.ID, time.Now()).
+			Update("uses", gorm.Expr("uses + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvitationUnavailable
+		}
+
+		member := models.ProjectMember{
+			ProjectId: invitation.ProjectId,
+			UserId:    userId,
+			Role:      invitation.Role,
+		}
+		if err := tx.Create(&member).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		err := tx.Model(&models.OrganizationMember{}).
+			Where("organization_id = ? AND user_id = ?", invitation.Project.OrganizationId, userId).
+			Count(&count).Error
+		if err != nil || count > 0 {
+			return err
+		}
+		orgMember := models.OrganizationMember{
+			OrganizationId: invitation.Project.OrganizationId,
+			UserId:         userId,
+			Role:           models.OrgRoleMember,
+		}
+		return tx.Create(&orgMember).Error
+	})
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LabelRepository struct {
+	db *gorm.DB
+}
+
+func NewLabelRepository(db *gorm.DB) *LabelRepository {
+	return &LabelRepository{
+		db: db,
+	}
+}
+
+func (r *LabelRepository) CreateLabel(label *models.Label) error {
+	return r.db.Create(label).Error
+}
+
+// GetLabel returns label id if it belongs to organizationId.
+func (r *LabelRepository) GetLabel(organizationId, id uint) (*models.Label, error) {
+	var label models.Label
+	err := r.db.Where("organization_id = ?", organizationId).First(&label, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &label, nil
+}
+
+// GetLabels returns the labels of the whole organization and, when
+// projectId is not 0, those of that project.
+func (r *LabelRepository) GetLabels(organizationId, projectId uint) ([]models.Label, error) {
+	var labels []models.Label
+	err := r.db.Where("organization_id = ? AND (project_id IS NULL OR project_id = ?)", organizationId, projectId).
+		Order("LOWER(name), id").
+		Find(&labels).Error
+	if err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// ExistLabelName reports whether name, ignoring case, is taken. Labels of
+// the whole organization need a name no other label of the organization
+// has; project labels must not clash with those or with labels of the same
+// project.
+func (r *LabelRepository) ExistLabelName(organizationId uint, projectId *uint, name string, exceptId uint) bool {
+	query := r.db.Model(&models.Label{}).
+		Where("organization_id = ? AND LOWER(name) = LOWER(?) AND id <> ?", organizationId, name, exceptId)
+	if projectId != nil {
+		query = query.Where("project_id IS NULL OR project_id = ?", *projectId)
+	}
+	var count int64
+	query.Count(&count)
+	return count > 0
+}
+
+func (r *LabelRepository) UpdateLabel(label *models.Label) error {
+	return r.db.Save(label).Error
+}
+
+// DeleteLabel removes a label from all tasks and deletes it.
+func (r *LabelRepository) DeleteLabel(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("label_id = ?", id).Delete(&models.TaskLabel{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&models.Label{}, id).Error
+	})
+}
+
+// MergeLabels puts target on every task labeled source and deletes source.
+// It returns the number of tasks that got target.
+func (r *LabelRepository) MergeLabels(source, target *models.Label) (int64, error) {
+	var merged int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec(`
+			INSERT INTO task_labels (task_id, label_id, added_by, added_at)
+			SELECT task_id, ?, added_by, added_at FROM task_labels WHERE label_id = ?
+			ON CONFLICT DO NOTHING`, target.ID, source.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		merged = result.RowsAffected
+		if err := tx.Where("label_id = ?", source.ID).Delete(&models.TaskLabel{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&models.Label{}, source.ID).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return merged, nil
+}
+
+// AddTaskLabel puts labelId on taskId. It reports false when the task
+// already had the label.
+func (r *LabelRepository) AddTaskLabel(taskId, labelId, addedBy uint) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.TaskLabel{
+		TaskId:  taskId,
+		LabelId: labelId,
+		AddedBy: addedBy,
+		AddedAt: time.Now(),
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// RemoveTaskLabel takes labelId off taskId. It reports false when the task
+// did not have the label.
+func (r *LabelRepository) RemoveTaskLabel(taskId, labelId uint) (bool, error) {
+	result := r.db.Where("task_id = ? AND label_id = ?", taskId, labelId).Delete(&models.TaskLabel{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aminasadiam/DevTasks/internal/database/dbtest"
+	"github.com/aminasadiam/DevTasks/internal/models"
+)
+
+func TestMergeLabels(t *testing.T) {
+	source, target := models.Label{OrganizationId: 4, Name: "defect"}, models.Label{OrganizationId: 4, Name: "bug"}
+	source.ID, target.ID = 5, 6
+
+	t.Run("moves the tasks and deletes the source", func(t *testing.T) {
+		db, fake := dbtest.Open(t)
+		fake.Return(`INSERT INTO task_labels`, dbtest.Affected(2))
+		fake.Return(`DELETE FROM "task_labels"`, dbtest.Affected(3))
+		fake.Return(`DELETE FROM "labels"`, dbtest.Affected(1))
+
+		merged, err := NewLabelRepository(db).MergeLabels(&source, &target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// One of the three tasks already had the target.
+		if merged != 2 {
+			t.Errorf("merged = %d, want 2", merged)
+		}
+
+		var steps []string
+		for _, statement := range fake.Statements() {
+			steps = append(steps, strings.Fields(statement.Query)[0])
+		}
+		if got := strings.Join(steps, " "); got != "BEGIN INSERT DELETE DELETE COMMIT" {
+			t.Errorf("statements = %s, want the copy and both deletes in one transaction", got)
+		}
+		copies := fake.Find(`INSERT INTO task_labels`)
+		if len(copies) != 1 || copies[0].Args[0] != int64(6) || copies[0].Args[1] != int64(5) || !strings.Contains(copies[0].Query, "ON CONFLICT DO NOTHING") {
+			t.Errorf("copy = %v, want the tasks of label 5 to get label 6 once", copies)
+		}
+		for _, fragment := range []string{`DELETE FROM "task_labels"`, `DELETE FROM "labels"`} {
+			if deletes := fake.Find(fragment); len(deletes) != 1 || !hasArg(deletes[0], int64(5)) || hasArg(deletes[0], int64(6)) {
+				t.Errorf("%s = %v, want only label 5 deleted", fragment, deletes)
+			}
+		}
+	})
+
+	t.Run("keeps the source when copying fails", func(t *testing.T) {
+		db, fake := dbtest.Open(t)
+		failure := errors.New("connection lost")
+		fake.Fail(`INSERT INTO task_labels`, failure)
+
+		if _, err := NewLabelRepository(db).MergeLabels(&source, &target); !errors.Is(err, failure) {
+			t.Fatalf("err = %v, want %v", err, failure)
+		}
+		if deletes := fake.Find(`DELETE`); len(deletes) != 0 {
+			t.Errorf("deleted after the copy failed: %v", deletes)
+		}
+		if rollbacks := fake.Find(`ROLLBACK`); len(rollbacks) != 1 {
+			t.Errorf("statements = %v, want a rollback", fake.Statements())
+		}
+	})
+}
+
+func TestExistLabelName(t *testing.T) {
+	project := uint(7)
+	tests := []struct {
+		name      string
+		projectId *uint
+		// projectClause is whether the names are only compared with
+		// organization labels and those of the project.
+		projectClause bool
+	}{
+		{"organization label", nil, false},
+		{"project label", &project, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, fake := dbtest.Open(t)
+			fake.Return(`SELECT count(*) FROM "labels"`, dbtest.Rows("count").Row(1))
+
+			if !NewLabelRepository(db).ExistLabelName(4, test.projectId, "Bug", 9) {
+				t.Error("name is free, want it taken")
+			}
+			counts := fake.Find(`SELECT count(*) FROM "labels"`)
+			if len(counts) != 1 {
+				t.Fatalf("counts = %v, want one", fake.Statements())
+			}
+			count := counts[0]
+			if !strings.Contains(count.Query, "LOWER(name) = LOWER(") || !hasArg(count, int64(4)) || !hasArg(count, "Bug") || !hasArg(count, int64(9)) {
+				t.Errorf("count = %v, want a case-insensitive match in organization 4 other than label 9", count)
+			}
+			if strings.Contains(count.Query, "project_id IS NULL OR project_id =") != test.projectClause || hasArg(count, int64(7)) != test.projectClause {
+				t.Errorf("count = %v, want project clause %v", count, test.projectClause)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/throttle"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginAttemptRepository is a throttle.Store backed by the database, so that
+// lockouts are shared between server instances.
+type LoginAttemptRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginAttemptRepository(db *gorm.DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{
+		db: db,
+	}
+}
+
+func (r *LoginAttemptRepository) Get(key string) (throttle.Attempt, error) {
+	var attempt models.LoginAttempt
+	err := r.db.Where("key = ?", key).First(&attempt).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return throttle.Attempt{}, nil
+	}
+	if err != nil {
+		return throttle.Attempt{}, err
+	}
+	return toThrottleAttempt(attempt), nil
+}
+
+// RecordFailure locks the row of key for the duration of the update so that
+// concurrent failures from several instances are all counted.
+func (r *LoginAttemptRepository) RecordFailure(key string, update func(throttle.Attempt) throttle.Attempt) (throttle.Attempt, error) {
+	var result throttle.Attempt
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		row := models.LoginAttempt{Key: key}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&row).Error; err != nil {
+			return err
+		}
+
+		var attempt models.LoginAttempt
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&attempt).Error; err != nil {
+			return err
+		}
+
+		result = update(toThrottleAttempt(attempt))
+		return tx.Model(&models.LoginAttempt{}).Where("id = ?", attempt.ID).Updates(map[string]any{
+			"failures":     result.Failures,
+			"last_failure": result.LastFailure,
+			"locked_until": result.LockedUntil,
+		}).Error
+	})
+	return result, err
+}
+
+func (r *LoginAttemptRepository) Reset(key string) error {
+	return r.db.Unscoped().Where("key = ?", key).Delete(&models.LoginAttempt{}).Error
+}
+
+func toThrottleAttempt(attempt models.LoginAttempt) throttle.Attempt {
+	return throttle.Attempt{
+		Failures:    attempt.Failures,
+		LastFailure: attempt.LastFailure,
+		LockedUntil: attempt.LockedUntil,
+	}
+}
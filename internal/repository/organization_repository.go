@@ -0,0 +1,265 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"gorm.io/gorm"
+)
+
+type OrganizationRepository struct {
+	db *gorm.DB
+}
+
+func NewOrganizationRepository(db *gorm.DB) *OrganizationRepository {
+	return &OrganizationRepository{
+		db: db,
+	}
+}
+
+// CreateOrganization creates organization with a unique slug and makes
+// ownerId its owner.
+func (r *OrganizationRepository) CreateOrganization(organization *models.Organization, ownerId uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return createOrganization(tx, organization, ownerId)
+	})
+}
+
+// CreatePersonalOrganization creates the workspace every user starts with.
+func (r *OrganizationRepository) CreatePersonalOrganization(user *models.User) (*models.Organization, error) {
+	organization := models.Organization{
+		Name:     user.Username + "'s workspace",
+		Slug:     user.Username,
+		Personal: true,
+	}
+	if err := r.CreateOrganization(&organization, user.ID); err != nil {
+		return nil, err
+	}
+	return &organization, nil
+}
+
+func createOrganization(tx *gorm.DB, organization *models.Organization, ownerId uint) error {
+	slug, err := uniqueSlug(tx, organization.Slug)
+	if err != nil {
+		return err
+	}
+	organization.Slug = slug
+	if err := tx.Create(organization).Error; err != nil {
+		return err
+	}
+	owner := models.OrganizationMember{
+		OrganizationId: organization.ID,
+		UserId:         ownerId,
+		Role:           models.OrgRoleOwner,
+	}
+	return tx.Create(&owner).Error
+}
+
+// uniqueSlug turns base into a URL friendly slug and appends a number when
+// the slug is already taken, including by deleted organizations.
+func uniqueSlug(tx *gorm.DB, base string) (string, error) {
+	var b strings.Builder
+	for _, c := range strings.ToLower(base) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+			b.WriteRune(c)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "-"):
+			b.WriteByte('-')
+		}
+	}
+	slug := strings.TrimSuffix(b.String(), "-")
+	if slug == "" {
+		slug = "organization"
+	}
+
+	candidate := slug
+	for i := 2; ; i++ {
+		var count int64
+		if err := tx.Unscoped().Model(&models.Organization{}).Where("slug = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s-%d", slug, i)
+	}
+}
+
+func (r *OrganizationRepository) GetOrganizationById(id uint) (*models.Organization, error) {
+	var organization models.Organization
+	err := r.db.First(&organization, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &organization, nil
+}
+
+// GetUserMemberships returns the memberships of userId with their
+// organizations preloaded, oldest first.
+func (r *OrganizationRepository) GetUserMemberships(userId uint) ([]models.OrganizationMember, error) {
+	var members []models.OrganizationMember
+	err := r.db.Preload("Organization").
+		Joins("JOIN organizations ON organizations.id = organization_members.organization_id AND organizations.deleted_at IS NULL").
+		Where("organization_members.user_id = ?", userId).
+		Order("organization_members.id").
+		Find(&members).Error
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// GetMembership returns the membership of userId in organizationId with the
+// organization preloaded, or nil when the user is not a member.
+func (r *OrganizationRepository) GetMembership(organizationId, userId uint) (*models.OrganizationMember, error) {
+	var member models.OrganizationMember
+	err := r.db.Preload("Organization").
+		Joins("JOIN organizations ON organizations.id = organization_members.organization_id AND organizations.deleted_at IS NULL").
+		Where("organization_members.organization_id = ? AND organization_members.user_id = ?", organizationId, userId).
+		First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// GetDefaultMembership picks the organization a new session starts in: the
+// personal workspace if the user still has one, otherwise the oldest
+// membership. It returns nil for users without any organization.
+func (r *OrganizationRepository) GetDefaultMembership(userId uint) (*models.OrganizationMember, error) {
+	members, err := r.GetUserMemberships(userId)
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+	for i := range members {
+		if members[i].Organization.Personal {
+			return &members[i], nil
+		}
+	}
+	return &members[0], nil
+}
+
+func (r *OrganizationRepository) UpdateOrganization(organization *models.Organization) error {
+	return r.db.Save(organization).Error
+}
+
+// DeleteOrganization deletes an organization together with its projects,
+// teams and memberships.
+func (r *OrganizationRepository) DeleteOrganization(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("organization_id = ?", id).Delete(&models.Project{}).Error; err != nil {
+			return err
+		}
+		teamIds := tx.Model(&models.Team{}).Select("id").Where("organization_id = ?", id)
+		if err := tx.Unscoped().Where("team_id IN (?)", teamIds).Delete(&models.ProjectTeamGrant{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("team_id IN (?)", teamIds).Delete(&models.TeamMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("organization_id = ?", id).Delete(&models.Team{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("organization_id = ?", id).Delete(&models.OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Organization{}, id).Error
+	})
+}
+
+func (r *OrganizationRepository) GetMembers(organizationId uint) ([]models.OrganizationMember, error) {
+	var members []models.OrganizationMember
+	err := r.db.Preload("User").Where("organization_id = ?", organizationId).Order("id").Find(&members).Error
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r *OrganizationRepository) AddMember(member *models.OrganizationMember) error {
+	return r.db.Create(member).Error
+}
+
+func (r *OrganizationRepository) UpdateMemberRole(organizationId, userId uint, role string) error {
+	return r.db.Model(&models.OrganizationMember{}).
+		Where("organization_id = ? AND user_id = ?", organizationId, userId).
+		Update("role", role).Error
+}
+
+// RemoveMember removes userId from the organization and from every project
+// and team of it, so that nothing of the organization stays reachable.
+func (r *OrganizationRepository) RemoveMember(organizationId, userId uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		projectIds := tx.Model(&models.Project{}).Select("id").Where("organization_id = ?", organizationId)
+		if err := tx.Unscoped().Where("user_id = ? AND project_id IN (?)", userId, projectIds).Delete(&models.ProjectMember{}).Error; err != nil {
+			return err
+		}
+		teamIds := tx.Model(&models.Team{}).Select("id").Where("organization_id = ?", organizationId)
+		if err := tx.Unscoped().Where("user_id = ? AND team_id IN (?)", userId, teamIds).Delete(&models.TeamMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("organization_id = ? AND user_id = ?", organizationId, userId).Delete(&models.OrganizationMember{}).Error
+	})
+}
+
+func (r *OrganizationRepository) CountOwners(organizationId uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.OrganizationMember{}).
+		Where("organization_id = ? AND role = ?", organizationId, models.OrgRoleOwner).
+		Count(&count).Error
+	return count, err
+}
+
+// Backfill moves data created before organizations existed into them: every
+// user without an organization gets a personal one, projects without an
+// organization move to their creator's personal organization, their
+// members join that organization and API tokens are pinned to it.
+func (r *OrganizationRepository) Backfill() error {
+	var users []models.User
+	err := r.db.Where("NOT EXISTS (SELECT 1 FROM organization_members m WHERE m.user_id = users.id)").Find(&users).Error
+	if err != nil {
+		return err
+	}
+	for i := range users {
+		if _, err := r.CreatePersonalOrganization(&users[i]); err != nil {
+			return err
+		}
+	}
+
+	personalOrganization := `
+		SELECT o.id FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id
+		WHERE m.user_id = %s AND o.personal AND o.deleted_at IS NULL
+		ORDER BY o.id LIMIT 1`
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`UPDATE projects SET organization_id = (` + fmt.Sprintf(personalOrganization, "projects.user_id") + `)
+			WHERE organization_id IS NULL OR organization_id = 0`).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Exec(`
+			INSERT INTO organization_members (created_at, updated_at, organization_id, user_id, role)
+			SELECT DISTINCT NOW(), NOW(), p.organization_id, pm.user_id, ?
+			FROM project_members pm
+			JOIN projects p ON p.id = pm.project_id
+			WHERE p.organization_id IS NOT NULL
+			  AND NOT EXISTS (SELECT 1 FROM organization_members m WHERE m.organization_id = p.organization_id AND m.user_id = pm.user_id)`,
+			models.OrgRoleMember).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Exec(`UPDATE api_tokens SET organization_id = (SELECT p.organization_id FROM projects p WHERE p.id = api_tokens.project_id)
+			WHERE (organization_id IS NULL OR organization_id = 0) AND project_id IS NOT NULL`).Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec(`UPDATE api_tokens SET organization_id = (` + fmt.Sprintf(personalOrganization, "api_tokens.user_id") + `)
+			WHERE organization_id IS NULL OR organization_id = 0`).Error
+	})
+}
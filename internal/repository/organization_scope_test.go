@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aminasadiam/DevTasks/internal/database/dbtest"
+	"github.com/aminasadiam/DevTasks/internal/models"
+)
+
+// TestOrganizationScope checks that every query of the scoped repositories
+// is limited to the organization, and that nothing runs without one.
+func TestOrganizationScope(t *testing.T) {
+	task := models.Task{ProjectId: 7}
+	task.ID = 3
+	calls := []struct {
+		name string
+		call func(projects *ProjectRepository, tasks *TaskRepository) error
+	}{
+		{"GetProjectById", func(p *ProjectRepository, _ *TaskRepository) error { _, err := p.GetProjectById(7); return err }},
+		{"HasProject", func(p *ProjectRepository, _ *TaskRepository) error { _, err := p.HasProject(7); return err }},
+		{"GetUserProjects", func(p *ProjectRepository, _ *TaskRepository) error { _, err := p.GetUserProjects(1); return err }},
+		{"DeleteProject", func(p *ProjectRepository, _ *TaskRepository) error { return p.DeleteProject(7) }},
+		{"GetTaskById", func(_ *ProjectRepository, r *TaskRepository) error { _, err := r.GetTaskById(3); return err }},
+		{"GetProjectTasks", func(_ *ProjectRepository, r *TaskRepository) error {
+			_, err := r.GetProjectTasks(7, TaskFilter{})
+			return err
+		}},
+		{"GetSubtree", func(_ *ProjectRepository, r *TaskRepository) error { _, err := r.GetSubtree(3); return err }},
+		{"CountSubtasks", func(_ *ProjectRepository, r *TaskRepository) error { _, err := r.CountSubtasks(3); return err }},
+		{"SaveTaskChanges", func(_ *ProjectRepository, r *TaskRepository) error { return r.SaveTaskChanges(&task, TaskUpdate{}) }},
+		{"DeleteTask", func(_ *ProjectRepository, r *TaskRepository) error { return r.DeleteTask(3) }},
+	}
+	for _, c := range calls {
+		t.Run(c.name, func(t *testing.T) {
+			db, fake := dbtest.Open(t)
+			projects, tasks := NewProjectRepository(db), NewTaskRepository(db)
+
+			if err := c.call(projects, tasks); !errors.Is(err, ErrNoOrganization) {
+				t.Errorf("without an organization: err = %v, want ErrNoOrganization", err)
+			}
+			if statements := fake.Statements(); len(statements) != 0 {
+				t.Fatalf("queries ran without an organization: %v", statements)
+			}
+
+			c.call(projects.ForOrganization(4), tasks.ForOrganization(4))
+			scoped := 0
+			for _, statement := range fake.Statements() {
+				if !touchesScopedRows(statement.Query) {
+					continue
+				}
+				if !strings.Contains(statement.Query, "organization_id = $") || !hasArg(statement, int64(4)) {
+					t.Errorf("query is not scoped to organization 4: %s %v", statement.Query, statement.Args)
+				}
+				scoped++
+			}
+			if scoped == 0 {
+				t.Errorf("no project or task rows were queried: %v", fake.Statements())
+			}
+		})
+	}
+}
+
+// touchesScopedRows reports whether query reads or changes rows of the
+// projects or tasks tables.
+func touchesScopedRows(query string) bool {
+	for _, table := range []string{`FROM "projects"`, `FROM "tasks"`, `UPDATE "projects"`, `UPDATE "tasks"`} {
+		if strings.HasPrefix(query, "SELECT") && strings.Contains(query, table) || strings.HasPrefix(query, table) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasArg(statement dbtest.Statement, value any) bool {
+	for _, arg := range statement.Args {
+		if arg == value {
+			return true
+		}
+	}
+	return false
+}
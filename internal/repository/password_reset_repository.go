@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/utils"
+	"gorm.io/gorm"
+)
+
+type PasswordResetRepository struct {
+	db *gorm.DB
+}
+
+func NewPasswordResetRepository(db *gorm.DB) *PasswordResetRepository {
+	return &PasswordResetRepository{
+		db: db,
+	}
+}
+
+func (r *PasswordResetRepository) Create(token *models.PasswordResetToken) error {
+	return r.db.Create(token).Error
+}
+
+// ConsumeToken marks an unused, unexpired token as used and returns it. The
+// update is conditional so that a token can only ever be consumed once.
+func (r *PasswordResetRepository) ConsumeToken(token string) (*models.PasswordResetToken, error) {
+	var resetToken models.PasswordResetToken
+	err := r.db.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", utils.HashToken(token), time.Now()).
+		First(&resetToken).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := r.db.Model(&models.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", resetToken.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &resetToken, nil
+}
+
+func (r *PasswordResetRepository) DeleteUserTokens(userId uint) error {
+	return r.db.Unscoped().Where("user_id = ?", userId).Delete(&models.PasswordResetToken{}).Error
+}
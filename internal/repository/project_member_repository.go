@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"gorm.io/gorm"
+)
+
+type ProjectMemberRepository struct {
+	db *gorm.DB
+}
+
+func NewProjectMemberRepository(db *gorm.DB) *ProjectMemberRepository {
+	return &ProjectMemberRepository{
+		db: db,
+	}
+}
+
+func (r *ProjectMemberRepository) AddMember(member *models.ProjectMember) error {
+	return r.db.Create(member).Error
+}
+
+// GetMemberRole returns the role of userId in projectId, or an empty string
+// when the user is not a member.
+func (r *ProjectMemberRepository) GetMemberRole(projectId, userId uint) (string, error) {
+	var member models.ProjectMember
+	err := r.db.Joins("JOIN projects ON projects.id = project_members.project_id AND projects.deleted_at IS NULL").
+		Where("project_members.project_id = ? AND project_members.user_id = ?", projectId, userId).
+		First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return member.Role, nil
+}
+
+// GetAccessGrants lists everything that gives userId a role in projectId:
+// the direct membership, if any, followed by the grants of the user's teams.
+func (r *ProjectMemberRepository) GetAccessGrants(projectId, userId uint) ([]models.AccessGrant, error) {
+	var grants []models.AccessGrant
+
+	role, err := r.GetMemberRole(projectId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if role != "" {
+		grants = append(grants, models.AccessGrant{Source: models.AccessSourceDirect, Role: role})
+	}
+
+	var teamGrants []models.AccessGrant
+	err = r.db.Model(&models.ProjectTeamGrant{}).
+		Select("? AS source, project_team_grants.role, teams.id AS team_id, teams.name AS team_name", models.AccessSourceTeam).
+		Joins("JOIN teams ON teams.id = project_team_grants.team_id AND teams.deleted_at IS NULL").
+		Joins("JOIN team_members ON team_members.team_id = teams.id").
+		Joins("JOIN projects ON projects.id = project_team_grants.project_id AND projects.deleted_at IS NULL").
+		Where("project_team_grants.project_id = ? AND team_members.user_id = ?", projectId, userId).
+		Order("teams.name").
+		Scan(&teamGrants).Error
+	if err != nil {
+		return nil, err
+	}
+	return append(grants, teamGrants...), nil
+}
+
+// GetEffectiveRole returns the highest role userId has in projectId either
+// directly or through a team, or an empty string when it has none.
+func (r *ProjectMemberRepository) GetEffectiveRole(projectId, userId uint) (string, error) {
+	grants, err := r.GetAccessGrants(projectId, userId)
+	if err != nil {
+		return "", err
+	}
+	return models.EffectiveProjectRole(grants), nil
+}
+
+func (r *ProjectMemberRepository) GetProjectMembers(projectId uint) ([]models.ProjectMember, error) {
+	var members []models.ProjectMember
+	err := r.db.Preload("User").Where("project_id = ?", projectId).Order("id").Find(&members).Error
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r *ProjectMemberRepository) UpdateMemberRole(projectId, userId uint, role string) error {
+	return r.db.Model(&models.ProjectMember{}).
+		Where("project_id = ? AND user_id = ?", projectId, userId).
+		Update("role", role).Error
+}
+
+func (r *ProjectMemberRepository) RemoveMember(projectId, userId uint) error {
+	return r.db.Unscoped().Where("project_id = ? AND user_id = ?", projectId, userId).Delete(&models.ProjectMember{}).Error
+}
+
+func (r *ProjectMemberRepository) CountOwners(projectId uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.ProjectMember{}).
+		Where("project_id = ? AND role = ?", projectId, models.ProjectRoleOwner).
+		Count(&count).Error
+	return count, err
+}
+
+// BackfillOwners makes the creator of every project without a membership
+// row its owner. Projects created before memberships existed need this.
+func (r *ProjectMemberRepository) BackfillOwners() error {
+	return r.db.Exec(`
+		INSERT INTO project_members (created_at, updated_at, project_id, user_id, role)
+		SELECT NOW(), NOW(), p.id, p.user_id, ?
+		FROM projects p
+		WHERE p.deleted_at IS NULL
+		  AND NOT EXISTS (SELECT 1 FROM project_members m WHERE m.project_id = p.id AND m.user_id = p.user_id)`,
+		models.ProjectRoleOwner).Error
+}
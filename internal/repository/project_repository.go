@@ -1,12 +1,22 @@
 package repository
 
 import (
+	"errors"
+
 	"github.com/aminasadiam/DevTasks/internal/models"
 	"gorm.io/gorm"
 )
 
+// ErrNoOrganization is returned by organization scoped repositories that
+// were not given an organization.
+var ErrNoOrganization = errors.New("no organization selected")
+
+// ProjectRepository only ever sees the projects of one organization. The
+// repository created by NewProjectRepository has no organization and every
+// query fails until it is narrowed with ForOrganization.
 type ProjectRepository struct {
-	db *gorm.DB
+	db             *gorm.DB
+	organizationId uint
 }
 
 func NewProjectRepository(db *gorm.DB) *ProjectRepository {
@@ -15,48 +25,129 @@ func NewProjectRepository(db *gorm.DB) *ProjectRepository {
 	}
 }
 
+// ForOrganization returns a copy of the repository scoped to organizationId.
+func (r *ProjectRepository) ForOrganization(organizationId uint) *ProjectRepository {
+	return &ProjectRepository{
+		db:             r.db,
+		organizationId: organizationId,
+	}
+}
+
+func (r *ProjectRepository) scoped() (*gorm.DB, error) {
+	if r.organizationId == 0 {
+		return nil, ErrNoOrganization
+	}
+	return r.db.Where("projects.organization_id = ?", r.organizationId), nil
+}
+
 func (r *ProjectRepository) Create(project *models.Project) error {
+	if r.organizationId == 0 {
+		return ErrNoOrganization
+	}
+	project.OrganizationId = r.organizationId
 	return r.db.Create(project).Error
 }
 
+// GetUserProjects returns the projects userId is a member of, directly or
+// through a team.
 func (r *ProjectRepository) GetUserProjects(userId uint) ([]models.Project, error) {
+	db, err := r.scoped()
+	if err != nil {
+		return nil, err
+	}
 	var projects []models.Project
-	err := r.db.Model(&models.Project{}).Where("user_id = ?", userId).Find(&projects).Error
+	err = db.Model(&models.Project{}).
+		Where("projects.id IN (?)", accessibleProjectIds(r.db, userId)).
+		Find(&projects).Error
 	if err != nil {
 		return nil, err
 	}
 	return projects, nil
 }
 
+// accessibleProjectIds selects the IDs of the projects userId has a role in,
+// directly or through a team, across all organizations. Callers scope it.
+func accessibleProjectIds(db *gorm.DB, userId uint) *gorm.DB {
+	direct := db.Model(&models.ProjectMember{}).Select("project_id").Where("user_id = ?", userId)
+	team := db.Model(&models.ProjectTeamGrant{}).
+		Select("project_team_grants.project_id").
+		Joins("JOIN team_members ON team_members.team_id = project_team_grants.team_id").
+		Where("team_members.user_id = ?", userId)
+	return db.Model(&models.Project{}).Select("projects.id").Where("projects.id IN (?) OR projects.id IN (?)", direct, team)
+}
+
+// projectUserIds selects the IDs of the users with a role in projectId,
+// directly or through a team.
+func projectUserIds(db *gorm.DB, projectId uint) *gorm.DB {
+	direct := db.Model(&models.ProjectMember{}).Select("user_id").Where("project_id = ?", projectId)
+	team := db.Model(&models.TeamMember{}).
+		Select("team_members.user_id").
+		Joins("JOIN project_team_grants ON project_team_grants.team_id = team_members.team_id").
+		Where("project_team_grants.project_id = ?", projectId)
+	return db.Model(&models.User{}).Select("users.id").Where("users.id IN (?) OR users.id IN (?)", direct, team)
+}
+
+// AddProject creates project with the default workflow and makes its
+// creator the owner.
 func (r *ProjectRepository) AddProject(project *models.Project) error {
-	if err := r.db.Create(project).Error; err != nil {
-		return err
+	if r.organizationId == 0 {
+		return ErrNoOrganization
 	}
-	return nil
+	project.OrganizationId = r.organizationId
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(project).Error; err != nil {
+			return err
+		}
+		owner := models.ProjectMember{
+			ProjectId: project.ID,
+			UserId:    project.UserId,
+			Role:      models.ProjectRoleOwner,
+		}
+		if err := tx.Create(&owner).Error; err != nil {
+			return err
+		}
+		return seedWorkflow(tx, project.ID)
+	})
 }
 
 func (r *ProjectRepository) GetProjectById(id uint) (*models.Project, error) {
+	db, err := r.scoped()
+	if err != nil {
+		return nil, err
+	}
 	var project models.Project
-	err := r.db.First(&project, id).Error
+	err = db.First(&project, id).Error
 	if err != nil {
 		return nil, err
 	}
 	return &project, nil
 }
 
+// HasProject reports whether project id belongs to the organization.
+func (r *ProjectRepository) HasProject(id uint) (bool, error) {
+	db, err := r.scoped()
+	if err != nil {
+		return false, err
+	}
+	var count int64
+	err = db.Model(&models.Project{}).Where("id = ?", id).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
 func (r *ProjectRepository) UpdateProject(project *models.Project) error {
+	if r.organizationId == 0 || project.OrganizationId != r.organizationId {
+		return ErrNoOrganization
+	}
 	return r.db.Save(project).Error
 }
 
 func (r *ProjectRepository) DeleteProject(id uint) error {
-	return r.db.Delete(&models.Project{}, id).Error
-}
-
-func (r *ProjectRepository) CheckProjectForUser(projectId, userId uint) (bool, error) {
-	var count int64
-	err := r.db.Model(&models.Project{}).Where("id = ? AND user_id = ?", projectId, userId).Count(&count).Error
+	db, err := r.scoped()
 	if err != nil {
-		return false, err
+		return err
 	}
-	return count > 0, nil
+	return db.Delete(&models.Project{}, id).Error
 }
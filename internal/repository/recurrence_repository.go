@@ -0,0 +1,314 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/rrule"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrTaskRecurring = errors.New("task already recurs")
+
+// taskRecurrenceLock namespaces the advisory locks that make sure only one
+// server instance at a time materializes occurrences of a recurrence.
+const taskRecurrenceLock = 2401
+
+type RecurrenceRepository struct {
+	db *gorm.DB
+}
+
+func NewRecurrenceRepository(db *gorm.DB) *RecurrenceRepository {
+	return &RecurrenceRepository{
+		db: db,
+	}
+}
+
+// Create makes task the first occurrence of recurrence, scheduled at
+// recurrence.DTStart. It fails with ErrTaskRecurring when the task is
+// already part of a recurrence.
+func (r *RecurrenceRepository) Create(recurrence *models.TaskRecurrence, task *models.Task) error {
+	recurrence.LastOccurrenceAt = recurrence.DTStart
+	next, ok, err := nextOccurrence(recurrence, recurrence.DTStart)
+	if err != nil {
+		return err
+	}
+	recurrence.NextAt = nil
+	if ok {
+		recurrence.NextAt = &next
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(recurrence).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&models.Task{}).
+			Where("id = ? AND recurrence_id IS NULL", task.ID).
+			Updates(map[string]any{"recurrence_id": recurrence.ID, "occurrence_at": recurrence.DTStart})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTaskRecurring
+		}
+		task.RecurrenceId = &recurrence.ID
+		task.OccurrenceAt = &recurrence.DTStart
+		return nil
+	})
+}
+
+func (r *RecurrenceRepository) GetRecurrence(id uint) (*models.TaskRecurrence, error) {
+	var recurrence models.TaskRecurrence
+	err := r.db.First(&recurrence, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &recurrence, nil
+}
+
+// UpdateRule replaces the rule of recurrence id and resumes it if it had
+// ended. The new rule starts at the newest occurrence, so COUNT counts from
+// there.
+func (r *RecurrenceRepository) UpdateRule(id uint, rule, timezone string) (*models.TaskRecurrence, error) {
+	var recurrence models.TaskRecurrence
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", taskRecurrenceLock, int32(id)).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&recurrence, id).Error; err != nil {
+			return err
+		}
+
+		recurrence.RRule = rule
+		recurrence.Timezone = timezone
+		recurrence.DTStart = recurrence.LastOccurrenceAt
+		recurrence.EndedAt = nil
+		recurrence.NextAt = nil
+		next, ok, err := nextOccurrence(&recurrence, recurrence.DTStart)
+		if err != nil {
+			return err
+		}
+		if ok {
+			recurrence.NextAt = &next
+		}
+		return tx.Model(&recurrence).Updates(map[string]any{
+			"rrule":    recurrence.RRule,
+			"timezone": recurrence.Timezone,
+			"dtstart":  recurrence.DTStart,
+			"next_at":  recurrence.NextAt,
+			"ended_at": nil,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &recurrence, nil
+}
+
+// End stops recurrence id. Occurrences created so far are kept.
+func (r *RecurrenceRepository) End(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", taskRecurrenceLock, int32(id)).Error; err != nil {
+			return err
+		}
+		return endRecurrence(tx, id)
+	})
+}
+
+// GetPendingIds returns the recurrences that may need a new occurrence:
+// those whose next occurrence is due or whose newest occurrence is
+// completed.
+func (r *RecurrenceRepository) GetPendingIds(now time.Time) ([]uint, error) {
+	completed := r.db.Model(&models.Task{}).Select("1").
+		Where("tasks.recurrence_id = task_recurrences.id AND tasks.occurrence_at = task_recurrences.last_occurrence_at").
+		Where("tasks.completed_at IS NOT NULL")
+	var ids []uint
+	err := r.db.Model(&models.TaskRecurrence{}).
+		Where("ended_at IS NULL").
+		Where("next_at IS NULL OR next_at <= ? OR EXISTS (?)", now, completed).
+		Order("id").
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Materialize creates the next occurrence of recurrence id if it is due at
+// now or the newest occurrence is completed, and returns it. Occurrences
+// missed while the server was down are skipped in favour of the most recent
+// one. It returns nil when there is nothing to do, when the rule has ended,
+// or when another server instance is already working on the recurrence.
+// The unique index on occurrences guarantees that no occurrence is ever
+// created twice.
+func (r *RecurrenceRepository) Materialize(id uint, now time.Time) (*models.Task, error) {
+	var created *models.Task
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		err := tx.Raw("SELECT pg_try_advisory_xact_lock(?, ?)", taskRecurrenceLock, int32(id)).Scan(&locked).Error
+		if err != nil || !locked {
+			return err
+		}
+
+		var recurrence models.TaskRecurrence
+		if err := tx.First(&recurrence, id).Error; err != nil {
+			return err
+		}
+		if recurrence.EndedAt != nil {
+			return nil
+		}
+
+		// Deleted occurrences still count, so that deleting one does not
+		// bring it back.
+		var latest models.Task
+		err = tx.Unscoped().
+			Where("recurrence_id = ? AND occurrence_at = ?", recurrence.ID, recurrence.LastOccurrenceAt).
+			First(&latest).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return endRecurrence(tx, recurrence.ID)
+		}
+		if err != nil {
+			return err
+		}
+
+		var state models.WorkflowState
+		err = tx.Where("project_id = ? AND is_initial", recurrence.ProjectId).First(&state).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// The project is gone.
+			return endRecurrence(tx, recurrence.ID)
+		}
+		if err != nil {
+			return err
+		}
+
+		next, ok, err := nextOccurrence(&recurrence, recurrence.LastOccurrenceAt)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return endRecurrence(tx, recurrence.ID)
+		}
+		completed := latest.CompletedAt != nil && !latest.DeletedAt.Valid
+		if !completed && next.After(now) {
+			return nil
+		}
+		for {
+			following, ok, err := nextOccurrence(&recurrence, next)
+			if err != nil {
+				return err
+			}
+			if !ok || following.After(now) {
+				break
+			}
+			next = following
+		}
+
+		task := models.Task{
+			Title:             latest.Title,
+			Description:       latest.Description,
+			ProjectId:         latest.ProjectId,
+			CreatedBy:         latest.CreatedBy,
+			StateId:           &state.ID,
+			Priority:          latest.Priority,
+			DueDate:           &next,
+			ParentId:          latest.ParentId,
+			OriginalEstimate:  latest.OriginalEstimate,
+			RemainingEstimate: latest.OriginalEstimate,
+			RecurrenceId:      &recurrence.ID,
+			OccurrenceAt:      &next,
+		}
+		// Keep the time between start and due date of the previous
+		// occurrence.
+		if latest.StartDate != nil && latest.DueDate != nil {
+			startDate := next.Add(-latest.DueDate.Sub(*latest.StartDate))
+			task.StartDate = &startDate
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Omit(clause.Associations).Create(&task)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			transition := newTaskTransition(task.ID, nil, &state, recurrence.CreatedBy)
+			if err := tx.Create(&transition).Error; err != nil {
+				return err
+			}
+			// Assignees who lost access to the project are dropped.
+			err = tx.Exec(`
+				INSERT INTO task_assignees (task_id, user_id, assigned_by, assigned_at)
+				SELECT ?, user_id, assigned_by, ? FROM task_assignees
+				WHERE task_id = ? AND user_id IN (?)`,
+				task.ID, time.Now(), latest.ID, projectUserIds(tx, task.ProjectId)).Error
+			if err != nil {
+				return err
+			}
+			err = tx.Exec(`
+				INSERT INTO task_labels (task_id, label_id, added_by, added_at)
+				SELECT ?, label_id, added_by, ? FROM task_labels WHERE task_id = ?`,
+				task.ID, time.Now(), latest.ID).Error
+			if err != nil {
+				return err
+			}
+			created = &task
+		}
+
+		following, ok, err := nextOccurrence(&recurrence, next)
+		if err != nil {
+			return err
+		}
+		updates := map[string]any{"last_occurrence_at": next, "next_at": nil}
+		if ok {
+			updates["next_at"] = following
+		}
+		return tx.Model(&recurrence).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// UpcomingOccurrences returns up to limit schedules of recurrence after its
+// newest occurrence.
+func (r *RecurrenceRepository) UpcomingOccurrences(recurrence *models.TaskRecurrence, limit int) ([]time.Time, error) {
+	upcoming := []time.Time{}
+	if recurrence.EndedAt != nil {
+		return upcoming, nil
+	}
+	after := recurrence.LastOccurrenceAt
+	for len(upcoming) < limit {
+		next, ok, err := nextOccurrence(recurrence, after)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		upcoming = append(upcoming, next)
+		after = next
+	}
+	return upcoming, nil
+}
+
+func endRecurrence(tx *gorm.DB, id uint) error {
+	return tx.Model(&models.TaskRecurrence{}).Where("id = ?", id).Updates(map[string]any{
+		"ended_at": time.Now(),
+		"next_at":  nil,
+	}).Error
+}
+
+// nextOccurrence returns the first schedule of recurrence later than after,
+// computed in the recurrence's time zone.
+func nextOccurrence(recurrence *models.TaskRecurrence, after time.Time) (time.Time, bool, error) {
+	rule, err := rrule.Parse(recurrence.RRule)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	location, err := time.LoadLocation(recurrence.Timezone)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	next, ok := rule.Next(recurrence.DTStart.In(location), after)
+	return next, ok, nil
+}
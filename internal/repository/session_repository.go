@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/utils"
+	"gorm.io/gorm"
+)
+
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) *SessionRepository {
+	return &SessionRepository{
+		db: db,
+	}
+}
+
+func (r *SessionRepository) Create(session *models.Session) error {
+	return r.db.Create(session).Error
+}
+
+// GetSessionByToken looks up an unexpired session by its raw token and
+// preloads the owning user.
+func (r *SessionRepository) GetSessionByToken(token string) (*models.Session, error) {
+	var session models.Session
+	err := r.db.Preload("User").
+		Where("token_hash = ? AND expires_at > ?", utils.HashToken(token), time.Now()).
+		First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *SessionRepository) GetUserSessions(userId uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.db.Model(&models.Session{}).
+		Where("user_id = ? AND expires_at > ?", userId, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (r *SessionRepository) TouchSession(session *models.Session, ip string) error {
+	session.LastSeenAt = time.Now()
+	session.IP = ip
+	return r.db.Model(&models.Session{}).Where("id = ?", session.ID).
+		Updates(map[string]any{"last_seen_at": session.LastSeenAt, "ip": ip}).Error
+}
+
+// DeleteSession removes a single session owned by userId and reports whether
+// anything was deleted.
+func (r *SessionRepository) DeleteSession(id, userId uint) (bool, error) {
+	result := r.db.Unscoped().Where("id = ? AND user_id = ?", id, userId).Delete(&models.Session{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// DeleteUserSessions removes every session of userId except exceptId, which
+// may be zero to remove them all.
+func (r *SessionRepository) DeleteUserSessions(userId, exceptId uint) error {
+	return r.db.Unscoped().Where("user_id = ? AND id <> ?", userId, exceptId).Delete(&models.Session{}).Error
+}
+
+func (r *SessionRepository) DeleteExpired() error {
+	return r.db.Unscoped().Where("expires_at <= ?", time.Now()).Delete(&models.Session{}).Error
+}
+
+// SetOrganization switches the organization session is working in.
+func (r *SessionRepository) SetOrganization(session *models.Session, organizationId uint) error {
+	session.OrganizationId = organizationId
+	return r.db.Model(&models.Session{}).Where("id = ?", session.ID).
+		Update("organization_id", organizationId).Error
+}
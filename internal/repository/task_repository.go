@@ -1,12 +1,47 @@
 package repository
 
 import (
+	"errors"
+	"time"
+
 	"github.com/aminasadiam/DevTasks/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrTaskStateChanged = errors.New("task state changed concurrently")
+	ErrTaskCycle        = errors.New("a task cannot be moved below itself")
+	ErrTaskTooDeep      = errors.New("task hierarchy would be too deep")
+	ErrTaskParent       = errors.New("parent task not found in this project")
 )
 
+// taskHierarchyLock namespaces the advisory locks that serialize changes to
+// the task hierarchy of a project.
+const taskHierarchyLock = 1801
+
+// TaskFilter narrows GetAccessibleTasks. Zero values do not filter.
+type TaskFilter struct {
+	ProjectId  uint
+	StateId    uint
+	AssigneeId uint
+	// DueFrom is inclusive, DueBefore exclusive.
+	DueFrom   *time.Time
+	DueBefore *time.Time
+	// Completed selects only completed or only open tasks when set.
+	Completed *bool
+	// LabelIds selects tasks with any of the labels, or with all of them
+	// when AllLabels is set. It must not contain duplicates.
+	LabelIds  []uint
+	AllLabels bool
+}
+
+// TaskRepository is scoped to one organization like ProjectRepository: it
+// only sees tasks whose project belongs to that organization.
 type TaskRepository struct {
-	db *gorm.DB
+	db             *gorm.DB
+	organizationId uint
+	maxDepth       int
 }
 
 func NewTaskRepository(db *gorm.DB) *TaskRepository {
@@ -15,13 +50,87 @@ func NewTaskRepository(db *gorm.DB) *TaskRepository {
 	}
 }
 
-func (r *TaskRepository) Create(task *models.Task) error {
-	return r.db.Create(task).Error
+// ForOrganization returns a copy of the repository scoped to organizationId.
+func (r *TaskRepository) ForOrganization(organizationId uint) *TaskRepository {
+	return &TaskRepository{
+		db:             r.db,
+		organizationId: organizationId,
+		maxDepth:       r.maxDepth,
+	}
+}
+
+// SetMaxDepth limits how deep tasks can be nested, counting top-level tasks
+// as 1. Zero means no limit.
+func (r *TaskRepository) SetMaxDepth(depth int) {
+	r.maxDepth = depth
 }
 
-func (r *TaskRepository) GetProjectTasks(projectId uint) ([]models.Task, error) {
+func (r *TaskRepository) scoped() (*gorm.DB, error) {
+	if r.organizationId == 0 {
+		return nil, ErrNoOrganization
+	}
+	return r.db.Where("tasks.project_id IN (?)", r.projectIds()), nil
+}
+
+func (r *TaskRepository) projectIds() *gorm.DB {
+	return r.db.Model(&models.Project{}).Select("id").Where("organization_id = ?", r.organizationId)
+}
+
+// ownsProject reports whether projectId belongs to the organization.
+func (r *TaskRepository) ownsProject(projectId uint) error {
+	if r.organizationId == 0 {
+		return ErrNoOrganization
+	}
+	var count int64
+	err := r.projectIds().Where("id = ?", projectId).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNoOrganization
+	}
+	return nil
+}
+
+// Create creates task with its assignees in state and records that as its
+// first transition. A parent must be a task of the same project that leaves
+// room for one more level.
+func (r *TaskRepository) Create(task *models.Task, state *models.WorkflowState, userId uint) error {
+	if err := r.ownsProject(task.ProjectId); err != nil {
+		return err
+	}
+	task.StateId = &state.ID
+	for i := range task.Assignees {
+		task.Assignees[i].AssignedBy = userId
+		task.Assignees[i].AssignedAt = time.Now()
+	}
+	if state.IsDone {
+		now := time.Now()
+		task.CompletedAt = &now
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if task.ParentId != nil {
+			if err := r.checkParent(tx, task.ProjectId, *task.ParentId, 1); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(task).Error; err != nil {
+			return err
+		}
+		transition := newTaskTransition(task.ID, nil, state, userId)
+		return tx.Create(&transition).Error
+	})
+}
+
+// GetProjectTasks returns the tasks of projectId that match filter.
+func (r *TaskRepository) GetProjectTasks(projectId uint, filter TaskFilter) ([]models.Task, error) {
+	db, err := r.scoped()
+	if err != nil {
+		return nil, err
+	}
+	filter.ProjectId = projectId
 	var tasks []models.Task
-	err := r.db.Model(&models.Task{}).Where("project_id = ?", projectId).Find(&tasks).Error
+	err = filterTasks(db.Model(&models.Task{}), filter).Preload("Assignees.User").Preload("Labels.Label").Find(&tasks).Error
 	if err != nil {
 		return nil, err
 	}
@@ -29,45 +138,423 @@ func (r *TaskRepository) GetProjectTasks(projectId uint) ([]models.Task, error)
 }
 
 func (r *TaskRepository) GetTaskById(id uint) (*models.Task, error) {
+	db, err := r.scoped()
+	if err != nil {
+		return nil, err
+	}
 	var task models.Task
-	err := r.db.First(&task, id).Error
+	err = db.Preload("Assignees.User").Preload("Labels.Label").First(&task, id).Error
 	if err != nil {
 		return nil, err
 	}
 	return &task, nil
 }
 
-func (r *TaskRepository) UpdateTask(task *models.Task) error {
-	return r.db.Save(task).Error
+// TaskUpdate holds the changes SaveTaskChanges applies besides the task's
+// own fields.
+type TaskUpdate struct {
+	// AssigneeIds replaces the assignees when SetAssignees is set.
+	AssigneeIds  []uint
+	SetAssignees bool
+	// To is the state the task moves to from From; nil keeps the state.
+	From, To *models.WorkflowState
+	UserId   uint
+}
+
+// SaveTaskChanges saves task and applies update in the same transaction, so
+// that nothing is saved when the transition fails with ErrTaskStateChanged.
+// The parent is left alone; it changes through MoveTask.
+func (r *TaskRepository) SaveTaskChanges(task *models.Task, update TaskUpdate) error {
+	if err := r.ownsProject(task.ProjectId); err != nil {
+		return err
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if update.To != nil {
+			if err := transitionTask(tx, task, update.From, update.To, update.UserId); err != nil {
+				return err
+			}
+		}
+		if err := tx.Omit("state_id", "completed_at", "parent_id", clause.Associations).Save(task).Error; err != nil {
+			return err
+		}
+		if update.SetAssignees {
+			return setAssignees(tx, task, update.AssigneeIds, update.UserId)
+		}
+		return nil
+	})
+}
+
+// transitionTask moves task from one workflow state to another and records
+// the transition. Entering a done state sets CompletedAt, leaving the done
+// states clears it. It fails with ErrTaskStateChanged when the task is no
+// longer in from, e.g. because of a concurrent update.
+func transitionTask(tx *gorm.DB, task *models.Task, from, to *models.WorkflowState, userId uint) error {
+	// Read is_done under a share lock so that it cannot flip between here and
+	// the commit; UpdateState locks the state before changing it.
+	var target models.WorkflowState
+	if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).Select("id", "is_done").First(&target, to.ID).Error; err != nil {
+		return err
+	}
+	to.IsDone = target.IsDone
+
+	query := tx.Model(&models.Task{}).Where("id = ?", task.ID)
+	if from == nil {
+		query = query.Where("state_id IS NULL")
+	} else {
+		query = query.Where("state_id = ?", from.ID)
+	}
+	updates := map[string]any{"state_id": to.ID}
+	completedAt := task.CompletedAt
+	if !to.IsDone {
+		completedAt = nil
+	} else if from == nil || !from.IsDone || completedAt == nil {
+		now := time.Now()
+		completedAt = &now
+	}
+	updates["completed_at"] = completedAt
+	result := query.Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTaskStateChanged
+	}
+
+	transition := newTaskTransition(task.ID, from, to, userId)
+	if err := tx.Create(&transition).Error; err != nil {
+		return err
+	}
+	task.StateId = &to.ID
+	task.CompletedAt = completedAt
+	return nil
 }
 
 func (r *TaskRepository) DeleteTask(id uint) error {
-	return r.db.Delete(&models.Task{}, id).Error
+	db, err := r.scoped()
+	if err != nil {
+		return err
+	}
+	return db.Delete(&models.Task{}, id).Error
 }
 
 func (r *TaskRepository) CheckTaskForProject(taskId, projectId uint) (bool, error) {
+	db, err := r.scoped()
+	if err != nil {
+		return false, err
+	}
 	var count int64
-	err := r.db.Model(&models.Task{}).Where("id = ? AND project_id = ?", taskId, projectId).Count(&count).Error
+	err = db.Model(&models.Task{}).Where("id = ? AND project_id = ?", taskId, projectId).Count(&count).Error
 	if err != nil {
 		return false, err
 	}
 	return count > 0, nil
 }
 
+// CheckTaskForUser reports whether taskId is assigned to userId.
 func (r *TaskRepository) CheckTaskForUser(taskId, userId uint) (bool, error) {
+	db, err := r.scoped()
+	if err != nil {
+		return false, err
+	}
 	var count int64
-	err := r.db.Model(&models.Task{}).Where("id = ? AND user_id = ?", taskId, userId).Count(&count).Error
+	err = db.Model(&models.Task{}).
+		Joins("JOIN task_assignees ON task_assignees.task_id = tasks.id").
+		Where("tasks.id = ? AND task_assignees.user_id = ?", taskId, userId).
+		Count(&count).Error
 	if err != nil {
 		return false, err
 	}
 	return count > 0, nil
 }
 
-func (r *TaskRepository) GetTasksByUserId(userId uint) ([]models.Task, error) {
+// GetTasksByUserId returns the tasks assigned to userId in projects the
+// user still has access to.
+func (r *TaskRepository) GetTasksByUserId(userId uint, filter TaskFilter) ([]models.Task, error) {
+	filter.AssigneeId = userId
+	return r.GetAccessibleTasks(userId, filter)
+}
+
+// setAssignees replaces the assignees of task with userIds. Users who stay
+// assigned keep their original assignment.
+func setAssignees(tx *gorm.DB, task *models.Task, userIds []uint, assignedBy uint) error {
+	remove := tx.Where("task_id = ?", task.ID)
+	if len(userIds) > 0 {
+		remove = remove.Where("user_id NOT IN ?", userIds)
+	}
+	if err := remove.Delete(&models.TaskAssignee{}).Error; err != nil {
+		return err
+	}
+	for _, userId := range userIds {
+		if err := addAssignee(tx, task.ID, userId, assignedBy).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Preload("User").Where("task_id = ?", task.ID).Find(&task.Assignees).Error
+}
+
+// AddAssignee assigns task to userId. It reports false when the user was
+// already assigned.
+func (r *TaskRepository) AddAssignee(task *models.Task, userId, assignedBy uint) (bool, error) {
+	if err := r.ownsProject(task.ProjectId); err != nil {
+		return false, err
+	}
+	result := addAssignee(r.db, task.ID, userId, assignedBy)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *TaskRepository) RemoveAssignee(task *models.Task, userId uint) (bool, error) {
+	if err := r.ownsProject(task.ProjectId); err != nil {
+		return false, err
+	}
+	result := r.db.Where("task_id = ? AND user_id = ?", task.ID, userId).Delete(&models.TaskAssignee{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func addAssignee(tx *gorm.DB, taskId, userId, assignedBy uint) *gorm.DB {
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.TaskAssignee{
+		TaskId:     taskId,
+		UserId:     userId,
+		AssignedBy: assignedBy,
+		AssignedAt: time.Now(),
+	})
+}
+
+// GetAccessibleTasks returns the tasks of every project of the organization
+// userId has a role in, most urgent first and then by due date.
+func (r *TaskRepository) GetAccessibleTasks(userId uint, filter TaskFilter) ([]models.Task, error) {
+	db, err := r.scoped()
+	if err != nil {
+		return nil, err
+	}
+	query := db.Model(&models.Task{}).Where("tasks.project_id IN (?)", accessibleProjectIds(r.db, userId))
+	query = filterTasks(query, filter)
+
+	var tasks []models.Task
+	err = query.Preload("Assignees.User").Preload("Labels.Label").Order("tasks.priority DESC, tasks.due_date ASC NULLS LAST, tasks.id").Find(&tasks).Error
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// filterTasks narrows a query on tasks to those matching filter.
+func filterTasks(query *gorm.DB, filter TaskFilter) *gorm.DB {
+	if filter.DueFrom != nil {
+		query = query.Where("tasks.due_date >= ?", *filter.DueFrom)
+	}
+	if filter.DueBefore != nil {
+		query = query.Where("tasks.due_date < ?", *filter.DueBefore)
+	}
+	if filter.Completed != nil {
+		if *filter.Completed {
+			query = query.Where("tasks.completed_at IS NOT NULL")
+		} else {
+			query = query.Where("tasks.completed_at IS NULL")
+		}
+	}
+	if filter.ProjectId != 0 {
+		query = query.Where("tasks.project_id = ?", filter.ProjectId)
+	}
+	if filter.StateId != 0 {
+		query = query.Where("tasks.state_id = ?", filter.StateId)
+	}
+	if filter.AssigneeId != 0 {
+		query = query.Where("EXISTS (SELECT 1 FROM task_assignees a WHERE a.task_id = tasks.id AND a.user_id = ?)", filter.AssigneeId)
+	}
+	if len(filter.LabelIds) > 0 {
+		if filter.AllLabels {
+			query = query.Where("(SELECT COUNT(DISTINCT l.label_id) FROM task_labels l WHERE l.task_id = tasks.id AND l.label_id IN ?) = ?",
+				filter.LabelIds, len(filter.LabelIds))
+		} else {
+			query = query.Where("EXISTS (SELECT 1 FROM task_labels l WHERE l.task_id = tasks.id AND l.label_id IN ?)", filter.LabelIds)
+		}
+	}
+	return query
+}
+
+// GetSubtree returns taskId and all of its descendants.
+func (r *TaskRepository) GetSubtree(taskId uint) ([]models.Task, error) {
+	db, err := r.scoped()
+	if err != nil {
+		return nil, err
+	}
+	levels, err := subtreeLevels(r.db, taskId)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, 0, len(levels))
+	for id := range levels {
+		ids = append(ids, id)
+	}
+
 	var tasks []models.Task
-	err := r.db.Model(&models.Task{}).Where("user_id = ?", userId).Find(&tasks).Error
+	err = db.Preload("Assignees.User").Preload("Labels.Label").Where("tasks.id IN ?", ids).Order("tasks.id").Find(&tasks).Error
 	if err != nil {
 		return nil, err
 	}
 	return tasks, nil
 }
+
+func (r *TaskRepository) CountSubtasks(taskId uint) (int64, error) {
+	db, err := r.scoped()
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	err = db.Model(&models.Task{}).Where("parent_id = ?", taskId).Count(&count).Error
+	return count, err
+}
+
+// MoveTask moves task with its subtree below parentId, or to the top level
+// when parentId is nil. Moves that would create a cycle or exceed the
+// maximum depth are rejected.
+func (r *TaskRepository) MoveTask(task *models.Task, parentId *uint) error {
+	if err := r.ownsProject(task.ProjectId); err != nil {
+		return err
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockTaskHierarchy(tx, task.ProjectId); err != nil {
+			return err
+		}
+		if parentId != nil {
+			levels, err := subtreeLevels(tx, task.ID)
+			if err != nil {
+				return err
+			}
+			if _, ok := levels[*parentId]; ok {
+				return ErrTaskCycle
+			}
+			height := 0
+			for _, level := range levels {
+				height = max(height, level)
+			}
+			if err := r.checkParent(tx, task.ProjectId, *parentId, height); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&models.Task{}).Where("id = ?", task.ID).Update("parent_id", parentId).Error; err != nil {
+			return err
+		}
+		task.ParentId = parentId
+		return nil
+	})
+}
+
+// DeleteTaskTree deletes task together with all of its descendants.
+func (r *TaskRepository) DeleteTaskTree(task *models.Task) error {
+	if err := r.ownsProject(task.ProjectId); err != nil {
+		return err
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockTaskHierarchy(tx, task.ProjectId); err != nil {
+			return err
+		}
+		levels, err := subtreeLevels(tx, task.ID)
+		if err != nil {
+			return err
+		}
+		ids := make([]uint, 0, len(levels))
+		for id := range levels {
+			ids = append(ids, id)
+		}
+		return tx.Where("id IN ?", ids).Delete(&models.Task{}).Error
+	})
+}
+
+// DeleteTaskReparent deletes task and hands its subtasks to its parent, or
+// makes them top-level tasks.
+func (r *TaskRepository) DeleteTaskReparent(task *models.Task) error {
+	if err := r.ownsProject(task.ProjectId); err != nil {
+		return err
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockTaskHierarchy(tx, task.ProjectId); err != nil {
+			return err
+		}
+		err := tx.Model(&models.Task{}).Where("parent_id = ?", task.ID).Update("parent_id", task.ParentId).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&models.Task{}, task.ID).Error
+	})
+}
+
+// checkParent makes sure parentId is a task of projectId and that a subtree
+// of the given height fits below it.
+func (r *TaskRepository) checkParent(tx *gorm.DB, projectId, parentId uint, height int) error {
+	if err := lockTaskHierarchy(tx, projectId); err != nil {
+		return err
+	}
+	var count int64
+	err := tx.Model(&models.Task{}).Where("id = ? AND project_id = ?", parentId, projectId).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrTaskParent
+	}
+	if r.maxDepth <= 0 {
+		return nil
+	}
+	depth, err := taskDepth(tx, parentId)
+	if err != nil {
+		return err
+	}
+	if depth+height > r.maxDepth {
+		return ErrTaskTooDeep
+	}
+	return nil
+}
+
+// lockTaskHierarchy serializes hierarchy changes within a project until the
+// transaction ends, so that concurrent moves cannot form a cycle.
+func lockTaskHierarchy(tx *gorm.DB, projectId uint) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", taskHierarchyLock, int32(projectId)).Error
+}
+
+// taskDepth returns the depth of taskId, counting top-level tasks as 1.
+func taskDepth(tx *gorm.DB, taskId uint) (int, error) {
+	var depth int
+	err := tx.Raw(`
+		WITH RECURSIVE ancestors (id, parent_id, depth) AS (
+			SELECT id, parent_id, 1 FROM tasks WHERE id = ?
+			UNION
+			SELECT t.id, t.parent_id, a.depth + 1
+			FROM tasks t JOIN ancestors a ON t.id = a.parent_id
+			WHERE a.depth < 100
+		)
+		SELECT COALESCE(MAX(depth), 0) FROM ancestors`, taskId).Scan(&depth).Error
+	return depth, err
+}
+
+// subtreeLevels returns taskId and its undeleted descendants mapped to
+// their level below it, taskId itself being level 1.
+func subtreeLevels(tx *gorm.DB, taskId uint) (map[uint]int, error) {
+	var rows []struct {
+		ID    uint
+		Level int
+	}
+	err := tx.Raw(`
+		WITH RECURSIVE subtree (id, level) AS (
+			SELECT id, 1 FROM tasks WHERE id = ? AND deleted_at IS NULL
+			UNION
+			SELECT t.id, s.level + 1
+			FROM tasks t JOIN subtree s ON t.parent_id = s.id
+			WHERE t.deleted_at IS NULL AND s.level < 100
+		)
+		SELECT id, MIN(level) AS level FROM subtree GROUP BY id`, taskId).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	levels := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		levels[row.ID] = row.Level
+	}
+	return levels, nil
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aminasadiam/DevTasks/internal/database/dbtest"
+	"github.com/aminasadiam/DevTasks/internal/models"
+)
+
+// hierarchyFixture scripts a project 7 holding the chain 1 → 2 → 3 and a
+// separate top-level task 4. Task 9 belongs to another project.
+func hierarchyFixture(fake *dbtest.DB) {
+	parents := map[int64]int64{1: 0, 2: 1, 3: 2, 4: 0}
+	fake.Return(`SELECT COUNT("id") FROM "projects"`, dbtest.Rows("count").Row(1))
+	fake.On(`WITH RECURSIVE subtree`, func(args []any) (*dbtest.Result, error) {
+		rows := dbtest.Rows("id", "level")
+		for id, level := args[0].(int64), 1; id != 0; level++ {
+			rows.Row(id, level)
+			next := int64(0)
+			for child, parent := range parents {
+				if parent == id {
+					next = child
+				}
+			}
+			id = next
+		}
+		return rows, nil
+	})
+	fake.On(`WITH RECURSIVE ancestors`, func(args []any) (*dbtest.Result, error) {
+		depth := 0
+		for id := args[0].(int64); id != 0; id = parents[id] {
+			depth++
+		}
+		return dbtest.Rows("depth").Row(depth), nil
+	})
+	fake.On(`SELECT count(*) FROM "tasks" WHERE (id = $1 AND project_id = $2)`, func(args []any) (*dbtest.Result, error) {
+		if _, ok := parents[args[0].(int64)]; ok && args[1] == int64(7) {
+			return dbtest.Rows("count").Row(1), nil
+		}
+		return dbtest.Rows("count").Row(0), nil
+	})
+}
+
+func TestMoveTask(t *testing.T) {
+	tests := []struct {
+		name     string
+		task     uint
+		parent   uint
+		maxDepth int
+		err      error
+	}{
+		{"below another tree", 2, 4, 0, nil},
+		{"to the top level", 3, 0, 0, nil},
+		{"below itself", 2, 2, 0, ErrTaskCycle},
+		{"below its child", 1, 2, 0, ErrTaskCycle},
+		{"below its grandchild", 1, 3, 0, ErrTaskCycle},
+		{"into another project", 2, 9, 0, ErrTaskParent},
+		{"within the depth limit", 4, 2, 3, nil},
+		{"beyond the depth limit", 4, 3, 3, ErrTaskTooDeep},
+		{"subtree beyond the depth limit", 1, 4, 3, ErrTaskTooDeep},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, fake := dbtest.Open(t)
+			hierarchyFixture(fake)
+			tasks := NewTaskRepository(db)
+			tasks.SetMaxDepth(test.maxDepth)
+
+			task := models.Task{ProjectId: 7}
+			task.ID = test.task
+			var parentId *uint
+			if test.parent != 0 {
+				parentId = &test.parent
+			}
+			err := tasks.ForOrganization(4).MoveTask(&task, parentId)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("MoveTask(%d below %d) = %v, want %v", test.task, test.parent, err, test.err)
+			}
+
+			if locks := fake.Find(`pg_advisory_xact_lock`); len(locks) == 0 || !hasArg(locks[0], int64(7)) {
+				t.Errorf("hierarchy of project 7 not locked: %v", locks)
+			}
+			updates := fake.Find(`UPDATE "tasks" SET "parent_id"`)
+			if test.err != nil {
+				if len(updates) != 0 {
+					t.Errorf("rejected move was saved: %v", updates)
+				}
+				return
+			}
+			if len(updates) != 1 || !hasArg(updates[0], int64(test.task)) {
+				t.Fatalf("updates = %v, want one for task %d", updates, test.task)
+			}
+			if parentId == nil && updates[0].Args[0] != nil || parentId != nil && updates[0].Args[0] != int64(test.parent) {
+				t.Errorf("parent saved as %v, want %d", updates[0].Args[0], test.parent)
+			}
+		})
+	}
+}
+
+func TestDeleteTaskSubtasks(t *testing.T) {
+	db, fake := dbtest.Open(t)
+	hierarchyFixture(fake)
+	tasks := NewTaskRepository(db).ForOrganization(4)
+	parent := uint(1)
+	task := models.Task{ProjectId: 7, ParentId: &parent}
+	task.ID = 2
+
+	if err := tasks.DeleteTaskTree(&task); err != nil {
+		t.Fatal(err)
+	}
+	deletes := fake.Find(`UPDATE "tasks" SET "deleted_at"`)
+	if len(deletes) != 1 || !hasArg(deletes[0], int64(2)) || !hasArg(deletes[0], int64(3)) || hasArg(deletes[0], int64(1)) {
+		t.Errorf("cascade deleted %v, want tasks 2 and 3", deletes)
+	}
+
+	db, fake = dbtest.Open(t)
+	hierarchyFixture(fake)
+	if err := NewTaskRepository(db).ForOrganization(4).DeleteTaskReparent(&task); err != nil {
+		t.Fatal(err)
+	}
+	reparents := fake.Find(`UPDATE "tasks" SET "parent_id"`)
+	if len(reparents) != 1 || reparents[0].Args[0] != int64(1) || !hasArg(reparents[0], int64(2)) {
+		t.Errorf("reparent = %v, want the children of 2 moved below 1", reparents)
+	}
+	deletes = fake.Find(`UPDATE "tasks" SET "deleted_at"`)
+	if len(deletes) != 1 || hasArg(deletes[0], int64(3)) {
+		t.Errorf("reparent deleted %v, want task 2 alone", deletes)
+	}
+}
+
+func TestGetProjectTasksByLabels(t *testing.T) {
+	// Task 1 has labels 1 and 2, task 2 label 1, task 3 labels 2 and 3 and
+	// task 4 none.
+	labels := map[uint][]int64{1: {1, 2}, 2: {1}, 3: {2, 3}, 4: nil}
+	tests := []struct {
+		name   string
+		filter TaskFilter
+		want   []uint
+	}{
+		{"no labels", TaskFilter{}, []uint{1, 2, 3, 4}},
+		{"any of one", TaskFilter{LabelIds: []uint{1}}, []uint{1, 2}},
+		{"any of two", TaskFilter{LabelIds: []uint{1, 3}}, []uint{1, 2, 3}},
+		{"all of two", TaskFilter{LabelIds: []uint{1, 2}, AllLabels: true}, []uint{1}},
+		{"all of two nobody has", TaskFilter{LabelIds: []uint{1, 3}, AllLabels: true}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, fake := dbtest.Open(t)
+			// The label filters are answered the way Postgres evaluates
+			// them on the labels above. The label IDs are the last
+			// arguments, followed by their count when all are required.
+			matching := func(all bool) dbtest.Answer {
+				return func(args []any) (*dbtest.Result, error) {
+					wanted := args[len(args)-len(test.filter.LabelIds):]
+					if all {
+						wanted = args[len(args)-len(test.filter.LabelIds)-1 : len(args)-1]
+					}
+					var tasks []any
+					for id := uint(1); id <= 4; id++ {
+						found := 0
+						for _, label := range labels[id] {
+							for _, arg := range wanted {
+								if arg == label {
+									found++
+								}
+							}
+						}
+						if found > 0 && (!all || found == len(wanted)) {
+							task := models.Task{ProjectId: 7}
+							task.ID = id
+							tasks = append(tasks, task)
+						}
+					}
+					if len(tasks) == 0 {
+						return nil, nil
+					}
+					return dbtest.Records(tasks...), nil
+				}
+			}
+			var unfiltered []any
+			for id := uint(1); id <= 4; id++ {
+				task := models.Task{ProjectId: 7}
+				task.ID = id
+				unfiltered = append(unfiltered, task)
+			}
+			fake.Return(`FROM "tasks"`, dbtest.Records(unfiltered...))
+			fake.On(`EXISTS (SELECT 1 FROM task_labels`, matching(false))
+			fake.On(`COUNT(DISTINCT l.label_id)`, matching(true))
+
+			tasks, err := NewTaskRepository(db).ForOrganization(4).GetProjectTasks(7, test.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []uint
+			for _, task := range tasks {
+				got = append(got, task.ID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("tasks = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
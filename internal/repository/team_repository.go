@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"gorm.io/gorm"
+)
+
+type TeamRepository struct {
+	db *gorm.DB
+}
+
+func NewTeamRepository(db *gorm.DB) *TeamRepository {
+	return &TeamRepository{
+		db: db,
+	}
+}
+
+func (r *TeamRepository) CreateTeam(team *models.Team) error {
+	return r.db.Create(team).Error
+}
+
+// GetTeam returns team id if it belongs to organizationId.
+func (r *TeamRepository) GetTeam(organizationId, id uint) (*models.Team, error) {
+	var team models.Team
+	err := r.db.Where("organization_id = ?", organizationId).First(&team, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+func (r *TeamRepository) GetOrganizationTeams(organizationId uint) ([]models.Team, error) {
+	var teams []models.Team
+	err := r.db.Where("organization_id = ?", organizationId).Order("name").Find(&teams).Error
+	if err != nil {
+		return nil, err
+	}
+	return teams, nil
+}
+
+func (r *TeamRepository) ExistTeamName(organizationId uint, name string, exceptId uint) bool {
+	var count int64
+	r.db.Model(&models.Team{}).
+		Where("organization_id = ? AND name = ? AND id <> ?", organizationId, name, exceptId).
+		Count(&count)
+	return count > 0
+}
+
+func (r *TeamRepository) UpdateTeam(team *models.Team) error {
+	return r.db.Save(team).Error
+}
+
+// DeleteTeam removes a team with its members and project grants.
+func (r *TeamRepository) DeleteTeam(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("team_id = ?", id).Delete(&models.ProjectTeamGrant{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("team_id = ?", id).Delete(&models.TeamMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&models.Team{}, id).Error
+	})
+}
+
+func (r *TeamRepository) GetTeamMembers(teamId uint) ([]models.TeamMember, error) {
+	var members []models.TeamMember
+	err := r.db.Preload("User").Where("team_id = ?", teamId).Order("id").Find(&members).Error
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r *TeamRepository) IsTeamMember(teamId, userId uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.TeamMember{}).Where("team_id = ? AND user_id = ?", teamId, userId).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *TeamRepository) AddTeamMember(member *models.TeamMember) error {
+	return r.db.Create(member).Error
+}
+
+func (r *TeamRepository) RemoveTeamMember(teamId, userId uint) (bool, error) {
+	result := r.db.Unscoped().Where("team_id = ? AND user_id = ?", teamId, userId).Delete(&models.TeamMember{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *TeamRepository) GetProjectGrants(projectId uint) ([]models.ProjectTeamGrant, error) {
+	var grants []models.ProjectTeamGrant
+	err := r.db.Preload("Team").Where("project_id = ?", projectId).Order("id").Find(&grants).Error
+	if err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+// GetGrantRole returns the role teamId has on projectId, or an empty string
+// when the team has no grant.
+func (r *TeamRepository) GetGrantRole(projectId, teamId uint) (string, error) {
+	var grant models.ProjectTeamGrant
+	err := r.db.Where("project_id = ? AND team_id = ?", projectId, teamId).First(&grant).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return grant.Role, nil
+}
+
+func (r *TeamRepository) AddGrant(grant *models.ProjectTeamGrant) error {
+	return r.db.Create(grant).Error
+}
+
+func (r *TeamRepository) UpdateGrantRole(projectId, teamId uint, role string) error {
+	return r.db.Model(&models.ProjectTeamGrant{}).
+		Where("project_id = ? AND team_id = ?", projectId, teamId).
+		Update("role", role).Error
+}
+
+func (r *TeamRepository) RemoveGrant(projectId, teamId uint) error {
+	return r.db.Unscoped().Where("project_id = ? AND team_id = ?", projectId, teamId).Delete(&models.ProjectTeamGrant{}).Error
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"github.com/aminasadiam/DevTasks/internal/utils"
+	"gorm.io/gorm"
+)
+
+type TwoFactorRepository struct {
+	db *gorm.DB
+}
+
+func NewTwoFactorRepository(db *gorm.DB) *TwoFactorRepository {
+	return &TwoFactorRepository{
+		db: db,
+	}
+}
+
+// ReplaceRecoveryCodes swaps every recovery code of userId for the given
+// codes, storing only their hashes.
+func (r *TwoFactorRepository) ReplaceRecoveryCodes(userId uint, codes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", userId).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		for _, code := range codes {
+			recoveryCode := models.RecoveryCode{
+				UserId:   userId,
+				CodeHash: utils.HashToken(utils.NormalizeRecoveryCode(code)),
+			}
+			if err := tx.Create(&recoveryCode).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ConsumeRecoveryCode marks an unused recovery code of userId as used and
+// reports whether one matched.
+func (r *TwoFactorRepository) ConsumeRecoveryCode(userId uint, code string) (bool, error) {
+	result := r.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userId, utils.HashToken(utils.NormalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *TwoFactorRepository) DeleteRecoveryCodes(userId uint) error {
+	return r.db.Unscoped().Where("user_id = ?", userId).Delete(&models.RecoveryCode{}).Error
+}
+
+func (r *TwoFactorRepository) CreatePendingLogin(pending *models.PendingLogin) error {
+	return r.db.Create(pending).Error
+}
+
+// GetPendingLogin looks up an unexpired pending login by its raw token and
+// preloads the user.
+func (r *TwoFactorRepository) GetPendingLogin(token string) (*models.PendingLogin, error) {
+	var pending models.PendingLogin
+	err := r.db.Preload("User").
+		Where("token_hash = ? AND expires_at > ?", utils.HashToken(token), time.Now()).
+		First(&pending).Error
+	if err != nil {
+		return nil, err
+	}
+	return &pending, nil
+}
+
+func (r *TwoFactorRepository) IncrementPendingAttempts(pending *models.PendingLogin) error {
+	pending.Attempts++
+	return r.db.Model(&models.PendingLogin{}).Where("id = ?", pending.ID).
+		Update("attempts", gorm.Expr("attempts + 1")).Error
+}
+
+func (r *TwoFactorRepository) DeletePendingLogin(id uint) error {
+	return r.db.Unscoped().Delete(&models.PendingLogin{}, id).Error
+}
+
+func (r *TwoFactorRepository) DeleteExpiredPendingLogins() error {
+	return r.db.Unscoped().Where("expires_at <= ?", time.Now()).Delete(&models.PendingLogin{}).Error
+}
@@ -1,6 +1,9 @@
 package repository
 
 import (
+	"strings"
+	"time"
+
 	"github.com/aminasadiam/DevTasks/internal/models"
 	"github.com/aminasadiam/DevTasks/internal/utils"
 	"gorm.io/gorm"
@@ -16,8 +19,16 @@ func NewUserRepository(db *gorm.DB) *UserRepository {
 	}
 }
 
+// Create saves user and points its profile picture at the avatar endpoint,
+// which serves an identicon until the user uploads a picture.
 func (r *UserRepository) Create(user *models.User) error {
-	return r.db.Create(user).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		user.Profile = models.AvatarURL(user.ID, user.AvatarVersion)
+		return tx.Model(user).Update("profile", user.Profile).Error
+	})
 }
 
 func (r *UserRepository) AllUsers() []models.User {
@@ -28,7 +39,7 @@ func (r *UserRepository) AllUsers() []models.User {
 
 func (r *UserRepository) GetUserByUsername(username string) (*models.User, bool) {
 	var user models.User
-	if err := r.db.Model(&models.User{}).Where("username = ?", username).Find(&user).Error; err != nil {
+	if err := r.db.Model(&models.User{}).Where("username = ?", username).First(&user).Error; err != nil {
 		return &models.User{}, false
 	}
 	return &user, true
@@ -63,9 +74,138 @@ func (r *UserRepository) Update(user *models.User) {
 	r.db.Model(&models.User{}).Where("id = ?", user.ID).Save(user)
 }
 
-func (r *UserRepository) GetUserIdByUsername(username string) (userId uint) {
+func (r *UserRepository) GetUserByEmail(email string) (*models.User, bool) {
+	var user models.User
+	if err := r.db.Where("LOWER(email) = LOWER(?)", email).First(&user).Error; err != nil {
+		return &models.User{}, false
+	}
+	return &user, true
+}
+
+func (r *UserRepository) UpdatePassword(userId uint, hashedPassword string) error {
+	return r.db.Model(&models.User{}).Where("id = ?", userId).Update("password", hashedPassword).Error
+}
+
+func (r *UserRepository) MarkEmailVerified(userId uint) error {
+	return r.db.Model(&models.User{}).Where("id = ?", userId).
+		Updates(map[string]any{"email_verified": true, "email_verified_at": time.Now()}).Error
+}
+
+func (r *UserRepository) SetTOTPSecret(userId uint, secret string) error {
+	return r.db.Model(&models.User{}).Where("id = ?", userId).
+		Updates(map[string]any{"totp_secret": secret, "totp_enabled": false, "totp_last_step": 0}).Error
+}
+
+func (r *UserRepository) EnableTOTP(userId uint, step int64) error {
+	return r.db.Model(&models.User{}).Where("id = ?", userId).
+		Updates(map[string]any{"totp_enabled": true, "totp_last_step": step}).Error
+}
+
+func (r *UserRepository) DisableTOTP(userId uint) error {
+	return r.db.Model(&models.User{}).Where("id = ?", userId).
+		Updates(map[string]any{"totp_secret": "", "totp_enabled": false, "totp_last_step": 0}).Error
+}
+
+// AdvanceTOTPStep records step as the last used TOTP step of userId. It
+// reports false when an equal or later step was already used, which means
+// the code is being replayed.
+func (r *UserRepository) AdvanceTOTPStep(userId uint, step int64) (bool, error) {
+	result := r.db.Model(&models.User{}).Where("id = ? AND totp_last_step < ?", userId, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *UserRepository) GetUserByOIDCSubject(issuer, subject string) (*models.User, bool) {
 	var user models.User
-	r.db.Model(models.User{}).Where("username = ?", username).Find(&user)
-	userId = user.ID
-	return
+	if err := r.db.Where("oidc_issuer = ? AND oidc_subject = ?", issuer, subject).First(&user).Error; err != nil {
+		return &models.User{}, false
+	}
+	return &user, true
+}
+
+// LinkOIDC attaches an external identity to userId.
+func (r *UserRepository) LinkOIDC(userId uint, issuer, subject string) error {
+	return r.db.Model(&models.User{}).Where("id = ?", userId).Updates(map[string]any{
+		"oidc_issuer":  issuer,
+		"oidc_subject": subject,
+	}).Error
+}
+
+func (r *UserRepository) GetUserById(id uint) (*models.User, error) {
+	var user models.User
+	if err := r.db.First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SearchUsers filters users by a case-insensitive match on username or
+// email, by role and by activation state. Empty filters match everything.
+func (r *UserRepository) SearchUsers(query, role string, active *bool) ([]models.User, error) {
+	var users []models.User
+	db := r.db.Model(&models.User{})
+	if query != "" {
+		like := "%" + strings.ToLower(query) + "%"
+		db = db.Where("LOWER(username) LIKE ? OR LOWER(email) LIKE ?", like, like)
+	}
+	if role != "" {
+		db = db.Where("role = ?", role)
+	}
+	if active != nil {
+		if *active {
+			db = db.Where("deactivated_at IS NULL")
+		} else {
+			db = db.Where("deactivated_at IS NOT NULL")
+		}
+	}
+	if err := db.Order("username").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *UserRepository) SetRole(userId uint, role string) error {
+	return r.db.Model(&models.User{}).Where("id = ?", userId).Update("role", role).Error
+}
+
+// SetDeactivatedAt deactivates userId at the given time, or reactivates it
+// when deactivatedAt is nil.
+func (r *UserRepository) SetDeactivatedAt(userId uint, deactivatedAt *time.Time) error {
+	return r.db.Model(&models.User{}).Where("id = ?", userId).Update("deactivated_at", deactivatedAt).Error
+}
+
+// PromoteAdmins gives the admin role to the listed usernames.
+func (r *UserRepository) PromoteAdmins(usernames []string) error {
+	if len(usernames) == 0 {
+		return nil
+	}
+	return r.db.Model(&models.User{}).Where("username IN ?", usernames).Update("role", models.RoleAdmin).Error
+}
+
+// SetAvatar switches userId to the avatar uploaded as version, or back to
+// the identicon when version is empty.
+func (r *UserRepository) SetAvatar(userId uint, version string) error {
+	return r.db.Model(&models.User{}).Where("id = ?", userId).Updates(map[string]any{
+		"avatar_version": version,
+		"profile":        models.AvatarURL(userId, version),
+	}).Error
+}
+
+// BackfillAvatars points users created before avatars existed, which still
+// have the old hard-coded picture, at the avatar endpoint.
+func (r *UserRepository) BackfillAvatars() error {
+	var users []models.User
+	err := r.db.Select("id").Where("avatar_version = '' AND (profile = '' OR profile = 'Default.png')").Find(&users).Error
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if err := r.SetAvatar(user.ID, ""); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,255 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WorkflowRepository struct {
+	db *gorm.DB
+}
+
+func NewWorkflowRepository(db *gorm.DB) *WorkflowRepository {
+	return &WorkflowRepository{
+		db: db,
+	}
+}
+
+func (r *WorkflowRepository) GetStates(projectId uint) ([]models.WorkflowState, error) {
+	var states []models.WorkflowState
+	err := r.db.Where("project_id = ?", projectId).Order("position, id").Find(&states).Error
+	if err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+// GetState returns state id if it belongs to projectId.
+func (r *WorkflowRepository) GetState(projectId, id uint) (*models.WorkflowState, error) {
+	var state models.WorkflowState
+	err := r.db.Where("project_id = ?", projectId).First(&state, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (r *WorkflowRepository) GetInitialState(projectId uint) (*models.WorkflowState, error) {
+	var state models.WorkflowState
+	err := r.db.Where("project_id = ? AND is_initial", projectId).First(&state).Error
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (r *WorkflowRepository) ExistStateName(projectId uint, name string, exceptId uint) bool {
+	var count int64
+	r.db.Model(&models.WorkflowState{}).
+		Where("project_id = ? AND LOWER(name) = LOWER(?) AND id <> ?", projectId, name, exceptId).
+		Count(&count)
+	return count > 0
+}
+
+// CreateState adds a state to the end of the workflow unless it has a
+// position. A new initial state replaces the previous one.
+func (r *WorkflowRepository) CreateState(state *models.WorkflowState) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if state.Position == 0 {
+			var last int
+			err := tx.Model(&models.WorkflowState{}).Where("project_id = ?", state.ProjectId).
+				Select("COALESCE(MAX(position), 0)").Scan(&last).Error
+			if err != nil {
+				return err
+			}
+			state.Position = last + 1
+		}
+		if state.IsInitial {
+			if err := clearInitialState(tx, state.ProjectId); err != nil {
+				return err
+			}
+		}
+		return tx.Create(state).Error
+	})
+}
+
+// UpdateState saves state. Making it the initial state replaces the previous
+// one. When it becomes a done state its open tasks are completed now, and
+// when it stops being one its tasks are reopened.
+func (r *WorkflowRepository) UpdateState(state *models.WorkflowState) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// The lock keeps tasks from entering the state with the old is_done
+		// until the change is committed.
+		var stored models.WorkflowState
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&stored, state.ID).Error; err != nil {
+			return err
+		}
+
+		if state.IsInitial {
+			if err := clearInitialState(tx, state.ProjectId); err != nil {
+				return err
+			}
+		}
+		if err := tx.Save(state).Error; err != nil {
+			return err
+		}
+
+		if stored.IsDone == state.IsDone {
+			return nil
+		}
+		tasks := tx.Model(&models.Task{}).Where("state_id = ?", state.ID)
+		if state.IsDone {
+			return tasks.Where("completed_at IS NULL").Update("completed_at", time.Now()).Error
+		}
+		return tasks.Update("completed_at", nil).Error
+	})
+}
+
+func clearInitialState(tx *gorm.DB, projectId uint) error {
+	return tx.Model(&models.WorkflowState{}).
+		Where("project_id = ? AND is_initial", projectId).
+		Update("is_initial", false).Error
+}
+
+// DeleteState removes a state and every transition from or to it.
+func (r *WorkflowRepository) DeleteState(projectId, id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Where("project_id = ? AND (from_state_id = ? OR to_state_id = ?)", projectId, id, id).
+			Delete(&models.WorkflowTransition{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("project_id = ?", projectId).Delete(&models.WorkflowState{}, id).Error
+	})
+}
+
+func (r *WorkflowRepository) CountTasksInState(stateId uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Task{}).Where("state_id = ?", stateId).Count(&count).Error
+	return count, err
+}
+
+func (r *WorkflowRepository) GetTransitions(projectId uint) ([]models.WorkflowTransition, error) {
+	var transitions []models.WorkflowTransition
+	err := r.db.Where("project_id = ?", projectId).Order("from_state_id, to_state_id").Find(&transitions).Error
+	if err != nil {
+		return nil, err
+	}
+	return transitions, nil
+}
+
+func (r *WorkflowRepository) IsTransitionAllowed(fromStateId, toStateId uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.WorkflowTransition{}).
+		Where("from_state_id = ? AND to_state_id = ?", fromStateId, toStateId).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *WorkflowRepository) AddTransition(transition *models.WorkflowTransition) error {
+	return r.db.Create(transition).Error
+}
+
+func (r *WorkflowRepository) RemoveTransition(projectId, id uint) (bool, error) {
+	result := r.db.Unscoped().Where("project_id = ?", projectId).Delete(&models.WorkflowTransition{}, id)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// GetTaskTransitions returns the state history of a task, oldest first.
+func (r *WorkflowRepository) GetTaskTransitions(taskId uint) ([]models.TaskTransition, error) {
+	var transitions []models.TaskTransition
+	err := r.db.Where("task_id = ?", taskId).Order("transitioned_at, id").Find(&transitions).Error
+	if err != nil {
+		return nil, err
+	}
+	return transitions, nil
+}
+
+// seedWorkflow gives a new project the default workflow.
+func seedWorkflow(tx *gorm.DB, projectId uint) error {
+	ids := make(map[string]uint, len(models.DefaultWorkflowStates))
+	for i, def := range models.DefaultWorkflowStates {
+		state := models.WorkflowState{
+			ProjectId: projectId,
+			Name:      def.Name,
+			Position:  i + 1,
+			IsInitial: def.IsInitial,
+			IsDone:    def.IsDone,
+		}
+		if err := tx.Create(&state).Error; err != nil {
+			return err
+		}
+		ids[def.Name] = state.ID
+	}
+	for _, def := range models.DefaultWorkflowTransitions {
+		transition := models.WorkflowTransition{
+			ProjectId:   projectId,
+			FromStateId: ids[def[0]],
+			ToStateId:   ids[def[1]],
+		}
+		if err := tx.Create(&transition).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BackfillWorkflows gives projects created before workflows existed the
+// default workflow and puts their tasks in its initial state, recording the
+// task's creation as the first transition.
+func (r *WorkflowRepository) BackfillWorkflows() error {
+	var projectIds []uint
+	err := r.db.Model(&models.Project{}).
+		Where("NOT EXISTS (SELECT 1 FROM workflow_states s WHERE s.project_id = projects.id AND s.deleted_at IS NULL)").
+		Pluck("id", &projectIds).Error
+	if err != nil {
+		return err
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, projectId := range projectIds {
+			if err := seedWorkflow(tx, projectId); err != nil {
+				return err
+			}
+		}
+
+		err := tx.Exec(`
+			INSERT INTO task_transitions (task_id, to_state_id, to_state_name, user_id, transitioned_at)
+			SELECT t.id, s.id, s.name, t.created_by, t.created_at
+			FROM tasks t
+			JOIN workflow_states s ON s.project_id = t.project_id AND s.is_initial AND s.deleted_at IS NULL
+			WHERE t.state_id IS NULL`).Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec(`
+			UPDATE tasks SET state_id = s.id
+			FROM workflow_states s
+			WHERE s.project_id = tasks.project_id AND s.is_initial AND s.deleted_at IS NULL
+			  AND tasks.state_id IS NULL`).Error
+	})
+}
+
+func newTaskTransition(taskId uint, from *models.WorkflowState, to *models.WorkflowState, userId uint) models.TaskTransition {
+	transition := models.TaskTransition{
+		TaskId:         taskId,
+		ToStateId:      to.ID,
+		ToStateName:    to.Name,
+		UserId:         userId,
+		TransitionedAt: time.Now(),
+	}
+	if from != nil {
+		transition.FromStateId = &from.ID
+		transition.FromStateName = from.Name
+	}
+	return transition
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/database/dbtest"
+	"github.com/aminasadiam/DevTasks/internal/models"
+)
+
+func TestUpdateStateSyncsCompletion(t *testing.T) {
+	tests := []struct {
+		name          string
+		stored, saved bool
+		// tasks is what happens to the tasks in the state.
+		tasks string
+	}{
+		{"stays open", false, false, ""},
+		{"stays done", true, true, ""},
+		{"becomes done", false, true, "complete"},
+		{"stops being done", true, false, "reopen"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, fake := dbtest.Open(t)
+			stored := models.WorkflowState{ProjectId: 7, Name: "Done", IsDone: test.stored}
+			stored.ID = 12
+			fake.Return(`FROM "workflow_states"`, dbtest.Records(stored))
+			fake.Return(`UPDATE "workflow_states"`, dbtest.Affected(1))
+
+			state := stored
+			state.IsDone = test.saved
+			if err := NewWorkflowRepository(db).UpdateState(&state); err != nil {
+				t.Fatal(err)
+			}
+
+			if locks := fake.Find(`FOR UPDATE`); len(locks) != 1 {
+				t.Errorf("state read %v, want it locked", locks)
+			}
+			updates := fake.Find(`UPDATE "tasks" SET "completed_at"`)
+			if test.tasks == "" {
+				if len(updates) != 0 {
+					t.Errorf("tasks updated: %v", updates)
+				}
+				return
+			}
+			if len(updates) != 1 || !hasArg(updates[0], int64(12)) {
+				t.Fatalf("task updates = %v, want one for state 12", updates)
+			}
+			if _, completed := updates[0].Args[0].(time.Time); completed != (test.tasks == "complete") {
+				t.Errorf("completed_at = %v, want tasks to %s", updates[0].Args[0], test.tasks)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrTimerRunning = errors.New("a timer is already running")
+	ErrNoTimer      = errors.New("no timer is running")
+)
+
+// TimeReportFilter narrows TimeReport. Zero values do not filter.
+type TimeReportFilter struct {
+	ProjectId uint
+	TaskId    uint
+	UserId    uint
+	// From is inclusive, Before exclusive. Both apply to StartedAt.
+	From   *time.Time
+	Before *time.Time
+	// GroupBy is one of the models.ReportBy constants. Days are cut in
+	// Location.
+	GroupBy  string
+	Location *time.Location
+}
+
+type WorklogRepository struct {
+	db *gorm.DB
+}
+
+func NewWorklogRepository(db *gorm.DB) *WorklogRepository {
+	return &WorklogRepository{
+		db: db,
+	}
+}
+
+// StartTimer starts a timer for worklog.UserId on worklog.TaskId. It fails
+// with ErrTimerRunning when the user already has one.
+func (r *WorklogRepository) StartTimer(worklog *models.Worklog) error {
+	worklog.StartedAt = time.Now()
+	worklog.EndedAt = nil
+	worklog.Seconds = 0
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Locking the user serializes timer changes per user; the partial
+		// unique index on running timers backs this up.
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.User{}, worklog.UserId).Error
+		if err != nil {
+			return err
+		}
+		var running int64
+		err = tx.Model(&models.Worklog{}).Where("user_id = ? AND ended_at IS NULL", worklog.UserId).Count(&running).Error
+		if err != nil {
+			return err
+		}
+		if running > 0 {
+			return ErrTimerRunning
+		}
+		return tx.Create(worklog).Error
+	})
+}
+
+// GetRunningTimer returns the running timer of userId, or nil when there is
+// none.
+func (r *WorklogRepository) GetRunningTimer(userId uint) (*models.Worklog, error) {
+	var worklogs []models.Worklog
+	err := r.db.Preload("User").Where("user_id = ? AND ended_at IS NULL", userId).Limit(1).Find(&worklogs).Error
+	if err != nil || len(worklogs) == 0 {
+		return nil, err
+	}
+	return &worklogs[0], nil
+}
+
+// StopTimer stops the running timer of userId and books its time on the
+// task. It fails with ErrNoTimer when no timer is running.
+func (r *WorklogRepository) StopTimer(userId uint) (*models.Worklog, error) {
+	var worklog models.Worklog
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND ended_at IS NULL", userId).
+			First(&worklog).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNoTimer
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		worklog.EndedAt = &now
+		worklog.Seconds = int64(now.Sub(worklog.StartedAt).Seconds())
+		err = tx.Model(&worklog).Updates(map[string]any{
+			"ended_at": worklog.EndedAt,
+			"seconds":  worklog.Seconds,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return burnEstimate(tx, worklog.TaskId, worklog.Seconds)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &worklog, nil
+}
+
+// AddWorklog saves time logged by hand and books it on the task.
+func (r *WorklogRepository) AddWorklog(worklog *models.Worklog) error {
+	endedAt := worklog.StartedAt.Add(time.Duration(worklog.Seconds) * time.Second)
+	worklog.EndedAt = &endedAt
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(worklog).Error; err != nil {
+			return err
+		}
+		return burnEstimate(tx, worklog.TaskId, worklog.Seconds)
+	})
+}
+
+// burnEstimate lowers the remaining estimate of taskId by seconds, down to
+// zero. Tasks without a remaining estimate are left alone.
+func burnEstimate(tx *gorm.DB, taskId uint, seconds int64) error {
+	minutes := seconds / 60
+	if minutes == 0 {
+		return nil
+	}
+	return tx.Model(&models.Task{}).
+		Where("id = ? AND remaining_estimate IS NOT NULL", taskId).
+		Update("remaining_estimate", gorm.Expr("GREATEST(remaining_estimate - ?, 0)", minutes)).Error
+}
+
+func (r *WorklogRepository) GetWorklog(id uint) (*models.Worklog, error) {
+	var worklog models.Worklog
+	err := r.db.Preload("User").First(&worklog, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &worklog, nil
+}
+
+// GetTaskWorklogs returns the time logged on taskId, newest first, running
+// timers included.
+func (r *WorklogRepository) GetTaskWorklogs(taskId uint) ([]models.Worklog, error) {
+	var worklogs []models.Worklog
+	err := r.db.Preload("User").Where("task_id = ?", taskId).Order("started_at DESC, id DESC").Find(&worklogs).Error
+	if err != nil {
+		return nil, err
+	}
+	return worklogs, nil
+}
+
+// UpdateWorklog saves the start, duration and note of a finished worklog.
+// The remaining estimate is not adjusted again.
+func (r *WorklogRepository) UpdateWorklog(worklog *models.Worklog) error {
+	endedAt := worklog.StartedAt.Add(time.Duration(worklog.Seconds) * time.Second)
+	worklog.EndedAt = &endedAt
+	return r.db.Model(worklog).Updates(map[string]any{
+		"started_at": worklog.StartedAt,
+		"ended_at":   worklog.EndedAt,
+		"seconds":    worklog.Seconds,
+		"note":       worklog.Note,
+	}).Error
+}
+
+func (r *WorklogRepository) DeleteWorklog(id uint) error {
+	return r.db.Delete(&models.Worklog{}, id).Error
+}
+
+// timeReportEntry is a finished worklog with everything TimeReport can
+// group it by.
+type timeReportEntry struct {
+	TaskId      uint
+	TaskTitle   string
+	ProjectId   uint
+	ProjectName string
+	UserId      uint
+	Username    string
+	StartedAt   time.Time
+	Seconds     int64
+}
+
+// TimeReport sums the finished worklogs on tasks of organizationId that
+// viewerId can access. The sums are taken here rather than in SQL so that
+// days are cut in the report's location, including its daylight saving
+// changes.
+func (r *WorklogRepository) TimeReport(organizationId, viewerId uint, filter TimeReportFilter) (*models.TimeReport, error) {
+	query := r.db.Table("worklogs").
+		Select("tasks.id AS task_id, tasks.title AS task_title, projects.id AS project_id, projects.name AS project_name, "+
+			"users.id AS user_id, users.username AS username, worklogs.started_at, worklogs.seconds").
+		Joins("JOIN tasks ON tasks.id = worklogs.task_id AND tasks.deleted_at IS NULL").
+		Joins("JOIN projects ON projects.id = tasks.project_id AND projects.deleted_at IS NULL").
+		Joins("JOIN users ON users.id = worklogs.user_id").
+		Where("worklogs.deleted_at IS NULL AND worklogs.ended_at IS NOT NULL").
+		Where("projects.organization_id = ?", organizationId).
+		Where("projects.id IN (?)", accessibleProjectIds(r.db, viewerId))
+	if filter.ProjectId != 0 {
+		query = query.Where("tasks.project_id = ?", filter.ProjectId)
+	}
+	if filter.TaskId != 0 {
+		query = query.Where("worklogs.task_id = ?", filter.TaskId)
+	}
+	if filter.UserId != 0 {
+		query = query.Where("worklogs.user_id = ?", filter.UserId)
+	}
+	if filter.From != nil {
+		query = query.Where("worklogs.started_at >= ?", *filter.From)
+	}
+	if filter.Before != nil {
+		query = query.Where("worklogs.started_at < ?", *filter.Before)
+	}
+
+	var entries []timeReportEntry
+	if err := query.Scan(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	location := time.UTC
+	if filter.Location != nil {
+		location = filter.Location
+	}
+	report := models.TimeReport{GroupBy: filter.GroupBy, Rows: groupTimeReport(entries, filter.GroupBy, location)}
+	for i := range report.Rows {
+		report.Rows[i].Hours = models.Hours(report.Rows[i].Seconds)
+		report.TotalSeconds += report.Rows[i].Seconds
+	}
+	report.TotalHours = models.Hours(report.TotalSeconds)
+	return &report, nil
+}
+
+// groupTimeReport sums entries by groupBy. Days are listed in order, the
+// other groups by the most time spent and then by ID.
+func groupTimeReport(entries []timeReportEntry, groupBy string, location *time.Location) []models.TimeReportRow {
+	rows := []models.TimeReportRow{}
+	index := make(map[models.TimeReportRow]int)
+	byDay := false
+	for _, entry := range entries {
+		var key models.TimeReportRow
+		switch groupBy {
+		case models.ReportByTask:
+			key = models.TimeReportRow{Id: entry.TaskId, Name: entry.TaskTitle}
+		case models.ReportByProject:
+			key = models.TimeReportRow{Id: entry.ProjectId, Name: entry.ProjectName}
+		case models.ReportByUser:
+			key = models.TimeReportRow{Id: entry.UserId, Name: entry.Username}
+		default:
+			key = models.TimeReportRow{Name: entry.StartedAt.In(location).Format(time.DateOnly)}
+			byDay = true
+		}
+
+		i, ok := index[key]
+		if !ok {
+			i = len(rows)
+			index[key] = i
+			rows = append(rows, key)
+		}
+		rows[i].Seconds += entry.Seconds
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		if byDay {
+			return rows[i].Name < rows[j].Name
+		}
+		if rows[i].Seconds != rows[j].Seconds {
+			return rows[i].Seconds > rows[j].Seconds
+		}
+		return rows[i].Id < rows[j].Id
+	})
+	return rows
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aminasadiam/DevTasks/internal/database/dbtest"
+	"github.com/aminasadiam/DevTasks/internal/models"
+)
+
+// worklogFixture answers the report query with four finished worklogs. The
+// first two start on different days in UTC but on the same day in Berlin
+// and the other way round.
+func worklogFixture(fake *dbtest.DB) {
+	rows := dbtest.Rows("task_id", "task_title", "project_id", "project_name", "user_id", "username", "started_at", "seconds")
+	rows.Row(1, "Design", 1, "Web", 1, "alice", time.Date(2025, 3, 3, 22, 30, 0, 0, time.UTC), 1800)
+	rows.Row(1, "Design", 1, "Web", 2, "bob", time.Date(2025, 3, 3, 23, 30, 0, 0, time.UTC), 3600)
+	rows.Row(2, "Build", 2, "API", 1, "alice", time.Date(2025, 3, 4, 8, 0, 0, 0, time.UTC), 7200)
+	rows.Row(3, "Test", 1, "Web", 2, "bob", time.Date(2025, 3, 4, 9, 0, 0, 0, time.UTC), 5400)
+	fake.Return(`FROM "worklogs"`, rows)
+}
+
+func TestTimeReportGrouping(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		groupBy  string
+		location *time.Location
+		rows     []models.TimeReportRow
+	}{
+		{"task", models.ReportByTask, nil, []models.TimeReportRow{
+			{Id: 2, Name: "Build", Seconds: 7200, Hours: 2},
+			{Id: 1, Name: "Design", Seconds: 5400, Hours: 1.5},
+			{Id: 3, Name: "Test", Seconds: 5400, Hours: 1.5},
+		}},
+		{"project", models.ReportByProject, nil, []models.TimeReportRow{
+			{Id: 1, Name: "Web", Seconds: 10800, Hours: 3},
+			{Id: 2, Name: "API", Seconds: 7200, Hours: 2},
+		}},
+		{"user", models.ReportByUser, nil, []models.TimeReportRow{
+			{Id: 1, Name: "alice", Seconds: 9000, Hours: 2.5},
+			{Id: 2, Name: "bob", Seconds: 9000, Hours: 2.5},
+		}},
+		{"day in UTC", models.ReportByDay, nil, []models.TimeReportRow{
+			{Name: "2025-03-03", Seconds: 5400, Hours: 1.5},
+			{Name: "2025-03-04", Seconds: 12600, Hours: 3.5},
+		}},
+		{"day in Berlin", models.ReportByDay, berlin, []models.TimeReportRow{
+			{Name: "2025-03-03", Seconds: 1800, Hours: 0.5},
+			{Name: "2025-03-04", Seconds: 16200, Hours: 4.5},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, fake := dbtest.Open(t)
+			worklogFixture(fake)
+
+			report, err := NewWorklogRepository(db).TimeReport(4, 1, TimeReportFilter{GroupBy: test.groupBy, Location: test.location})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.GroupBy != test.groupBy || !slices.Equal(report.Rows, test.rows) {
+				t.Errorf("rows = %+v, want %+v", report.Rows, test.rows)
+			}
+			if report.TotalSeconds != 18000 || report.TotalHours != 5 {
+				t.Errorf("total = %d seconds, %v hours, want 18000 seconds, 5 hours", report.TotalSeconds, report.TotalHours)
+			}
+		})
+	}
+}
+
+func TestTimeReportFilter(t *testing.T) {
+	db, fake := dbtest.Open(t)
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	before := from.AddDate(0, 1, 0)
+
+	report, err := NewWorklogRepository(db).TimeReport(4, 1, TimeReportFilter{
+		ProjectId: 7, TaskId: 3, UserId: 2, From: &from, Before: &before, GroupBy: models.ReportByTask,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Rows == nil || len(report.Rows) != 0 || report.TotalSeconds != 0 {
+		t.Errorf("empty report = %+v", report)
+	}
+
+	queries := fake.Find(`FROM "worklogs"`)
+	if len(queries) != 1 {
+		t.Fatalf("queries = %v, want one", queries)
+	}
+	for _, arg := range []any{int64(4), int64(1), int64(7), int64(3), int64(2), from, before} {
+		if !hasArg(queries[0], arg) {
+			t.Errorf("query lacks argument %v: %v", arg, queries[0])
+		}
+	}
+	if !strings.Contains(queries[0].Query, "worklogs.ended_at IS NOT NULL") {
+		t.Errorf("running timers are not left out: %s", queries[0].Query)
+	}
+}
@@ -0,0 +1,455 @@
+// Package rrule parses and expands the recurrence rules of RFC 5545
+// (RRULE) used by recurring tasks.
+//
+// Supported are FREQ=DAILY, WEEKLY, MONTHLY and YEARLY with INTERVAL,
+// COUNT, UNTIL, WKST, BYDAY (with ordinals such as 1MO or -1FR in monthly
+// and yearly rules), BYMONTHDAY and BYMONTH. Occurrences keep the time of
+// day of DTSTART; rules with BYHOUR, BYSETPOS and similar parts are
+// rejected.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency int
+
+const (
+	Daily Frequency = iota
+	Weekly
+	Monthly
+	Yearly
+)
+
+var frequencyNames = []string{"DAILY", "WEEKLY", "MONTHLY", "YEARLY"}
+
+var weekdayNames = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// maxPeriods bounds how many days, weeks, months or years are searched for
+// the next occurrence, so that rules that never match cannot loop forever.
+const maxPeriods = 50000
+
+// Weekday is a BYDAY entry. N selects the Nth such weekday of the month or
+// year, counting from the end when negative; 0 selects all of them.
+type Weekday struct {
+	Day time.Weekday
+	N   int
+}
+
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	Count    int
+	Until    *time.Time
+	// FloatingUntil marks an UNTIL without a time zone. Its wall clock time
+	// is read in the location of DTSTART.
+	FloatingUntil bool
+	WeekStart     time.Weekday
+	ByDay         []Weekday
+	ByMonthDay    []int
+	ByMonth       []time.Month
+}
+
+// Parse parses a rule such as "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH". An
+// "RRULE:" prefix is allowed.
+func Parse(value string) (*Rule, error) {
+	value = strings.TrimSpace(value)
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "RRULE:"), "rrule:")
+	if value == "" {
+		return nil, errors.New("rule is empty")
+	}
+
+	rule := Rule{Freq: -1, Interval: 1, WeekStart: time.Monday}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(value, ";") {
+		key, val, ok := strings.Cut(part, "=")
+		key = strings.ToUpper(strings.TrimSpace(key))
+		val = strings.ToUpper(strings.TrimSpace(val))
+		if !ok || key == "" || val == "" {
+			return nil, fmt.Errorf("invalid rule part %q", part)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("%s is given more than once", key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			rule.Freq = Frequency(indexOf(frequencyNames, val))
+			if rule.Freq < 0 {
+				err = fmt.Errorf("unsupported frequency %q", val)
+			}
+		case "INTERVAL":
+			rule.Interval, err = positive(val)
+		case "COUNT":
+			rule.Count, err = positive(val)
+		case "UNTIL":
+			var until time.Time
+			until, rule.FloatingUntil, err = parseUntil(val)
+			rule.Until = &until
+		case "WKST":
+			day := indexOf(weekdayNames, val)
+			if day < 0 {
+				err = fmt.Errorf("invalid weekday %q", val)
+			}
+			rule.WeekStart = time.Weekday(day)
+		case "BYDAY":
+			rule.ByDay, err = parseByDay(val)
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseList(val, -31, 31)
+		case "BYMONTH":
+			var months []int
+			months, err = parseList(val, 1, 12)
+			for _, month := range months {
+				rule.ByMonth = append(rule.ByMonth, time.Month(month))
+			}
+		default:
+			err = fmt.Errorf("%s is not supported", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if rule.Freq < 0 {
+		return nil, errors.New("FREQ is required")
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, errors.New("COUNT and UNTIL cannot be combined")
+	}
+	for _, day := range rule.ByDay {
+		if day.N != 0 && rule.Freq != Monthly && rule.Freq != Yearly {
+			return nil, errors.New("BYDAY ordinals are only allowed in monthly and yearly rules")
+		}
+	}
+	if len(rule.ByMonthDay) > 0 && rule.Freq == Weekly {
+		return nil, errors.New("BYMONTHDAY is not allowed in weekly rules")
+	}
+	return &rule, nil
+}
+
+// String formats the rule in canonical form.
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + frequencyNames[r.Freq]}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil && r.FloatingUntil {
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102T150405"))
+	} else if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayNames[r.WeekStart])
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = weekdayNames[day.Day]
+			if day.N != 0 {
+				days[i] = strconv.Itoa(day.N) + days[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]int, len(r.ByMonth))
+		for i, month := range r.ByMonth {
+			months[i] = int(month)
+		}
+		parts = append(parts, "BYMONTH="+joinInts(months))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next returns the first occurrence of the rule started at dtstart that is
+// later than after, or false when the rule has ended. dtstart is always the
+// first occurrence and counts towards COUNT. Occurrences are computed in the
+// location of dtstart, so they keep their wall clock time across daylight
+// saving changes.
+func (r *Rule) Next(dtstart, after time.Time) (time.Time, bool) {
+	until := r.until(dtstart.Location())
+	if dtstart.After(after) {
+		if until != nil && dtstart.After(*until) {
+			return time.Time{}, false
+		}
+		return dtstart, true
+	}
+
+	count := 1
+	for period := 0; period < maxPeriods; period++ {
+		for _, occurrence := range r.expand(dtstart, period*r.Interval) {
+			if !occurrence.After(dtstart) {
+				continue
+			}
+			if until != nil && occurrence.After(*until) {
+				return time.Time{}, false
+			}
+			count++
+			if r.Count > 0 && count > r.Count {
+				return time.Time{}, false
+			}
+			if occurrence.After(after) {
+				return occurrence, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// until returns UNTIL as an instant, reading a floating UNTIL in loc.
+func (r *Rule) until(loc *time.Location) *time.Time {
+	if r.Until == nil || !r.FloatingUntil {
+		return r.Until
+	}
+	u := *r.Until
+	until := time.Date(u.Year(), u.Month(), u.Day(), u.Hour(), u.Minute(), u.Second(), 0, loc)
+	return &until
+}
+
+// expand returns the sorted occurrences in the period offset days, weeks,
+// months or years after the one containing dtstart.
+func (r *Rule) expand(dtstart time.Time, offset int) []time.Time {
+	loc := dtstart.Location()
+	year, month, day := dtstart.Date()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, dtstart.Hour(), dtstart.Minute(), dtstart.Second(), 0, loc)
+	}
+
+	var days []time.Time
+	switch r.Freq {
+	case Daily:
+		date := at(year, month, day+offset)
+		if r.matchesMonth(date) && r.matchesMonthDay(date) && r.matchesWeekday(date) {
+			days = append(days, date)
+		}
+
+	case Weekly:
+		back := (int(dtstart.Weekday()) - int(r.WeekStart) + 7) % 7
+		start := at(year, month, day-back+offset*7)
+		for i := 0; i < 7; i++ {
+			date := at(start.Year(), start.Month(), start.Day()+i)
+			weekday := date.Weekday() == dtstart.Weekday()
+			if len(r.ByDay) > 0 {
+				weekday = r.matchesWeekday(date)
+			}
+			if weekday && r.matchesMonth(date) {
+				days = append(days, date)
+			}
+		}
+
+	case Monthly:
+		first := at(year, month+time.Month(offset), 1)
+		if r.matchesMonth(first) {
+			days = r.selectDays(monthDays(first), dtstart)
+		}
+
+	case Yearly:
+		y := year + offset
+		if len(r.ByMonth) > 0 {
+			for _, m := range r.ByMonth {
+				days = append(days, r.selectDays(monthDays(at(y, m, 1)), dtstart)...)
+			}
+		} else {
+			var all []time.Time
+			for m := time.January; m <= time.December; m++ {
+				all = append(all, monthDays(at(y, m, 1))...)
+			}
+			days = r.selectDays(all, dtstart)
+		}
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days
+}
+
+// selectDays applies BYDAY and BYMONTHDAY to the days of a month or year.
+// Ordinals count within set. Without either part, the day of DTSTART (and
+// for yearly rules without BYMONTH its month) is used.
+func (r *Rule) selectDays(set []time.Time, dtstart time.Time) []time.Time {
+	if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 {
+		var days []time.Time
+		for _, date := range set {
+			if date.Day() == dtstart.Day() && (r.Freq != Yearly || len(r.ByMonth) > 0 || date.Month() == dtstart.Month()) {
+				days = append(days, date)
+			}
+		}
+		return days
+	}
+
+	selected := set
+	if len(r.ByDay) > 0 {
+		picked := make(map[int]bool)
+		for _, byDay := range r.ByDay {
+			var matching []int
+			for i, date := range set {
+				if date.Weekday() == byDay.Day {
+					matching = append(matching, i)
+				}
+			}
+			switch {
+			case byDay.N == 0:
+				for _, i := range matching {
+					picked[i] = true
+				}
+			case byDay.N > 0 && byDay.N <= len(matching):
+				picked[matching[byDay.N-1]] = true
+			case byDay.N < 0 && -byDay.N <= len(matching):
+				picked[matching[len(matching)+byDay.N]] = true
+			}
+		}
+		selected = nil
+		for i, date := range set {
+			if picked[i] {
+				selected = append(selected, date)
+			}
+		}
+	}
+
+	var days []time.Time
+	for _, date := range selected {
+		if r.matchesMonthDay(date) {
+			days = append(days, date)
+		}
+	}
+	return days
+}
+
+func (r *Rule) matchesMonth(date time.Time) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, month := range r.ByMonth {
+		if date.Month() == month {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesMonthDay(date time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	length := daysIn(date)
+	for _, day := range r.ByMonthDay {
+		if day == date.Day() || (day < 0 && length+day+1 == date.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesWeekday checks BYDAY without ordinals, as used by daily and
+// weekly rules.
+func (r *Rule) matchesWeekday(date time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, day := range r.ByDay {
+		if date.Weekday() == day.Day {
+			return true
+		}
+	}
+	return false
+}
+
+func monthDays(first time.Time) []time.Time {
+	days := make([]time.Time, daysIn(first))
+	for i := range days {
+		days[i] = time.Date(first.Year(), first.Month(), i+1, first.Hour(), first.Minute(), first.Second(), 0, first.Location())
+	}
+	return days
+}
+
+func daysIn(date time.Time) int {
+	return time.Date(date.Year(), date.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// parseUntil parses UNTIL and reports whether it is floating, i.e. has no
+// time zone. Floating values are returned with their wall clock time in
+// UTC; a date covers the whole day.
+func parseUntil(value string) (time.Time, bool, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.Parse("20060102T150405", value); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.Parse("20060102", value); err == nil {
+		return t.Add(24*time.Hour - time.Second), true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("invalid UNTIL %q", value)
+}
+
+func parseByDay(value string) ([]Weekday, error) {
+	var days []Weekday
+	for _, item := range strings.Split(value, ",") {
+		if len(item) < 2 {
+			return nil, fmt.Errorf("invalid weekday %q", item)
+		}
+		day := indexOf(weekdayNames, item[len(item)-2:])
+		if day < 0 {
+			return nil, fmt.Errorf("invalid weekday %q", item)
+		}
+		n := 0
+		if ordinal := item[:len(item)-2]; ordinal != "" {
+			var err error
+			n, err = strconv.Atoi(strings.TrimPrefix(ordinal, "+"))
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, fmt.Errorf("invalid weekday %q", item)
+			}
+		}
+		days = append(days, Weekday{Day: time.Weekday(day), N: n})
+	}
+	return days, nil
+}
+
+func parseList(value string, min, max int) ([]int, error) {
+	var values []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimPrefix(item, "+"))
+		if err != nil || n == 0 || n < min || n > max {
+			return nil, fmt.Errorf("invalid value %q", item)
+		}
+		values = append(values, n)
+	}
+	return values, nil
+}
+
+func positive(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid number %q", value)
+	}
+	return n, nil
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ",")
+}
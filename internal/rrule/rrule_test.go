@@ -0,0 +1,343 @@
+package rrule
+
+import (
+	"testing"
+	"time"
+)
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s not available: %v", name, err)
+	}
+	return loc
+}
+
+// occurrences returns up to n occurrences of value starting at dtstart.
+func occurrences(t *testing.T, value string, dtstart time.Time, n int) []time.Time {
+	t.Helper()
+	rule, err := Parse(value)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", value, err)
+	}
+	var found []time.Time
+	after := dtstart.Add(-time.Second)
+	for len(found) < n {
+		next, ok := rule.Next(dtstart, after)
+		if !ok {
+			break
+		}
+		found = append(found, next)
+		after = next
+	}
+	return found
+}
+
+// dates formats times as local dates and times for comparison.
+func dates(times []time.Time) []string {
+	formatted := make([]string, len(times))
+	for i, t := range times {
+		formatted[i] = t.Format("2006-01-02 15:04 Mon")
+	}
+	return formatted
+}
+
+func checkOccurrences(t *testing.T, value string, dtstart time.Time, want []string) {
+	t.Helper()
+	got := dates(occurrences(t, value, dtstart, len(want)+1))
+	if len(got) > len(want) {
+		got = got[:len(want)]
+	}
+	if len(got) != len(want) {
+		t.Fatalf("%s from %s:\n got %q\nwant %q", value, dtstart, got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("%s from %s:\n got %q\nwant %q", value, dtstart, got, want)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart time.Time
+		want    []string
+	}{
+		{
+			"daily interval",
+			"FREQ=DAILY;INTERVAL=3",
+			time.Date(2025, 1, 30, 9, 0, 0, 0, time.UTC),
+			[]string{"2025-01-30 09:00 Thu", "2025-02-02 09:00 Sun", "2025-02-05 09:00 Wed"},
+		},
+		{
+			"weekly on several days",
+			"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE,FR",
+			time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC),
+			[]string{"2025-01-06 09:00 Mon", "2025-01-08 09:00 Wed", "2025-01-10 09:00 Fri", "2025-01-20 09:00 Mon"},
+		},
+		{
+			"weekly with week start",
+			"FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,SU;WKST=SU",
+			time.Date(1997, 8, 5, 9, 0, 0, 0, time.UTC),
+			[]string{"1997-08-05 09:00 Tue", "1997-08-17 09:00 Sun", "1997-08-19 09:00 Tue", "1997-08-31 09:00 Sun"},
+		},
+		{
+			"first monday",
+			"FREQ=MONTHLY;BYDAY=1MO",
+			time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC),
+			[]string{"2025-01-06 09:00 Mon", "2025-02-03 09:00 Mon", "2025-03-03 09:00 Mon", "2025-04-07 09:00 Mon"},
+		},
+		{
+			"last friday",
+			"FREQ=MONTHLY;BYDAY=-1FR",
+			time.Date(2025, 1, 31, 17, 0, 0, 0, time.UTC),
+			[]string{"2025-01-31 17:00 Fri", "2025-02-28 17:00 Fri", "2025-03-28 17:00 Fri", "2025-04-25 17:00 Fri"},
+		},
+		{
+			"second to last weekday ordinal",
+			"FREQ=MONTHLY;BYDAY=-2TH",
+			time.Date(2025, 5, 22, 9, 0, 0, 0, time.UTC),
+			[]string{"2025-05-22 09:00 Thu", "2025-06-19 09:00 Thu", "2025-07-24 09:00 Thu"},
+		},
+		{
+			"fifth monday skips months without one",
+			"FREQ=MONTHLY;BYDAY=5MO",
+			time.Date(2025, 3, 31, 9, 0, 0, 0, time.UTC),
+			[]string{"2025-03-31 09:00 Mon", "2025-06-30 09:00 Mon", "2025-09-29 09:00 Mon", "2025-12-29 09:00 Mon"},
+		},
+		{
+			"last day of month",
+			"FREQ=MONTHLY;BYMONTHDAY=-1",
+			time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC),
+			[]string{"2024-01-31 09:00 Wed", "2024-02-29 09:00 Thu", "2024-03-31 09:00 Sun", "2024-04-30 09:00 Tue"},
+		},
+		{
+			"31st skips short months",
+			"FREQ=MONTHLY",
+			time.Date(2025, 1, 31, 9, 0, 0, 0, time.UTC),
+			[]string{"2025-01-31 09:00 Fri", "2025-03-31 09:00 Mon", "2025-05-31 09:00 Sat", "2025-07-31 09:00 Thu", "2025-08-31 09:00 Sun"},
+		},
+		{
+			"explicit 31st skips short months",
+			"FREQ=MONTHLY;BYMONTHDAY=31",
+			time.Date(2025, 3, 31, 9, 0, 0, 0, time.UTC),
+			[]string{"2025-03-31 09:00 Mon", "2025-05-31 09:00 Sat", "2025-07-31 09:00 Thu"},
+		},
+		{
+			"monthly on two days",
+			"FREQ=MONTHLY;BYMONTHDAY=1,-1",
+			time.Date(2025, 2, 1, 9, 0, 0, 0, time.UTC),
+			[]string{"2025-02-01 09:00 Sat", "2025-02-28 09:00 Fri", "2025-03-01 09:00 Sat", "2025-03-31 09:00 Mon"},
+		},
+		{
+			"friday the 13th",
+			"FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13",
+			time.Date(2025, 6, 13, 9, 0, 0, 0, time.UTC),
+			[]string{"2025-06-13 09:00 Fri", "2026-02-13 09:00 Fri", "2026-03-13 09:00 Fri"},
+		},
+		{
+			"leap day",
+			"FREQ=YEARLY",
+			time.Date(2024, 2, 29, 9, 0, 0, 0, time.UTC),
+			[]string{"2024-02-29 09:00 Thu", "2028-02-29 09:00 Tue", "2032-02-29 09:00 Sun"},
+		},
+		{
+			"yearly thanksgiving",
+			"FREQ=YEARLY;BYMONTH=11;BYDAY=4TH",
+			time.Date(2024, 11, 28, 12, 0, 0, 0, time.UTC),
+			[]string{"2024-11-28 12:00 Thu", "2025-11-27 12:00 Thu", "2026-11-26 12:00 Thu"},
+		},
+		{
+			"last monday of the year",
+			"FREQ=YEARLY;BYDAY=-1MO",
+			time.Date(2024, 12, 30, 9, 0, 0, 0, time.UTC),
+			[]string{"2024-12-30 09:00 Mon", "2025-12-29 09:00 Mon", "2026-12-28 09:00 Mon"},
+		},
+		{
+			"count",
+			"FREQ=DAILY;COUNT=3",
+			time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC),
+			[]string{"2025-01-01 09:00 Wed", "2025-01-02 09:00 Thu", "2025-01-03 09:00 Fri"},
+		},
+		{
+			"count includes dtstart off the pattern",
+			"FREQ=WEEKLY;BYDAY=MO;COUNT=2",
+			time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC),
+			[]string{"2025-01-01 09:00 Wed", "2025-01-06 09:00 Mon"},
+		},
+		{
+			"until is inclusive",
+			"FREQ=DAILY;UNTIL=20250103T090000Z",
+			time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC),
+			[]string{"2025-01-01 09:00 Wed", "2025-01-02 09:00 Thu", "2025-01-03 09:00 Fri"},
+		},
+		{
+			"until between occurrences",
+			"FREQ=WEEKLY;UNTIL=20250120T000000Z",
+			time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC),
+			[]string{"2025-01-01 09:00 Wed", "2025-01-08 09:00 Wed", "2025-01-15 09:00 Wed"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checkOccurrences(t, test.rule, test.dtstart, test.want)
+		})
+	}
+}
+
+func TestNextEnds(t *testing.T) {
+	dtstart := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		rule  string
+		count int
+	}{
+		{"FREQ=DAILY;COUNT=3", 3},
+		{"FREQ=DAILY;UNTIL=20250103T090000Z", 3},
+		{"FREQ=DAILY;UNTIL=20250103T085959Z", 2},
+		{"FREQ=MONTHLY;BYMONTHDAY=1;COUNT=1", 1},
+		// DTSTART itself is past UNTIL.
+		{"FREQ=DAILY;UNTIL=20241231T000000Z", 0},
+		// February never has a 30th.
+		{"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30", 1},
+	}
+	for _, test := range tests {
+		if got := occurrences(t, test.rule, dtstart, 10); len(got) != test.count {
+			t.Errorf("%s: %d occurrences %q, want %d", test.rule, len(got), dates(got), test.count)
+		}
+	}
+}
+
+func TestNextAfter(t *testing.T) {
+	rule, err := Parse("FREQ=WEEKLY;BYDAY=MO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dtstart := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		after time.Time
+		want  time.Time
+	}{
+		{dtstart.Add(-time.Hour), dtstart},
+		{dtstart, time.Date(2025, 1, 13, 9, 0, 0, 0, time.UTC)},
+		{time.Date(2025, 3, 5, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)},
+		{time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC), time.Date(2025, 3, 17, 9, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		next, ok := rule.Next(dtstart, test.after)
+		if !ok || !next.Equal(test.want) {
+			t.Errorf("Next after %s = %s, %v, want %s", test.after, next, ok, test.want)
+		}
+	}
+}
+
+func TestNextKeepsWallClockAcrossDST(t *testing.T) {
+	newYork := loadLocation(t, "America/New_York")
+	berlin := loadLocation(t, "Europe/Berlin")
+
+	// Daylight saving time starts in New York on 2025-03-09.
+	checkOccurrences(t, "FREQ=DAILY", time.Date(2025, 3, 8, 9, 0, 0, 0, newYork),
+		[]string{"2025-03-08 09:00 Sat", "2025-03-09 09:00 Sun", "2025-03-10 09:00 Mon"})
+
+	// And ends in Berlin on 2025-10-26.
+	checkOccurrences(t, "FREQ=WEEKLY", time.Date(2025, 10, 20, 9, 30, 0, 0, berlin),
+		[]string{"2025-10-20 09:30 Mon", "2025-10-27 09:30 Mon", "2025-11-03 09:30 Mon"})
+
+	found := occurrences(t, "FREQ=DAILY", time.Date(2025, 3, 8, 9, 0, 0, 0, newYork), 2)
+	if gap := found[1].Sub(found[0]); gap != 23*time.Hour {
+		t.Errorf("occurrences across the change are %s apart, want 23h", gap)
+	}
+
+	// A monthly rule keeps 09:00 in both summer and winter.
+	for _, occurrence := range occurrences(t, "FREQ=MONTHLY;BYDAY=-1SU", time.Date(2025, 1, 26, 9, 0, 0, 0, berlin), 12) {
+		if occurrence.Hour() != 9 || occurrence.Location() != berlin {
+			t.Errorf("occurrence %s is not at 09:00 Berlin time", occurrence)
+		}
+	}
+}
+
+func TestNextFloatingUntil(t *testing.T) {
+	newYork := loadLocation(t, "America/New_York")
+	dtstart := time.Date(2025, 1, 1, 9, 0, 0, 0, newYork)
+
+	// 09:00 in New York is 14:00 UTC. Read as UTC, this UNTIL would end the
+	// rule a day early.
+	checkOccurrences(t, "FREQ=DAILY;UNTIL=20250103T090000", dtstart,
+		[]string{"2025-01-01 09:00 Wed", "2025-01-02 09:00 Thu", "2025-01-03 09:00 Fri"})
+	if got := occurrences(t, "FREQ=DAILY;UNTIL=20250103T090000", dtstart, 10); len(got) != 3 {
+		t.Errorf("floating UNTIL: %d occurrences, want 3", len(got))
+	}
+
+	// A date covers the whole local day.
+	if got := occurrences(t, "FREQ=DAILY;UNTIL=20250103", dtstart, 10); len(got) != 3 {
+		t.Errorf("date UNTIL: %d occurrences, want 3", len(got))
+	}
+
+	// A UTC UNTIL stays an instant: 13:00 UTC is 08:00 in New York.
+	if got := occurrences(t, "FREQ=DAILY;UNTIL=20250103T130000Z", dtstart, 10); len(got) != 2 {
+		t.Errorf("UTC UNTIL: %d occurrences, want 2", len(got))
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY"},
+		{"RRULE:freq=weekly;byday=mo,th;interval=2", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH"},
+		{"FREQ=MONTHLY;BYDAY=+1MO,-1FR", "FREQ=MONTHLY;BYDAY=1MO,-1FR"},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1", "FREQ=MONTHLY;BYMONTHDAY=-1"},
+		{"FREQ=YEARLY;BYMONTH=3,9;COUNT=4", "FREQ=YEARLY;COUNT=4;BYMONTH=3,9"},
+		{"FREQ=WEEKLY;WKST=SU", "FREQ=WEEKLY;WKST=SU"},
+		{"FREQ=DAILY;UNTIL=20250103T090000Z", "FREQ=DAILY;UNTIL=20250103T090000Z"},
+		{"FREQ=DAILY;UNTIL=20250103T090000", "FREQ=DAILY;UNTIL=20250103T090000"},
+		{"FREQ=DAILY;UNTIL=20250103", "FREQ=DAILY;UNTIL=20250103T235959"},
+	}
+	for _, test := range tests {
+		rule, err := Parse(test.value)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.value, err)
+			continue
+		}
+		if got := rule.String(); got != test.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", test.value, got, test.want)
+		}
+		// The canonical form parses to the same rule.
+		again, err := Parse(rule.String())
+		if err != nil || again.String() != rule.String() {
+			t.Errorf("canonical form %q does not round-trip: %v", rule.String(), err)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=-1",
+		"FREQ=DAILY;COUNT=2;UNTIL=20250101T000000Z",
+		"FREQ=DAILY;UNTIL=2025-01-01",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=0MO",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=YEARLY;BYMONTH=13",
+		"FREQ=DAILY;BYHOUR=9",
+		"FREQ=MONTHLY;BYSETPOS=-1",
+		"FREQ=DAILY;WKST=XX",
+		"FREQ",
+	} {
+		if _, err := Parse(value); err == nil {
+			t.Errorf("Parse(%q) accepted an invalid rule", value)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion    = "us-east-1"
+	testBucket    = "devtasks"
+)
+
+var authorizationHeader = regexp.MustCompile(`^AWS4-HMAC-SHA256 Credential=([^/]+)/(\d{8})/([^/]+)/s3/aws4_request, SignedHeaders=([a-z0-9;-]+), Signature=([0-9a-f]{64})$`)
+
+// fakeS3 is a minimal S3 server that checks the Signature Version 4 of every
+// request on its own and keeps objects in memory.
+type fakeS3 struct {
+	server    *httptest.Server
+	pathStyle bool
+
+	mu       sync.Mutex
+	objects  map[string][]byte
+	types    map[string]string
+	rejected []error
+}
+
+func newFakeS3(t *testing.T, pathStyle bool) *fakeS3 {
+	t.Helper()
+	f := &fakeS3{
+		pathStyle: pathStyle,
+		objects:   map[string][]byte{},
+		types:     map[string]string{},
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// store returns an S3Store for the fake. Virtual-hosted requests go to
+// <bucket>.<host>, so the client dials the fake whatever the host name.
+func (f *fakeS3) store(t *testing.T, secretKey string) *S3Store {
+	t.Helper()
+	s, err := NewS3Store(f.server.URL, testRegion, testBucket, testAccessKey, secretKey, f.pathStyle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := f.server.Listener.Addr().String()
+	s.client = &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+	return s
+}
+
+func (f *fakeS3) serve(w http.ResponseWriter, r *http.Request) {
+	if err := f.verifySignature(r); err != nil {
+		f.mu.Lock()
+		f.rejected = append(f.rejected, fmt.Errorf("%s %s: %w", r.Method, r.URL.EscapedPath(), err))
+		f.mu.Unlock()
+		http.Error(w, "<Error><Code>SignatureDoesNotMatch</Code></Error>", http.StatusForbidden)
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	if f.pathStyle {
+		var ok bool
+		if key, ok = strings.CutPrefix(key, testBucket+"/"); !ok {
+			http.Error(w, "<Error><Code>NoSuchBucket</Code></Error>", http.StatusNotFound)
+			return
+		}
+	} else if !strings.HasPrefix(r.Host, testBucket+".") {
+		http.Error(w, "<Error><Code>NoSuchBucket</Code></Error>", http.StatusNotFound)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if sum := sha256.Sum256(body); hex.EncodeToString(sum[:]) != r.Header.Get("X-Amz-Content-Sha256") {
+			http.Error(w, "<Error><Code>XAmzContentSHA256Mismatch</Code></Error>", http.StatusBadRequest)
+			return
+		}
+		f.objects[key] = body
+		f.types[key] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		body, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", f.types[key])
+		w.Write(body)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "", http.StatusMethodNotAllowed)
+	}
+}
+
+// verifySignature recomputes the signature of r from what arrived on the
+// wire.
+func (f *fakeS3) verifySignature(r *http.Request) error {
+	match := authorizationHeader.FindStringSubmatch(r.Header.Get("Authorization"))
+	if match == nil {
+		return errors.New("malformed Authorization header")
+	}
+	accessKey, date, region, signedHeaders, signature := match[1], match[2], match[3], match[4], match[5]
+	if accessKey != testAccessKey || region != testRegion {
+		return errors.New("unexpected credential scope")
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	requestTime, err := time.Parse("20060102T150405Z", amzDate)
+	if err != nil || !strings.HasPrefix(amzDate, date) {
+		return errors.New("invalid X-Amz-Date")
+	}
+	if skew := time.Since(requestTime); skew > 15*time.Minute || skew < -15*time.Minute {
+		return errors.New("request time too skewed")
+	}
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if len(payloadHash) != 64 {
+		return errors.New("missing X-Amz-Content-Sha256")
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range strings.Split(signedHeaders, ";") {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	canonicalRequest := r.Method + "\n" +
+		r.URL.EscapedPath() + "\n" +
+		r.URL.RawQuery + "\n" +
+		canonicalHeaders.String() + "\n" +
+		signedHeaders + "\n" +
+		payloadHash
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" +
+		date + "/" + region + "/s3/aws4_request\n" +
+		hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + testSecretKey)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(stringToSign))
+	if !hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(signature)) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// rejections returns the requests refused for a bad signature.
+func (f *fakeS3) rejections() []error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rejected
+}
+
+func (f *fakeS3) object(key string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	body, ok := f.objects[key]
+	return body, ok
+}
+
+func blobInfo(content []byte, contentType string) BlobInfo {
+	sum := sha256.Sum256(content)
+	return BlobInfo{
+		Size:        int64(len(content)),
+		ContentType: contentType,
+		SHA256:      hex.EncodeToString(sum[:]),
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	for _, pathStyle := range []bool{true, false} {
+		name := "virtual hosted"
+		if pathStyle {
+			name = "path style"
+		}
+		t.Run(name, func(t *testing.T) {
+			fake := newFakeS3(t, pathStyle)
+			s := fake.store(t, testSecretKey)
+			ctx := context.Background()
+
+			// Spaces and non-ASCII characters have to be escaped the same
+			// way on both sides of the signature.
+			key := "attachments/1/report (final) ü.txt"
+			content := []byte("hello from devtasks")
+			if err := s.Put(ctx, key, bytes.NewReader(content), blobInfo(content, "text/plain")); err != nil {
+				t.Fatalf("Put: %v (rejected: %v)", err, fake.rejections())
+			}
+			if stored, ok := fake.object(key); !ok || !bytes.Equal(stored, content) {
+				t.Fatalf("stored object = %q, %v", stored, ok)
+			}
+
+			body, err := s.Get(ctx, key)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			got, err := io.ReadAll(body)
+			body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("Get = %q, want %q", got, content)
+			}
+
+			if err := s.Delete(ctx, key); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if _, ok := fake.object(key); ok {
+				t.Error("object still stored after Delete")
+			}
+			if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get after Delete: err = %v, want ErrNotFound", err)
+			}
+			if err := s.Delete(ctx, key); err != nil {
+				t.Errorf("Delete of a missing key: %v", err)
+			}
+			if rejected := fake.rejections(); len(rejected) > 0 {
+				t.Errorf("requests with bad signatures: %v", rejected)
+			}
+		})
+	}
+}
+
+func TestS3StoreEmptyObject(t *testing.T) {
+	fake := newFakeS3(t, true)
+	s := fake.store(t, testSecretKey)
+
+	if err := s.Put(context.Background(), "empty", bytes.NewReader(nil), blobInfo(nil, "")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if stored, ok := fake.object("empty"); !ok || len(stored) != 0 {
+		t.Errorf("stored object = %q, %v", stored, ok)
+	}
+}
+
+func TestS3StoreRejectsWrongPayloadHash(t *testing.T) {
+	fake := newFakeS3(t, true)
+	s := fake.store(t, testSecretKey)
+
+	content := []byte("content")
+	info := blobInfo([]byte("other content"), "text/plain")
+	info.Size = int64(len(content))
+	if err := s.Put(context.Background(), "key", bytes.NewReader(content), info); err == nil {
+		t.Error("Put succeeded with a payload hash that does not match the body")
+	}
+	if _, ok := fake.object("key"); ok {
+		t.Error("object stored despite the hash mismatch")
+	}
+}
+
+func TestS3StoreWrongSecret(t *testing.T) {
+	fake := newFakeS3(t, true)
+	s := fake.store(t, "wrong-secret")
+
+	content := []byte("content")
+	err := s.Put(context.Background(), "key", bytes.NewReader(content), blobInfo(content, ""))
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Put with a wrong secret: err = %v, want 403", err)
+	}
+	if rejected := fake.rejections(); len(rejected) != 1 {
+		t.Errorf("fake rejected %d requests, want 1", len(rejected))
+	}
+}
+
+func TestS3StoreRejectsInvalidKeys(t *testing.T) {
+	fake := newFakeS3(t, true)
+	s := fake.store(t, testSecretKey)
+
+	for _, key := range []string{"", "/absolute"} {
+		if _, err := s.Get(context.Background(), key); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q): err = %v, want invalid key", key, err)
+		}
+	}
+}
+
+func TestNewS3StoreValidatesConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		bucket   string
+	}{
+		{"no scheme", "localhost:9000", testBucket},
+		{"ftp", "ftp://localhost", testBucket},
+		{"no bucket", "http://localhost:9000", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := NewS3Store(test.endpoint, testRegion, test.bucket, testAccessKey, testSecretKey, true); err == nil {
+				t.Error("invalid configuration accepted")
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/aminasadiam/DevTasks/config"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobInfo describes the content passed to BlobStore.Put.
+type BlobInfo struct {
+	Size        int64
+	ContentType string
+	// SHA256 is the hex encoded digest of the content.
+	SHA256 string
+}
+
+// BlobStore keeps file contents by key. Keys are slash separated paths
+// chosen by the caller.
+type BlobStore interface {
+	Put(ctx context.Context, key string, body io.Reader, info BlobInfo) error
+	// Get fails with ErrNotFound for unknown keys.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete succeeds for unknown keys.
+	Delete(ctx context.Context, key string) error
+}
+
+// NewBlobStore returns the BlobStore selected by cfg.Driver. Anything other
+// than "s3" falls back to the local filesystem.
+func NewBlobStore(cfg *config.StorageConfig) (BlobStore, error) {
+	if cfg.Driver == "s3" {
+		return NewS3Store(cfg.S3Endpoint, cfg.S3Region, cfg.S3Bucket, cfg.S3AccessKey, cfg.S3SecretKey, cfg.S3PathStyle)
+	}
+	return NewLocalStore(cfg.LocalPath)
+}
@@ -0,0 +1,74 @@
+package throttle
+
+import (
+	"strings"
+	"time"
+)
+
+// LoginGuard tracks failed logins per username and per client IP. The IP
+// policy is looser because many users can share an address.
+type LoginGuard struct {
+	users *Limiter
+	ips   *Limiter
+}
+
+func NewLoginGuard(store Store) *LoginGuard {
+	return &LoginGuard{
+		users: NewLimiter(store, "user:", Policy{
+			FreeAttempts: 5,
+			BaseDelay:    time.Second,
+			MaxDelay:     15 * time.Minute,
+			Window:       time.Hour,
+		}),
+		ips: NewLimiter(store, "ip:", Policy{
+			FreeAttempts: 20,
+			BaseDelay:    time.Second,
+			MaxDelay:     time.Hour,
+			Window:       time.Hour,
+		}),
+	}
+}
+
+// Check returns how long a login for username from ip has to wait.
+func (g *LoginGuard) Check(username, ip string) (time.Duration, error) {
+	userWait, err := g.users.Check(normalizeUsername(username))
+	if err != nil {
+		return 0, err
+	}
+	ipWait, err := g.ips.Check(ip)
+	if err != nil {
+		return 0, err
+	}
+	return max(userWait, ipWait), nil
+}
+
+// Fail records a failed login and returns the resulting lockout.
+func (g *LoginGuard) Fail(username, ip string) (time.Duration, error) {
+	userWait, err := g.users.Fail(normalizeUsername(username))
+	if err != nil {
+		return 0, err
+	}
+	ipWait, err := g.ips.Fail(ip)
+	if err != nil {
+		return 0, err
+	}
+	return max(userWait, ipWait), nil
+}
+
+// Succeed clears the failures of username. The IP record is left alone so
+// that one valid account cannot be used to reset the counter of an address.
+func (g *LoginGuard) Succeed(username string) error {
+	return g.users.Reset(normalizeUsername(username))
+}
+
+func (g *LoginGuard) UnlockUser(username string) error {
+	return g.users.Reset(normalizeUsername(username))
+}
+
+func (g *LoginGuard) UnlockIP(ip string) error {
+	return g.ips.Reset(ip)
+}
+
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
@@ -0,0 +1,62 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// memorySweepSize is the number of keys above which stale records are
+// dropped before a new one is added.
+const memorySweepSize = 10000
+
+// MemoryStore keeps attempts in process memory. It is the default and only
+// suitable when a single server instance is running.
+type MemoryStore struct {
+	mu       sync.Mutex
+	attempts map[string]Attempt
+	maxAge   time.Duration
+}
+
+// NewMemoryStore returns a store that forgets records whose last failure and
+// lockout are older than maxAge.
+func NewMemoryStore(maxAge time.Duration) *MemoryStore {
+	return &MemoryStore{
+		attempts: make(map[string]Attempt),
+		maxAge:   maxAge,
+	}
+}
+
+func (s *MemoryStore) Get(key string) (Attempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attempts[key], nil
+}
+
+func (s *MemoryStore) RecordFailure(key string, update func(Attempt) Attempt) (Attempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.attempts[key]; !ok && len(s.attempts) >= memorySweepSize {
+		s.sweep()
+	}
+
+	attempt := update(s.attempts[key])
+	s.attempts[key] = attempt
+	return attempt, nil
+}
+
+func (s *MemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.attempts, key)
+	return nil
+}
+
+func (s *MemoryStore) sweep() {
+	cutoff := time.Now().Add(-s.maxAge)
+	for key, attempt := range s.attempts {
+		if attempt.LastFailure.Before(cutoff) && attempt.LockedUntil.Before(cutoff) {
+			delete(s.attempts, key)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package throttle
+
+import (
+	"time"
+)
+
+// ResetGuard limits password reset mails per address and per client IP so
+// that the endpoint cannot be used to flood mailboxes. Every request counts,
+// whether or not the address belongs to an account.
+type ResetGuard struct {
+	addresses *Limiter
+	ips       *Limiter
+}
+
+func NewResetGuard(store Store) *ResetGuard {
+	return &ResetGuard{
+		addresses: NewLimiter(store, "reset:", Policy{
+			FreeAttempts: 0,
+			BaseDelay:    time.Minute,
+			MaxDelay:     time.Hour,
+			Window:       time.Hour,
+		}),
+		ips: NewLimiter(store, "reset-ip:", Policy{
+			FreeAttempts: 10,
+			BaseDelay:    time.Minute,
+			MaxDelay:     time.Hour,
+			Window:       time.Hour,
+		}),
+	}
+}
+
+// Request records a reset request for address from ip. It returns how long
+// the caller has to wait when the request is not allowed yet, in which case
+// nothing is recorded.
+func (g *ResetGuard) Request(address, ip string) (time.Duration, error) {
+	address = normalizeUsername(address)
+	addressWait, err := g.addresses.Check(address)
+	if err != nil {
+		return 0, err
+	}
+	ipWait, err := g.ips.Check(ip)
+	if err != nil {
+		return 0, err
+	}
+	if wait := max(addressWait, ipWait); wait > 0 {
+		return wait, nil
+	}
+
+	if _, err := g.addresses.Fail(address); err != nil {
+		return 0, err
+	}
+	if _, err := g.ips.Fail(ip); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
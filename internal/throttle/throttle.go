@@ -0,0 +1,97 @@
+package throttle
+
+import (
+	"time"
+)
+
+// Attempt is the failure record kept for a single key.
+type Attempt struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// Store persists attempts. Implementations must apply RecordFailure
+// atomically so that concurrent failures are all counted.
+type Store interface {
+	Get(key string) (Attempt, error)
+	// RecordFailure adds a failure to key. update receives the current record
+	// and returns the record to store.
+	RecordFailure(key string, update func(Attempt) Attempt) (Attempt, error)
+	Reset(key string) error
+}
+
+// Policy describes how quickly a key gets locked out.
+type Policy struct {
+	// FreeAttempts is the number of failures allowed before backoff starts.
+	FreeAttempts int
+	// BaseDelay is the lockout after the first failure past FreeAttempts. It
+	// doubles with every further failure up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Window is the period of inactivity after which failures are forgotten.
+	Window time.Duration
+}
+
+func (p Policy) lockout(failures int) time.Duration {
+	over := failures - p.FreeAttempts
+	if over <= 0 {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < over && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// Limiter applies a Policy to keys stored under a common prefix.
+type Limiter struct {
+	store  Store
+	prefix string
+	policy Policy
+	now    func() time.Time
+}
+
+func NewLimiter(store Store, prefix string, policy Policy) *Limiter {
+	return &Limiter{
+		store:  store,
+		prefix: prefix,
+		policy: policy,
+		now:    time.Now,
+	}
+}
+
+// Check returns how long key stays locked, or zero when it may try again.
+func (l *Limiter) Check(key string) (time.Duration, error) {
+	attempt, err := l.store.Get(l.prefix + key)
+	if err != nil {
+		return 0, err
+	}
+	return max(attempt.LockedUntil.Sub(l.now()), 0), nil
+}
+
+// Fail records a failure for key and returns the resulting lockout.
+func (l *Limiter) Fail(key string) (time.Duration, error) {
+	now := l.now()
+	attempt, err := l.store.RecordFailure(l.prefix+key, func(attempt Attempt) Attempt {
+		if now.Sub(attempt.LastFailure) > l.policy.Window {
+			attempt.Failures = 0
+		}
+		attempt.Failures++
+		attempt.LastFailure = now
+		if delay := l.policy.lockout(attempt.Failures); delay > 0 {
+			attempt.LockedUntil = now.Add(delay)
+		}
+		return attempt
+	})
+	if err != nil {
+		return 0, err
+	}
+	return max(attempt.LockedUntil.Sub(now), 0), nil
+}
+
+func (l *Limiter) Reset(key string) error {
+	return l.store.Reset(l.prefix + key)
+}
@@ -0,0 +1,188 @@
+package throttle
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPolicyLockout(t *testing.T) {
+	policy := Policy{FreeAttempts: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{3, 0},
+		{4, time.Second},
+		{5, 2 * time.Second},
+		{6, 4 * time.Second},
+		{7, 8 * time.Second},
+		{8, 10 * time.Second},
+		{50, 10 * time.Second},
+	}
+	for _, test := range tests {
+		if got := policy.lockout(test.failures); got != test.want {
+			t.Errorf("lockout(%d) = %v, want %v", test.failures, got, test.want)
+		}
+	}
+}
+
+// clock is a settable time source for a Limiter.
+type clock struct{ now time.Time }
+
+func (c *clock) advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestLimiter(store Store, prefix string, policy Policy) (*Limiter, *clock) {
+	c := &clock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	l := NewLimiter(store, prefix, policy)
+	l.now = func() time.Time { return c.now }
+	return l, c
+}
+
+func TestLimiter(t *testing.T) {
+	policy := Policy{FreeAttempts: 2, BaseDelay: time.Second, MaxDelay: time.Minute, Window: time.Hour}
+	store := NewMemoryStore(time.Hour)
+	l, c := newTestLimiter(store, "user:", policy)
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		fail    bool
+		reset   bool
+		want    time.Duration
+	}{
+		{name: "first failure is free", fail: true, want: 0},
+		{name: "second failure is free", fail: true, want: 0},
+		{name: "lockout starts", fail: true, want: time.Second},
+		{name: "still locked", advance: 500 * time.Millisecond, want: 500 * time.Millisecond},
+		{name: "lockout over", advance: time.Second, want: 0},
+		{name: "lockout doubles", fail: true, want: 2 * time.Second},
+		{name: "window passes", advance: 2 * time.Hour, want: 0},
+		{name: "failures forgotten", fail: true, want: 0},
+		{name: "free again", fail: true, want: 0},
+		{name: "locked again", fail: true, want: time.Second},
+		{name: "reset", reset: true, want: 0},
+		{name: "free after reset", fail: true, want: 0},
+	}
+	for _, step := range steps {
+		c.advance(step.advance)
+		var got time.Duration
+		var err error
+		switch {
+		case step.fail:
+			got, err = l.Fail("alice")
+		case step.reset:
+			err = l.Reset("alice")
+		default:
+			got, err = l.Check("alice")
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got != step.want {
+			t.Errorf("%s: wait = %v, want %v", step.name, got, step.want)
+		}
+		if check, _ := l.Check("alice"); check != step.want {
+			t.Errorf("%s: Check = %v, want %v", step.name, check, step.want)
+		}
+	}
+
+	// Keys of other limiters on the same store are separate.
+	other, _ := newTestLimiter(store, "ip:", policy)
+	if wait, _ := other.Check("alice"); wait != 0 {
+		t.Errorf("prefix ip: shares the record of user:, wait = %v", wait)
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	store := NewMemoryStore(time.Hour)
+	stale := Attempt{Failures: 1, LastFailure: time.Now().Add(-2 * time.Hour)}
+	locked := Attempt{Failures: 9, LastFailure: stale.LastFailure, LockedUntil: time.Now().Add(time.Minute)}
+	for i := range memorySweepSize {
+		store.attempts[strconv.Itoa(i)] = stale
+	}
+	store.attempts["locked"] = locked
+
+	if _, err := store.RecordFailure("new", func(a Attempt) Attempt { a.Failures++; return a }); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.attempts) != 2 {
+		t.Errorf("%d records left, want the locked and the new one", len(store.attempts))
+	}
+	if got, _ := store.Get("locked"); got != locked {
+		t.Errorf("locked record = %+v, want %+v", got, locked)
+	}
+}
+
+func TestLoginGuard(t *testing.T) {
+	g := NewLoginGuard(NewMemoryStore(time.Hour))
+
+	for range 5 {
+		if wait, err := g.Fail(" Alice ", "192.0.2.1"); err != nil || wait != 0 {
+			t.Fatalf("free failure: wait = %v, err = %v", wait, err)
+		}
+	}
+	if wait, _ := g.Fail("alice", "192.0.2.2"); wait <= 0 {
+		t.Fatal("sixth failure of a username did not lock it")
+	}
+	if wait, _ := g.Check("ALICE", "198.51.100.1"); wait <= 0 {
+		t.Error("lockout of a username does not apply from another address")
+	}
+	if wait, _ := g.Check("bob", "192.0.2.1"); wait != 0 {
+		t.Errorf("other user from the same address waits %v", wait)
+	}
+
+	if err := g.Succeed("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if wait, _ := g.Check("alice", "192.0.2.1"); wait != 0 {
+		t.Errorf("wait after success = %v, want 0", wait)
+	}
+}
+
+func TestLoginGuardIP(t *testing.T) {
+	g := NewLoginGuard(NewMemoryStore(time.Hour))
+
+	// Spraying many usernames from one address locks the address.
+	for i := range 20 {
+		if wait, _ := g.Fail(string(rune('a'+i)), "192.0.2.1"); wait != 0 {
+			t.Fatalf("failure %d locked already", i+1)
+		}
+	}
+	if wait, _ := g.Fail("x", "192.0.2.1"); wait <= 0 {
+		t.Fatal("21st failure from an address did not lock it")
+	}
+	if wait, _ := g.Check("y", "192.0.2.1"); wait <= 0 {
+		t.Error("locked address may try another username")
+	}
+
+	// A valid login must not clear the address.
+	g.Succeed("y")
+	if wait, _ := g.Check("y", "192.0.2.1"); wait <= 0 {
+		t.Error("a successful login unlocked the address")
+	}
+	g.UnlockIP("192.0.2.1")
+	if wait, _ := g.Check("y", "192.0.2.1"); wait != 0 {
+		t.Errorf("wait after UnlockIP = %v, want 0", wait)
+	}
+}
+
+func TestResetGuard(t *testing.T) {
+	g := NewResetGuard(NewMemoryStore(time.Hour))
+
+	if wait, err := g.Request("alice@example.org", "192.0.2.1"); err != nil || wait != 0 {
+		t.Fatalf("first request: wait = %v, err = %v", wait, err)
+	}
+	wait, _ := g.Request(" ALICE@example.org", "192.0.2.2")
+	if wait <= 0 || wait > time.Minute {
+		t.Fatalf("second request for the address: wait = %v, want up to a minute", wait)
+	}
+	// Refused requests are not recorded, so waiting does not grow.
+	if again, _ := g.Request("alice@example.org", "192.0.2.2"); again > wait {
+		t.Errorf("refused request extended the wait from %v to %v", wait, again)
+	}
+	if wait, _ := g.Request("bob@example.org", "192.0.2.1"); wait != 0 {
+		t.Errorf("other address waits %v", wait)
+	}
+}
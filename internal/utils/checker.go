@@ -1,17 +1,16 @@
 package utils
 
-import "strings"
+import "net/mail"
 
+// IsValidEmail reports whether email is a bare RFC 5322 address, without a
+// display name or angle brackets.
 func IsValidEmail(email string) bool {
-	if !strings.Contains(email, "@") {
+	addr, err := mail.ParseAddress(email)
+	if err != nil {
 		return false
 	}
 
-	if !strings.Contains(email, ".com") {
-		return false
-	}
-
-	return true
+	return addr.Name == "" && addr.Address == email
 }
 
 func IsValidPassword(password string) bool {
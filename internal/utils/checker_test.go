@@ -0,0 +1,53 @@
+package utils
+
+import "testing"
+
+func TestIsValidEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		want  bool
+	}{
+		{"alice@example.com", true},
+		{"alice@example.org", true},
+		{"alice@mail.example.co.uk", true},
+		{"alice+devtasks@example.io", true},
+		{"o'brien@example.ie", true},
+		{"alice@localhost", true},
+		{"", false},
+		{"alice", false},
+		{"alice@", false},
+		{"@example.org", false},
+		{"alice@@example.org", false},
+		{"alice example@example.org", false},
+		{"Alice <alice@example.org>", false},
+		{"<alice@example.org>", false},
+		{"alice@example.org, bob@example.org", false},
+	}
+	for _, test := range tests {
+		if got := IsValidEmail(test.email); got != test.want {
+			t.Errorf("IsValidEmail(%q) = %v, want %v", test.email, got, test.want)
+		}
+	}
+}
+
+func TestIsValidPassword(t *testing.T) {
+	long := make([]byte, 201)
+	for i := range long {
+		long[i] = 'a'
+	}
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"", false},
+		{"1234567", false},
+		{"12345678", true},
+		{string(long[:200]), true},
+		{string(long), false},
+	}
+	for _, test := range tests {
+		if got := IsValidPassword(test.password); got != test.want {
+			t.Errorf("IsValidPassword(%d characters) = %v, want %v", len(test.password), got, test.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2id keeps the tests fast; production uses far more memory.
+var testArgon2id = Argon2idHasher{Memory: 64, Time: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func usePasswordHasher(t *testing.T, hasher PasswordHasher) {
+	hasherMu.RLock()
+	previous := currentHasher
+	hasherMu.RUnlock()
+	SetPasswordHasher(hasher)
+	t.Cleanup(func() { SetPasswordHasher(previous) })
+}
+
+func TestPasswordHashers(t *testing.T) {
+	tests := []struct {
+		name    string
+		hasher  PasswordHasher
+		prefix  string
+		changed []PasswordHasher
+	}{
+		{"bcrypt", BcryptHasher{Cost: bcrypt.MinCost}, "$2a$04$", []PasswordHasher{BcryptHasher{Cost: bcrypt.MinCost + 1}}},
+		{"argon2id", testArgon2id, "$argon2id$v=19$m=64,t=1,p=1$", []PasswordHasher{
+			Argon2idHasher{Memory: 128, Time: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+			Argon2idHasher{Memory: 64, Time: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+			Argon2idHasher{Memory: 64, Time: 1, Parallelism: 2, SaltLength: 16, KeyLength: 32},
+			Argon2idHasher{Memory: 64, Time: 1, Parallelism: 1, SaltLength: 8, KeyLength: 32},
+			Argon2idHasher{Memory: 64, Time: 1, Parallelism: 1, SaltLength: 16, KeyLength: 64},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encoded, err := test.hasher.Hash("correct horse")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(encoded, test.prefix) || !test.hasher.Handles(encoded) {
+				t.Fatalf("hash %q does not start with %q", encoded, test.prefix)
+			}
+			if again, _ := test.hasher.Hash("correct horse"); again == encoded {
+				t.Error("two hashes of the same password are equal, the salt is missing")
+			}
+
+			if ok, err := test.hasher.Verify("correct horse", encoded); !ok || err != nil {
+				t.Errorf("Verify(right password) = %v, %v", ok, err)
+			}
+			if ok, err := test.hasher.Verify("correct horsE", encoded); ok || err != nil {
+				t.Errorf("Verify(wrong password) = %v, %v", ok, err)
+			}
+
+			if test.hasher.NeedsRehash(encoded) {
+				t.Error("hash made with the current parameters needs a rehash")
+			}
+			for _, changed := range test.changed {
+				if !changed.NeedsRehash(encoded) {
+					t.Errorf("%+v does not rehash %q", changed, encoded)
+				}
+			}
+		})
+	}
+}
+
+func TestPasswordMigration(t *testing.T) {
+	usePasswordHasher(t, BcryptHasher{Cost: bcrypt.MinCost})
+	old, err := HashPass("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if PasswordNeedsRehash(old) {
+		t.Fatal("bcrypt hash needs a rehash while bcrypt is configured")
+	}
+
+	usePasswordHasher(t, testArgon2id)
+	if !VerifyPassword("correct horse", old) {
+		t.Error("bcrypt hash no longer verifies after switching to argon2id")
+	}
+	if VerifyPassword("wrong", old) {
+		t.Error("wrong password verifies against the bcrypt hash")
+	}
+	if !PasswordNeedsRehash(old) {
+		t.Error("bcrypt hash is not migrated to argon2id")
+	}
+
+	migrated, err := HashPass("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(migrated, "$argon2id$") || !VerifyPassword("correct horse", migrated) || PasswordNeedsRehash(migrated) {
+		t.Errorf("migrated hash %q is not a current argon2id hash", migrated)
+	}
+}
+
+func TestVerifyPasswordMalformed(t *testing.T) {
+	valid, err := testArgon2id.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(valid, "$")
+
+	tests := []string{
+		"",
+		"correct horse",
+		"$argon2i$v=19$m=64,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$" + parts[4],
+		"$argon2id$v=x$m=64,t=1,p=1$" + parts[4] + "$" + parts[5],
+		"$argon2id$v=19$m=64$" + parts[4] + "$" + parts[5],
+		"$argon2id$v=19$m=64,t=1,p=1$!!!$" + parts[5],
+		"$argon2id$v=19$m=64,t=1,p=1$" + parts[4] + "$",
+		"$2a$04$short",
+	}
+	for _, encoded := range tests {
+		if VerifyPassword("correct horse", encoded) {
+			t.Errorf("VerifyPassword accepted %q", encoded)
+		}
+		if !PasswordNeedsRehash(encoded) {
+			t.Errorf("PasswordNeedsRehash(%q) = false", encoded)
+		}
+	}
+}
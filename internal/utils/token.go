@@ -1,9 +1,11 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"log"
-	"math/rand"
 )
 
 func GenerateToken(length int) string {
@@ -13,3 +15,10 @@ func GenerateToken(length int) string {
 	}
 	return base64.URLEncoding.EncodeToString(bytes)
 }
+
+// HashToken returns the hex encoded SHA-256 digest of a token so that only
+// the digest has to be persisted.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of RFC 6238 Appendix B,
+// "12345678901234567890", in base32.
+var rfc6238Secret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+// The appendix lists 8 digit codes; ours are the last 6 digits of the same
+// truncated value.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	for _, vector := range rfc6238Vectors {
+		code, err := TOTPCode(rfc6238Secret, time.Unix(vector.unix, 0))
+		if err != nil {
+			t.Fatalf("TOTPCode(%d): %v", vector.unix, err)
+		}
+		if code != vector.code {
+			t.Errorf("TOTPCode(%d) = %s, want %s", vector.unix, code, vector.code)
+		}
+	}
+}
+
+func TestVerifyTOTPRFC6238(t *testing.T) {
+	for _, vector := range rfc6238Vectors {
+		step, ok := VerifyTOTP(rfc6238Secret, vector.code, time.Unix(vector.unix, 0), 0)
+		if !ok {
+			t.Errorf("VerifyTOTP(%d) rejected %s", vector.unix, vector.code)
+			continue
+		}
+		if want := vector.unix / totpPeriod; step != want {
+			t.Errorf("VerifyTOTP(%d) step = %d, want %d", vector.unix, step, want)
+		}
+	}
+}
+
+func TestVerifyTOTPSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, err := TOTPCode(rfc6238Secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		offset time.Duration
+		ok     bool
+	}{
+		{"same step", 0, true},
+		{"one step early", -totpPeriod * time.Second, true},
+		{"one step late", totpPeriod * time.Second, true},
+		{"two steps early", -2 * totpPeriod * time.Second, false},
+		{"two steps late", 2 * totpPeriod * time.Second, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Verifying at now+offset is the same as a code generated offset
+			// away from the server clock.
+			_, ok := VerifyTOTP(rfc6238Secret, code, now.Add(test.offset), 0)
+			if ok != test.ok {
+				t.Errorf("VerifyTOTP at %v = %v, want %v", test.offset, ok, test.ok)
+			}
+		})
+	}
+}
+
+func TestVerifyTOTPReplay(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, err := TOTPCode(rfc6238Secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	step, ok := VerifyTOTP(rfc6238Secret, code, now, 0)
+	if !ok {
+		t.Fatal("first use rejected")
+	}
+	if _, ok := VerifyTOTP(rfc6238Secret, code, now, step); ok {
+		t.Error("code accepted again after its step was used")
+	}
+	if _, ok := VerifyTOTP(rfc6238Secret, code, now, step+1); ok {
+		t.Error("code accepted after a later step was used")
+	}
+	if _, ok := VerifyTOTP(rfc6238Secret, code, now, step-1); !ok {
+		t.Error("code rejected although only an earlier step was used")
+	}
+}
+
+func TestVerifyTOTPInput(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	tests := []struct {
+		name string
+		code string
+		ok   bool
+	}{
+		{"spaces", " 005 924 ", true},
+		{"wrong code", "005925", false},
+		{"too short", "05924", false},
+		{"too long", "0005924", false},
+		{"empty", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, ok := VerifyTOTP(rfc6238Secret, test.code, now, 0); ok != test.ok {
+				t.Errorf("VerifyTOTP(%q) = %v, want %v", test.code, ok, test.ok)
+			}
+		})
+	}
+
+	if _, ok := VerifyTOTP("not base32!", "005924", now, 0); ok {
+		t.Error("VerifyTOTP accepted an invalid secret")
+	}
+}